@@ -0,0 +1,409 @@
+package admin
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/derbylock/async-integration-testing/cmd/server"
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/go-redis/redis/v9"
+)
+
+const usage = `usage: asit admin <command> [flags]
+
+commands:
+  migrate-redis-hashtag  moves the keys of the single-node Redis layout to the {hashtag} layout required by Redis Cluster
+  copy-redis-namespace   copies or moves the keys of one Redis namespace to another one
+  fsck                   checks the consistency of the stored clients and optionally repairs it
+  export                 writes all the clients with their keys to a file
+  import                 reads the clients written by export and stores them
+  rewrite-values         writes all the Redis or bolt values again, e.g. to encrypt them with the active key after the key rotation,
+                         it must be run before ENCRYPTION_REQUIRED is enabled
+
+The ENCRYPTION_KEYS and CLIENT_KEYS_HMAC_KEY environment variables are read the same way as by the ASIT servers,
+the keys aren't accepted as flags to keep them out of the shell history.`
+
+// Run executes the admin command specified by args, e.g. "migrate-redis-hashtag -hashtag asit"
+func Run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "migrate-redis-hashtag":
+		return migrateRedisHashTag(args[1:])
+	case "copy-redis-namespace":
+		return copyRedisNamespace(args[1:])
+	case "fsck":
+		return fsck(args[1:])
+	case "export":
+		return export(args[1:])
+	case "import":
+		return importClients(args[1:])
+	case "rewrite-values":
+		return rewriteValues(args[1:])
+	default:
+		return fmt.Errorf("unknown admin command %s\n%s", args[0], usage)
+	}
+}
+
+type redisFlags struct {
+	addrs    *string
+	password *string
+}
+
+func addRedisFlags(flags *flag.FlagSet) redisFlags {
+	return redisFlags{
+		addrs:    flags.String("redis-addrs", os.Getenv("REDIS_ADDRS"), "comma-separated Redis addresses, REDIS_ADDRS by default"),
+		password: flags.String("redis-password", os.Getenv("REDIS_PASSWORD"), "Redis password, REDIS_PASSWORD by default"),
+	}
+}
+
+func (f redisFlags) newClient() (redis.UniversalClient, error) {
+	if *f.addrs == "" {
+		return nil, errors.New("redis addresses are not specified")
+	}
+	return redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:    strings.Split(*f.addrs, ","),
+		Password: *f.password,
+	}), nil
+}
+
+func migrateRedisHashTag(args []string) error {
+	flags := flag.NewFlagSet("migrate-redis-hashtag", flag.ContinueOnError)
+	redisConfig := addRedisFlags(flags)
+	hashTag := flags.String("hashtag", "asit", "hash tag of the new keys layout, it must be the same as REDIS_HASH_TAG of the ASIT servers")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := redisConfig.newClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	migrated, skipped, err := db.MigrateRedisKeysToHashTag(context.Background(), client, *hashTag)
+	log.Printf("Migrated %d keys to the {%s} hash tag", migrated, *hashTag)
+	for _, key := range skipped {
+		log.Printf("Skipped %s, the key already exists in the new layout", key)
+	}
+	return err
+}
+
+func copyRedisNamespace(args []string) error {
+	flags := flag.NewFlagSet("copy-redis-namespace", flag.ContinueOnError)
+	redisConfig := addRedisFlags(flags)
+	hashTag := flags.String("hashtag", os.Getenv("REDIS_HASH_TAG"), "hash tag of the keys layout, REDIS_HASH_TAG by default")
+	from := flags.String("from", "", "source namespace, the empty one is the layout without a namespace")
+	to := flags.String("to", "", "target namespace, the empty one is the layout without a namespace")
+	move := flags.Bool("move", false, "delete the copied keys from the source namespace")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := redisConfig.newClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	copied, skipped, err := db.CopyRedisNamespace(context.Background(), client, *hashTag, *from, *to, *move)
+	action := "Copied"
+	if *move {
+		action = "Moved"
+	}
+	log.Printf("%s %d keys from the %q namespace to the %q namespace", action, copied, *from, *to)
+	for _, key := range skipped {
+		log.Printf("Skipped %s, the key already exists in the target namespace", key)
+	}
+	return err
+}
+
+type storageFlags struct {
+	storageType *string
+	redis       redisFlags
+	hashTag     *string
+	namespace   *string
+	boltFile    *string
+	sqliteFile  *string
+	codec       *string
+	compress    *int
+	keyfile     *string
+	activeKey   *string
+}
+
+func addStorageFlags(flags *flag.FlagSet) storageFlags {
+	return storageFlags{
+		storageType: flags.String("storage", os.Getenv("STORAGE_TYPE"), "storage type: redis, bolt or sqlite, STORAGE_TYPE by default"),
+		redis:       addRedisFlags(flags),
+		hashTag:     flags.String("hashtag", os.Getenv("REDIS_HASH_TAG"), "hash tag of the Redis keys layout, REDIS_HASH_TAG by default"),
+		namespace:   flags.String("namespace", os.Getenv("REDIS_NAMESPACE"), "namespace of the Redis keys, REDIS_NAMESPACE by default"),
+		boltFile:    flags.String("bolt-file", envOrDefault("BOLT_FILE", "asit.db"), "bolt file, it's locked by the running ASIT server, BOLT_FILE by default"),
+		sqliteFile:  flags.String("sqlite-file", envOrDefault("SQLITE_FILE", "asit.sqlite"), "SQLite file, SQLITE_FILE by default"),
+		codec:       flags.String("codec", envOrDefault("STORAGE_CODEC", "proto"), "encoding of the written Redis and bolt values: proto or protojson, STORAGE_CODEC by default"),
+		compress:    flags.Int("compress-min-size", envIntOrDefault("STORAGE_COMPRESSION_MIN_SIZE", 0), "size in bytes starting from which the written values are compressed, STORAGE_COMPRESSION_MIN_SIZE by default"),
+		keyfile:     flags.String("encryption-keyfile", os.Getenv("ENCRYPTION_KEYFILE"), "file with the encryption keys of the Redis and bolt values, ENCRYPTION_KEYFILE by default"),
+		activeKey:   flags.String("encryption-active-key", os.Getenv("ENCRYPTION_ACTIVE_KEY"), "id of the key encrypting the written values, the last key by default, ENCRYPTION_ACTIVE_KEY by default"),
+	}
+}
+
+// openClientsRepository opens the clients repository of the configured storage, the returned function closes the storage
+func (f storageFlags) openClientsRepository() (db.ClientsRepository, func() error, error) {
+	encoding, err := db.ParseCodecEncoding(*f.codec)
+	if err != nil {
+		return nil, nil, err
+	}
+	var codec db.StorageCodec
+	codec, err = db.NewEnvelopeCodec(encoding, *f.compress)
+	if err != nil {
+		return nil, nil, err
+	}
+	var keyring *db.EncryptionKeyring
+	if *f.keyfile != "" {
+		keyring, err = db.LoadEncryptionKeyringFile(*f.keyfile, *f.activeKey)
+	} else if keys := os.Getenv("ENCRYPTION_KEYS"); keys != "" {
+		keyring, err = db.ParseEncryptionKeyring(keys, *f.activeKey)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if keyring != nil {
+		codec = db.NewEncryptingCodec(codec, keyring)
+	}
+	var hmacKey []byte
+	if value := os.Getenv("CLIENT_KEYS_HMAC_KEY"); value != "" {
+		if hmacKey, err = base64.StdEncoding.DecodeString(value); err != nil || len(hmacKey) == 0 {
+			return nil, nil, errors.New("CLIENT_KEYS_HMAC_KEY must be base64 encoded")
+		}
+	}
+	newKVClientsRepository := func(storage db.Storage) db.ClientsRepository {
+		if hmacKey != nil {
+			return db.NewKVClientsRepositoryWithHashedKeys(storage, hmacKey)
+		}
+		return db.NewKVClientsRepository(storage)
+	}
+
+	switch *f.storageType {
+	case "", "redis":
+		if err := db.ValidateRedisNamespace(*f.namespace); err != nil {
+			return nil, nil, err
+		}
+		client, err := f.redis.newClient()
+		if err != nil {
+			return nil, nil, err
+		}
+		hashTag := *f.hashTag
+		if hashTag == "" && strings.Contains(*f.redis.addrs, ",") {
+			// the same default as the one of the ASIT servers
+			hashTag = server.DEFAULT_REDIS_CLUSTER_HASH_TAG
+		}
+		return newKVClientsRepository(db.NewRedisStorageWithNamespace(client, codec, hashTag, *f.namespace)), client.Close, nil
+	case "bolt":
+		storage, err := db.NewBoltStorage(*f.boltFile, codec)
+		if err != nil {
+			return nil, nil, err
+		}
+		return newKVClientsRepository(storage), storage.Close, nil
+	case "sqlite":
+		sqlDB, err := db.OpenSQLite(*f.sqliteFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		clientsRepository, err := db.NewSQLClientsRepository(sqlDB, db.SQLITE_DIALECT)
+		if err != nil {
+			sqlDB.Close()
+			return nil, nil, err
+		}
+		return clientsRepository, sqlDB.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported storage type %s", *f.storageType)
+	}
+}
+
+// fsckCaller is the caller of the repairs in the client history
+const fsckCaller = "admin-fsck"
+
+func fsck(args []string) error {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	storageConfig := addStorageFlags(flags)
+	repair := flags.Bool("repair", false, "repair the found inconsistencies")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	clientsRepository, closeStorage, err := storageConfig.openClientsRepository()
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	ctx := db.WithAuditInfo(context.Background(), db.AuditInfo{Caller: fsckCaller})
+	report, err := clientsRepository.CheckConsistency(ctx, *repair)
+	if report == nil {
+		return err
+	}
+	for _, inconsistency := range report.Inconsistencies {
+		state := "found"
+		if inconsistency.Repaired {
+			state = "repaired"
+		}
+		log.Printf("%s %s: client %q, key %q, index %q, member %q, %s", state, inconsistency.Type,
+			inconsistency.ClientId, inconsistency.Key, inconsistency.Index, inconsistency.Member, inconsistency.Description)
+	}
+	log.Printf("Checked %d clients and %d keys, found %d inconsistencies", report.CheckedClients, report.CheckedKeys, len(report.Inconsistencies))
+	if err == nil && !*repair && len(report.Inconsistencies) > 0 {
+		return errors.New("the clients are inconsistent, run fsck with -repair to repair them")
+	}
+	return err
+}
+
+func export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	storageConfig := addStorageFlags(flags)
+	format := flags.String("format", db.EXPORT_FORMAT_NDJSON, "export format: ndjson or proto")
+	output := flags.String("o", "", "output file, stdout by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	writer, err := db.NewExportWriter(out, *format)
+	if err != nil {
+		return err
+	}
+
+	clientsRepository, closeStorage, err := storageConfig.openClientsRepository()
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	exported, err := db.ExportClients(context.Background(), clientsRepository, writer)
+	log.Printf("Exported %d clients", exported)
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		return out.Sync()
+	}
+	return nil
+}
+
+// importCaller is the caller of the imported changes in the client history
+const importCaller = "admin-import"
+
+func importClients(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	storageConfig := addStorageFlags(flags)
+	format := flags.String("format", db.EXPORT_FORMAT_NDJSON, "export format: ndjson or proto")
+	input := flags.String("i", "", "input file, stdin by default")
+	mode := flags.String("mode", db.IMPORT_MODE_MERGE, "import mode: merge keeps the clients missing in the file, replace purges all the clients before the import")
+	dryRun := flags.Bool("dry-run", false, "report the conflicts without changing the clients")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	in := os.Stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	reader, err := db.NewExportReader(in, *format)
+	if err != nil {
+		return err
+	}
+
+	clientsRepository, closeStorage, err := storageConfig.openClientsRepository()
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	ctx := db.WithAuditInfo(context.Background(), db.AuditInfo{Caller: importCaller})
+	report, err := db.ImportClients(ctx, clientsRepository, reader, *mode, *dryRun)
+	if report == nil {
+		return err
+	}
+	for _, conflict := range report.Conflicts {
+		log.Printf("skipped %s: client %q, key %q, %s", conflict.Type, conflict.ClientId, conflict.Key, conflict.Description)
+	}
+	prefix := "Imported"
+	if report.DryRun {
+		prefix = "Dry run, would import"
+	}
+	log.Printf("%s %d new clients, %d updated clients and %d keys, removed %d clients, found %d conflicts", prefix,
+		report.CreatedClients, report.UpdatedClients, report.ImportedKeys, report.RemovedClients, len(report.Conflicts))
+	return err
+}
+
+func rewriteValues(args []string) error {
+	flags := flag.NewFlagSet("rewrite-values", flag.ContinueOnError)
+	storageConfig := addStorageFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	clientsRepository, closeStorage, err := storageConfig.openClientsRepository()
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+	kvClientsRepository, ok := clientsRepository.(*db.KVClientsRepository)
+	if !ok {
+		return fmt.Errorf("storage type %s doesn't use the storage codec", *storageConfig.storageType)
+	}
+
+	ctx := context.Background()
+	rewriters := []func(ctx context.Context) (int, error){
+		kvClientsRepository.RewriteValues,
+		db.NewKVTestSuitesRepository(kvClientsRepository.Storage()).RewriteValues,
+		db.NewKVTestRunsRepository(kvClientsRepository.Storage()).RewriteValues,
+	}
+	total := 0
+	for _, rewrite := range rewriters {
+		var rewritten int
+		rewritten, err = rewrite(ctx)
+		total += rewritten
+		if err != nil {
+			break
+		}
+	}
+	log.Printf("Rewritten %d values", total)
+	return err
+}
+
+func envOrDefault(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// envIntOrDefault returns the integer value of the environment variable, the invalid values are replaced by the defaultValue
+func envIntOrDefault(name string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
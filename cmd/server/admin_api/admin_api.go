@@ -0,0 +1,112 @@
+package admin_api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	srv "github.com/derbylock/async-integration-testing/cmd/server/httputils"
+	srvErrors "github.com/derbylock/async-integration-testing/cmd/server/servererrors"
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/julienschmidt/httprouter"
+)
+
+type AdminAPIController struct {
+	clientsRepository db.ClientsRepository
+}
+
+func NewAdminAPIController(clientsRepository db.ClientsRepository) *AdminAPIController {
+	return &AdminAPIController{clientsRepository: clientsRepository}
+}
+
+func (c *AdminAPIController) InitRoutes(pathPrefix string, router *httprouter.Router) {
+	router.GET(pathPrefix+"/admin/fsck", c.CheckConsistencyHandler)
+	router.POST(pathPrefix+"/admin/fsck", c.RepairConsistencyHandler)
+	router.GET(pathPrefix+"/admin/export", c.ExportHandler)
+	router.POST(pathPrefix+"/admin/import", c.ImportHandler)
+	router.GET(pathPrefix+"/admin/cache", c.CacheStatsHandler)
+}
+
+// CheckConsistencyHandler reports the inconsistencies of the stored clients without changing them
+func (c *AdminAPIController) CheckConsistencyHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	report, err := c.clientsRepository.CheckConsistency(r.Context(), false)
+	srv.WriteProtoJsonMessageOrError(w, report, err)
+}
+
+// RepairConsistencyHandler reports the inconsistencies of the stored clients and repairs them,
+// the repairs of the key lists are recorded in the client history
+func (c *AdminAPIController) RepairConsistencyHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	report, err := c.clientsRepository.CheckConsistency(r.Context(), true)
+	srv.WriteProtoJsonMessageOrError(w, report, err)
+}
+
+// CacheStatsHandler returns the statistics of the clients cache of the instance, not found if the cache is disabled
+func (c *AdminAPIController) CacheStatsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	cache, ok := c.clientsRepository.(*db.CachingClientsRepository)
+	if !ok {
+		srvErrors.RenderError(w, "the clients cache is disabled", http.StatusNotFound)
+		return
+	}
+	srv.WriteProtoJsonMessageOrError(w, cache.Stats(), nil)
+}
+
+// exportContentTypes are the content types of the export formats
+var exportContentTypes = map[string]string{
+	db.EXPORT_FORMAT_NDJSON: "application/x-ndjson",
+	db.EXPORT_FORMAT_PROTO:  "application/x-protobuf",
+}
+
+func exportFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	return db.EXPORT_FORMAT_NDJSON
+}
+
+// ExportHandler streams all the clients with their keys in the format specified by the format query parameter.
+// The response is already started when the export fails, so the failure is logged and the response is truncated.
+func (c *AdminAPIController) ExportHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	format := exportFormat(r)
+	writer, err := db.NewExportWriter(w, format)
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", "attachment; filename=\"asit-export."+format+"\"")
+	if _, err := db.ExportClients(r.Context(), c.clientsRepository, writer); err != nil {
+		log.Printf("can't export clients, %v", err)
+	}
+}
+
+// ImportHandler imports the clients from the request body written by ExportHandler and returns the import report.
+// The mode query parameter is merge or replace, the dryRun query parameter reports the conflicts without changing the clients.
+func (c *AdminAPIController) ImportHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = db.IMPORT_MODE_MERGE
+	}
+	dryRun := false
+	if value := r.URL.Query().Get("dryRun"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			srvErrors.RenderError(w, "dryRun must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	reader, err := db.NewExportReader(r.Body, exportFormat(r))
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := db.ImportClients(r.Context(), c.clientsRepository, reader, mode, dryRun)
+	var invalidFileErr *db.InvalidExportFileError
+	var invalidModeErr *db.InvalidImportModeError
+	if errors.As(err, &invalidFileErr) || errors.As(err, &invalidModeErr) {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	srv.WriteProtoJsonMessageOrError(w, report, err)
+}
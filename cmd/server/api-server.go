@@ -1,6 +1,8 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -9,64 +11,317 @@ import (
 	"time"
 
 	"github.com/NYTimes/gziphandler"
+	"github.com/derbylock/async-integration-testing/cmd/server/admin_api"
 	"github.com/derbylock/async-integration-testing/cmd/server/asit_api"
+	"github.com/derbylock/async-integration-testing/cmd/server/audit"
 	"github.com/derbylock/async-integration-testing/cmd/server/cors"
 	"github.com/derbylock/async-integration-testing/cmd/server/debug_api"
 	"github.com/derbylock/async-integration-testing/cmd/server/health"
+	"github.com/derbylock/async-integration-testing/cmd/server/httputils"
 	"github.com/derbylock/async-integration-testing/cmd/server/requestlogger"
 	"github.com/derbylock/async-integration-testing/internal/db"
 	"github.com/go-redis/redis/v9"
 	"github.com/julienschmidt/httprouter"
 )
 
+// DEFAULT_TRASH_RETENTION is the time after which the trashed clients are purged automatically
+const DEFAULT_TRASH_RETENTION = 30 * 24 * time.Hour
+
+const trashPurgeInterval = time.Hour
+
+// trashPurgeCaller is the caller of the automatic purges in the client history
+const trashPurgeCaller = "trash-retention"
+
+// keyExpiryInterval is the interval of the removal of the expired client keys, they can't be found even before it
+const keyExpiryInterval = time.Minute
+
+// keyExpiryCaller is the caller of the key expirations in the client history
+const keyExpiryCaller = "key-expiry"
+
+// clientChangesRetryInterval is the interval between the subscriptions to the client changes
+const clientChangesRetryInterval = 5 * time.Second
+
 type Server struct {
 	clientsRepository db.ClientsRepository
 	port              int
+	trashRetention    time.Duration
+	// clientChanges deliver the changes of the clients between the instances sharing the storage
+	clientChanges   db.ClientChanges
+	clientsWatcher  *db.ClientsWatcher
+	clientsCache    *db.CachingClientsRepository
+	cacheMaxEntries int
+	cacheMaxAge     time.Duration
+	// testSuitesRepository and testRunsRepository are nil if the server is created without a key-value storage
+	testSuitesRepository db.TestSuitesRepository
+	testRunsRepository   db.TestRunsRepository
+	// testRunChanges deliver the ids of the clients whose runs have changed, they wake up the waiting agents of the clients
+	testRunChanges  db.ClientChanges
+	testRunsWatcher *db.ClientsWatcher
+	// adminAddr is the address of the admin API, it's disabled if the address is empty
+	adminAddr string
 }
 
 func NewServer(storage db.Storage) *Server {
-	clientsRepository := db.NewKVClientsRepository(storage)
+	return newServerWithStorage(db.NewKVClientsRepository(storage), storage)
+}
+
+// newServerWithStorage creates the server which keeps the test suites and their runs in the storage of the clients
+func newServerWithStorage(clientsRepository db.ClientsRepository, storage db.Storage) *Server {
+	s := NewServerWithRepository(clientsRepository)
+	s.testSuitesRepository = db.NewKVTestSuitesRepository(storage)
+	s.testRunsRepository = db.NewKVTestRunsRepository(storage)
+	return s
+}
 
+// KVStorageOptions configure the values and the key names of the key-value storages
+type KVStorageOptions struct {
+	Codec db.StorageCodec
+	// ClientKeysHMACKey enables the hashing of the client keys in the key names, nil keeps the plain client keys
+	ClientKeysHMACKey []byte
+	// RedisAtomicUpdates are the atomic updates of the Redis storage, the scripts by default
+	RedisAtomicUpdates db.RedisAtomicUpdates
+}
+
+func newKVServer(storage db.Storage, options KVStorageOptions) *Server {
+	if options.ClientKeysHMACKey != nil {
+		return newServerWithStorage(db.NewKVClientsRepositoryWithHashedKeys(storage, options.ClientKeysHMACKey), storage)
+	}
+	return NewServer(storage)
+}
+
+func NewServerWithRepository(clientsRepository db.ClientsRepository) *Server {
 	return &Server{
 		clientsRepository: clientsRepository,
 		port:              9580,
+		trashRetention:    DEFAULT_TRASH_RETENTION,
+		clientChanges:     db.NewLocalClientChanges(),
+		clientsWatcher:    db.NewClientsWatcher(),
+		testRunChanges:    db.NewLocalClientChanges(),
+		testRunsWatcher:   db.NewClientsWatcher(),
 	}
 }
 
+// SetTrashRetention changes the time after which the trashed clients are purged, 0 disables the automatic purge
+func (s *Server) SetTrashRetention(retention time.Duration) {
+	s.trashRetention = retention
+}
+
+// SetClientsCache enables the cache of up to maxEntries clients found by their keys, the cached clients are stale
+// for at most maxAge if an invalidation is lost. 0 maxEntries disables the cache.
+func (s *Server) SetClientsCache(maxEntries int, maxAge time.Duration) {
+	s.cacheMaxEntries = maxEntries
+	s.cacheMaxAge = maxAge
+}
+
+// SetAdminAddr serves the admin API on the separate address, e.g. 127.0.0.1:9581, so it isn't exposed with the public API.
+// The admin API exports and replaces all the clients, it's disabled if the address is empty.
+func (s *Server) SetAdminAddr(addr string) {
+	s.adminAddr = addr
+}
+
 const asitAPIPrefix = "/asit/api/v1"
 
 func (s *Server) ListenAndServe() error {
 	log.Println("Starting HTTP server")
 
+	// the changes made through the API are published to all the instances, the cache invalidates its entries immediately
+	s.clientsRepository = db.NewNotifyingClientsRepository(s.clientsRepository, s.clientChanges.Publish)
+	if s.cacheMaxEntries > 0 {
+		s.clientsCache = db.NewCachingClientsRepository(s.clientsRepository, s.cacheMaxEntries, s.cacheMaxAge)
+		s.clientsRepository = s.clientsCache
+	}
+
 	router := httprouter.New()
 	health.InitAPIRoutes(asitAPIPrefix, router)
-	asit_api.NewClientsAPIController(s.clientsRepository).InitRoutes(asitAPIPrefix, router)
+	asit_api.NewClientsAPIController(s.clientsRepository, s.clientsWatcher).InitRoutes(asitAPIPrefix, router)
+	if s.testSuitesRepository != nil {
+		s.testRunsRepository = db.NewNotifyingTestRunsRepository(s.testRunsRepository, s.testRunChanges.Publish)
+		asit_api.NewTestSuitesAPIController(s.testSuitesRepository).InitRoutes(asitAPIPrefix, router)
+		asit_api.NewTestRunsAPIController(s.testRunsRepository, s.testSuitesRepository, s.clientsRepository).InitRoutes(asitAPIPrefix, router)
+		asit_api.NewAgentAPIController(s.clientsRepository, s.testRunsRepository, s.testRunsWatcher).InitRoutes(asitAPIPrefix, router)
+	} else {
+		log.Println("Test suites are not supported by the storage, their API, the test runs API and the agent API are disabled")
+	}
 	debug_api.InitAPIRoutes(asitAPIPrefix, router)
 
 	log.Printf("Listening on port %d \r\n", *&s.port)
-	handler := cors.NewCorsRouter(router)
+	handler := cors.NewCorsRouter(audit.Handler(router))
 	handlerLogger := requestlogger.Logger(os.Stdout, handler)
-	handlerWithGZip := gziphandler.GzipHandler(handlerLogger)
+	handlerWithGZip := withoutGzipForEventStreams(gziphandler.GzipHandler(handlerLogger), handlerLogger)
 
 	httpServer := &http.Server{
 		Addr:           ":" + strconv.Itoa(s.port),
 		Handler:        handlerWithGZip,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
+		ReadTimeout:    httputils.REQUEST_TIMEOUT,
+		WriteTimeout:   httputils.REQUEST_TIMEOUT,
 		IdleTimeout:    300 * time.Second,
 		MaxHeaderBytes: 1 << 20,
+		// the watches extend the timeouts of their connections
+		ConnContext: httputils.ConnContext,
 	}
 	httpServer.SetKeepAlivesEnabled(true)
+
+	if s.trashRetention > 0 {
+		go s.purgeTrashPeriodically()
+	}
+	go s.expireClientKeysPeriodically()
+	go s.listenForClientChanges()
+	if s.testSuitesRepository != nil {
+		go s.listenForTestRunChanges()
+	}
+	if s.adminAddr != "" {
+		go s.listenAndServeAdmin()
+	} else {
+		log.Println("Admin API is disabled, its address is not specified")
+	}
 	return httpServer.ListenAndServe()
 }
 
-func NewRedisBackedServer(redisAddrs string, redisPassword string) *Server {
+// listenAndServeAdmin serves the admin API on adminAddr, the server exits if it can't listen on the address.
+// There is no write timeout, so the large exports aren't interrupted.
+func (s *Server) listenAndServeAdmin() {
+	router := httprouter.New()
+	admin_api.NewAdminAPIController(s.clientsRepository).InitRoutes(asitAPIPrefix, router)
+	log.Printf("Listening on %s for the admin API\r\n", s.adminAddr)
+	adminServer := &http.Server{
+		Addr:           s.adminAddr,
+		Handler:        requestlogger.Logger(os.Stdout, audit.Handler(router)),
+		ReadTimeout:    10 * time.Second,
+		IdleTimeout:    300 * time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
+	log.Fatal(adminServer.ListenAndServe())
+}
+
+// withoutGzipForEventStreams serves the server-sent events without the compression,
+// because the compressing handler buffers the small responses, so the events wouldn't be sent immediately
+func withoutGzipForEventStreams(gzipHandler http.Handler, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		gzipHandler.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) purgeTrashPeriodically() {
+	for {
+		ctx := db.WithAuditInfo(context.Background(), db.AuditInfo{Caller: trashPurgeCaller})
+		purged, err := s.clientsRepository.PurgeTrash(ctx, time.Now().Add(-s.trashRetention))
+		if err != nil {
+			log.Printf("can't purge the trashed clients, %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d trashed clients", purged)
+		}
+		time.Sleep(trashPurgeInterval)
+	}
+}
+
+func (s *Server) expireClientKeysPeriodically() {
+	for {
+		ctx := db.WithAuditInfo(context.Background(), db.AuditInfo{Caller: keyExpiryCaller})
+		expired, err := s.clientsRepository.ExpireClientKeys(ctx, time.Now())
+		if err != nil {
+			log.Printf("can't remove the expired client keys, %v", err)
+		} else if expired > 0 {
+			log.Printf("Removed %d expired client keys", expired)
+		}
+		time.Sleep(keyExpiryInterval)
+	}
+}
+
+// listenForClientChanges invalidates the cached clients and wakes up their watchers on the changes made by any instance
+func (s *Server) listenForClientChanges() {
+	changed := func(clientId string) {
+		if s.clientsCache != nil {
+			s.clientsCache.Invalidate(clientId)
+		}
+		s.clientsWatcher.Notify(clientId)
+	}
+	for {
+		if err := s.clientChanges.Subscribe(context.Background(), changed); err != nil {
+			log.Printf("can't receive the client changes, %v", err)
+		}
+		// the changes are lost while the subscription is broken, all the clients are changed on the resubscription
+		time.Sleep(clientChangesRetryInterval)
+	}
+}
+
+// listenForTestRunChanges wakes up the agents waiting for the actions of the clients on the changes of their runs made by any instance
+func (s *Server) listenForTestRunChanges() {
+	for {
+		if err := s.testRunChanges.Subscribe(context.Background(), s.testRunsWatcher.Notify); err != nil {
+			log.Printf("can't receive the test run changes, %v", err)
+		}
+		time.Sleep(clientChangesRetryInterval)
+	}
+}
+
+// DEFAULT_REDIS_CLUSTER_HASH_TAG is used when several Redis addresses are specified without a hash tag,
+// because the cluster requires all the keys of a transaction to be in the same slot. The hash tag puts
+// the whole data of the namespace in a single slot, so the data isn't sharded across the cluster nodes.
+const DEFAULT_REDIS_CLUSTER_HASH_TAG = "asit"
+
+// NewRedisBackedServer creates the server keeping its data in the redisNamespace, the namespace must be checked by db.ValidateRedisNamespace
+func NewRedisBackedServer(redisAddrs string, redisPassword string, redisHashTag string, redisNamespace string, options KVStorageOptions) *Server {
+	addrs := strings.Split(redisAddrs, ",")
 	redisClient := redis.NewUniversalClient(&redis.UniversalOptions{
-		Addrs:    strings.Split(redisAddrs, ","),
+		Addrs:    addrs,
 		Password: redisPassword,
 	})
-	defer redisClient.Close()
 
-	storage := db.NewRedisStorage(redisClient, db.PROTO_CODEC)
-	return NewServer(storage)
+	if redisHashTag == "" && len(addrs) > 1 {
+		log.Printf("Using the {%s} hash tag for Redis Cluster keys", DEFAULT_REDIS_CLUSTER_HASH_TAG)
+		redisHashTag = DEFAULT_REDIS_CLUSTER_HASH_TAG
+	}
+	if len(addrs) > 1 {
+		log.Printf("All the keys are kept in the single Redis Cluster slot of the {%s} hash tag, they aren't sharded across the nodes", redisHashTag)
+	}
+	if redisNamespace != "" {
+		log.Printf("Using the %s namespace for Redis keys", redisNamespace)
+	}
+	storage := db.NewRedisStorageWithNamespace(redisClient, options.Codec, redisHashTag, redisNamespace)
+	storage.SetAtomicUpdates(options.RedisAtomicUpdates)
+	s := newKVServer(storage, options)
+	s.clientChanges = db.NewRedisClientChanges(redisClient, redisHashTag, redisNamespace)
+	s.testRunChanges = db.NewRedisTestRunChanges(redisClient, redisHashTag, redisNamespace)
+	return s
+}
+
+func NewMemoryBackedServer(options KVStorageOptions) *Server {
+	storage := db.NewMemoryStorage(options.Codec)
+	return newKVServer(storage, options)
+}
+
+func NewBoltBackedServer(path string, options KVStorageOptions) (*Server, error) {
+	storage, err := db.NewBoltStorage(path, options.Codec)
+	if err != nil {
+		return nil, err
+	}
+	return newKVServer(storage, options), nil
+}
+
+// NewSQLiteBackedServer creates the server keeping the clients in the tables of the SQLite db, so only the codec of the options is used
+// for the values of the key-value tables. The client keys can't be hashed, ClientKeysHMACKey must be nil.
+func NewSQLiteBackedServer(path string, options KVStorageOptions) (*Server, error) {
+	if options.ClientKeysHMACKey != nil {
+		return nil, errors.New("the client keys can't be hashed in the SQLite clients tables")
+	}
+	sqlDB, err := db.OpenSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	clientsRepository, err := db.NewSQLClientsRepository(sqlDB, db.SQLITE_DIALECT)
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	// the clients are kept in their tables, the test suites and their runs are kept in the key-value tables of the same db
+	storage, err := db.NewSQLStorage(sqlDB, db.SQLITE_DIALECT, options.Codec)
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return newServerWithStorage(clientsRepository, storage), nil
 }
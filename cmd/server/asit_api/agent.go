@@ -0,0 +1,90 @@
+package asit_api
+
+import (
+	"net/http"
+	"time"
+
+	srv "github.com/derbylock/async-integration-testing/cmd/server/httputils"
+	srvErrors "github.com/derbylock/async-integration-testing/cmd/server/servererrors"
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/julienschmidt/httprouter"
+)
+
+// AgentAPIController serves the agents of the tested clients, they claim the actions of their runs and report the results
+type AgentAPIController struct {
+	clientsRepository db.ClientsRepository
+	testRunEngine     *db.TestRunEngine
+	testRunsWatcher   *db.ClientsWatcher
+}
+
+// NewAgentAPIController creates the controller, the watcher must be notified about the changes of the runs by the ids of their clients
+func NewAgentAPIController(clientsRepository db.ClientsRepository, testRunsRepository db.TestRunsRepository,
+	testRunsWatcher *db.ClientsWatcher) *AgentAPIController {
+	return &AgentAPIController{
+		clientsRepository: clientsRepository,
+		testRunEngine:     db.NewTestRunEngine(testRunsRepository),
+		testRunsWatcher:   testRunsWatcher,
+	}
+}
+
+func (c *AgentAPIController) InitRoutes(pathPrefix string, router *httprouter.Router) {
+	router.GET(pathPrefix+"/agent/next", c.NextActionHandler)
+}
+
+// NextActionHandler claims the action of the active step of the runs of the client found by the clientKey query parameter.
+// It waits for the action until the timeout query parameter (DEFAULT_WATCH_TIMEOUT by default) and returns 204 if there is none.
+// Every action is returned only once until its claim lease expires, so several agents of the same client may poll concurrently.
+func (c *AgentAPIController) NextActionHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	clientKey := r.URL.Query().Get("clientKey")
+	if clientKey == "" || len(clientKey) > MAX_KEY_SIZE {
+		srvErrors.SendBadRequest(w)
+		return
+	}
+	timeout, err := parseWatchTimeout(r.URL.Query().Get("timeout"))
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	client, err := c.clientsRepository.GetClientByKey(r.Context(), clientKey)
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	if client == nil {
+		srvErrors.SendEntityNotFound(w)
+		return
+	}
+
+	if err := srv.ExtendDeadlines(r, timeout+srv.REQUEST_TIMEOUT); err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	// the watch is started before the first claim, so the runs changed after it aren't missed
+	changes, stop := c.testRunsWatcher.Watch(client.Id)
+	defer stop()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	// the runs are read again periodically, so the lost notifications only delay the agent
+	ticker := time.NewTicker(watchHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		action, err := c.testRunEngine.ClaimNextAction(r.Context(), client.Id)
+		if err != nil {
+			srvErrors.SendInternalError(w, err)
+			return
+		}
+		if action != nil {
+			srv.WriteProtoJsonMessageOrError(w, action, nil)
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+}
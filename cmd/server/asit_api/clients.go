@@ -1,9 +1,16 @@
 package asit_api
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	srv "github.com/derbylock/async-integration-testing/cmd/server/httputils"
@@ -16,13 +23,21 @@ import (
 )
 
 const MAX_KEY_SIZE = 1024
+const MAX_CLIENTS_PAGE_SIZE = 1000
+
+// NextCursorHeaderName is the header with the cursor of the next page of the paginated lists
+const NextCursorHeaderName = "X-ASIT-NEXT-CURSOR"
+
+const clientPropertiesParamPrefix = "clientProperties."
 
 type ClientsAPIController struct {
 	clientsRepository db.ClientsRepository
+	clientsWatcher    *db.ClientsWatcher
 }
 
-func NewClientsAPIController(clientsRepository db.ClientsRepository) *ClientsAPIController {
-	return &ClientsAPIController{clientsRepository: clientsRepository}
+// NewClientsAPIController creates the controller, the watcher must be notified about the changes of the clients
+func NewClientsAPIController(clientsRepository db.ClientsRepository, clientsWatcher *db.ClientsWatcher) *ClientsAPIController {
+	return &ClientsAPIController{clientsRepository: clientsRepository, clientsWatcher: clientsWatcher}
 }
 
 func (c *ClientsAPIController) InitRoutes(pathPrefix string, router *httprouter.Router) {
@@ -30,17 +45,90 @@ func (c *ClientsAPIController) InitRoutes(pathPrefix string, router *httprouter.
 	router.POST(pathPrefix+"/clients", c.AddClientHandler)
 	router.GET(pathPrefix+"/clients/:clientId", c.GetClientHandler)
 	router.PUT(pathPrefix+"/clients/:clientId", c.UpdateClientHandler)
+	router.PATCH(pathPrefix+"/clients/:clientId", c.PatchClientHandler)
 	router.DELETE(pathPrefix+"/clients/:clientId", c.DeleteClientHandler)
+	router.GET(pathPrefix+"/clients/:clientId/events", c.ClientEventsHandler)
+
+	router.GET(pathPrefix+"/clients/:clientId/history", c.GetClientHistoryHandler)
+	router.GET(pathPrefix+"/clients/:clientId/history/diff", c.DiffClientRevisionsHandler)
+	router.GET(pathPrefix+"/clients/:clientId/revisions/:revision", c.GetClientRevisionHandler)
+	router.POST(pathPrefix+"/clients/:clientId/revisions/:revision/rollback", c.RollbackClientHandler)
 
 	router.GET(pathPrefix+"/clients/:clientId/keys", c.GetAllClientKeysHandler)
 	router.POST(pathPrefix+"/client_keys/:key", c.AddClientKeyHandler)
 	router.GET(pathPrefix+"/client_keys/:key", c.GetClientByKeyHandler)
 	router.DELETE(pathPrefix+"/client_keys/:key", c.DeleteClientKeyHandler)
+
+	router.GET(pathPrefix+"/trash/clients", c.GetTrashedClientsHandler)
+	router.GET(pathPrefix+"/trash/clients/:clientId", c.GetTrashedClientHandler)
+	router.POST(pathPrefix+"/trash/clients/:clientId/restore", c.RestoreClientHandler)
+	router.DELETE(pathPrefix+"/trash/clients/:clientId", c.PurgeClientHandler)
 }
 
+// GetAllClientsHandler returns all the clients if no query parameters are specified.
+// The next page cursor is returned in the NextCursorHeaderName header if the limit is specified and there are more clients.
 func (c *ClientsAPIController) GetAllClientsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	allClients, err := c.clientsRepository.GetAllClients(r.Context())
-	srv.WriteProtoArrayJsonMessageOrError(w, allClients, err)
+	query, err := parseClientsQuery(r.URL.Query())
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	clients, nextCursor, err := c.clientsRepository.QueryClients(r.Context(), query)
+	var invalidQueryErr *db.InvalidClientsQueryError
+	if errors.As(err, &invalidQueryErr) {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	setNextCursor(w, nextCursor)
+	srv.WriteProtoArrayJsonMessageOrError(w, clients, err)
+}
+
+func setNextCursor(w http.ResponseWriter, nextCursor string) {
+	if nextCursor != "" {
+		w.Header().Set(NextCursorHeaderName, base64.RawURLEncoding.EncodeToString([]byte(nextCursor)))
+	}
+}
+
+// parseClientsQuery parses the limit, cursor, sort and namePrefix parameters,
+// other parameters prefixed with clientPropertiesParamPrefix are the filters on client properties values.
+func parseClientsQuery(params url.Values) (db.ClientsQuery, error) {
+	query := db.ClientsQuery{
+		SortBy:     params.Get("sort"),
+		NamePrefix: params.Get("namePrefix"),
+	}
+	var err error
+	query.Cursor, query.Limit, err = parsePageParams(params)
+	if err != nil {
+		return query, err
+	}
+	for param, values := range params {
+		if !strings.HasPrefix(param, clientPropertiesParamPrefix) {
+			continue
+		}
+		if query.Properties == nil {
+			query.Properties = map[string]string{}
+		}
+		query.Properties[strings.TrimPrefix(param, clientPropertiesParamPrefix)] = values[0]
+	}
+	return query, nil
+}
+
+// parsePageParams returns the decoded cursor and the limit (0 if it's not specified) of the paginated lists
+func parsePageParams(params url.Values) (string, int, error) {
+	limit := 0
+	if limitParam := params.Get("limit"); limitParam != "" {
+		var err error
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > MAX_CLIENTS_PAGE_SIZE {
+			return "", 0, fmt.Errorf("limit must be a number from 1 to %d", MAX_CLIENTS_PAGE_SIZE)
+		}
+	}
+	cursor, err := base64.RawURLEncoding.DecodeString(params.Get("cursor"))
+	if err != nil {
+		return "", 0, errors.New("malformed cursor")
+	}
+	return string(cursor), limit, nil
 }
 
 func (c *ClientsAPIController) AddClientHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -58,21 +146,47 @@ func (c *ClientsAPIController) AddClientHandler(w http.ResponseWriter, r *http.R
 	client.Id = newId.String()
 	client.LastUpdated = timestamppb.New(time.Now())
 	err = c.clientsRepository.SetClient(r.Context(), &client)
+	if err == nil {
+		w.Header().Set("ETag", srv.ETag(client.Revision))
+	}
 	srv.WriteProtoJsonMessageOrError(w, &client, err)
 }
 
+// GetClientHandler returns the client revision as the ETag, If-None-Match with the current ETag returns 304.
+// The watch=true query parameter waits for the change of the client, see watchClient.
 func (c *ClientsAPIController) GetClientHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	clientId := params.ByName("clientId")
+	if r.URL.Query().Get("watch") == "true" {
+		c.watchClient(w, r, clientId)
+		return
+	}
 	client, err := c.clientsRepository.GetClientById(r.Context(), clientId)
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
 	if client == nil {
 		srvErrors.SendEntityNotFound(w)
 		return
 	}
-	srv.WriteProtoJsonMessageOrError(w, client, err)
+
+	etag := srv.ETag(client.Revision)
+	w.Header().Set("ETag", etag)
+	if srv.ETagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	srv.WriteProtoJsonMessageOrError(w, client, nil)
 }
 
+// DeleteClientHandler removes the client only if its revision matches the If-Match ETag (if any)
 func (c *ClientsAPIController) DeleteClientHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	clientId := params.ByName("clientId")
+	revision, err := srv.IfMatchRevision(r.Header.Get("If-Match"), db.EXISTING_CLIENT_REVISION)
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	client, err := c.clientsRepository.GetClientById(r.Context(), clientId)
 	if err != nil {
 		srvErrors.SendInternalError(w, err)
@@ -82,13 +196,26 @@ func (c *ClientsAPIController) DeleteClientHandler(w http.ResponseWriter, r *htt
 		srvErrors.SendEntityNotFound(w)
 		return
 	}
-	err = c.clientsRepository.RemoveClient(r.Context(), clientId)
+	err = c.clientsRepository.RemoveClientIfRevision(r.Context(), clientId, revision)
+	if sendClientRevisionError(w, err) {
+		return
+	}
 	srv.WriteNoContentOrError(w, err)
 }
 
+// UpdateClientHandler updates the client properties only if the client revision matches the If-Match ETag (if any)
 func (c *ClientsAPIController) UpdateClientHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	clientId := params.ByName("clientId")
+	revision, err := srv.IfMatchRevision(r.Header.Get("If-Match"), db.EXISTING_CLIENT_REVISION)
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	client, err := c.clientsRepository.GetClientById(r.Context(), clientId)
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
 	if client == nil {
 		srvErrors.SendEntityNotFound(w)
 		return
@@ -101,10 +228,103 @@ func (c *ClientsAPIController) UpdateClientHandler(w http.ResponseWriter, r *htt
 	}
 
 	client.ClientProperties = newClient.ClientProperties
-	c.clientsRepository.SetClient(r.Context(), client)
+	err = c.clientsRepository.SetClientIfRevision(r.Context(), client, revision)
+	if sendClientRevisionError(w, err) {
+		return
+	}
+	if err == nil {
+		w.Header().Set("ETag", srv.ETag(client.Revision))
+	}
+	srv.WriteProtoJsonMessageOrError(w, client, err)
+}
+
+// PatchClientHandler applies the JSON Merge Patch (RFC 7396) to the client name and client properties.
+// The body is parsed as the merge patch regardless of the content type, like other handlers parse JSON.
+// The patch is applied atomically, so the concurrent patches of different properties don't overwrite each other.
+func (c *ClientsAPIController) PatchClientHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	clientId := params.ByName("clientId")
+	revision, err := srv.IfMatchRevision(r.Header.Get("If-Match"), db.EXISTING_CLIENT_REVISION)
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	patch, err := parseClientPatch(r.Body)
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client, err := c.clientsRepository.PatchClient(r.Context(), clientId, patch, revision)
+	if sendClientRevisionError(w, err) {
+		return
+	}
+	if err == nil {
+		w.Header().Set("ETag", srv.ETag(client.Revision))
+	}
 	srv.WriteProtoJsonMessageOrError(w, client, err)
 }
 
+// parseClientPatch parses the merge patch, only the name and clientProperties can be patched.
+// The null name is the empty name and the null clientProperties removes all the client properties.
+func parseClientPatch(body io.Reader) (db.ClientPatch, error) {
+	patch := db.ClientPatch{}
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&fields); err != nil {
+		return patch, fmt.Errorf("invalid JSON, %w", err)
+	}
+	if fields == nil {
+		return patch, errors.New("patch must be a JSON object")
+	}
+
+	for field, value := range fields {
+		isNull := string(bytes.TrimSpace(value)) == "null"
+		switch field {
+		case "name":
+			name := ""
+			if !isNull {
+				if err := json.Unmarshal(value, &name); err != nil {
+					return patch, errors.New("name must be a string")
+				}
+			}
+			patch.Name = &name
+		case "clientProperties":
+			if isNull {
+				patch.RemoveAllProperties = true
+				continue
+			}
+			if err := json.Unmarshal(value, &patch.Properties); err != nil {
+				return patch, errors.New("clientProperties must be an object with string or null values")
+			}
+		case "id", "lastUpdated", "revision":
+			return patch, fmt.Errorf("%s is read-only", field)
+		default:
+			return patch, fmt.Errorf("unknown field %s", field)
+		}
+	}
+	return patch, nil
+}
+
+// sendClientRevisionError sends the response for the errors of the conditional client updates.
+// It returns false if the error isn't related to the revision check.
+func sendClientRevisionError(w http.ResponseWriter, err error) bool {
+	var mismatchErr *db.ClientRevisionMismatchError
+	var notFoundErr *db.NotFoundClientByIdError
+	var conflictErr *db.ClientIdConflictError
+	switch {
+	case errors.As(err, &mismatchErr):
+		srvErrors.SendPreconditionFailed(w, err)
+	case errors.As(err, &notFoundErr):
+		// removed concurrently
+		srvErrors.SendEntityNotFound(w)
+	case errors.As(err, &conflictErr):
+		// the client id is taken by a trashed client
+		srvErrors.SendConflictError(w, err)
+	default:
+		return false
+	}
+	return true
+}
+
 func (c *ClientsAPIController) GetAllClientKeysHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	clientId := params.ByName("clientId")
 	allKeys, err := c.clientsRepository.GetClientKeys(r.Context(), clientId)
@@ -130,12 +350,22 @@ func (c *ClientsAPIController) AddClientKeyHandler(w http.ResponseWriter, r *htt
 		srvErrors.SendBadRequest(w)
 		return
 	}
+	// the key without ttl never expires
+	var ttl time.Duration
+	if value := r.URL.Query().Get("ttl"); value != "" {
+		var err error
+		ttl, err = time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			srvErrors.RenderError(w, "ttl must be a positive duration, e.g. 90s or 12h", http.StatusBadRequest)
+			return
+		}
+	}
 	var client asit.Client
 	if err := json.NewDecoder(r.Body).Decode(&client); err != nil {
 		srvErrors.SendInvalidJSON(w, err)
 		return
 	}
-	err := c.clientsRepository.AddClientKey(r.Context(), client.Id, key)
+	err := c.clientsRepository.AddClientKeyWithTTL(r.Context(), client.Id, key, ttl)
 	switch errors.Unwrap(err).(type) {
 	case *db.NonUniqueClientKeyError:
 		srvErrors.SendConflictError(w, err)
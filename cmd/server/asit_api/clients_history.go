@@ -0,0 +1,156 @@
+package asit_api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	srv "github.com/derbylock/async-integration-testing/cmd/server/httputils"
+	srvErrors "github.com/derbylock/async-integration-testing/cmd/server/servererrors"
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"github.com/julienschmidt/httprouter"
+)
+
+// valueChange is the change of a single value, nil means that the value is missing
+type valueChange struct {
+	Old *string `json:"old"`
+	New *string `json:"new"`
+}
+
+// clientDiff contains only the changed values of the client
+type clientDiff struct {
+	FromRevision     int64                  `json:"fromRevision"`
+	ToRevision       int64                  `json:"toRevision"`
+	Name             *valueChange           `json:"name,omitempty"`
+	ClientProperties map[string]valueChange `json:"clientProperties"`
+}
+
+func diffClients(from *asit.Client, to *asit.Client) clientDiff {
+	diff := clientDiff{
+		FromRevision:     from.Revision,
+		ToRevision:       to.Revision,
+		ClientProperties: map[string]valueChange{},
+	}
+	if from.Name != to.Name {
+		diff.Name = &valueChange{Old: &from.Name, New: &to.Name}
+	}
+	for name, oldValue := range from.ClientProperties {
+		oldValue := oldValue
+		newValue, ok := to.ClientProperties[name]
+		if !ok {
+			diff.ClientProperties[name] = valueChange{Old: &oldValue}
+		} else if newValue != oldValue {
+			diff.ClientProperties[name] = valueChange{Old: &oldValue, New: &newValue}
+		}
+	}
+	for name, newValue := range to.ClientProperties {
+		newValue := newValue
+		if _, ok := from.ClientProperties[name]; !ok {
+			diff.ClientProperties[name] = valueChange{New: &newValue}
+		}
+	}
+	return diff
+}
+
+// GetClientHistoryHandler returns the client history in the order of the changes,
+// the pagination is the same as in GetAllClientsHandler
+func (c *ClientsAPIController) GetClientHistoryHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	clientId := params.ByName("clientId")
+	cursor, limit, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, nextCursor, err := c.clientsRepository.GetClientHistory(r.Context(), clientId, cursor, limit)
+	var invalidQueryErr *db.InvalidClientsQueryError
+	if errors.As(err, &invalidQueryErr) {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	setNextCursor(w, nextCursor)
+	srv.WriteProtoArrayJsonMessageOrError(w, entries, err)
+}
+
+// GetClientRevisionHandler returns the client value of the revision from the client history
+func (c *ClientsAPIController) GetClientRevisionHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	clientId := params.ByName("clientId")
+	revision, err := parseRevision(params.ByName("revision"))
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	client, err := c.clientsRepository.GetClientRevision(r.Context(), clientId, revision)
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	if client == nil {
+		srvErrors.SendEntityNotFound(w)
+		return
+	}
+	srv.WriteProtoJsonMessageOrError(w, client, nil)
+}
+
+// DiffClientRevisionsHandler returns the changes of the client name and client properties between the from and to revisions
+func (c *ClientsAPIController) DiffClientRevisionsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	clientId := params.ByName("clientId")
+	query := r.URL.Query()
+	clients := make([]*asit.Client, 2)
+	for i, param := range []string{"from", "to"} {
+		revision, err := parseRevision(query.Get(param))
+		if err != nil {
+			srvErrors.RenderError(w, fmt.Sprintf("%s: %s", param, err.Error()), http.StatusBadRequest)
+			return
+		}
+		clients[i], err = c.clientsRepository.GetClientRevision(r.Context(), clientId, revision)
+		if err != nil {
+			srvErrors.SendInternalError(w, err)
+			return
+		}
+		if clients[i] == nil {
+			srvErrors.SendEntityNotFound(w)
+			return
+		}
+	}
+	srv.WriteJsonMessageOrError(w, diffClients(clients[0], clients[1]), nil)
+}
+
+// RollbackClientHandler sets the client name and client properties to the values of the revision
+// only if the current client revision matches the If-Match ETag (if any)
+func (c *ClientsAPIController) RollbackClientHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	clientId := params.ByName("clientId")
+	revision, err := parseRevision(params.ByName("revision"))
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ifRevision, err := srv.IfMatchRevision(r.Header.Get("If-Match"), db.EXISTING_CLIENT_REVISION)
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client, err := c.clientsRepository.RollbackClient(r.Context(), clientId, revision, ifRevision)
+	var notFoundRevisionErr *db.NotFoundClientRevisionError
+	if errors.As(err, &notFoundRevisionErr) {
+		srvErrors.SendEntityNotFound(w)
+		return
+	}
+	if sendClientRevisionError(w, err) {
+		return
+	}
+	if err == nil {
+		w.Header().Set("ETag", srv.ETag(client.Revision))
+	}
+	srv.WriteProtoJsonMessageOrError(w, client, err)
+}
+
+func parseRevision(value string) (int64, error) {
+	revision, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || revision < 1 {
+		return 0, errors.New("revision must be a positive number")
+	}
+	return revision, nil
+}
@@ -0,0 +1,62 @@
+package asit_api
+
+import (
+	"errors"
+	"net/http"
+
+	srv "github.com/derbylock/async-integration-testing/cmd/server/httputils"
+	srvErrors "github.com/derbylock/async-integration-testing/cmd/server/servererrors"
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/julienschmidt/httprouter"
+)
+
+// GetTrashedClientsHandler returns the trashed clients ordered by id, the pagination is the same as in GetAllClientsHandler
+func (c *ClientsAPIController) GetTrashedClientsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	cursor, limit, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	clients, nextCursor, err := c.clientsRepository.GetTrashedClients(r.Context(), cursor, limit)
+	setNextCursor(w, nextCursor)
+	srv.WriteProtoArrayJsonMessageOrError(w, clients, err)
+}
+
+func (c *ClientsAPIController) GetTrashedClientHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	clientId := params.ByName("clientId")
+	trashed, err := c.clientsRepository.GetTrashedClientById(r.Context(), clientId)
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	if trashed == nil {
+		srvErrors.SendEntityNotFound(w)
+		return
+	}
+	srv.WriteProtoJsonMessageOrError(w, trashed, nil)
+}
+
+// RestoreClientHandler moves the client from the trash back with all its keys and returns the restored client
+func (c *ClientsAPIController) RestoreClientHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	clientId := params.ByName("clientId")
+	client, err := c.clientsRepository.RestoreClient(r.Context(), clientId)
+	if sendClientRevisionError(w, err) {
+		return
+	}
+	if err == nil {
+		w.Header().Set("ETag", srv.ETag(client.Revision))
+	}
+	srv.WriteProtoJsonMessageOrError(w, client, err)
+}
+
+// PurgeClientHandler permanently removes the trashed client and releases its keys
+func (c *ClientsAPIController) PurgeClientHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	clientId := params.ByName("clientId")
+	err := c.clientsRepository.PurgeClient(r.Context(), clientId)
+	var notFoundErr *db.NotFoundClientByIdError
+	if errors.As(err, &notFoundErr) {
+		srvErrors.SendEntityNotFound(w)
+		return
+	}
+	srv.WriteNoContentOrError(w, err)
+}
@@ -0,0 +1,226 @@
+package asit_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	srv "github.com/derbylock/async-integration-testing/cmd/server/httputils"
+	srvErrors "github.com/derbylock/async-integration-testing/cmd/server/servererrors"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// DEFAULT_WATCH_TIMEOUT is the timeout of the long polls without the timeout query parameter
+const DEFAULT_WATCH_TIMEOUT = 30 * time.Second
+
+// MAX_WATCH_TIMEOUT is the maximum timeout of the long polls, the clients repeat them after it
+const MAX_WATCH_TIMEOUT = 60 * time.Second
+
+// watchHeartbeatInterval is the interval of the keep-alive comments of the event streams. The client is read again
+// with every comment, so the changes which haven't been published (e.g. made by the admin commands) are streamed too.
+const watchHeartbeatInterval = 2 * time.Second
+
+// watchRetryInterval is the reconnection time of the event streams recommended to the clients
+const watchRetryInterval = time.Second
+
+func parseWatchTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return DEFAULT_WATCH_TIMEOUT, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 || timeout > MAX_WATCH_TIMEOUT {
+		return 0, fmt.Errorf("timeout must be a positive duration up to %s", MAX_WATCH_TIMEOUT)
+	}
+	return timeout, nil
+}
+
+// parseSinceRevision returns the last revision seen by the watcher, -1 if it's not specified
+func parseSinceRevision(value string) (int64, error) {
+	if value == "" {
+		return -1, nil
+	}
+	revision, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || revision < 0 {
+		return 0, errors.New("sinceRevision must be a non-negative number")
+	}
+	return revision, nil
+}
+
+// watchClient waits until the client revision is greater than the sinceRevision query parameter
+// (the current revision by default) and returns the client like GetClientHandler.
+// The client removed while waiting is not found, 304 is returned if the client hasn't changed until the timeout.
+func (c *ClientsAPIController) watchClient(w http.ResponseWriter, r *http.Request, clientId string) {
+	timeout, err := parseWatchTimeout(r.URL.Query().Get("timeout"))
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sinceRevision, err := parseSinceRevision(r.URL.Query().Get("sinceRevision"))
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := srv.ExtendDeadlines(r, timeout+srv.REQUEST_TIMEOUT); err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	// the watch is started before the first read, so the changes made after the read aren't missed
+	changes, stop := c.clientsWatcher.Watch(clientId)
+	defer stop()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		client, err := c.clientsRepository.GetClientById(r.Context(), clientId)
+		if err != nil {
+			srvErrors.SendInternalError(w, err)
+			return
+		}
+		if client == nil {
+			srvErrors.SendEntityNotFound(w)
+			return
+		}
+		if sinceRevision < 0 {
+			sinceRevision = client.Revision
+		}
+		w.Header().Set("ETag", srv.ETag(client.Revision))
+		if client.Revision > sinceRevision {
+			srv.WriteProtoJsonMessageOrError(w, client, nil)
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-changes:
+		}
+	}
+}
+
+// ClientEventsHandler streams the changes of the client as the server-sent events. The client event contains
+// the client and its revision as the event id, the keys event contains the client keys, the removed event ends the stream.
+// The client event is sent first if the client revision is greater than the Last-Event-ID header
+// or the sinceRevision query parameter, the keys event is always sent first.
+// The stream isn't limited in time, the deadlines of the connection are extended before every heartbeat.
+func (c *ClientsAPIController) ClientEventsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	clientId := params.ByName("clientId")
+	lastEventId := r.Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = r.URL.Query().Get("sinceRevision")
+	}
+	lastRevision, err := parseSinceRevision(lastEventId)
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		srvErrors.SendInternalError(w, errors.New("the response can't be streamed"))
+		return
+	}
+
+	changes, stop := c.clientsWatcher.Watch(clientId)
+	defer stop()
+	client, err := c.clientsRepository.GetClientById(r.Context(), clientId)
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	if client == nil {
+		srvErrors.SendEntityNotFound(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(w, "retry: %d\n\n", watchRetryInterval.Milliseconds())
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+	var keys []string
+	for sentKeys := false; ; sentKeys = true {
+		if client == nil {
+			data, _ := json.Marshal(clientId)
+			writeEvent(w, "removed", "", data)
+			flusher.Flush()
+			return
+		}
+		// the client which doesn't read the events is disconnected by the write deadline
+		if err := srv.ExtendDeadlines(r, watchHeartbeatInterval+srv.REQUEST_TIMEOUT); err != nil {
+			return
+		}
+		if err := c.writeClientEvents(r.Context(), w, client, &lastRevision, &keys, sentKeys); err != nil {
+			log.Printf("can't stream the events of client %s, %v", clientId, err)
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-changes:
+		}
+		if client, err = c.clientsRepository.GetClientById(r.Context(), clientId); err != nil {
+			log.Printf("can't stream the events of client %s, %v", clientId, err)
+			return
+		}
+	}
+}
+
+// writeClientEvents writes the client event if the client revision is greater than the last one
+// and the keys event if the keys of the client have changed or they haven't been sent yet
+func (c *ClientsAPIController) writeClientEvents(ctx context.Context, w io.Writer, client *asit.Client, lastRevision *int64, keys *[]string, sentKeys bool) error {
+	if client.Revision > *lastRevision {
+		data, err := protojson.Marshal(client)
+		if err != nil {
+			return err
+		}
+		if err := writeEvent(w, "client", strconv.FormatInt(client.Revision, 10), data); err != nil {
+			return err
+		}
+		*lastRevision = client.Revision
+	}
+
+	clientKeys, err := c.clientsRepository.GetClientKeys(ctx, client.Id)
+	if err != nil {
+		return err
+	}
+	currentKeys := clientKeys.GetKeys()
+	if currentKeys == nil {
+		currentKeys = []string{}
+	}
+	if sentKeys && slices.Equal(currentKeys, *keys) {
+		return nil
+	}
+	data, err := json.Marshal(currentKeys)
+	if err != nil {
+		return err
+	}
+	*keys = currentKeys
+	return writeEvent(w, "keys", "", data)
+}
+
+// writeEvent writes the server-sent event, the data must not contain new lines
+func writeEvent(w io.Writer, event string, id string, data []byte) error {
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
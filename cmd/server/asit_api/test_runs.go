@@ -0,0 +1,194 @@
+package asit_api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	srv "github.com/derbylock/async-integration-testing/cmd/server/httputils"
+	srvErrors "github.com/derbylock/async-integration-testing/cmd/server/servererrors"
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// MAX_TEST_RUN_REQUEST_SIZE is the max size of the bodies of the requests changing the runs
+const MAX_TEST_RUN_REQUEST_SIZE = 1 << 20
+
+type TestRunsAPIController struct {
+	testRunsRepository   db.TestRunsRepository
+	testSuitesRepository db.TestSuitesRepository
+	clientsRepository    db.ClientsRepository
+	testRunEngine        *db.TestRunEngine
+}
+
+func NewTestRunsAPIController(testRunsRepository db.TestRunsRepository, testSuitesRepository db.TestSuitesRepository,
+	clientsRepository db.ClientsRepository) *TestRunsAPIController {
+	return &TestRunsAPIController{
+		testRunsRepository:   testRunsRepository,
+		testSuitesRepository: testSuitesRepository,
+		clientsRepository:    clientsRepository,
+		testRunEngine:        db.NewTestRunEngine(testRunsRepository),
+	}
+}
+
+func (c *TestRunsAPIController) InitRoutes(pathPrefix string, router *httprouter.Router) {
+	router.GET(pathPrefix+"/runs", c.GetTestRunsHandler)
+	router.POST(pathPrefix+"/runs", c.StartTestRunHandler)
+	router.GET(pathPrefix+"/runs/:runId", c.GetTestRunHandler)
+	router.POST(pathPrefix+"/runs/:runId/steps/:stepId/status", c.TransitionTestStepHandler)
+	router.POST(pathPrefix+"/runs/:runId/steps/:stepId/result", c.ReportActionResultHandler)
+}
+
+// GetTestRunsHandler returns the runs (without the suite snapshot and the state) ordered by id,
+// the clientId query parameter returns the runs of the client only. The pagination is the same as in GetAllClientsHandler.
+func (c *TestRunsAPIController) GetTestRunsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	cursor, limit, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	runs, nextCursor, err := c.testRunsRepository.GetTestRuns(r.Context(), r.URL.Query().Get("clientId"), cursor, limit)
+	setNextCursor(w, nextCursor)
+	srv.WriteProtoArrayJsonMessageOrError(w, runs, err)
+}
+
+// StartTestRunHandler starts the run of the suite for the client specified by its id or by one of its keys
+func (c *TestRunsAPIController) StartTestRunHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var request asit.StartTestRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		srvErrors.SendInvalidJSON(w, err)
+		return
+	}
+	if request.TestSuiteId == "" {
+		srvErrors.RenderError(w, "testSuiteId is required", http.StatusBadRequest)
+		return
+	}
+	if (request.ClientId == "") == (request.ClientKey == "") {
+		srvErrors.RenderError(w, "either clientId or clientKey is required", http.StatusBadRequest)
+		return
+	}
+
+	var client *asit.Client
+	var err error
+	if request.ClientId != "" {
+		client, err = c.clientsRepository.GetClientById(r.Context(), request.ClientId)
+	} else {
+		client, err = c.clientsRepository.GetClientByKey(r.Context(), request.ClientKey)
+	}
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	if client == nil {
+		srvErrors.RenderError(w, "client not found", http.StatusNotFound)
+		return
+	}
+	suite, err := c.testSuitesRepository.GetTestSuite(r.Context(), request.TestSuiteId)
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	if suite == nil {
+		srvErrors.RenderError(w, "test suite not found", http.StatusNotFound)
+		return
+	}
+	if len(suite.Tests) == 0 {
+		srvErrors.RenderError(w, "test suite has no test cases", http.StatusBadRequest)
+		return
+	}
+
+	newId, err := uuid.NewUUID()
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	run := db.NewTestRun(newId.String(), suite, client)
+	err = c.testRunsRepository.CreateTestRun(r.Context(), run)
+	srv.WriteProtoJsonMessageOrError(w, run, err)
+}
+
+func (c *TestRunsAPIController) GetTestRunHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	run, err := c.testRunsRepository.GetTestRun(r.Context(), params.ByName("runId"))
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	if run == nil {
+		srvErrors.SendEntityNotFound(w)
+		return
+	}
+	srv.WriteProtoJsonMessageOrError(w, run, nil)
+}
+
+// TransitionTestStepHandler changes the status of the current step of the run and returns the updated run,
+// the illegal transitions and the stale claim attempts of the claimed steps are rejected with 409, see db.TransitionTestStep
+func (c *TestRunsAPIController) TransitionTestStepHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var transition asit.TestStepRun
+	if err := decodeProtoJson(r, &transition); err != nil {
+		srvErrors.SendInvalidJSON(w, err)
+		return
+	}
+	if len(transition.StatusDescription) > db.MAX_TEST_DESCRIPTION_SIZE {
+		srvErrors.RenderError(w, fmt.Sprintf("statusDescription is longer than %d bytes", db.MAX_TEST_DESCRIPTION_SIZE), http.StatusBadRequest)
+		return
+	}
+	run, err := c.testRunEngine.TransitionStep(r.Context(), params.ByName("runId"), params.ByName("stepId"),
+		transition.ClaimAttempt, transition.Status, transition.StatusDescription)
+	if sendTestRunError(w, err) {
+		return
+	}
+	srv.WriteProtoJsonMessageOrError(w, run, err)
+}
+
+// ReportActionResultHandler finishes the started action of the step with the result reported by the agent
+// of its last claim, verifies the step by its checks and returns the updated run, see db.ApplyTestActionResult
+func (c *TestRunsAPIController) ReportActionResultHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var result asit.TestActionResult
+	if err := decodeProtoJson(r, &result); err != nil {
+		srvErrors.SendInvalidJSON(w, err)
+		return
+	}
+	if err := db.ValidateTestActionResult(&result); err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	run, err := c.testRunEngine.ReportActionResult(r.Context(), params.ByName("runId"), params.ByName("stepId"), &result)
+	if sendTestRunError(w, err) {
+		return
+	}
+	srv.WriteProtoJsonMessageOrError(w, run, err)
+}
+
+// decodeProtoJson decodes the body with the names of the enum values, which aren't supported by encoding/json
+func decodeProtoJson(r *http.Request, m proto.Message) error {
+	data, err := io.ReadAll(io.LimitReader(r.Body, MAX_TEST_RUN_REQUEST_SIZE+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > MAX_TEST_RUN_REQUEST_SIZE {
+		return fmt.Errorf("request body is larger than %d bytes", MAX_TEST_RUN_REQUEST_SIZE)
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, m)
+}
+
+// sendTestRunError sends the response for the illegal transitions and the not found errors and returns true if it's sent
+func sendTestRunError(w http.ResponseWriter, err error) bool {
+	var illegalErr *db.IllegalTestStepTransitionError
+	var notFoundRunErr *db.NotFoundTestRunError
+	var notFoundStepErr *db.NotFoundTestStepRunError
+	switch {
+	case errors.As(err, &illegalErr):
+		srvErrors.SendConflictError(w, illegalErr)
+	case errors.As(err, &notFoundRunErr), errors.As(err, &notFoundStepErr):
+		srvErrors.SendEntityNotFound(w)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,231 @@
+package asit_api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	srv "github.com/derbylock/async-integration-testing/cmd/server/httputils"
+	srvErrors "github.com/derbylock/async-integration-testing/cmd/server/servererrors"
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+type TestSuitesAPIController struct {
+	testSuitesRepository db.TestSuitesRepository
+}
+
+func NewTestSuitesAPIController(testSuitesRepository db.TestSuitesRepository) *TestSuitesAPIController {
+	return &TestSuitesAPIController{testSuitesRepository: testSuitesRepository}
+}
+
+func (c *TestSuitesAPIController) InitRoutes(pathPrefix string, router *httprouter.Router) {
+	router.GET(pathPrefix+"/suites", c.GetTestSuitesHandler)
+	router.POST(pathPrefix+"/suites", c.AddTestSuiteHandler)
+	router.GET(pathPrefix+"/suites/:suiteId", c.GetTestSuiteHandler)
+	router.PUT(pathPrefix+"/suites/:suiteId", c.UpdateTestSuiteHandler)
+	router.DELETE(pathPrefix+"/suites/:suiteId", c.DeleteTestSuiteHandler)
+
+	router.GET(pathPrefix+"/suites/:suiteId/cases", c.GetTestCasesHandler)
+	router.POST(pathPrefix+"/suites/:suiteId/cases", c.AddTestCaseHandler)
+	router.GET(pathPrefix+"/suites/:suiteId/cases/:caseId", c.GetTestCaseHandler)
+	router.PUT(pathPrefix+"/suites/:suiteId/cases/:caseId", c.UpdateTestCaseHandler)
+	router.DELETE(pathPrefix+"/suites/:suiteId/cases/:caseId", c.DeleteTestCaseHandler)
+}
+
+// GetTestSuitesHandler returns the test suites without their test cases ordered by id,
+// the pagination is the same as in GetAllClientsHandler
+func (c *TestSuitesAPIController) GetTestSuitesHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	cursor, limit, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		srvErrors.RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	suites, nextCursor, err := c.testSuitesRepository.GetTestSuites(r.Context(), cursor, limit)
+	setNextCursor(w, nextCursor)
+	srv.WriteProtoArrayJsonMessageOrError(w, suites, err)
+}
+
+// AddTestSuiteHandler creates the suite with the new id, the test cases and the steps without ids get the new ids too
+func (c *TestSuitesAPIController) AddTestSuiteHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var suite asit.TestSuite
+	if err := json.NewDecoder(r.Body).Decode(&suite); err != nil {
+		srvErrors.SendInvalidJSON(w, err)
+		return
+	}
+
+	newId, err := uuid.NewUUID()
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	suite.Id = newId.String()
+	c.setTestSuite(w, r, &suite)
+}
+
+// UpdateTestSuiteHandler creates or replaces the suite with the id from the path
+func (c *TestSuitesAPIController) UpdateTestSuiteHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var suite asit.TestSuite
+	if err := json.NewDecoder(r.Body).Decode(&suite); err != nil {
+		srvErrors.SendInvalidJSON(w, err)
+		return
+	}
+	suite.Id = params.ByName("suiteId")
+	c.setTestSuite(w, r, &suite)
+}
+
+func (c *TestSuitesAPIController) setTestSuite(w http.ResponseWriter, r *http.Request, suite *asit.TestSuite) {
+	for _, testCase := range suite.Tests {
+		if testCase == nil {
+			continue
+		}
+		if err := fillTestCaseIds(testCase); err != nil {
+			srvErrors.SendInternalError(w, err)
+			return
+		}
+	}
+	err := c.testSuitesRepository.SetTestSuite(r.Context(), suite)
+	if sendTestSuiteError(w, err) {
+		return
+	}
+	srv.WriteProtoJsonMessageOrError(w, suite, err)
+}
+
+func (c *TestSuitesAPIController) GetTestSuiteHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	suite, ok := c.getTestSuite(w, r, params.ByName("suiteId"))
+	if !ok {
+		return
+	}
+	srv.WriteProtoJsonMessageOrError(w, suite, nil)
+}
+
+// getTestSuite sends the error response and returns false if the suite can't be found
+func (c *TestSuitesAPIController) getTestSuite(w http.ResponseWriter, r *http.Request, suiteId string) (*asit.TestSuite, bool) {
+	suite, err := c.testSuitesRepository.GetTestSuite(r.Context(), suiteId)
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return nil, false
+	}
+	if suite == nil {
+		srvErrors.SendEntityNotFound(w)
+		return nil, false
+	}
+	return suite, true
+}
+
+func (c *TestSuitesAPIController) DeleteTestSuiteHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	err := c.testSuitesRepository.RemoveTestSuite(r.Context(), params.ByName("suiteId"))
+	if sendTestSuiteError(w, err) {
+		return
+	}
+	srv.WriteNoContentOrError(w, err)
+}
+
+func (c *TestSuitesAPIController) GetTestCasesHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	suite, ok := c.getTestSuite(w, r, params.ByName("suiteId"))
+	if !ok {
+		return
+	}
+	srv.WriteProtoArrayJsonMessageOrError(w, suite.Tests, nil)
+}
+
+// AddTestCaseHandler adds the test case with the new id to the end of the suite
+func (c *TestSuitesAPIController) AddTestCaseHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var testCase asit.TestCase
+	if err := json.NewDecoder(r.Body).Decode(&testCase); err != nil {
+		srvErrors.SendInvalidJSON(w, err)
+		return
+	}
+
+	newId, err := uuid.NewUUID()
+	if err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	testCase.Id = newId.String()
+	c.setTestCase(w, r, params.ByName("suiteId"), &testCase)
+}
+
+// UpdateTestCaseHandler replaces the test case with the id from the path or adds it to the end of the suite
+func (c *TestSuitesAPIController) UpdateTestCaseHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var testCase asit.TestCase
+	if err := json.NewDecoder(r.Body).Decode(&testCase); err != nil {
+		srvErrors.SendInvalidJSON(w, err)
+		return
+	}
+	testCase.Id = params.ByName("caseId")
+	c.setTestCase(w, r, params.ByName("suiteId"), &testCase)
+}
+
+func (c *TestSuitesAPIController) setTestCase(w http.ResponseWriter, r *http.Request, suiteId string, testCase *asit.TestCase) {
+	if err := fillTestCaseIds(testCase); err != nil {
+		srvErrors.SendInternalError(w, err)
+		return
+	}
+	_, err := c.testSuitesRepository.SetTestCase(r.Context(), suiteId, testCase)
+	if sendTestSuiteError(w, err) {
+		return
+	}
+	srv.WriteProtoJsonMessageOrError(w, testCase, err)
+}
+
+func (c *TestSuitesAPIController) GetTestCaseHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	suite, ok := c.getTestSuite(w, r, params.ByName("suiteId"))
+	if !ok {
+		return
+	}
+	testCase := db.FindTestCase(suite, params.ByName("caseId"))
+	if testCase == nil {
+		srvErrors.SendEntityNotFound(w)
+		return
+	}
+	srv.WriteProtoJsonMessageOrError(w, testCase, nil)
+}
+
+func (c *TestSuitesAPIController) DeleteTestCaseHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	err := c.testSuitesRepository.RemoveTestCase(r.Context(), params.ByName("suiteId"), params.ByName("caseId"))
+	if sendTestSuiteError(w, err) {
+		return
+	}
+	srv.WriteNoContentOrError(w, err)
+}
+
+// fillTestCaseIds sets the new ids of the test case and its steps if they are empty
+func fillTestCaseIds(testCase *asit.TestCase) error {
+	if testCase.Id == "" {
+		newId, err := uuid.NewUUID()
+		if err != nil {
+			return err
+		}
+		testCase.Id = newId.String()
+	}
+	for _, step := range testCase.Steps {
+		if step == nil || step.Id != "" {
+			continue
+		}
+		newId, err := uuid.NewUUID()
+		if err != nil {
+			return err
+		}
+		step.Id = newId.String()
+	}
+	return nil
+}
+
+// sendTestSuiteError sends the response for the validation and the not found errors and returns true if it's sent
+func sendTestSuiteError(w http.ResponseWriter, err error) bool {
+	var invalidErr *db.InvalidTestSuiteError
+	if errors.As(err, &invalidErr) {
+		srvErrors.RenderError(w, invalidErr.Error(), http.StatusBadRequest)
+		return true
+	}
+	var notFoundSuiteErr *db.NotFoundTestSuiteError
+	var notFoundCaseErr *db.NotFoundTestCaseError
+	if errors.As(err, &notFoundSuiteErr) || errors.As(err, &notFoundCaseErr) {
+		srvErrors.SendEntityNotFound(w)
+		return true
+	}
+	return false
+}
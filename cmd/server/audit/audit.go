@@ -0,0 +1,32 @@
+package audit
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/derbylock/async-integration-testing/cmd/server/requestlogger"
+	"github.com/derbylock/async-integration-testing/internal/db"
+)
+
+// CallerHeaderName is the header identifying the caller in the history of the changed clients
+const CallerHeaderName = "X-ASIT-CALLER"
+
+// Handler records the request id and the caller in the history of the clients changed by the request.
+// The caller is identified by the CallerHeaderName header or by the remote address if the header is missing.
+// It must be wrapped by the requestlogger.Logger, which generates the request ids.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller := r.Header.Get(CallerHeaderName)
+		if caller == "" {
+			caller = r.RemoteAddr
+			if i := strings.LastIndex(caller, ":"); i != -1 {
+				caller = caller[:i]
+			}
+		}
+		ctx := db.WithAuditInfo(r.Context(), db.AuditInfo{
+			RequestId: requestlogger.RequestId(r.Context()),
+			Caller:    caller,
+		})
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,30 @@
+package httputils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// REQUEST_TIMEOUT is the read and write timeout of the requests, the watches extend it by ExtendDeadlines
+const REQUEST_TIMEOUT = 10 * time.Second
+
+type connContextKey struct{}
+
+// ConnContext keeps the connection in the request context for ExtendDeadlines, it's used as http.Server.ConnContext
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
+
+// ExtendDeadlines moves the read and write deadlines of the request connection to the timeout from now,
+// so the long polls and the event streams aren't interrupted by the timeouts of the server.
+// The server resets the deadlines before the next request of the connection.
+// It does nothing if the server doesn't keep the connections by ConnContext.
+func ExtendDeadlines(r *http.Request, timeout time.Duration) error {
+	conn, ok := r.Context().Value(connContextKey{}).(net.Conn)
+	if !ok {
+		return nil
+	}
+	return conn.SetDeadline(time.Now().Add(timeout))
+}
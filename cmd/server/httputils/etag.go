@@ -0,0 +1,42 @@
+package httputils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ETag returns the strong entity tag of the entity revision
+func ETag(revision int64) string {
+	return `"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+// ETagMatches checks if the If-None-Match header value contains the entity tag.
+// The weak comparison is used, so the weak tags match the strong tags with the same value.
+func ETagMatches(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatchRevision returns the revision required by the If-Match header value, anyRevision is returned for the empty value or "*".
+// anyRevision must not match the missing entity, because "*" doesn't match it (RFC 9110).
+// Only a single strong entity tag created by ETag is supported, because the revision is checked by the storage.
+func IfMatchRevision(header string, anyRevision int64) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return anyRevision, nil
+	}
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, errors.New("If-Match must contain a single strong entity tag")
+	}
+	revision, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || revision < 0 {
+		return 0, errors.New("If-Match contains an unknown entity tag")
+	}
+	return revision, nil
+}
@@ -1,6 +1,7 @@
 package requestlogger
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -40,6 +41,12 @@ var requestCounter uint64
 var RequestIdLoggingKey = RequestLoggingKey{Name: "rid"}
 var baseRequestIdPrefix = uuid.New().String()
 
+// RequestId returns the id of the request handled by the Logger
+func RequestId(ctx context.Context) string {
+	requestId, _ := ctx.Value(RequestIdLoggingKey).(string)
+	return requestId
+}
+
 func Logger(out io.Writer, h http.Handler) http.Handler {
 	logger := log.New(out, "", 0)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -50,7 +57,7 @@ func Logger(out io.Writer, h http.Handler) http.Handler {
 		}
 
 		o := &responseObserver{ResponseWriter: w, requestId: requestId}
-		h.ServeHTTP(o, r)
+		h.ServeHTTP(o, r.WithContext(context.WithValue(r.Context(), RequestIdLoggingKey, requestId)))
 		addr := r.RemoteAddr
 		if i := strings.LastIndex(addr, ":"); i != -1 {
 			addr = addr[:i]
@@ -110,6 +117,13 @@ func (o *responseObserver) WriteHeader(code int) {
 	o.status = code
 }
 
+// Flush allows streaming the responses, e.g. the server-sent events
+func (o *responseObserver) Flush() {
+	if flusher, ok := o.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func trunc(str string, maxlen int) string {
 	return stringutils.TruncateStart(str, maxlen, "...")
 }
@@ -50,3 +50,10 @@ func SendConflictError(w http.ResponseWriter, err error) {
 	}
 	w.WriteHeader(http.StatusConflict)
 }
+
+func SendPreconditionFailed(w http.ResponseWriter, err error) {
+	if err != nil {
+		w.Header().Set(ErrorHeaderName, err.Error())
+	}
+	w.WriteHeader(http.StatusPreconditionFailed)
+}
@@ -0,0 +1,294 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	boltValuesBucket  = []byte("values")
+	boltIndexesBucket = []byte("indexes")
+	// boltExpiresBucket keeps the expiration unix nanos of the values with TTL
+	boltExpiresBucket = []byte("expires")
+)
+
+// BoltStorage is a Storage implementation backed by the embedded bbolt key-value file.
+// It is intended for single-node deployments, the file can't be shared between several ASIT instances.
+type BoltStorage struct {
+	db    *bolt.DB
+	codec StorageCodec
+}
+
+func NewBoltStorage(path string, codec StorageCodec) (*BoltStorage, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("can't open bolt db %s, %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{boltValuesBucket, boltIndexesBucket, boltExpiresBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("can't initialize bolt db %s, %w", path, err)
+	}
+	return &BoltStorage{db: db, codec: codec}, nil
+}
+
+func (s *BoltStorage) Set(ctx context.Context, k string, v proto.Message) error {
+	return s.SetWithTTL(ctx, k, v, 0)
+}
+
+// SetWithTTL keeps the expired values in the file until their keys are written again
+func (s *BoltStorage) SetWithTTL(ctx context.Context, k string, v proto.Message, ttl time.Duration) error {
+	bytes, err := marshalValue(s.codec, k, v)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return boltPut(tx, k, bytes, ttl)
+	})
+}
+
+func (s *BoltStorage) Get(ctx context.Context, k string, v proto.Message) (found bool, err error) {
+	var bytes []byte
+	err = s.db.View(func(tx *bolt.Tx) error {
+		bytes, found = boltGet(tx, k)
+		return nil
+	})
+	if err != nil || !found {
+		return false, err
+	}
+
+	err = unmarshalValue(s.codec, k, bytes, v)
+	return true, err
+}
+
+func (s *BoltStorage) Delete(ctx context.Context, k ...string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, key := range k {
+			if err := boltDelete(tx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStorage) Close() error {
+	return s.db.Close()
+}
+
+// SetAndDeleteAtomically uses the same optimistic locking as the redis implementation does:
+// the updaters are called outside of the bolt write transaction with the previously read values,
+// and the changes are committed only if the locked keys still have the same values.
+// It keeps the single bolt writer free while the updaters are running.
+func (s *BoltStorage) SetAndDeleteAtomically(ctx context.Context, lockedSets []SetValueCommand, lockedDeleteKeys []string, unlockedSets func() []SetValueUnlockedCommand, unlockedDeleteKeys func() []string, indexUpdates func() []IndexCommand) error {
+	allKeys := make([]string, 0, len(lockedDeleteKeys)+len(lockedSets))
+	allKeys = append(allKeys, lockedDeleteKeys...)
+	for _, set := range lockedSets {
+		allKeys = append(allKeys, set.key)
+	}
+
+	for i := 0; i < maxRetries; i++ {
+		watched := make([][]byte, len(allKeys))
+		watchedFound := make([]bool, len(allKeys))
+		err := s.db.View(func(tx *bolt.Tx) error {
+			for j, key := range allKeys {
+				watched[j], watchedFound[j] = boltGet(tx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		type write struct {
+			key    string
+			bytes  []byte
+			ttl    time.Duration
+			delete bool
+		}
+		var writes []write
+		for j, set := range lockedSets {
+			oldIndex := len(lockedDeleteKeys) + j
+			requiresUpdate, newVal, err := set.updater(func(m proto.Message) (bool, error) {
+				if !watchedFound[oldIndex] {
+					return false, nil
+				}
+				return true, unmarshalValue(s.codec, set.key, watched[oldIndex], m)
+			})
+			if err != nil {
+				return err
+			}
+			if !requiresUpdate {
+				continue
+			}
+			if newVal == nil {
+				writes = append(writes, write{key: set.key, delete: true})
+				continue
+			}
+			newValBytes, err := marshalValue(s.codec, set.key, newVal)
+			if err != nil {
+				return err
+			}
+			writes = append(writes, write{key: set.key, bytes: newValBytes, ttl: set.ttl})
+		}
+		for _, deleteKey := range lockedDeleteKeys {
+			writes = append(writes, write{key: deleteKey, delete: true})
+		}
+		for _, set := range unlockedSets() {
+			newValBytes, err := marshalValue(s.codec, set.key, set.newValue)
+			if err != nil {
+				return err
+			}
+			writes = append(writes, write{key: set.key, bytes: newValBytes, ttl: set.ttl})
+		}
+		for _, deleteKey := range unlockedDeleteKeys() {
+			writes = append(writes, write{key: deleteKey, delete: true})
+		}
+		var indexCmds []IndexCommand
+		if indexUpdates != nil {
+			indexCmds = indexUpdates()
+		}
+
+		changed := false
+		err = s.db.Update(func(tx *bolt.Tx) error {
+			for j, key := range allKeys {
+				current, found := boltGet(tx, key)
+				if found != watchedFound[j] || !bytes.Equal(current, watched[j]) {
+					changed = true
+					return nil
+				}
+			}
+			for _, w := range writes {
+				var err error
+				if w.delete {
+					err = boltDelete(tx, w.key)
+				} else {
+					err = boltPut(tx, w.key, w.bytes, w.ttl)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return boltUpdateIndexes(tx, indexCmds)
+		})
+		if err != nil {
+			return err
+		}
+		if changed {
+			// Optimistic lock lost. Retry.
+			continue
+		}
+		return nil
+	}
+
+	return &ConcurrentUpdateError{keys: allKeys}
+}
+
+// IndexRange keeps every index in a nested bucket, bolt keys are already sorted
+func (s *BoltStorage) IndexRange(ctx context.Context, index string, after string, limit int) ([]string, error) {
+	members := []string{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltIndexesBucket).Bucket([]byte(index))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		key, _ := cursor.Seek([]byte(after))
+		if key != nil && string(key) == after {
+			key, _ = cursor.Next()
+		}
+		for ; key != nil && (limit <= 0 || len(members) < limit); key, _ = cursor.Next() {
+			members = append(members, string(key))
+		}
+		return nil
+	})
+	return members, err
+}
+
+// ScanKeys seeks the first key with the prefix, bolt keys are already sorted
+func (s *BoltStorage) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltValuesBucket).Cursor()
+		for key, _ := cursor.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, _ = cursor.Next() {
+			if _, ok := boltGet(tx, string(key)); ok {
+				keys = append(keys, string(key))
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func boltUpdateIndexes(tx *bolt.Tx, cmds []IndexCommand) error {
+	indexes := tx.Bucket(boltIndexesBucket)
+	for _, cmd := range cmds {
+		if cmd.remove {
+			bucket := indexes.Bucket([]byte(cmd.index))
+			if bucket == nil {
+				continue
+			}
+			if err := bucket.Delete([]byte(cmd.member)); err != nil {
+				return err
+			}
+			continue
+		}
+		bucket, err := indexes.CreateBucketIfNotExists([]byte(cmd.index))
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte(cmd.member), []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boltGet returns a copy of the value, because bolt values are valid only during the transaction.
+// It distinguishes missing keys from the keys with empty values, the expired values are missing.
+func boltGet(tx *bolt.Tx, k string) ([]byte, bool) {
+	if expires := tx.Bucket(boltExpiresBucket).Get([]byte(k)); expires != nil &&
+		int64(binary.BigEndian.Uint64(expires)) <= time.Now().UnixNano() {
+		return nil, false
+	}
+	key, value := tx.Bucket(boltValuesBucket).Cursor().Seek([]byte(k))
+	if key == nil || string(key) != k {
+		return nil, false
+	}
+	return append([]byte{}, value...), true
+}
+
+func boltPut(tx *bolt.Tx, k string, v []byte, ttl time.Duration) error {
+	if v == nil {
+		// empty messages are marshalled to nil
+		v = []byte{}
+	}
+	if err := tx.Bucket(boltValuesBucket).Put([]byte(k), v); err != nil {
+		return err
+	}
+	expires := tx.Bucket(boltExpiresBucket)
+	if ttl <= 0 {
+		return expires.Delete([]byte(k))
+	}
+	return expires.Put([]byte(k), binary.BigEndian.AppendUint64(nil, uint64(time.Now().Add(ttl).UnixNano())))
+}
+
+func boltDelete(tx *bolt.Tx, k string) error {
+	if err := tx.Bucket(boltValuesBucket).Delete([]byte(k)); err != nil {
+		return err
+	}
+	return tx.Bucket(boltExpiresBucket).Delete([]byte(k))
+}
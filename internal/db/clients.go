@@ -3,6 +3,8 @@ package db
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/derbylock/async-integration-testing/pkg/asit"
@@ -12,26 +14,96 @@ import (
 )
 
 const (
-	KEY_ALL_CLIENTS        = "all_clients"
-	KEY_CLIENT_PREFIX      = "client:"
-	KEY_CLIENT_KEY_PREFIX  = "client_key:"
-	KEY_CLIENT_KEYS_PREFIX = "client_keys:"
+	// KEY_ALL_CLIENTS is the legacy list of all the clients, it's migrated to the KEY_CLIENTS_INDEX automatically
+	KEY_ALL_CLIENTS                   = "all_clients"
+	KEY_CLIENTS_INDEX                 = "clients_index"
+	KEY_CLIENTS_BY_NAME_INDEX         = "clients_by_name_index"
+	KEY_CLIENTS_BY_LAST_UPDATED_INDEX = "clients_by_last_updated_index"
+	KEY_CLIENT_PREFIX                 = "client:"
+	KEY_CLIENT_KEY_PREFIX             = "client_key:"
+	KEY_CLIENT_KEYS_PREFIX            = "client_keys:"
 )
 
+// kvClientsIndexes are the indexes used by the sort orders of ClientsQuery
+var kvClientsIndexes = map[string]string{
+	CLIENTS_SORT_BY_ID:           KEY_CLIENTS_INDEX,
+	CLIENTS_SORT_BY_NAME:         KEY_CLIENTS_BY_NAME_INDEX,
+	CLIENTS_SORT_BY_LAST_UPDATED: KEY_CLIENTS_BY_LAST_UPDATED_INDEX,
+}
+
 type ClientsRepository interface {
 	GetAllClients(ctx context.Context) ([]*asit.Client, error)
+	// GetClients returns up to limit clients (without client properties) ordered by id and starting after the cursor.
+	// The empty cursor starts from the first client. The returned next cursor is empty if there are no more clients.
+	GetClients(ctx context.Context, cursor string, limit int) (clients []*asit.Client, nextCursor string, err error)
+	// QueryClients returns a page of the sorted and filtered clients (without client properties).
+	// The returned next cursor is empty if there are no more clients. It returns InvalidClientsQueryError for the invalid queries.
+	QueryClients(ctx context.Context, query ClientsQuery) (clients []*asit.Client, nextCursor string, err error)
 	GetClientById(ctx context.Context, id string) (*asit.Client, error)
+	// SetClient creates or updates the client and increments its revision
 	SetClient(ctx context.Context, client *asit.Client) error
+	// SetClientIfRevision updates the client only if its current revision is equal to the specified one.
+	// It returns ClientRevisionMismatchError otherwise and NotFoundClientByIdError if the client doesn't exist.
+	// ANY_CLIENT_REVISION disables the check, so the missing client is created.
+	// EXISTING_CLIENT_REVISION matches any revision, but returns ClientRevisionMismatchError if the client doesn't exist.
+	SetClientIfRevision(ctx context.Context, client *asit.Client, revision int64) error
+	// PatchClient atomically applies the patch to the client if its current revision is equal to the specified one
+	// and returns the updated client. The errors are the same as the SetClientIfRevision ones.
+	PatchClient(ctx context.Context, clientId string, patch ClientPatch, revision int64) (*asit.Client, error)
+	// RemoveClient moves the client to the trash. Keys of the trashed client stay reserved until it's purged.
 	RemoveClient(ctx context.Context, clientId string) error
+	// RemoveClientIfRevision moves the client to the trash only if its current revision is equal to the specified one,
+	// the errors are the same as the SetClientIfRevision ones.
+	RemoveClientIfRevision(ctx context.Context, clientId string, revision int64) error
 
+	// GetTrashedClients returns up to limit trashed clients ordered by id and starting after the cursor
+	GetTrashedClients(ctx context.Context, cursor string, limit int) (clients []*asit.TrashedClient, nextCursor string, err error)
+	GetTrashedClientById(ctx context.Context, id string) (*asit.TrashedClient, error)
+	// RestoreClient moves the client from the trash back with all its keys and returns the restored client.
+	// It returns NotFoundClientByIdError if the client isn't in the trash.
+	RestoreClient(ctx context.Context, clientId string) (*asit.Client, error)
+	// PurgeClient permanently removes the trashed client and releases its keys.
+	// It returns NotFoundClientByIdError if the client isn't in the trash.
+	PurgeClient(ctx context.Context, clientId string) error
+	// PurgeTrash purges the clients trashed before the specified time and returns the number of the purged clients
+	PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, error)
+
+	// GetClientKeys returns the keys of the client, the expired keys are not returned
 	GetClientKeys(ctx context.Context, clientId string) (*asit.ClientKeys, error)
 	AddClientKey(ctx context.Context, clientId string, key string) error
+	// AddClientKeyWithTTL associates the key with the client until the ttl passes, the ttl <= 0 means no expiry.
+	// Adding the key which is already associated with the client replaces its expiration time.
+	AddClientKeyWithTTL(ctx context.Context, clientId string, key string, ttl time.Duration) error
+	// GetClientByKey returns nil for the expired keys
 	GetClientByKey(ctx context.Context, key string) (*asit.Client, error)
 	RemoveClientKey(ctx context.Context, key string) error
+	// ExpireClientKeys removes the keys expired before now from the keys of their clients
+	// and returns the number of the removed keys, the expirations are recorded in the client history
+	ExpireClientKeys(ctx context.Context, now time.Time) (int, error)
+
+	// GetClientHistory returns up to limit entries of the client history in the order of the changes starting after the cursor.
+	// The history is kept after the client is purged. It returns InvalidClientsQueryError for the malformed cursor.
+	GetClientHistory(ctx context.Context, clientId string, cursor string, limit int) (entries []*asit.ClientHistoryEntry, nextCursor string, err error)
+	// GetClientRevision returns the client value of the revision from the client history or nil if the history doesn't contain it
+	GetClientRevision(ctx context.Context, clientId string, revision int64) (*asit.Client, error)
+	// RollbackClient sets the name and the client properties of the client to the values of the revision
+	// if the current revision is equal to ifRevision. The client keys are not changed.
+	// It returns NotFoundClientRevisionError if the history doesn't contain the revision,
+	// other errors are the same as the SetClientIfRevision ones.
+	RollbackClient(ctx context.Context, clientId string, revision int64, ifRevision int64) (*asit.Client, error)
+
+	// CheckConsistency scans the stored clients and reports the inconsistencies of the values describing them.
+	// Every inconsistency is repaired atomically if repair is true. The scan isn't atomic, so the concurrent changes
+	// may be reported, but they are checked again before the repair and the actual values aren't overwritten.
+	CheckConsistency(ctx context.Context, repair bool) (*asit.ClientsConsistencyReport, error)
 }
 
 type KVClientsRepository struct {
 	storage Storage
+	// indexMigrated is set to 1 when the data of the previous ASIT versions is migrated to the current indexes
+	indexMigrated int32
+	// clientKeysHMACKey is the key of the client key hashes in the db key names, nil keeps the plain client keys
+	clientKeysHMACKey []byte
 }
 
 func NewKVClientsRepository(store Storage) *KVClientsRepository {
@@ -40,17 +112,251 @@ func NewKVClientsRepository(store Storage) *KVClientsRepository {
 	}
 }
 
+// NewKVClientsRepositoryWithHashedKeys creates the repository which replaces the client keys in the db key names,
+// e.g. client_key:<key>, with their HMAC-SHA256 hashes, so the keys like tokens aren't readable from the key names.
+// The hmacKey can't be changed without the loss of the key aliases. The aliases of the existing keys are moved
+// to the hashed names by CheckConsistency with repair and by RewriteValues.
+func NewKVClientsRepositoryWithHashedKeys(store Storage, hmacKey []byte) *KVClientsRepository {
+	return &KVClientsRepository{
+		storage:           store,
+		clientKeysHMACKey: hmacKey,
+	}
+}
+
+// Storage returns the storage of the clients, it's shared with the test suites and runs repositories
+func (r *KVClientsRepository) Storage() Storage {
+	return r.storage
+}
+
+// clientKeyId returns the client key or its hash if the key names are hashed
+func (r *KVClientsRepository) clientKeyId(key string) string {
+	if r.clientKeysHMACKey == nil {
+		return key
+	}
+	return hashClientKey(r.clientKeysHMACKey, key)
+}
+
+func (r *KVClientsRepository) clientKeyName(key string) string {
+	return KEY_CLIENT_KEY_PREFIX + r.clientKeyId(key)
+}
+
+func (r *KVClientsRepository) reservedClientKeyName(key string) string {
+	return KEY_RESERVED_CLIENT_KEY_PREFIX + r.clientKeyId(key)
+}
+
+// allClientsPageSize is the number of clients read at once by GetAllClients
+const allClientsPageSize = 1000
+
 func (r *KVClientsRepository) GetAllClients(ctx context.Context) ([]*asit.Client, error) {
-	clients := &asit.ClientList{}
-	ok, err := r.storage.Get(ctx, KEY_ALL_CLIENTS, clients)
+	allClients := []*asit.Client{}
+	cursor := ""
+	for {
+		clients, nextCursor, err := r.GetClients(ctx, cursor, allClientsPageSize)
+		if err != nil {
+			return nil, err
+		}
+		allClients = append(allClients, clients...)
+		if nextCursor == "" {
+			return allClients, nil
+		}
+		cursor = nextCursor
+	}
+}
+
+func (r *KVClientsRepository) GetClients(ctx context.Context, cursor string, limit int) ([]*asit.Client, string, error) {
+	return r.QueryClients(ctx, ClientsQuery{Cursor: cursor, Limit: limit})
+}
+
+// QueryClients walks the index of the requested sort order and filters the clients read from it.
+// The next cursor is the last checked index member, so the filtered out clients aren't checked again by the next page.
+func (r *KVClientsRepository) QueryClients(ctx context.Context, query ClientsQuery) ([]*asit.Client, string, error) {
+	if err := query.validate(); err != nil {
+		return nil, "", err
+	}
+	if err := r.migrateIndexes(ctx); err != nil {
+		return nil, "", err
+	}
+
+	index := kvClientsIndexes[query.sortBy()]
+	after := query.Cursor
+	// names index members start with the name, so the clients with the name prefix are stored sequentially
+	prefixSearch := query.sortBy() == CLIENTS_SORT_BY_NAME && query.NamePrefix != ""
+	if prefixSearch && after < query.NamePrefix {
+		after = query.NamePrefix
+	}
+	batchSize := query.Limit
+	if batchSize <= 0 || batchSize > allClientsPageSize {
+		batchSize = allClientsPageSize
+	}
+
+	clients := []*asit.Client{}
+	for {
+		members, err := r.storage.IndexRange(ctx, index, after, batchSize)
+		if err != nil {
+			return nil, "", fmt.Errorf("can't retrieve db index %s, %w", index, err)
+		}
+		for _, member := range members {
+			if prefixSearch && !strings.HasPrefix(member, query.NamePrefix) {
+				return clients, "", nil
+			}
+			after = member
+			client, err := r.GetClientById(ctx, kvClientsIndexMemberId(member))
+			if err != nil {
+				return nil, "", err
+			}
+			if client == nil || !query.matches(client) {
+				// removed concurrently or filtered out
+				continue
+			}
+			client.ClientProperties = nil
+			clients = append(clients, client)
+			if query.Limit > 0 && len(clients) == query.Limit {
+				return clients, after, nil
+			}
+		}
+		if len(members) < batchSize {
+			return clients, "", nil
+		}
+	}
+}
+
+// kvClientsIndexMemberId returns the client id of the member of any clients index
+func kvClientsIndexMemberId(member string) string {
+	return member[strings.LastIndexByte(member, 0)+1:]
+}
+
+// clientSortIndexCommands moves the client from the positions in the sort indexes defined by its old value to the new ones.
+// The nil client means that the client is missing.
+func clientSortIndexCommands(oldClient *asit.Client, newClient *asit.Client) []IndexCommand {
+	cmds := []IndexCommand{}
+	for _, sortBy := range []string{CLIENTS_SORT_BY_NAME, CLIENTS_SORT_BY_LAST_UPDATED} {
+		var oldMember, newMember string
+		if oldClient != nil {
+			oldMember = clientsCursor(sortBy, oldClient)
+		}
+		if newClient != nil {
+			newMember = clientsCursor(sortBy, newClient)
+		}
+		if oldMember == newMember {
+			continue
+		}
+		if oldMember != "" {
+			cmds = append(cmds, NewIndexRemoveCommand(kvClientsIndexes[sortBy], oldMember))
+		}
+		if newMember != "" {
+			cmds = append(cmds, NewIndexAddCommand(kvClientsIndexes[sortBy], newMember))
+		}
+	}
+	return cmds
+}
+
+// migrateIndexes migrates the data written by the previous versions of ASIT to the current indexes.
+// Every repository instance checks it once, so the data is migrated automatically after an upgrade.
+func (r *KVClientsRepository) migrateIndexes(ctx context.Context) error {
+	if atomic.LoadInt32(&r.indexMigrated) == 1 {
+		return nil
+	}
+	if err := r.migrateLegacyClientList(ctx); err != nil {
+		return err
+	}
+	if err := r.buildSortIndexes(ctx); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&r.indexMigrated, 1)
+	return nil
+}
+
+// buildSortIndexes adds the clients created before the sort indexes were introduced to them.
+// KEY_CLIENTS_INDEX contains all the clients, so the empty names index of the non-empty repository means it isn't built yet.
+func (r *KVClientsRepository) buildSortIndexes(ctx context.Context) error {
+	names, err := r.storage.IndexRange(ctx, KEY_CLIENTS_BY_NAME_INDEX, "", 1)
 	if err != nil {
-		return nil, fmt.Errorf("can't retrieve db key %s, %w", KEY_ALL_CLIENTS, err)
+		return fmt.Errorf("can't retrieve db index %s, %w", KEY_CLIENTS_BY_NAME_INDEX, err)
 	}
-	if !ok {
-		return []*asit.Client{}, nil
+	if len(names) > 0 {
+		return nil
 	}
 
-	return clients.Clients, nil
+	cursor := ""
+	for {
+		ids, err := r.storage.IndexRange(ctx, KEY_CLIENTS_INDEX, cursor, allClientsPageSize)
+		if err != nil {
+			return fmt.Errorf("can't retrieve db index %s, %w", KEY_CLIENTS_INDEX, err)
+		}
+		for _, id := range ids {
+			if err := r.reindexClient(ctx, id); err != nil {
+				return err
+			}
+		}
+		if len(ids) < allClientsPageSize {
+			return nil
+		}
+		cursor = ids[len(ids)-1]
+	}
+}
+
+// reindexClient adds the current value of the client to the sort indexes.
+// The client key is locked, so a concurrent SetClient either sees the added members or overwrites them.
+func (r *KVClientsRepository) reindexClient(ctx context.Context, clientId string) error {
+	var client *asit.Client
+	err := r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
+		{
+			key: KEY_CLIENT_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				client = nil
+				oldClient := &asit.Client{}
+				found, err := oldValue(oldClient)
+				if err != nil || !found {
+					return false, nil, err
+				}
+				client = oldClient
+				return false, nil, nil
+			},
+		},
+	}, []string{}, func() []SetValueUnlockedCommand { return nil }, func() []string { return nil },
+		func() []IndexCommand {
+			if client == nil {
+				return nil
+			}
+			return clientSortIndexCommands(nil, client)
+		})
+	if err != nil {
+		return fmt.Errorf("can't index client with Id %s, %w", clientId, err)
+	}
+	return nil
+}
+
+// migrateLegacyClientList moves clients of the legacy KEY_ALL_CLIENTS list to the KEY_CLIENTS_INDEX.
+func (r *KVClientsRepository) migrateLegacyClientList(ctx context.Context) error {
+	var legacyIds []string
+	err := r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
+		{
+			key: KEY_ALL_CLIENTS,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				legacyIds = nil
+				clientList := &asit.ClientList{}
+				found, err := oldValue(clientList)
+				if err != nil || !found {
+					return false, nil, err
+				}
+				for _, client := range clientList.Clients {
+					legacyIds = append(legacyIds, client.Id)
+				}
+				return true, nil, nil
+			},
+		},
+	}, []string{}, func() []SetValueUnlockedCommand { return nil }, func() []string { return nil },
+		func() []IndexCommand {
+			cmds := make([]IndexCommand, len(legacyIds))
+			for i, id := range legacyIds {
+				cmds[i] = NewIndexAddCommand(KEY_CLIENTS_INDEX, id)
+			}
+			return cmds
+		})
+	if err != nil {
+		return fmt.Errorf("can't migrate db key %s, %w", KEY_ALL_CLIENTS, err)
+	}
+	return nil
 }
 
 func (r *KVClientsRepository) GetClientById(ctx context.Context, id string) (*asit.Client, error) {
@@ -76,14 +382,15 @@ func (r *KVClientsRepository) GetClientKeys(ctx context.Context, clientId string
 		return nil, nil
 	}
 
+	clientKeys.Keys, clientKeys.Expires = activeClientKeys(clientKeys.Keys, clientKeys.Expires, time.Now())
 	return clientKeys, nil
 }
 
 func (r *KVClientsRepository) GetClientByKey(ctx context.Context, key string) (*asit.Client, error) {
 	client := &asit.Client{}
-	ok, err := r.storage.Get(ctx, KEY_CLIENT_KEY_PREFIX+key, client)
+	ok, err := r.storage.Get(ctx, r.clientKeyName(key), client)
 	if err != nil {
-		return nil, fmt.Errorf("can't retrieve db key %s, %w", KEY_CLIENT_KEY_PREFIX+key, err)
+		return nil, fmt.Errorf("can't retrieve db key %s, %w", r.clientKeyName(key), err)
 	}
 	if !ok {
 		return nil, nil
@@ -100,6 +407,43 @@ func (e *NonUniqueClientKeyError) Error() string {
 	return fmt.Sprintf("non-unique client key %s", e.key)
 }
 
+// ANY_CLIENT_REVISION is the revision matching any current revision of the client and the missing client
+const ANY_CLIENT_REVISION int64 = -1
+
+// EXISTING_CLIENT_REVISION is the revision matching any current revision of the client, but not the missing client,
+// so the updates of the clients removed concurrently fail instead of creating them again
+const EXISTING_CLIENT_REVISION int64 = -2
+
+type ClientRevisionMismatchError struct {
+	id       string
+	expected int64
+	actual   int64
+}
+
+func (e *ClientRevisionMismatchError) Error() string {
+	if e.expected == EXISTING_CLIENT_REVISION {
+		return fmt.Sprintf("client %s doesn't exist", e.id)
+	}
+	return fmt.Sprintf("client %s has revision %d, expected %d", e.id, e.actual, e.expected)
+}
+
+// checkClientRevision checks the revision of the current client value, the nil client means that the client is missing
+func checkClientRevision(clientId string, current *asit.Client, revision int64) error {
+	if revision == ANY_CLIENT_REVISION {
+		return nil
+	}
+	if current == nil {
+		if revision == EXISTING_CLIENT_REVISION {
+			return &ClientRevisionMismatchError{id: clientId, expected: revision}
+		}
+		return &NotFoundClientByIdError{id: clientId}
+	}
+	if revision != EXISTING_CLIENT_REVISION && current.Revision != revision {
+		return &ClientRevisionMismatchError{id: clientId, expected: revision, actual: current.Revision}
+	}
+	return nil
+}
+
 type NotFoundClientByIdError struct {
 	id string
 }
@@ -109,7 +453,17 @@ func (e *NotFoundClientByIdError) Error() string {
 }
 
 func (r *KVClientsRepository) AddClientKey(ctx context.Context, clientId string, key string) error {
+	return r.AddClientKeyWithTTL(ctx, clientId, key, 0)
+}
+
+// AddClientKeyWithTTL writes the key alias with the TTL, so it expires on its own,
+// the key is removed from the client keys by ExpireClientKeys
+func (r *KVClientsRepository) AddClientKeyWithTTL(ctx context.Context, clientId string, key string, ttl time.Duration) error {
 	client := &asit.Client{}
+	now := time.Now()
+	var oldKeys, newKeys []string
+	var oldExpires, newExpires *timestamppb.Timestamp
+	history := newKVHistoryAppender(clientId)
 	err := r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
 		{
 			key: KEY_CLIENT_PREFIX + clientId,
@@ -129,22 +483,46 @@ func (r *KVClientsRepository) AddClientKey(ctx context.Context, clientId string,
 			key: KEY_CLIENT_KEYS_PREFIX + clientId,
 			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
 				clientKeys := &asit.ClientKeys{}
-				found, err := oldValue(clientKeys)
+				_, err := oldValue(clientKeys)
 				if err != nil {
 					return false, nil, err
 				}
-				if found {
-					newKeys := clientKeys.Keys
-					if !slices.Contains(newKeys, key) {
-						newKeys = append(newKeys, key)
+				oldKeys, _ = activeClientKeys(clientKeys.Keys, clientKeys.Expires, now)
+				oldExpires, newExpires = clientKeys.Expires[key], nil
+				if !slices.Contains(clientKeys.Keys, key) {
+					clientKeys.Keys = append(clientKeys.Keys, key)
+				}
+				if ttl > 0 {
+					newExpires = timestamppb.New(now.Add(ttl))
+					if clientKeys.Expires == nil {
+						clientKeys.Expires = map[string]*timestamppb.Timestamp{}
 					}
-					return true, &asit.ClientKeys{Keys: newKeys}, nil
+					clientKeys.Expires[key] = newExpires
+				} else {
+					delete(clientKeys.Expires, key)
+				}
+				newKeys, _ = activeClientKeys(clientKeys.Keys, clientKeys.Expires, now)
+				return true, clientKeys, nil
+			},
+		},
+		{
+			// keys of the trashed clients stay reserved
+			key: r.reservedClientKeyName(key),
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				owner := &asit.Client{}
+				found, err := oldValue(owner)
+				if err != nil {
+					return false, nil, err
+				}
+				if found && owner.Id != clientId {
+					return false, nil, &NonUniqueClientKeyError{key: key}
 				}
-				return true, &asit.ClientKeys{Keys: []string{key}}, nil
+				return false, nil, nil
 			},
 		},
 		{
-			key: KEY_CLIENT_KEY_PREFIX + key,
+			key: r.clientKeyName(key),
+			ttl: ttl,
 			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
 				oldClient := &asit.Client{}
 				found, err := oldValue(oldClient)
@@ -157,7 +535,25 @@ func (r *KVClientsRepository) AddClientKey(ctx context.Context, clientId string,
 				return true, client, nil
 			},
 		},
-	}, []string{}, func() []SetValueUnlockedCommand { return nil }, func() []string { return nil })
+		history.command(func() *asit.ClientHistoryEntry {
+			if slices.Contains(oldKeys, key) {
+				// the key is already associated with the client
+				return nil
+			}
+			entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_KEY_ADDED)
+			entry.Key, entry.OldKeys, entry.NewKeys = key, oldKeys, newKeys
+			return entry
+		}),
+	}, []string{}, history.unlockedSets, func() []string { return nil }, func() []IndexCommand {
+		var cmds []IndexCommand
+		if oldExpires != nil {
+			cmds = append(cmds, NewIndexRemoveCommand(KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, r.clientKeyExpiryMember(oldExpires, clientId, key)))
+		}
+		if newExpires != nil {
+			cmds = append(cmds, NewIndexAddCommand(KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, r.clientKeyExpiryMember(newExpires, clientId, key)))
+		}
+		return cmds
+	})
 
 	if err != nil {
 		return fmt.Errorf("can't add client key %s, %w", key, err)
@@ -175,31 +571,45 @@ func (r *KVClientsRepository) RemoveClientKey(ctx context.Context, key string) e
 		return nil
 	}
 
+	now := time.Now()
+	var oldKeys, newKeys []string
+	var oldExpires *timestamppb.Timestamp
+	history := newKVHistoryAppender(client.Id)
 	err = r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
 		{
 			key: KEY_CLIENT_KEYS_PREFIX + client.Id,
 			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				oldKeys, newKeys, oldExpires = nil, nil, nil
 				clientKeys := &asit.ClientKeys{}
 				if found, err := oldValue(clientKeys); err != nil || !found {
 					return false, nil, err
 				}
-				// filter slice, remove client with the specified Id if it exists
-				newKeys := make([]string, len(clientKeys.Keys))
-				i := 0
-				for _, clientKey := range clientKeys.Keys {
-					if key == clientKey {
-						continue
-					}
-					newKeys[i] = clientKey
-					i++
-				}
-				return true, &asit.ClientKeys{Keys: newKeys[:i]}, nil
+				oldKeys, _ = activeClientKeys(clientKeys.Keys, clientKeys.Expires, now)
+				oldExpires = clientKeys.Expires[key]
+				clientKeys.Keys = withoutClientKey(clientKeys.Keys, key)
+				delete(clientKeys.Expires, key)
+				newKeys, _ = activeClientKeys(clientKeys.Keys, clientKeys.Expires, now)
+				return true, clientKeys, nil
 			},
 		},
+		history.command(func() *asit.ClientHistoryEntry {
+			if len(oldKeys) == len(newKeys) {
+				// the key has been removed concurrently
+				return nil
+			}
+			entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_KEY_REMOVED)
+			entry.Key, entry.OldKeys, entry.NewKeys = key, oldKeys, newKeys
+			return entry
+		}),
 	}, []string{
-		KEY_CLIENT_KEY_PREFIX + key,
-	}, func() []SetValueUnlockedCommand { return nil },
-		func() []string { return nil })
+		r.clientKeyName(key),
+	}, history.unlockedSets,
+		func() []string { return nil }, func() []IndexCommand {
+			if oldExpires == nil {
+				return nil
+			}
+			return []IndexCommand{NewIndexRemoveCommand(KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, r.clientKeyExpiryMember(oldExpires, client.Id, key))}
+		})
 
 	if err != nil {
 		return fmt.Errorf("can't delete client key %s, %w", key, err)
@@ -208,138 +618,142 @@ func (r *KVClientsRepository) RemoveClientKey(ctx context.Context, key string) e
 }
 
 func (r *KVClientsRepository) SetClient(ctx context.Context, client *asit.Client) error {
-	var outdatedKeys *[]string
-	err := r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
-		{
-			key: KEY_ALL_CLIENTS,
-			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
-				clientHead := proto.Clone(client).(*asit.Client)
-				clientHead.ClientProperties = nil
-				clientList := &asit.ClientList{}
-				found, err := oldValue(clientList)
-				if err != nil {
-					return false, nil, err
-				}
-				if !found {
-					return true, &asit.ClientList{Clients: []*asit.Client{clientHead}}, nil
-				}
-				// filter slice, remove client with the specified Id if it exists
-				newClients := clientList.Clients
-				indexFound := -1
-				for i, c := range newClients {
-					if c.Id == client.Id {
-						indexFound = i
-						break
-					}
-				}
+	return r.SetClientIfRevision(ctx, client, ANY_CLIENT_REVISION)
+}
 
-				if indexFound < 0 {
-					client.LastUpdated = timestamppb.New(time.Now())
-					newClients = append(newClients, clientHead)
-				} else {
-					newClients[indexFound].ClientProperties = nil
-					newClients[indexFound].LastUpdated = timestamppb.New(time.Now())
-				}
-				return true, &asit.ClientList{Clients: newClients}, nil
-			},
-		},
+func (r *KVClientsRepository) SetClientIfRevision(ctx context.Context, client *asit.Client, revision int64) error {
+	_, err := r.updateClient(ctx, client.Id, asit.ClientChangeType_CLIENT_UPDATED, func(current *asit.Client) (*asit.Client, error) {
+		if err := checkClientRevision(client.Id, current, revision); err != nil {
+			return nil, err
+		}
+		return client, nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't set client with Id %s, %w", client.Id, err)
+	}
+	return nil
+}
+
+func (r *KVClientsRepository) PatchClient(ctx context.Context, clientId string, patch ClientPatch, revision int64) (*asit.Client, error) {
+	client, err := r.updateClient(ctx, clientId, asit.ClientChangeType_CLIENT_UPDATED, func(current *asit.Client) (*asit.Client, error) {
+		if err := checkClientRevision(clientId, current, revision); err != nil {
+			return nil, err
+		}
+		if current == nil {
+			return nil, &NotFoundClientByIdError{id: clientId}
+		}
+		patch.apply(current)
+		return current, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't patch client with Id %s, %w", clientId, err)
+	}
+	return client, nil
+}
+
+// updateClient atomically replaces the client with the value returned by the update function.
+// The update function is called with the current value of the client (nil if it's missing) and may be called several times on conflicts.
+// It sets the revision and lastUpdated of the new value and updates the client key aliases and the indexes.
+// The change is recorded in the client history, CLIENT_UPDATED of the missing client is recorded as CLIENT_CREATED.
+func (r *KVClientsRepository) updateClient(ctx context.Context, clientId string, change asit.ClientChangeType,
+	update func(current *asit.Client) (*asit.Client, error)) (*asit.Client, error) {
+	if err := r.migrateIndexes(ctx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var outdatedKeys *asit.ClientKeys
+	var oldClient, client *asit.Client
+	history := newKVHistoryAppender(clientId)
+	err := r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
 		{
 			// just to prevent any changes in client's key during update and to update allKeys
-			key: KEY_CLIENT_KEYS_PREFIX + client.Id,
+			key: KEY_CLIENT_KEYS_PREFIX + clientId,
 			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				outdatedKeys = nil
 				clientKeys := &asit.ClientKeys{}
 				if found, err := oldValue(clientKeys); err != nil || !found {
 					return false, nil, err
 				}
 
-				outdatedKeys = &clientKeys.Keys
+				outdatedKeys = clientKeys
 				return true, clientKeys, nil
 			},
 		},
 		{
-			key: KEY_CLIENT_PREFIX + client.Id,
-			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
-				return true, client, nil
-			},
-		},
-	},
-		[]string{},
-		func() []SetValueUnlockedCommand {
-			if outdatedKeys == nil {
-				return nil
-			}
-			cmds := make([]SetValueUnlockedCommand, len(*outdatedKeys))
-			for i, key := range *outdatedKeys {
-				cmds[i] = SetValueUnlockedCommand{key: KEY_CLIENT_KEY_PREFIX + key, newValue: client}
-			}
-			return cmds
-		}, func() []string {
-			return nil
-		})
-	if err != nil {
-		return fmt.Errorf("can't set client with Id %s, %w", client.Id, err)
-	}
-
-	return nil
-}
-
-func (r *KVClientsRepository) RemoveClient(ctx context.Context, clientId string) error {
-	var outdatedKeys *[]string
-	err := r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
-		{
-			key: KEY_ALL_CLIENTS,
+			// the trashed client can't be created again until it's purged
+			key: KEY_TRASHED_CLIENT_PREFIX + clientId,
 			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
-				clientList := &asit.ClientList{}
-				found, err := oldValue(clientList)
+				found, err := oldValue(&asit.TrashedClient{})
 				if err != nil {
 					return false, nil, err
 				}
-				if !found {
-					return true, &asit.ClientList{Clients: []*asit.Client{}}, nil
-				}
-				// filter slice, remove client with the specified Id if it exists
-				newClients := make([]*asit.Client, len(clientList.Clients))
-				i := 0
-				for _, client := range clientList.Clients {
-					if clientId == client.Id {
-						continue
-					}
-					newClients[i] = client
-					i++
+				if found {
+					return false, nil, &ClientIdConflictError{id: clientId}
 				}
-				return true, &asit.ClientList{Clients: newClients[:i]}, nil
+				return false, nil, nil
 			},
 		},
 		{
-			key: KEY_CLIENT_KEYS_PREFIX + clientId,
+			key: KEY_CLIENT_PREFIX + clientId,
 			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
-				clientKeys := &asit.ClientKeys{}
-				if found, err := oldValue(clientKeys); err != nil || !found {
+				oldClient = nil
+				old := &asit.Client{}
+				found, err := oldValue(old)
+				if err != nil {
 					return false, nil, err
 				}
-				outdatedKeys = &clientKeys.Keys
-				return true, nil, nil
+				var current *asit.Client
+				if found {
+					oldClient = old
+					// the update function may change the passed value, but the old one is required to update the indexes
+					current = proto.Clone(old).(*asit.Client)
+				}
+				client, err = update(current)
+				if err != nil {
+					return false, nil, err
+				}
+				client.Revision = old.Revision + 1
+				client.LastUpdated = timestamppb.New(time.Now())
+				return true, client, nil
 			},
 		},
+		history.command(func() *asit.ClientHistoryEntry {
+			entry := newClientHistoryEntry(ctx, change)
+			if oldClient == nil && change == asit.ClientChangeType_CLIENT_UPDATED {
+				entry.Change = asit.ClientChangeType_CLIENT_CREATED
+			}
+			entry.OldClient, entry.NewClient = oldClient, client
+			return entry
+		}),
 	},
-		[]string{
-			KEY_CLIENT_PREFIX + clientId,
-		},
+		[]string{},
 		func() []SetValueUnlockedCommand {
-			return []SetValueUnlockedCommand{}
-		}, func() []string {
-			if outdatedKeys != nil {
-				res := make([]string, len(*outdatedKeys))
-				for i, v := range *outdatedKeys {
-					res[i] = KEY_CLIENT_KEY_PREFIX + v
+			cmds := history.unlockedSets()
+			if outdatedKeys == nil {
+				return cmds
+			}
+			for _, key := range outdatedKeys.Keys {
+				if clientKeyExpired(outdatedKeys.Expires, key, now) {
+					// the alias has expired and may belong to another client already
+					continue
 				}
-				return res
+				cmds = append(cmds, SetValueUnlockedCommand{key: r.clientKeyName(key), newValue: client,
+					ttl: clientKeyTTL(outdatedKeys.Expires, key, now)})
 			}
+			return cmds
+		}, func() []string {
 			return nil
+		}, func() []IndexCommand {
+			cmds := []IndexCommand{NewIndexAddCommand(KEY_CLIENTS_INDEX, clientId)}
+			return append(cmds, clientSortIndexCommands(oldClient, client)...)
 		})
 	if err != nil {
-		return fmt.Errorf("can't delete db key %s, %w", KEY_CLIENT_PREFIX+clientId, err)
+		return nil, err
 	}
+	return client, nil
+}
 
-	return nil
+func (r *KVClientsRepository) RemoveClient(ctx context.Context, clientId string) error {
+	return r.RemoveClientIfRevision(ctx, clientId, ANY_CLIENT_REVISION)
 }
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/proto"
+)
+
+// CachingClientsRepository caches the clients found by GetClientByKey in the in-process LRU cache.
+// The cached clients are invalidated by the changes made through the repository and by the changes
+// published by other instances, see Invalidate. The entries expire after maxAge, it bounds the staleness when a change is lost.
+// The entries of the keys with TTL expire with the keys. The missing keys aren't cached.
+type CachingClientsRepository struct {
+	// ClientsRepository invalidates the clients changed through the cache
+	ClientsRepository
+	maxEntries int
+	maxAge     time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru keeps the most recently used entries at the front
+	lru *list.List
+	// clientKeys are the cached keys of the clients
+	clientKeys map[string]map[string]struct{}
+	// generation is incremented by every invalidation, so the values read before it aren't cached after it
+	generation uint64
+
+	hits        int64
+	misses      int64
+	evictions   int64
+	invalidated int64
+}
+
+type clientsCacheEntry struct {
+	key     string
+	client  *asit.Client
+	expires time.Time
+}
+
+// NewCachingClientsRepository creates the cache of up to maxEntries clients
+func NewCachingClientsRepository(r ClientsRepository, maxEntries int, maxAge time.Duration) *CachingClientsRepository {
+	c := &CachingClientsRepository{
+		maxEntries: maxEntries,
+		maxAge:     maxAge,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		clientKeys: map[string]map[string]struct{}{},
+	}
+	c.ClientsRepository = NewNotifyingClientsRepository(r, func(ctx context.Context, clientId string) error {
+		c.Invalidate(clientId)
+		return nil
+	})
+	return c
+}
+
+// Stats returns the statistics of the cache usage since the start
+func (c *CachingClientsRepository) Stats() *asit.ClientsCacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+	return &asit.ClientsCacheStats{
+		Hits:          atomic.LoadInt64(&c.hits),
+		Misses:        atomic.LoadInt64(&c.misses),
+		Evictions:     atomic.LoadInt64(&c.evictions),
+		Invalidations: atomic.LoadInt64(&c.invalidated),
+		Entries:       int64(entries),
+		MaxEntries:    int64(c.maxEntries),
+		MaxAgeSeconds: c.maxAge.Seconds(),
+	}
+}
+
+func (c *CachingClientsRepository) GetClientByKey(ctx context.Context, key string) (*asit.Client, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*clientsCacheEntry)
+		if now.Before(entry.expires) {
+			c.lru.MoveToFront(element)
+			c.mu.Unlock()
+			atomic.AddInt64(&c.hits, 1)
+			return proto.Clone(entry.client).(*asit.Client), nil
+		}
+		c.remove(element)
+	}
+	generation := c.generation
+	c.mu.Unlock()
+	atomic.AddInt64(&c.misses, 1)
+
+	client, err := c.ClientsRepository.GetClientByKey(ctx, key)
+	if err != nil || client == nil {
+		return client, err
+	}
+	expires := now.Add(c.maxAge)
+	clientKeys, err := c.ClientsRepository.GetClientKeys(ctx, client.Id)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt, ok := clientKeys.GetExpires()[key]; ok && expiresAt.AsTime().Before(expires) {
+		expires = expiresAt.AsTime()
+	}
+	c.put(generation, &clientsCacheEntry{key: key, client: proto.Clone(client).(*asit.Client), expires: expires})
+	return client, nil
+}
+
+// put caches the entry if there were no invalidations since the generation
+func (c *CachingClientsRepository) put(generation uint64, entry *clientsCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation || c.maxEntries <= 0 {
+		return
+	}
+	if element, ok := c.entries[entry.key]; ok {
+		c.remove(element)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	keys, ok := c.clientKeys[entry.client.Id]
+	if !ok {
+		keys = map[string]struct{}{}
+		c.clientKeys[entry.client.Id] = keys
+	}
+	keys[entry.key] = struct{}{}
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+		atomic.AddInt64(&c.evictions, 1)
+	}
+}
+
+// remove must be called with the locked mutex
+func (c *CachingClientsRepository) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*clientsCacheEntry)
+	delete(c.entries, entry.key)
+	if keys, ok := c.clientKeys[entry.client.Id]; ok {
+		delete(keys, entry.key)
+		if len(keys) == 0 {
+			delete(c.clientKeys, entry.client.Id)
+		}
+	}
+}
+
+// Invalidate removes the entries of the changed client, the empty id removes all the entries.
+// It's called with the changes published by other instances, see ClientChanges.
+func (c *CachingClientsRepository) Invalidate(clientId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	atomic.AddInt64(&c.invalidated, 1)
+	if clientId == "" {
+		c.entries = map[string]*list.Element{}
+		c.lru.Init()
+		c.clientKeys = map[string]map[string]struct{}{}
+		return
+	}
+	for key := range c.clientKeys[clientId] {
+		c.remove(c.entries[key])
+	}
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+)
+
+// ClientChanges delivers the notifications of the changed clients between the ASIT instances sharing the storage
+type ClientChanges interface {
+	// Publish notifies the subscribed instances that the client has changed, the empty id notifies about all the clients
+	Publish(ctx context.Context, clientId string) error
+	// Subscribe calls changed for every published change until the context is done.
+	// The changes published while the subscription is broken are lost, so changed is called
+	// with the empty id every time the subscription is established.
+	Subscribe(ctx context.Context, changed func(clientId string)) error
+}
+
+// LocalClientChanges delivers the changes to the subscribers of the same process, it's used by the single instance storages
+type LocalClientChanges struct {
+	mu          sync.Mutex
+	subscribers map[int]func(clientId string)
+	nextId      int
+}
+
+func NewLocalClientChanges() *LocalClientChanges {
+	return &LocalClientChanges{subscribers: map[int]func(string){}}
+}
+
+func (c *LocalClientChanges) Publish(ctx context.Context, clientId string) error {
+	c.mu.Lock()
+	subscribers := make([]func(string), 0, len(c.subscribers))
+	for _, changed := range c.subscribers {
+		subscribers = append(subscribers, changed)
+	}
+	c.mu.Unlock()
+	for _, changed := range subscribers {
+		changed(clientId)
+	}
+	return nil
+}
+
+func (c *LocalClientChanges) Subscribe(ctx context.Context, changed func(clientId string)) error {
+	c.mu.Lock()
+	id := c.nextId
+	c.nextId++
+	c.subscribers[id] = changed
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.subscribers, id)
+		c.mu.Unlock()
+	}()
+	changed("")
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// NotifyingClientsRepository calls notify after every change of the clients and their keys made through the repository.
+// The failed changes are notified too, because the change may be made even if its result is lost.
+// The expired keys aren't notified, the clients can't be found by them already.
+type NotifyingClientsRepository struct {
+	ClientsRepository
+	notify func(ctx context.Context, clientId string) error
+}
+
+// NewNotifyingClientsRepository creates the repository notifying about the changes, e.g. by ClientChanges.Publish.
+// The empty client id passed to notify means that any client could be changed.
+func NewNotifyingClientsRepository(r ClientsRepository, notify func(ctx context.Context, clientId string) error) *NotifyingClientsRepository {
+	return &NotifyingClientsRepository{ClientsRepository: r, notify: notify}
+}
+
+// changed notifies about the change and returns the error of the change. The failed notification is only logged,
+// because the change is made already, and the watchers catch up with it by the revision on their next poll.
+func (r *NotifyingClientsRepository) changed(ctx context.Context, clientId string, err error) error {
+	if notifyErr := r.notify(ctx, clientId); notifyErr != nil {
+		log.Printf("can't notify about the change of client %q, %v", clientId, notifyErr)
+	}
+	return err
+}
+
+func (r *NotifyingClientsRepository) SetClient(ctx context.Context, client *asit.Client) error {
+	return r.changed(ctx, client.Id, r.ClientsRepository.SetClient(ctx, client))
+}
+
+func (r *NotifyingClientsRepository) SetClientIfRevision(ctx context.Context, client *asit.Client, revision int64) error {
+	return r.changed(ctx, client.Id, r.ClientsRepository.SetClientIfRevision(ctx, client, revision))
+}
+
+func (r *NotifyingClientsRepository) PatchClient(ctx context.Context, clientId string, patch ClientPatch, revision int64) (*asit.Client, error) {
+	client, err := r.ClientsRepository.PatchClient(ctx, clientId, patch, revision)
+	return client, r.changed(ctx, clientId, err)
+}
+
+func (r *NotifyingClientsRepository) RemoveClient(ctx context.Context, clientId string) error {
+	return r.changed(ctx, clientId, r.ClientsRepository.RemoveClient(ctx, clientId))
+}
+
+func (r *NotifyingClientsRepository) RemoveClientIfRevision(ctx context.Context, clientId string, revision int64) error {
+	return r.changed(ctx, clientId, r.ClientsRepository.RemoveClientIfRevision(ctx, clientId, revision))
+}
+
+func (r *NotifyingClientsRepository) RestoreClient(ctx context.Context, clientId string) (*asit.Client, error) {
+	client, err := r.ClientsRepository.RestoreClient(ctx, clientId)
+	return client, r.changed(ctx, clientId, err)
+}
+
+func (r *NotifyingClientsRepository) AddClientKey(ctx context.Context, clientId string, key string) error {
+	return r.changed(ctx, clientId, r.ClientsRepository.AddClientKey(ctx, clientId, key))
+}
+
+func (r *NotifyingClientsRepository) AddClientKeyWithTTL(ctx context.Context, clientId string, key string, ttl time.Duration) error {
+	return r.changed(ctx, clientId, r.ClientsRepository.AddClientKeyWithTTL(ctx, clientId, key, ttl))
+}
+
+// RemoveClientKey notifies about the client of the key, it's read before the removal,
+// so the removal of the key which isn't associated with any client isn't notified
+func (r *NotifyingClientsRepository) RemoveClientKey(ctx context.Context, key string) error {
+	client, err := r.ClientsRepository.GetClientByKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	err = r.ClientsRepository.RemoveClientKey(ctx, key)
+	if client == nil {
+		return err
+	}
+	return r.changed(ctx, client.Id, err)
+}
+
+func (r *NotifyingClientsRepository) RollbackClient(ctx context.Context, clientId string, revision int64, ifRevision int64) (*asit.Client, error) {
+	client, err := r.ClientsRepository.RollbackClient(ctx, clientId, revision, ifRevision)
+	return client, r.changed(ctx, clientId, err)
+}
+
+// CheckConsistency notifies about all the clients after the repair of the found inconsistencies
+func (r *NotifyingClientsRepository) CheckConsistency(ctx context.Context, repair bool) (*asit.ClientsConsistencyReport, error) {
+	report, err := r.ClientsRepository.CheckConsistency(ctx, repair)
+	if !repair || len(report.GetInconsistencies()) == 0 {
+		return report, err
+	}
+	return report, r.changed(ctx, "", err)
+}
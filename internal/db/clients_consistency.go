@@ -0,0 +1,524 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// kvClientsConsistencyCheck is the snapshot of the clients values read by CheckConsistency
+type kvClientsConsistencyCheck struct {
+	clients map[string]*asit.Client
+	// owners are the clients listing the active key in their client keys
+	owners  map[string][]string
+	aliases map[string]*asit.Client
+	report  *asit.ClientsConsistencyReport
+}
+
+// CheckConsistency compares the clients with the indexes, the client keys lists and the client key aliases.
+// They are written by separate commands, so they could drift apart after the failures or the concurrent updates.
+// The values are scanned without locks, so every inconsistency is checked again by its atomic repair.
+// The alias of the key listed by several clients decides which of them keeps the key.
+func (r *KVClientsRepository) CheckConsistency(ctx context.Context, repair bool) (*asit.ClientsConsistencyReport, error) {
+	if repair {
+		if err := r.migrateIndexes(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	check := &kvClientsConsistencyCheck{
+		clients: map[string]*asit.Client{},
+		owners:  map[string][]string{},
+		aliases: map[string]*asit.Client{},
+		report:  &asit.ClientsConsistencyReport{},
+	}
+	if err := r.readClients(ctx, check); err != nil {
+		return nil, err
+	}
+	if err := r.checkClientsIndexes(ctx, check); err != nil {
+		return nil, err
+	}
+	if err := r.checkClientKeysLists(ctx, check); err != nil {
+		return nil, err
+	}
+	if err := r.checkClientKeyAliases(ctx, check); err != nil {
+		return nil, err
+	}
+
+	if !repair {
+		return check.report, nil
+	}
+	for _, inconsistency := range check.report.Inconsistencies {
+		if err := r.repairInconsistency(ctx, inconsistency); err != nil {
+			return check.report, fmt.Errorf("can't repair %s of client %s, %w", inconsistency.Type, inconsistency.ClientId, err)
+		}
+		inconsistency.Repaired = true
+	}
+	return check.report, nil
+}
+
+func (c *kvClientsConsistencyCheck) add(inconsistency *asit.ClientsInconsistency) {
+	c.report.Inconsistencies = append(c.report.Inconsistencies, inconsistency)
+}
+
+// scanValues reads the values of the keys with the prefix, the values removed after the scan are skipped.
+// The read function is called with the key without the prefix.
+func (r *KVClientsRepository) scanValues(ctx context.Context, prefix string, newValue func() proto.Message, read func(k string, v proto.Message)) error {
+	keys, err := r.storage.ScanKeys(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("can't scan db keys %s*, %w", prefix, err)
+	}
+	for _, key := range keys {
+		v := newValue()
+		found, err := r.storage.Get(ctx, key, v)
+		if err != nil {
+			return fmt.Errorf("can't retrieve db key %s, %w", key, err)
+		}
+		if found {
+			read(key[len(prefix):], v)
+		}
+	}
+	return nil
+}
+
+func (r *KVClientsRepository) readClients(ctx context.Context, check *kvClientsConsistencyCheck) error {
+	err := r.scanValues(ctx, KEY_CLIENT_PREFIX, func() proto.Message { return &asit.Client{} }, func(id string, v proto.Message) {
+		check.clients[id] = v.(*asit.Client)
+	})
+	check.report.CheckedClients = int64(len(check.clients))
+	return err
+}
+
+// checkClientsIndexes checks that every index contains exactly the members of the current client values
+func (r *KVClientsRepository) checkClientsIndexes(ctx context.Context, check *kvClientsConsistencyCheck) error {
+	for _, sortBy := range []string{CLIENTS_SORT_BY_ID, CLIENTS_SORT_BY_NAME, CLIENTS_SORT_BY_LAST_UPDATED} {
+		index := kvClientsIndexes[sortBy]
+		expected := map[string]string{}
+		for id, client := range check.clients {
+			expected[clientsCursor(sortBy, client)] = id
+		}
+
+		after := ""
+		for {
+			members, err := r.storage.IndexRange(ctx, index, after, allClientsPageSize)
+			if err != nil {
+				return fmt.Errorf("can't retrieve db index %s, %w", index, err)
+			}
+			for _, member := range members {
+				if _, ok := expected[member]; ok {
+					delete(expected, member)
+					continue
+				}
+				check.add(&asit.ClientsInconsistency{
+					Type:        asit.ClientsInconsistencyType_ORPHANED_INDEX_MEMBER,
+					ClientId:    kvClientsIndexMemberId(member),
+					Index:       index,
+					Member:      member,
+					Description: "the index member doesn't match the current value of the client",
+				})
+			}
+			if len(members) < allClientsPageSize {
+				break
+			}
+			after = members[len(members)-1]
+		}
+
+		missing := make([]string, 0, len(expected))
+		for member := range expected {
+			missing = append(missing, member)
+		}
+		slices.Sort(missing)
+		for _, member := range missing {
+			check.add(&asit.ClientsInconsistency{
+				Type:        asit.ClientsInconsistencyType_MISSING_INDEX_MEMBER,
+				ClientId:    expected[member],
+				Index:       index,
+				Member:      member,
+				Description: "the client isn't indexed",
+			})
+		}
+	}
+	return nil
+}
+
+// checkClientKeysLists collects the owners of the active keys, the lists of the missing clients aren't used by any operation
+func (r *KVClientsRepository) checkClientKeysLists(ctx context.Context, check *kvClientsConsistencyCheck) error {
+	now := time.Now()
+	return r.scanValues(ctx, KEY_CLIENT_KEYS_PREFIX, func() proto.Message { return &asit.ClientKeys{} }, func(id string, v proto.Message) {
+		clientKeys := v.(*asit.ClientKeys)
+		if _, ok := check.clients[id]; !ok {
+			check.add(&asit.ClientsInconsistency{
+				Type:        asit.ClientsInconsistencyType_ORPHANED_CLIENT_KEYS,
+				ClientId:    id,
+				Description: fmt.Sprintf("the client is missing, but it has keys %v", clientKeys.Keys),
+			})
+			return
+		}
+		keys, _ := activeClientKeys(clientKeys.Keys, clientKeys.Expires, now)
+		for _, key := range keys {
+			check.owners[key] = append(check.owners[key], id)
+		}
+	})
+}
+
+// checkClientKeyAliases compares the aliases with the owners of the keys.
+// The owners are collected in the order of the ids, so the first one keeps the duplicate key without the alias.
+// The aliases are found by the key ids, so the orphaned aliases are reported with the key hashes if the key names are hashed.
+func (r *KVClientsRepository) checkClientKeyAliases(ctx context.Context, check *kvClientsConsistencyCheck) error {
+	err := r.scanValues(ctx, KEY_CLIENT_KEY_PREFIX, func() proto.Message { return &asit.Client{} }, func(keyId string, v proto.Message) {
+		check.aliases[keyId] = v.(*asit.Client)
+	})
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(check.owners)+len(check.aliases))
+	ownedIds := map[string]bool{}
+	for key := range check.owners {
+		keys = append(keys, key)
+		ownedIds[r.clientKeyId(key)] = true
+	}
+	for keyId := range check.aliases {
+		if !ownedIds[keyId] {
+			keys = append(keys, keyId)
+		}
+	}
+	slices.Sort(keys)
+	check.report.CheckedKeys = int64(len(keys))
+
+	for _, key := range keys {
+		alias, owners := check.aliases[key], check.owners[key]
+		if len(owners) > 0 {
+			alias = check.aliases[r.clientKeyId(key)]
+		}
+		if len(owners) == 0 {
+			check.add(&asit.ClientsInconsistency{
+				Type:        asit.ClientsInconsistencyType_ORPHANED_CLIENT_KEY_ALIAS,
+				ClientId:    alias.Id,
+				Key:         key,
+				Description: "the key isn't listed in the keys of any client",
+			})
+			continue
+		}
+
+		owner := owners[0]
+		if alias != nil && slices.Contains(owners, alias.Id) {
+			owner = alias.Id
+		}
+		switch {
+		case alias == nil:
+			check.add(&asit.ClientsInconsistency{
+				Type:        asit.ClientsInconsistencyType_MISSING_CLIENT_KEY_ALIAS,
+				ClientId:    owner,
+				Key:         key,
+				Description: "the key isn't associated with any client",
+			})
+		case alias.Id != owner:
+			check.add(&asit.ClientsInconsistency{
+				Type:        asit.ClientsInconsistencyType_MISSING_CLIENT_KEY_ALIAS,
+				ClientId:    owner,
+				Key:         key,
+				Description: fmt.Sprintf("the key is associated with client %s which doesn't list it", alias.Id),
+			})
+		case !proto.Equal(alias, check.clients[owner]):
+			check.add(&asit.ClientsInconsistency{
+				Type:        asit.ClientsInconsistencyType_STALE_CLIENT_KEY_ALIAS,
+				ClientId:    owner,
+				Key:         key,
+				Description: fmt.Sprintf("the key is associated with revision %d of the client, the current one is %d", alias.Revision, check.clients[owner].Revision),
+			})
+		}
+		// the duplicates are repaired after the alias of the owner
+		for _, id := range owners {
+			if id != owner {
+				check.add(&asit.ClientsInconsistency{
+					Type:        asit.ClientsInconsistencyType_DUPLICATE_CLIENT_KEY,
+					ClientId:    id,
+					Key:         key,
+					Description: fmt.Sprintf("the key is associated with client %s", owner),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func (r *KVClientsRepository) repairInconsistency(ctx context.Context, inconsistency *asit.ClientsInconsistency) error {
+	switch inconsistency.Type {
+	case asit.ClientsInconsistencyType_MISSING_INDEX_MEMBER, asit.ClientsInconsistencyType_ORPHANED_INDEX_MEMBER:
+		return r.repairIndexMember(ctx, inconsistency.ClientId, inconsistency.Index, inconsistency.Member)
+	case asit.ClientsInconsistencyType_ORPHANED_CLIENT_KEYS:
+		return r.repairOrphanedClientKeys(ctx, inconsistency.ClientId)
+	case asit.ClientsInconsistencyType_MISSING_CLIENT_KEY_ALIAS, asit.ClientsInconsistencyType_STALE_CLIENT_KEY_ALIAS:
+		return r.repairClientKeyAlias(ctx, inconsistency.ClientId, inconsistency.Key)
+	case asit.ClientsInconsistencyType_ORPHANED_CLIENT_KEY_ALIAS:
+		return r.repairOrphanedClientKeyAlias(ctx, inconsistency.ClientId, inconsistency.Key)
+	case asit.ClientsInconsistencyType_DUPLICATE_CLIENT_KEY:
+		return r.repairDuplicateClientKey(ctx, inconsistency.ClientId, inconsistency.Key)
+	}
+	return fmt.Errorf("unsupported inconsistency type %s", inconsistency.Type)
+}
+
+// repairIndexMember replaces the member of the index with the member of the current client value.
+// The client key is locked, so a concurrent update either sees the repaired index or overwrites it.
+func (r *KVClientsRepository) repairIndexMember(ctx context.Context, clientId string, index string, member string) error {
+	var client *asit.Client
+	return r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
+		{
+			key: KEY_CLIENT_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				client = nil
+				current := &asit.Client{}
+				found, err := oldValue(current)
+				if err != nil || !found {
+					return false, nil, err
+				}
+				client = current
+				return false, nil, nil
+			},
+		},
+	}, []string{}, func() []SetValueUnlockedCommand { return nil }, func() []string { return nil },
+		func() []IndexCommand {
+			expected := ""
+			for sortBy, sortIndex := range kvClientsIndexes {
+				if sortIndex == index && client != nil {
+					expected = clientsCursor(sortBy, client)
+				}
+			}
+			if expected == member {
+				return []IndexCommand{NewIndexAddCommand(index, member)}
+			}
+			cmds := []IndexCommand{NewIndexRemoveCommand(index, member)}
+			if expected != "" {
+				cmds = append(cmds, NewIndexAddCommand(index, expected))
+			}
+			return cmds
+		})
+}
+
+// repairOrphanedClientKeys deletes the keys list of the missing client, the aliases of the keys are reported separately
+func (r *KVClientsRepository) repairOrphanedClientKeys(ctx context.Context, clientId string) error {
+	var orphaned *asit.ClientKeys
+	return r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
+		{
+			key: KEY_CLIENT_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				orphaned = nil
+				found, err := oldValue(&asit.Client{})
+				if err != nil || found {
+					return false, nil, err
+				}
+				orphaned = &asit.ClientKeys{}
+				return false, nil, nil
+			},
+		},
+		{
+			key: KEY_CLIENT_KEYS_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				if orphaned == nil {
+					return false, nil, nil
+				}
+				found, err := oldValue(orphaned)
+				return found, nil, err
+			},
+		},
+	}, []string{}, func() []SetValueUnlockedCommand { return nil }, func() []string { return nil },
+		func() []IndexCommand {
+			if orphaned == nil {
+				return nil
+			}
+			var cmds []IndexCommand
+			for key, expires := range orphaned.Expires {
+				cmds = append(cmds, NewIndexRemoveCommand(KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, r.clientKeyExpiryMember(expires, clientId, key)))
+			}
+			return cmds
+		})
+}
+
+// repairClientKeyAlias associates the key listed by the client with its current value.
+// The alias of another client is replaced only if that client doesn't list the key.
+func (r *KVClientsRepository) repairClientKeyAlias(ctx context.Context, clientId string, key string) error {
+	alias := &asit.Client{}
+	found, err := r.storage.Get(ctx, r.clientKeyName(key), alias)
+	if err != nil {
+		return fmt.Errorf("can't retrieve db key %s, %w", r.clientKeyName(key), err)
+	}
+	aliasOwner := clientId
+	if found {
+		aliasOwner = alias.Id
+	}
+
+	now := time.Now()
+	var client *asit.Client
+	var ttl time.Duration
+	cmds := []SetValueCommand{
+		{
+			key: KEY_CLIENT_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				client = nil
+				current := &asit.Client{}
+				found, err := oldValue(current)
+				if err != nil || !found {
+					return false, nil, err
+				}
+				client = current
+				return false, nil, nil
+			},
+		},
+		{
+			key: KEY_CLIENT_KEYS_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				clientKeys := &asit.ClientKeys{}
+				_, err := oldValue(clientKeys)
+				if err != nil {
+					return false, nil, err
+				}
+				if keys, _ := activeClientKeys(clientKeys.Keys, clientKeys.Expires, now); !slices.Contains(keys, key) {
+					// the key has been removed or has expired
+					client = nil
+				}
+				ttl = clientKeyTTL(clientKeys.Expires, key, now)
+				return false, nil, nil
+			},
+		},
+	}
+	if aliasOwner != clientId {
+		cmds = append(cmds, SetValueCommand{
+			key: KEY_CLIENT_KEYS_PREFIX + aliasOwner,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				clientKeys := &asit.ClientKeys{}
+				_, err := oldValue(clientKeys)
+				if err != nil {
+					return false, nil, err
+				}
+				if keys, _ := activeClientKeys(clientKeys.Keys, clientKeys.Expires, now); slices.Contains(keys, key) {
+					// the key is duplicated, it stays with the client of the alias
+					client = nil
+				}
+				return false, nil, nil
+			},
+		})
+	}
+	write := false
+	cmds = append(cmds, SetValueCommand{
+		key: r.clientKeyName(key),
+		updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			write = false
+			current := &asit.Client{}
+			found, err := oldValue(current)
+			if err != nil || client == nil {
+				return false, nil, err
+			}
+			if found && current.Id != aliasOwner {
+				return false, nil, fmt.Errorf("client key %s has been associated with client %s concurrently", key, current.Id)
+			}
+			write = !found || !proto.Equal(current, client)
+			return false, nil, nil
+		},
+	})
+	// the alias is written by the unlocked command, because its ttl is known only after the keys list is read
+	return r.storage.SetAndDeleteAtomically(ctx, cmds, []string{}, func() []SetValueUnlockedCommand {
+		if !write {
+			return nil
+		}
+		return []SetValueUnlockedCommand{{key: r.clientKeyName(key), newValue: client, ttl: ttl}}
+	}, func() []string { return nil }, nil)
+}
+
+// repairOrphanedClientKeyAlias deletes the alias if its client still doesn't list the key.
+// The keyId is the suffix of the alias name, it's the hash of the key if the key names are hashed.
+func (r *KVClientsRepository) repairOrphanedClientKeyAlias(ctx context.Context, clientId string, keyId string) error {
+	now := time.Now()
+	listed := false
+	return r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
+		{
+			key: KEY_CLIENT_KEYS_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				clientKeys := &asit.ClientKeys{}
+				_, err := oldValue(clientKeys)
+				if err != nil {
+					return false, nil, err
+				}
+				keys, _ := activeClientKeys(clientKeys.Keys, clientKeys.Expires, now)
+				listed = false
+				for _, key := range keys {
+					listed = listed || r.clientKeyId(key) == keyId
+				}
+				return false, nil, nil
+			},
+		},
+		{
+			key: KEY_CLIENT_KEY_PREFIX + keyId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				alias := &asit.Client{}
+				found, err := oldValue(alias)
+				if err != nil || !found || listed || alias.Id != clientId {
+					return false, nil, err
+				}
+				return true, nil, nil
+			},
+		},
+	}, []string{}, func() []SetValueUnlockedCommand { return nil }, func() []string { return nil }, nil)
+}
+
+// repairDuplicateClientKey removes the key from the keys of the client if the key is associated with another client.
+// The removal is recorded in the client history.
+func (r *KVClientsRepository) repairDuplicateClientKey(ctx context.Context, clientId string, key string) error {
+	now := time.Now()
+	duplicate := false
+	var oldKeys, newKeys []string
+	var oldExpires *timestamppb.Timestamp
+	history := newKVHistoryAppender(clientId)
+	return r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
+		{
+			key: r.clientKeyName(key),
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				alias := &asit.Client{}
+				found, err := oldValue(alias)
+				if err != nil {
+					return false, nil, err
+				}
+				// the key stays with the client if the alias is missing, it's restored for the client listing the key first
+				duplicate = found && alias.Id != clientId
+				return false, nil, nil
+			},
+		},
+		{
+			key: KEY_CLIENT_KEYS_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				oldKeys, newKeys, oldExpires = nil, nil, nil
+				clientKeys := &asit.ClientKeys{}
+				if found, err := oldValue(clientKeys); err != nil || !found || !duplicate {
+					return false, nil, err
+				}
+				oldKeys, _ = activeClientKeys(clientKeys.Keys, clientKeys.Expires, now)
+				if !slices.Contains(oldKeys, key) {
+					return false, nil, nil
+				}
+				oldExpires = clientKeys.Expires[key]
+				clientKeys.Keys = withoutClientKey(clientKeys.Keys, key)
+				delete(clientKeys.Expires, key)
+				newKeys, _ = activeClientKeys(clientKeys.Keys, clientKeys.Expires, now)
+				return true, clientKeys, nil
+			},
+		},
+		history.command(func() *asit.ClientHistoryEntry {
+			if len(oldKeys) == len(newKeys) {
+				return nil
+			}
+			entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_KEY_REMOVED)
+			entry.Key, entry.OldKeys, entry.NewKeys = key, oldKeys, newKeys
+			return entry
+		}),
+	}, []string{}, history.unlockedSets, func() []string { return nil }, func() []IndexCommand {
+		if oldExpires == nil {
+			return nil
+		}
+		return []IndexCommand{NewIndexRemoveCommand(KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, r.clientKeyExpiryMember(oldExpires, clientId, key))}
+	})
+}
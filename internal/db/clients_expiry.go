@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// KEY_CLIENT_KEYS_BY_EXPIRY_INDEX members are the sortable expiration timestamps followed by the client ids and the key ids
+// of the keys added with TTL. The keys of the trashed clients are not indexed, they expire with their reservations.
+const KEY_CLIENT_KEYS_BY_EXPIRY_INDEX = "client_keys_by_expiry_index"
+
+func (r *KVClientsRepository) clientKeyExpiryMember(expires *timestamppb.Timestamp, clientId string, key string) string {
+	return sortableTimestamp(expires) + "\x00" + clientId + "\x00" + r.clientKeyId(key)
+}
+
+// parseClientKeyExpiryMember returns the expiration unix nanos, the client id and the key id of the index member
+func parseClientKeyExpiryMember(member string) (int64, string, string, error) {
+	parts := strings.SplitN(member, "\x00", 3)
+	if len(parts) != 3 {
+		return 0, "", "", fmt.Errorf("malformed member of %s %q", KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, member)
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("malformed member of %s %q, %w", KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, member, err)
+	}
+	return expires, parts[1], parts[2], nil
+}
+
+func clientKeyExpired(expires map[string]*timestamppb.Timestamp, key string, now time.Time) bool {
+	expiresAt, ok := expires[key]
+	return ok && !now.Before(expiresAt.AsTime())
+}
+
+// activeClientKeys returns the keys which are not expired at the specified time with their expiration times
+func activeClientKeys(keys []string, expires map[string]*timestamppb.Timestamp, now time.Time) ([]string, map[string]*timestamppb.Timestamp) {
+	var activeExpires map[string]*timestamppb.Timestamp
+	active := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if clientKeyExpired(expires, key, now) {
+			continue
+		}
+		active = append(active, key)
+		if expiresAt, ok := expires[key]; ok {
+			if activeExpires == nil {
+				activeExpires = map[string]*timestamppb.Timestamp{}
+			}
+			activeExpires[key] = expiresAt
+		}
+	}
+	return active, activeExpires
+}
+
+// clientKeyTTL returns the remaining TTL of the active key, it's 0 for the keys without expiry
+func clientKeyTTL(expires map[string]*timestamppb.Timestamp, key string, now time.Time) time.Duration {
+	expiresAt, ok := expires[key]
+	if !ok {
+		return 0
+	}
+	return expiresAt.AsTime().Sub(now)
+}
+
+func withoutClientKey(keys []string, key string) []string {
+	res := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k != key {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+// ExpireClientKeys removes the expired keys from the lists of client keys. The expired keys are already missing
+// for GetClientByKey and GetClientKeys, so it only cleans up the lists and records the expirations in the client history.
+func (r *KVClientsRepository) ExpireClientKeys(ctx context.Context, now time.Time) (int, error) {
+	expired := 0
+	for {
+		members, err := r.storage.IndexRange(ctx, KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, "", allClientsPageSize)
+		if err != nil {
+			return expired, fmt.Errorf("can't retrieve db index %s, %w", KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, err)
+		}
+		for _, member := range members {
+			expires, clientId, keyId, err := parseClientKeyExpiryMember(member)
+			if err != nil {
+				return expired, err
+			}
+			if expires > now.UnixNano() {
+				return expired, nil
+			}
+			removed, err := r.expireClientKey(ctx, member, clientId, keyId, now)
+			if err != nil {
+				return expired, err
+			}
+			if removed {
+				expired++
+			}
+		}
+		if len(members) < allClientsPageSize {
+			return expired, nil
+		}
+	}
+}
+
+// expireClientKey removes the expired key from the client keys and the index member of the key.
+// The key isn't removed if it has been added again with another TTL.
+func (r *KVClientsRepository) expireClientKey(ctx context.Context, member string, clientId string, keyId string, now time.Time) (bool, error) {
+	removed := false
+	// key is the client key of the key id, the plain keys of the members written before the hashing are matched too
+	key := keyId
+	var oldKeys, newKeys []string
+	history := newKVHistoryAppender(clientId)
+	err := r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
+		{
+			key: KEY_CLIENT_KEYS_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				removed = false
+				clientKeys := &asit.ClientKeys{}
+				found, err := oldValue(clientKeys)
+				if err != nil || !found {
+					return false, nil, err
+				}
+				for _, k := range clientKeys.Keys {
+					if k == keyId || r.clientKeyId(k) == keyId {
+						key = k
+					}
+				}
+				if !clientKeyExpired(clientKeys.Expires, key, now) {
+					return false, nil, nil
+				}
+				removed = true
+				// the old keys are the keys active right before the expiration
+				oldKeys = nil
+				for _, k := range clientKeys.Keys {
+					if k == key || !clientKeyExpired(clientKeys.Expires, k, now) {
+						oldKeys = append(oldKeys, k)
+					}
+				}
+				clientKeys.Keys = withoutClientKey(clientKeys.Keys, key)
+				delete(clientKeys.Expires, key)
+				newKeys, _ = activeClientKeys(clientKeys.Keys, clientKeys.Expires, now)
+				return true, clientKeys, nil
+			},
+		},
+		{
+			// the key id is already hashed, so the alias name is built from it, not from the client key
+			key: KEY_CLIENT_KEY_PREFIX + keyId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				owner := &asit.Client{}
+				found, err := oldValue(owner)
+				if err != nil || !removed {
+					return false, nil, err
+				}
+				// the missing alias may still be kept by the storage until it's written again
+				return !found || owner.Id == clientId, nil, nil
+			},
+		},
+		history.command(func() *asit.ClientHistoryEntry {
+			if !removed {
+				return nil
+			}
+			entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_KEY_EXPIRED)
+			entry.Key, entry.OldKeys, entry.NewKeys = key, oldKeys, newKeys
+			return entry
+		}),
+	}, []string{}, history.unlockedSets, func() []string { return nil }, func() []IndexCommand {
+		return []IndexCommand{NewIndexRemoveCommand(KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, member)}
+	})
+	if err != nil {
+		return false, fmt.Errorf("can't expire client key %s of client %s, %w", keyId, clientId, err)
+	}
+	return removed, nil
+}
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// EXPORT_VERSION is the version of the export file format written by ExportClients
+const EXPORT_VERSION = 1
+
+const (
+	// EXPORT_FORMAT_NDJSON is the stream of the protojson encoded records separated by new lines
+	EXPORT_FORMAT_NDJSON = "ndjson"
+	// EXPORT_FORMAT_PROTO is the stream of the binary protobuf records prefixed with their varint encoded sizes
+	EXPORT_FORMAT_PROTO = "proto"
+)
+
+// maxExportRecordSize protects the reader from allocating huge buffers for the malformed binary files
+const maxExportRecordSize = 64 << 20
+
+type InvalidExportFileError struct {
+	reason string
+}
+
+func (e *InvalidExportFileError) Error() string {
+	return "invalid export file, " + e.reason
+}
+
+type InvalidExportFormatError struct {
+	format string
+}
+
+func (e *InvalidExportFormatError) Error() string {
+	return fmt.Sprintf("unsupported export format %q, expected %s or %s", e.format, EXPORT_FORMAT_NDJSON, EXPORT_FORMAT_PROTO)
+}
+
+func validateExportFormat(format string) error {
+	if format != EXPORT_FORMAT_NDJSON && format != EXPORT_FORMAT_PROTO {
+		return &InvalidExportFormatError{format: format}
+	}
+	return nil
+}
+
+// ExportWriter writes the records of the export file in the specified format, Flush must be called after the last record
+type ExportWriter struct {
+	w      *bufio.Writer
+	format string
+}
+
+func NewExportWriter(w io.Writer, format string) (*ExportWriter, error) {
+	if err := validateExportFormat(format); err != nil {
+		return nil, err
+	}
+	return &ExportWriter{w: bufio.NewWriter(w), format: format}, nil
+}
+
+func (e *ExportWriter) Write(record *asit.ExportRecord) error {
+	var data []byte
+	var err error
+	if e.format == EXPORT_FORMAT_NDJSON {
+		data, err = protojson.Marshal(record)
+		data = append(data, '\n')
+	} else {
+		data, err = proto.Marshal(record)
+		data = append(binary.AppendUvarint(nil, uint64(len(data))), data...)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *ExportWriter) Flush() error {
+	return e.w.Flush()
+}
+
+// ExportReader reads the records of the export file in the specified format
+type ExportReader struct {
+	r      *bufio.Reader
+	format string
+}
+
+func NewExportReader(r io.Reader, format string) (*ExportReader, error) {
+	if err := validateExportFormat(format); err != nil {
+		return nil, err
+	}
+	return &ExportReader{r: bufio.NewReader(r), format: format}, nil
+}
+
+// Read returns the next record or io.EOF after the last one.
+// The unknown fields are skipped, so the files of the newer versions with the same major format could be read.
+func (e *ExportReader) Read() (*asit.ExportRecord, error) {
+	var data []byte
+	if e.format == EXPORT_FORMAT_NDJSON {
+		for len(data) == 0 {
+			line, err := e.r.ReadBytes('\n')
+			if err != nil && (err != io.EOF || len(line) == 0) {
+				return nil, err
+			}
+			data = bytes.TrimSpace(line)
+		}
+	} else {
+		size, err := binary.ReadUvarint(e.r)
+		if err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+			return nil, &InvalidExportFileError{reason: fmt.Sprintf("can't read record size, %v", err)}
+		}
+		if size > maxExportRecordSize {
+			return nil, &InvalidExportFileError{reason: fmt.Sprintf("record size %d exceeds %d bytes", size, maxExportRecordSize)}
+		}
+		data = make([]byte, size)
+		if _, err := io.ReadFull(e.r, data); err != nil {
+			return nil, &InvalidExportFileError{reason: fmt.Sprintf("can't read record, %v", err)}
+		}
+	}
+
+	record := &asit.ExportRecord{}
+	var err error
+	if e.format == EXPORT_FORMAT_NDJSON {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, record)
+	} else {
+		err = proto.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, record)
+	}
+	if err != nil {
+		return nil, &InvalidExportFileError{reason: fmt.Sprintf("can't decode record, %v", err)}
+	}
+	return record, nil
+}
+
+// readExportHeader reads the header which must be the first record of the file
+func readExportHeader(r *ExportReader) (*asit.ExportHeader, error) {
+	record, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, &InvalidExportFileError{reason: "the file is empty"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	header := record.GetHeader()
+	if header == nil {
+		return nil, &InvalidExportFileError{reason: "the first record isn't the header"}
+	}
+	if header.Version < 1 || header.Version > EXPORT_VERSION {
+		return nil, &InvalidExportFileError{reason: fmt.Sprintf("unsupported version %d, the supported one is %d", header.Version, EXPORT_VERSION)}
+	}
+	return header, nil
+}
+
+// ExportClients writes all the clients with their properties and keys to the writer and returns the number of the exported clients.
+// The clients are read page by page, so the clients changed during the export may be exported in any of their states.
+// The trashed clients and the client history are not exported.
+func ExportClients(ctx context.Context, r ClientsRepository, w *ExportWriter) (int, error) {
+	header := &asit.ExportHeader{Version: EXPORT_VERSION, Created: timestamppb.New(time.Now())}
+	if err := w.Write(&asit.ExportRecord{Record: &asit.ExportRecord_Header{Header: header}}); err != nil {
+		return 0, fmt.Errorf("can't write export header, %w", err)
+	}
+
+	exported := 0
+	cursor := ""
+	for {
+		clients, nextCursor, err := r.GetClients(ctx, cursor, allClientsPageSize)
+		if err != nil {
+			return exported, err
+		}
+		for _, c := range clients {
+			// the pages don't contain client properties
+			client, err := r.GetClientById(ctx, c.Id)
+			if err != nil {
+				return exported, err
+			}
+			if client == nil {
+				// removed concurrently
+				continue
+			}
+			clientKeys, err := r.GetClientKeys(ctx, c.Id)
+			if err != nil {
+				return exported, err
+			}
+			record := &asit.ExportedClient{Client: client}
+			if clientKeys != nil {
+				record.Keys, record.KeysExpires = clientKeys.Keys, clientKeys.Expires
+			}
+			if err := w.Write(&asit.ExportRecord{Record: &asit.ExportRecord_Client{Client: record}}); err != nil {
+				return exported, fmt.Errorf("can't write client %s, %w", c.Id, err)
+			}
+			exported++
+		}
+		if nextCursor == "" {
+			return exported, w.Flush()
+		}
+		cursor = nextCursor
+	}
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// KEY_CLIENT_HISTORY_PREFIX keys keep the last sequence of the client history, the entries are kept in separate keys
+	KEY_CLIENT_HISTORY_PREFIX       = "client_history:"
+	KEY_CLIENT_HISTORY_ENTRY_PREFIX = "client_history_entry:"
+)
+
+// clientHistoryPageSize is the number of history entries read at once when the client revision is searched
+const clientHistoryPageSize = 100
+
+// AuditInfo identifies the origin of the client changes, it's recorded in the client history
+type AuditInfo struct {
+	RequestId string
+	Caller    string
+}
+
+type auditInfoKey struct{}
+
+// WithAuditInfo returns the context recording the audit info in the history of the clients changed with it
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoKey{}, info)
+}
+
+func auditInfoFromContext(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditInfoKey{}).(AuditInfo)
+	return info
+}
+
+type NotFoundClientRevisionError struct {
+	id       string
+	revision int64
+}
+
+func (e *NotFoundClientRevisionError) Error() string {
+	return fmt.Sprintf("not found revision %d in the history of client %s", e.revision, e.id)
+}
+
+// newClientHistoryEntry returns the entry of the change made now with the audit info of the context
+func newClientHistoryEntry(ctx context.Context, change asit.ClientChangeType) *asit.ClientHistoryEntry {
+	info := auditInfoFromContext(ctx)
+	return &asit.ClientHistoryEntry{
+		Change:    change,
+		Time:      timestamppb.Now(),
+		RequestId: info.RequestId,
+		Caller:    info.Caller,
+	}
+}
+
+func parseClientHistoryCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	sequence, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || sequence < 0 {
+		return 0, &InvalidClientsQueryError{reason: "malformed cursor"}
+	}
+	return sequence, nil
+}
+
+// clientAtRevision searches the client history for the latest value written with the revision
+func clientAtRevision(ctx context.Context, r ClientsRepository, clientId string, revision int64) (*asit.Client, error) {
+	var client *asit.Client
+	cursor := ""
+	for {
+		entries, nextCursor, err := r.GetClientHistory(ctx, clientId, cursor, clientHistoryPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.NewClient != nil && entry.NewClient.Revision == revision {
+				client = entry.NewClient
+			}
+		}
+		if nextCursor == "" {
+			return client, nil
+		}
+		cursor = nextCursor
+	}
+}
+
+// rollbackUpdate returns the update function of updateClient which sets the name and the client properties of the target
+func rollbackUpdate(clientId string, target *asit.Client, ifRevision int64) func(current *asit.Client) (*asit.Client, error) {
+	return func(current *asit.Client) (*asit.Client, error) {
+		if err := checkClientRevision(clientId, current, ifRevision); err != nil {
+			return nil, err
+		}
+		if current == nil {
+			return nil, &NotFoundClientByIdError{id: clientId}
+		}
+		current.Name = target.Name
+		current.ClientProperties = target.ClientProperties
+		return current, nil
+	}
+}
+
+func kvClientHistoryEntryKey(clientId string, sequence int64) string {
+	// zero-padded, so the entry keys of a client are ordered as the entries
+	return fmt.Sprintf("%s%s:%020d", KEY_CLIENT_HISTORY_ENTRY_PREFIX, clientId, sequence)
+}
+
+// kvHistoryAppender appends an entry to the client history in the same atomic update as the recorded change
+type kvHistoryAppender struct {
+	clientId string
+	entry    *asit.ClientHistoryEntry
+}
+
+func newKVHistoryAppender(clientId string) *kvHistoryAppender {
+	return &kvHistoryAppender{clientId: clientId}
+}
+
+// command locks the history of the client. It must follow the commands of the recorded change, because newEntry
+// is called after their updaters. newEntry returns nil if there are no changes to record.
+func (a *kvHistoryAppender) command(newEntry func() *asit.ClientHistoryEntry) SetValueCommand {
+	return SetValueCommand{
+		key: KEY_CLIENT_HISTORY_PREFIX + a.clientId,
+		updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			a.entry = newEntry()
+			if a.entry == nil {
+				return false, nil, nil
+			}
+			head := &asit.ClientHistoryHead{}
+			if _, err := oldValue(head); err != nil {
+				return false, nil, err
+			}
+			head.LastSequence++
+			a.entry.Sequence = head.LastSequence
+			return true, head, nil
+		},
+	}
+}
+
+// unlockedSets returns the command writing the appended entry
+func (a *kvHistoryAppender) unlockedSets() []SetValueUnlockedCommand {
+	if a.entry == nil {
+		return nil
+	}
+	return []SetValueUnlockedCommand{{key: kvClientHistoryEntryKey(a.clientId, a.entry.Sequence), newValue: a.entry}}
+}
+
+func (r *KVClientsRepository) GetClientHistory(ctx context.Context, clientId string, cursor string, limit int) ([]*asit.ClientHistoryEntry, string, error) {
+	after, err := parseClientHistoryCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	head := &asit.ClientHistoryHead{}
+	if _, err := r.storage.Get(ctx, KEY_CLIENT_HISTORY_PREFIX+clientId, head); err != nil {
+		return nil, "", fmt.Errorf("can't retrieve db key %s, %w", KEY_CLIENT_HISTORY_PREFIX+clientId, err)
+	}
+
+	entries := []*asit.ClientHistoryEntry{}
+	sequence := after
+	for sequence < head.LastSequence && (limit <= 0 || len(entries) < limit) {
+		sequence++
+		key := kvClientHistoryEntryKey(clientId, sequence)
+		entry := &asit.ClientHistoryEntry{}
+		found, err := r.storage.Get(ctx, key, entry)
+		if err != nil {
+			return nil, "", fmt.Errorf("can't retrieve db key %s, %w", key, err)
+		}
+		if found {
+			entries = append(entries, entry)
+		}
+	}
+
+	nextCursor := ""
+	if sequence < head.LastSequence {
+		nextCursor = strconv.FormatInt(sequence, 10)
+	}
+	return entries, nextCursor, nil
+}
+
+func (r *KVClientsRepository) GetClientRevision(ctx context.Context, clientId string, revision int64) (*asit.Client, error) {
+	return clientAtRevision(ctx, r, clientId, revision)
+}
+
+func (r *KVClientsRepository) RollbackClient(ctx context.Context, clientId string, revision int64, ifRevision int64) (*asit.Client, error) {
+	target, err := r.GetClientRevision(ctx, clientId, revision)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, &NotFoundClientRevisionError{id: clientId, revision: revision}
+	}
+	client, err := r.updateClient(ctx, clientId, asit.ClientChangeType_CLIENT_ROLLED_BACK, rollbackUpdate(clientId, target, ifRevision))
+	if err != nil {
+		return nil, fmt.Errorf("can't roll back client with Id %s, %w", clientId, err)
+	}
+	return client, nil
+}
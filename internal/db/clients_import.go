@@ -0,0 +1,238 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+)
+
+const (
+	// IMPORT_MODE_MERGE creates and updates the imported clients and adds the imported keys, other clients and keys are kept
+	IMPORT_MODE_MERGE = "merge"
+	// IMPORT_MODE_REPLACE purges all the clients including the trashed ones before the import
+	IMPORT_MODE_REPLACE = "replace"
+)
+
+type InvalidImportModeError struct {
+	mode string
+}
+
+func (e *InvalidImportModeError) Error() string {
+	return fmt.Sprintf("unsupported import mode %q, expected %s or %s", e.mode, IMPORT_MODE_MERGE, IMPORT_MODE_REPLACE)
+}
+
+// clientsImport keeps the state of ImportClients. The dry run predicts the results of the writes
+// by the current values of the repository and the already imported records.
+type clientsImport struct {
+	r      ClientsRepository
+	dryRun bool
+	report *asit.ImportReport
+	// importedKeys are the clients of the keys imported so far
+	importedKeys map[string]string
+	// trashedIds and reservedKeys are the trashed clients and their keys, they are used only by the dry run
+	trashedIds   map[string]bool
+	reservedKeys map[string]string
+	// replaced is true if all the clients are purged, so the dry run doesn't look for the existing values
+	replaced bool
+}
+
+// ImportClients reads the clients written by ExportClients and stores them in the repository.
+// The clients are imported one by one, so the failed import leaves the clients imported before the failure.
+// The revisions and lastUpdated of the imported clients are assigned by the repository, the expired keys are skipped.
+// The dry run reads the file and reports the conflicts without changing the repository.
+func ImportClients(ctx context.Context, r ClientsRepository, reader *ExportReader, mode string, dryRun bool) (*asit.ImportReport, error) {
+	if mode != IMPORT_MODE_MERGE && mode != IMPORT_MODE_REPLACE {
+		return nil, &InvalidImportModeError{mode: mode}
+	}
+	if _, err := readExportHeader(reader); err != nil {
+		return nil, err
+	}
+
+	i := &clientsImport{
+		r:            r,
+		dryRun:       dryRun,
+		report:       &asit.ImportReport{Mode: mode, DryRun: dryRun},
+		importedKeys: map[string]string{},
+		trashedIds:   map[string]bool{},
+		reservedKeys: map[string]string{},
+	}
+	if mode == IMPORT_MODE_REPLACE {
+		if err := i.purgeAll(ctx); err != nil {
+			return i.report, err
+		}
+	} else if dryRun {
+		if err := i.readTrash(ctx); err != nil {
+			return i.report, err
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return i.report, nil
+		}
+		if err != nil {
+			return i.report, err
+		}
+		exported := record.GetClient()
+		if exported == nil || exported.Client.GetId() == "" {
+			return i.report, &InvalidExportFileError{reason: "the record isn't a client with an id"}
+		}
+		if err := i.importClient(ctx, exported); err != nil {
+			return i.report, err
+		}
+	}
+}
+
+// purgeAll removes all the clients with their keys, the client history is kept
+func (i *clientsImport) purgeAll(ctx context.Context) error {
+	i.replaced = true
+	clients, err := i.r.GetAllClients(ctx)
+	if err != nil {
+		return err
+	}
+	for _, client := range clients {
+		if !i.dryRun {
+			if err := i.r.RemoveClient(ctx, client.Id); err != nil {
+				return err
+			}
+		}
+		i.trashedIds[client.Id] = true
+	}
+	if err := i.readTrash(ctx); err != nil {
+		return err
+	}
+	for id := range i.trashedIds {
+		if !i.dryRun {
+			err := i.r.PurgeClient(ctx, id)
+			var notFoundErr *NotFoundClientByIdError
+			if err != nil && !errors.As(err, &notFoundErr) {
+				return err
+			}
+		}
+		i.report.RemovedClients++
+	}
+	i.trashedIds, i.reservedKeys = map[string]bool{}, map[string]string{}
+	return nil
+}
+
+func (i *clientsImport) readTrash(ctx context.Context) error {
+	cursor := ""
+	for {
+		trashed, nextCursor, err := i.r.GetTrashedClients(ctx, cursor, allClientsPageSize)
+		if err != nil {
+			return err
+		}
+		for _, t := range trashed {
+			i.trashedIds[t.Client.Id] = true
+			for _, key := range t.Keys {
+				i.reservedKeys[key] = t.Client.Id
+			}
+		}
+		if nextCursor == "" {
+			return nil
+		}
+		cursor = nextCursor
+	}
+}
+
+func (i *clientsImport) conflict(conflictType asit.ImportConflictType, clientId string, key string, description string) {
+	i.report.Conflicts = append(i.report.Conflicts, &asit.ImportConflict{Type: conflictType, ClientId: clientId, Key: key, Description: description})
+}
+
+func (i *clientsImport) importClient(ctx context.Context, exported *asit.ExportedClient) error {
+	client := exported.Client
+	client.Revision, client.LastUpdated = 0, nil
+
+	existing := false
+	if !i.replaced {
+		current, err := i.r.GetClientById(ctx, client.Id)
+		if err != nil {
+			return err
+		}
+		existing = current != nil
+	}
+	if i.trashedIds[client.Id] {
+		i.conflict(asit.ImportConflictType_TRASHED_CLIENT_ID, client.Id, "", "the client is in the trash")
+		return nil
+	}
+	if !i.dryRun {
+		err := i.r.SetClient(ctx, client)
+		var conflictErr *ClientIdConflictError
+		if errors.As(err, &conflictErr) {
+			i.conflict(asit.ImportConflictType_TRASHED_CLIENT_ID, client.Id, "", "the client is in the trash")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if existing {
+		i.report.UpdatedClients++
+	} else {
+		i.report.CreatedClients++
+	}
+
+	now := time.Now()
+	for _, key := range exported.Keys {
+		if clientKeyExpired(exported.KeysExpires, key, now) {
+			continue
+		}
+		if owner, ok := i.importedKeys[key]; ok && owner != client.Id {
+			i.conflict(asit.ImportConflictType_DUPLICATE_IMPORTED_KEY, client.Id, key, "the key is imported for client "+owner)
+			continue
+		}
+		owner, err := i.keyOwner(ctx, key)
+		if err != nil {
+			return err
+		}
+		if owner != "" && owner != client.Id {
+			i.conflict(asit.ImportConflictType_CLIENT_KEY_TAKEN, client.Id, key, "the key is associated with client "+owner)
+			continue
+		}
+		taken, err := i.addKey(ctx, client.Id, key, clientKeyTTL(exported.KeysExpires, key, now))
+		if err != nil {
+			return err
+		}
+		if taken {
+			i.conflict(asit.ImportConflictType_CLIENT_KEY_TAKEN, client.Id, key, "the key is associated with another client or reserved by a trashed client")
+			continue
+		}
+		i.importedKeys[key] = client.Id
+		i.report.ImportedKeys++
+	}
+	return nil
+}
+
+// keyOwner returns the id of the existing client associated with the key or reserving it
+func (i *clientsImport) keyOwner(ctx context.Context, key string) (string, error) {
+	if owner, ok := i.importedKeys[key]; ok || i.replaced {
+		return owner, nil
+	}
+	if owner, ok := i.reservedKeys[key]; ok {
+		return owner, nil
+	}
+	client, err := i.r.GetClientByKey(ctx, key)
+	if err != nil || client == nil {
+		return "", err
+	}
+	return client.Id, nil
+}
+
+// addKey adds the key to the client, it returns true if the key is associated with another client
+// or reserved by a trashed client
+func (i *clientsImport) addKey(ctx context.Context, clientId string, key string, ttl time.Duration) (bool, error) {
+	if i.dryRun {
+		return false, nil
+	}
+	err := i.r.AddClientKeyWithTTL(ctx, clientId, key, ttl)
+	var nonUniqueErr *NonUniqueClientKeyError
+	if errors.As(err, &nonUniqueErr) {
+		return true, nil
+	}
+	return false, err
+}
@@ -0,0 +1,32 @@
+package db
+
+import "github.com/derbylock/async-integration-testing/pkg/asit"
+
+// ClientPatch is the JSON Merge Patch (RFC 7396) of the client
+type ClientPatch struct {
+	// Name is the new name of the client, nil keeps the current name
+	Name *string
+	// RemoveAllProperties removes all the client properties before the Properties are applied
+	RemoveAllProperties bool
+	// Properties are the new values of the client properties, the properties with nil values are removed
+	Properties map[string]*string
+}
+
+func (p ClientPatch) apply(client *asit.Client) {
+	if p.Name != nil {
+		client.Name = *p.Name
+	}
+	if p.RemoveAllProperties {
+		client.ClientProperties = nil
+	}
+	for name, value := range p.Properties {
+		if value == nil {
+			delete(client.ClientProperties, name)
+			continue
+		}
+		if client.ClientProperties == nil {
+			client.ClientProperties = map[string]string{}
+		}
+		client.ClientProperties[name] = *value
+	}
+}
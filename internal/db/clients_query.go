@@ -0,0 +1,110 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	CLIENTS_SORT_BY_ID           = "id"
+	CLIENTS_SORT_BY_NAME         = "name"
+	CLIENTS_SORT_BY_LAST_UPDATED = "lastUpdated"
+)
+
+// ClientsQuery selects a page of clients. Clients are always sorted in the ascending order,
+// the id is used as a tie-breaker for the clients with the same name or lastUpdated.
+type ClientsQuery struct {
+	// SortBy is one of CLIENTS_SORT_BY_*, CLIENTS_SORT_BY_ID is used if it's empty
+	SortBy string
+	// NamePrefix selects only the clients with names starting with it (case-sensitive)
+	NamePrefix string
+	// Properties selects only the clients having all the client properties with the specified values
+	Properties map[string]string
+	// Cursor is the next cursor returned by the previous page of the same query, the empty cursor starts from the first client
+	Cursor string
+	// Limit is the max number of returned clients, all the clients are returned if it's <= 0
+	Limit int
+}
+
+func (q ClientsQuery) sortBy() string {
+	if q.SortBy == "" {
+		return CLIENTS_SORT_BY_ID
+	}
+	return q.SortBy
+}
+
+func (q ClientsQuery) validate() error {
+	switch q.sortBy() {
+	case CLIENTS_SORT_BY_ID, CLIENTS_SORT_BY_NAME, CLIENTS_SORT_BY_LAST_UPDATED:
+	default:
+		return &InvalidClientsQueryError{reason: "unknown sort field " + q.SortBy}
+	}
+	if q.Cursor != "" {
+		if _, _, err := parseClientsCursor(q.sortBy(), q.Cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches checks the filters of the query
+func (q ClientsQuery) matches(client *asit.Client) bool {
+	if !strings.HasPrefix(client.Name, q.NamePrefix) {
+		return false
+	}
+	for name, value := range q.Properties {
+		actual, ok := client.ClientProperties[name]
+		if !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+type InvalidClientsQueryError struct {
+	reason string
+}
+
+func (e *InvalidClientsQueryError) Error() string {
+	return fmt.Sprintf("invalid clients query, %s", e.reason)
+}
+
+// clientsCursor returns the position of the client in the specified sort order.
+// It's also a member of the corresponding clients index of the KVClientsRepository.
+func clientsCursor(sortBy string, client *asit.Client) string {
+	switch sortBy {
+	case CLIENTS_SORT_BY_NAME:
+		return client.Name + "\x00" + client.Id
+	case CLIENTS_SORT_BY_LAST_UPDATED:
+		return sortableTimestamp(client.LastUpdated) + "\x00" + client.Id
+	}
+	return client.Id
+}
+
+// sortableTimestamp formats the timestamp so that the lexicographical order of the strings is the order of the timestamps
+func sortableTimestamp(timestamp *timestamppb.Timestamp) string {
+	// zero-padded to keep the lexicographical order of the numbers
+	return fmt.Sprintf("%020d", timestamp.AsTime().UnixNano())
+}
+
+// parseClientsCursor returns the sort field value and the client id of the cursor
+func parseClientsCursor(sortBy string, cursor string) (string, string, error) {
+	if sortBy == CLIENTS_SORT_BY_ID {
+		return cursor, cursor, nil
+	}
+	separator := strings.LastIndexByte(cursor, 0)
+	if separator < 0 {
+		return "", "", &InvalidClientsQueryError{reason: "malformed cursor"}
+	}
+	value, id := cursor[:separator], cursor[separator+1:]
+	if sortBy == CLIENTS_SORT_BY_LAST_UPDATED {
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "", "", &InvalidClientsQueryError{reason: "malformed cursor"}
+		}
+	}
+	return value, id, nil
+}
@@ -0,0 +1,203 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/proto"
+)
+
+// RewriteValues writes all the values of the clients again with the current storage codec, e.g. to encrypt them
+// with the active key after the rotation of the encryption keys or to move them to another codec encoding.
+// The aliases and the reservations of the client keys are moved to the hashed names if the key names are hashed.
+// The values are rewritten with their TTLs by atomic commands, so the concurrent changes are kept.
+// The client history doesn't record the rewrites. It returns the number of the rewritten values.
+func (r *KVClientsRepository) RewriteValues(ctx context.Context) (int, error) {
+	if err := r.migrateIndexes(ctx); err != nil {
+		return 0, err
+	}
+
+	rewritten := 0
+	for _, prefix := range []string{KEY_CLIENT_PREFIX, KEY_TRASHED_CLIENT_PREFIX} {
+		keys, err := r.storage.ScanKeys(ctx, prefix)
+		if err != nil {
+			return rewritten, fmt.Errorf("can't scan db keys %s*, %w", prefix, err)
+		}
+		for _, key := range keys {
+			var count int
+			if prefix == KEY_CLIENT_PREFIX {
+				count, err = r.rewriteClient(ctx, key[len(prefix):])
+			} else {
+				count, err = r.rewriteTrashedClient(ctx, key[len(prefix):])
+			}
+			rewritten += count
+			if err != nil {
+				return rewritten, fmt.Errorf("can't rewrite db key %s, %w", key, err)
+			}
+		}
+	}
+
+	historyValues := map[string]func() proto.Message{
+		KEY_CLIENT_HISTORY_PREFIX:       func() proto.Message { return &asit.ClientHistoryHead{} },
+		KEY_CLIENT_HISTORY_ENTRY_PREFIX: func() proto.Message { return &asit.ClientHistoryEntry{} },
+	}
+	for prefix, newValue := range historyValues {
+		count, err := rewriteStorageValues(ctx, r.storage, prefix, newValue)
+		rewritten += count
+		if err != nil {
+			return rewritten, err
+		}
+	}
+	return rewritten, nil
+}
+
+// rewriteStorageValues writes the values of the keys starting with the prefix again, the values must have no TTL.
+// It returns the number of the rewritten values.
+func rewriteStorageValues(ctx context.Context, storage Storage, prefix string, newValue func() proto.Message) (int, error) {
+	keys, err := storage.ScanKeys(ctx, prefix)
+	if err != nil {
+		return 0, fmt.Errorf("can't scan db keys %s*, %w", prefix, err)
+	}
+	rewritten := 0
+	for _, key := range keys {
+		rewrite := newValueRewrite(key, 0, newValue, nil)
+		if err := storage.SetAndDeleteAtomically(ctx, []SetValueCommand{rewrite.command()}, []string{},
+			func() []SetValueUnlockedCommand { return nil }, func() []string { return nil }, nil); err != nil {
+			return rewritten, fmt.Errorf("can't rewrite db key %s, %w", key, err)
+		}
+		if rewrite.done {
+			rewritten++
+		}
+	}
+	return rewritten, nil
+}
+
+// valueRewrite writes the value of the key again if it exists and it's accepted by the keep function, nil keeps all the values
+type valueRewrite struct {
+	key      string
+	ttl      time.Duration
+	newValue func() proto.Message
+	keep     func(v proto.Message) bool
+	done     bool
+}
+
+func newValueRewrite(key string, ttl time.Duration, newValue func() proto.Message, keep func(v proto.Message) bool) *valueRewrite {
+	return &valueRewrite{key: key, ttl: ttl, newValue: newValue, keep: keep}
+}
+
+func (w *valueRewrite) command() SetValueCommand {
+	return SetValueCommand{
+		key: w.key,
+		ttl: w.ttl,
+		updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			v := w.newValue()
+			found, err := oldValue(v)
+			w.done = err == nil && found && (w.keep == nil || w.keep(v))
+			return w.done, v, err
+		},
+	}
+}
+
+// clientKeyRewrites returns the rewrites of the alias or the reservation of every key owned by the client.
+// The value of the plain name is moved to the hashed name, the moved value is written only if the hashed name is missing.
+func (r *KVClientsRepository) clientKeyRewrites(prefix string, clientId string, keys []string, ttl func(key string) time.Duration) ([]SetValueCommand, []*valueRewrite) {
+	var cmds []SetValueCommand
+	var rewrites []*valueRewrite
+	owned := func(v proto.Message) bool { return v.(*asit.Client).Id == clientId }
+	newClient := func() proto.Message { return &asit.Client{} }
+	for _, key := range keys {
+		rewrite := newValueRewrite(prefix+r.clientKeyId(key), ttl(key), newClient, owned)
+		if plainKey := prefix + key; plainKey != rewrite.key {
+			var moved proto.Message
+			cmds = append(cmds, SetValueCommand{
+				key: plainKey,
+				updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+					moved = nil
+					v := newClient()
+					found, err := oldValue(v)
+					if err != nil || !found || !owned(v) {
+						return false, nil, err
+					}
+					moved = v
+					return true, nil, nil
+				},
+			})
+			hashed := rewrite.command()
+			cmds = append(cmds, SetValueCommand{
+				key: hashed.key,
+				ttl: hashed.ttl,
+				updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+					requiresUpdate, v, err := hashed.updater(oldValue)
+					if err != nil || requiresUpdate {
+						return requiresUpdate, v, err
+					}
+					found, err := oldValue(newClient())
+					if err != nil || found || moved == nil {
+						return false, nil, err
+					}
+					rewrite.done = true
+					return true, moved, nil
+				},
+			})
+		} else {
+			cmds = append(cmds, rewrite.command())
+		}
+		rewrites = append(rewrites, rewrite)
+	}
+	return cmds, rewrites
+}
+
+// rewriteClient rewrites the client, its keys list and the aliases of its active keys
+func (r *KVClientsRepository) rewriteClient(ctx context.Context, clientId string) (int, error) {
+	clientKeys := &asit.ClientKeys{}
+	if _, err := r.storage.Get(ctx, KEY_CLIENT_KEYS_PREFIX+clientId, clientKeys); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	keys, expires := activeClientKeys(clientKeys.Keys, clientKeys.Expires, now)
+
+	client := newValueRewrite(KEY_CLIENT_PREFIX+clientId, 0, func() proto.Message { return &asit.Client{} }, nil)
+	keysList := newValueRewrite(KEY_CLIENT_KEYS_PREFIX+clientId, 0, func() proto.Message { return &asit.ClientKeys{} }, nil)
+	aliases, aliasRewrites := r.clientKeyRewrites(KEY_CLIENT_KEY_PREFIX, clientId, keys, func(key string) time.Duration {
+		return clientKeyTTL(expires, key, now)
+	})
+	err := r.storage.SetAndDeleteAtomically(ctx, append([]SetValueCommand{client.command(), keysList.command()}, aliases...), []string{},
+		func() []SetValueUnlockedCommand { return nil }, func() []string { return nil }, nil)
+	if err != nil {
+		return 0, err
+	}
+	return countDone(append(aliasRewrites, client, keysList)...), nil
+}
+
+// rewriteTrashedClient rewrites the trashed client and the reservations of its keys
+func (r *KVClientsRepository) rewriteTrashedClient(ctx context.Context, clientId string) (int, error) {
+	trashedClient := &asit.TrashedClient{}
+	if _, err := r.storage.Get(ctx, KEY_TRASHED_CLIENT_PREFIX+clientId, trashedClient); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	keys, expires := activeClientKeys(trashedClient.Keys, trashedClient.KeysExpires, now)
+
+	trashed := newValueRewrite(KEY_TRASHED_CLIENT_PREFIX+clientId, 0, func() proto.Message { return &asit.TrashedClient{} }, nil)
+	reservations, reservationRewrites := r.clientKeyRewrites(KEY_RESERVED_CLIENT_KEY_PREFIX, clientId, keys, func(key string) time.Duration {
+		return clientKeyTTL(expires, key, now)
+	})
+	err := r.storage.SetAndDeleteAtomically(ctx, append([]SetValueCommand{trashed.command()}, reservations...), []string{},
+		func() []SetValueUnlockedCommand { return nil }, func() []string { return nil }, nil)
+	if err != nil {
+		return 0, err
+	}
+	return countDone(append(reservationRewrites, trashed)...), nil
+}
+
+func countDone(rewrites ...*valueRewrite) int {
+	count := 0
+	for _, rewrite := range rewrites {
+		if rewrite.done {
+			count++
+		}
+	}
+	return count
+}
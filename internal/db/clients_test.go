@@ -0,0 +1,70 @@
+package db_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/internal/db/dbtest"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+)
+
+func TestKVClientsRepository(t *testing.T) {
+	for name, newStorage := range storages {
+		newStorage := newStorage
+		t.Run(name, func(t *testing.T) {
+			dbtest.RunKVClientsRepositoryTests(t, newStorage)
+		})
+	}
+}
+
+func TestSQLClientsRepository(t *testing.T) {
+	dbtest.RunClientsRepositoryTests(t, func(t *testing.T) db.ClientsRepository {
+		sqlDB, err := db.OpenSQLite(filepath.Join(t.TempDir(), "asit.sqlite"))
+		if err != nil {
+			t.Fatalf("can't open sqlite db, %v", err)
+		}
+		t.Cleanup(func() { sqlDB.Close() })
+		r, err := db.NewSQLClientsRepository(sqlDB, db.SQLITE_DIALECT)
+		if err != nil {
+			t.Fatalf("can't create sql clients repository, %v", err)
+		}
+		return r
+	})
+}
+
+// TestSQLStorageExpiredHashedClientKeys checks that the expired aliases of the hashed client keys are deleted,
+// SQLStorage only hides the expired rows until they are written again
+func TestSQLStorageExpiredHashedClientKeys(t *testing.T) {
+	ctx := context.Background()
+	sqlDB, err := db.OpenSQLite(filepath.Join(t.TempDir(), "asit.sqlite"))
+	if err != nil {
+		t.Fatalf("can't open sqlite db, %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	s, err := db.NewSQLStorage(sqlDB, db.SQLITE_DIALECT, db.PROTO_CODEC)
+	if err != nil {
+		t.Fatalf("can't create sql storage, %v", err)
+	}
+	r := db.NewKVClientsRepositoryWithHashedKeys(s, []byte("test hmac key"))
+	if err := r.SetClient(ctx, &asit.Client{Id: "1", Name: "first"}); err != nil {
+		t.Fatalf("can't set client, %v", err)
+	}
+	if err := r.AddClientKeyWithTTL(ctx, "1", "k1", 10*time.Millisecond); err != nil {
+		t.Fatalf("can't add client key, %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if expired, err := r.ExpireClientKeys(ctx, time.Now()); err != nil || expired != 1 {
+		t.Fatalf("ExpireClientKeys returned (%d, %v), expected (1, nil)", expired, err)
+	}
+	if client, err := r.GetClientByKey(ctx, "k1"); err != nil || client != nil {
+		t.Errorf("GetClientByKey(k1) returned (%v, %v) after the TTL, expected nil", client, err)
+	}
+	var aliases int
+	if err := sqlDB.QueryRow("SELECT COUNT(*) FROM kv_values WHERE k LIKE ?", db.KEY_CLIENT_KEY_PREFIX+"%").Scan(&aliases); err != nil || aliases != 0 {
+		t.Errorf("%d rows of the client key aliases are kept, %v", aliases, err)
+	}
+}
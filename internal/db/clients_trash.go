@@ -0,0 +1,350 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	KEY_TRASHED_CLIENT_PREFIX      = "trashed_client:"
+	KEY_RESERVED_CLIENT_KEY_PREFIX = "reserved_client_key:"
+	KEY_TRASHED_CLIENTS_INDEX      = "trashed_clients_index"
+	// KEY_TRASHED_CLIENTS_BY_DELETION_INDEX members are the sortable deletion timestamps followed by the client ids
+	KEY_TRASHED_CLIENTS_BY_DELETION_INDEX = "trashed_clients_by_deletion_index"
+)
+
+type ClientIdConflictError struct {
+	id string
+}
+
+func (e *ClientIdConflictError) Error() string {
+	return fmt.Sprintf("client %s exists both in the trash and outside of it", e.id)
+}
+
+func trashedClientDeletionMember(trashed *asit.TrashedClient) string {
+	return sortableTimestamp(trashed.Deleted) + "\x00" + trashed.Client.Id
+}
+
+// RemoveClientIfRevision moves the client to the trash. The client key aliases are replaced with the key reservations,
+// so GetClientByKey doesn't find the trashed client, but its keys can't be associated with other clients.
+// The reservations of the keys with TTL expire with the keys.
+func (r *KVClientsRepository) RemoveClientIfRevision(ctx context.Context, clientId string, revision int64) error {
+	if err := r.migrateIndexes(ctx); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var keys *asit.ClientKeys
+	var oldClient *asit.Client
+	var trashed *asit.TrashedClient
+	history := newKVHistoryAppender(clientId)
+	err := r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
+		{
+			key: KEY_CLIENT_KEYS_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				keys = &asit.ClientKeys{}
+				found, err := oldValue(keys)
+				if err != nil {
+					return false, nil, err
+				}
+				return found, nil, nil
+			},
+		},
+		{
+			key: KEY_CLIENT_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				oldClient = nil
+				old := &asit.Client{}
+				found, err := oldValue(old)
+				if err != nil {
+					return false, nil, err
+				}
+				if found {
+					oldClient = old
+				}
+				if err := checkClientRevision(clientId, oldClient, revision); err != nil {
+					return false, nil, err
+				}
+				return found, nil, nil
+			},
+		},
+		{
+			key: KEY_TRASHED_CLIENT_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				trashed = nil
+				found, err := oldValue(&asit.TrashedClient{})
+				if err != nil {
+					return false, nil, err
+				}
+				if found {
+					return false, nil, &ClientIdConflictError{id: clientId}
+				}
+				if oldClient == nil {
+					// nothing to remove
+					return false, nil, nil
+				}
+				trashed = &asit.TrashedClient{Client: oldClient, Deleted: timestamppb.New(now)}
+				trashed.Keys, trashed.KeysExpires = activeClientKeys(keys.Keys, keys.Expires, now)
+				return true, trashed, nil
+			},
+		},
+		history.command(func() *asit.ClientHistoryEntry {
+			if trashed == nil {
+				return nil
+			}
+			entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_DELETED)
+			entry.OldClient, entry.OldKeys = trashed.Client, trashed.Keys
+			return entry
+		}),
+	},
+		[]string{},
+		func() []SetValueUnlockedCommand {
+			if trashed == nil {
+				return nil
+			}
+			cmds := history.unlockedSets()
+			for _, key := range trashed.Keys {
+				cmds = append(cmds, SetValueUnlockedCommand{key: r.reservedClientKeyName(key), newValue: &asit.Client{Id: clientId},
+					ttl: clientKeyTTL(trashed.KeysExpires, key, now)})
+			}
+			return cmds
+		}, func() []string {
+			if trashed == nil {
+				return nil
+			}
+			res := make([]string, len(keys.Keys))
+			for i, key := range keys.Keys {
+				res[i] = r.clientKeyName(key)
+			}
+			return res
+		}, func() []IndexCommand {
+			if trashed == nil {
+				return nil
+			}
+			cmds := []IndexCommand{
+				NewIndexRemoveCommand(KEY_CLIENTS_INDEX, clientId),
+				NewIndexAddCommand(KEY_TRASHED_CLIENTS_INDEX, clientId),
+				NewIndexAddCommand(KEY_TRASHED_CLIENTS_BY_DELETION_INDEX, trashedClientDeletionMember(trashed)),
+			}
+			for key, expires := range keys.Expires {
+				cmds = append(cmds, NewIndexRemoveCommand(KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, r.clientKeyExpiryMember(expires, clientId, key)))
+			}
+			return append(cmds, clientSortIndexCommands(oldClient, nil)...)
+		})
+	if err != nil {
+		return fmt.Errorf("can't delete db key %s, %w", KEY_CLIENT_PREFIX+clientId, err)
+	}
+
+	return nil
+}
+
+func (r *KVClientsRepository) GetTrashedClients(ctx context.Context, cursor string, limit int) ([]*asit.TrashedClient, string, error) {
+	ids, err := r.storage.IndexRange(ctx, KEY_TRASHED_CLIENTS_INDEX, cursor, limit)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't retrieve db index %s, %w", KEY_TRASHED_CLIENTS_INDEX, err)
+	}
+	clients := make([]*asit.TrashedClient, 0, len(ids))
+	for _, id := range ids {
+		trashed, err := r.GetTrashedClientById(ctx, id)
+		if err != nil {
+			return nil, "", err
+		}
+		if trashed == nil {
+			// restored or purged concurrently
+			continue
+		}
+		clients = append(clients, trashed)
+	}
+
+	nextCursor := ""
+	if limit > 0 && len(ids) == limit {
+		nextCursor = ids[len(ids)-1]
+	}
+	return clients, nextCursor, nil
+}
+
+func (r *KVClientsRepository) GetTrashedClientById(ctx context.Context, id string) (*asit.TrashedClient, error) {
+	trashed := &asit.TrashedClient{}
+	ok, err := r.storage.Get(ctx, KEY_TRASHED_CLIENT_PREFIX+id, trashed)
+	if err != nil {
+		return nil, fmt.Errorf("can't retrieve db key %s, %w", KEY_TRASHED_CLIENT_PREFIX+id, err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	trashed.Keys, trashed.KeysExpires = activeClientKeys(trashed.Keys, trashed.KeysExpires, time.Now())
+	return trashed, nil
+}
+
+// RestoreClient moves the client from the trash back and associates it with all its keys again
+// except for the expired ones
+func (r *KVClientsRepository) RestoreClient(ctx context.Context, clientId string) (*asit.Client, error) {
+	if err := r.migrateIndexes(ctx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var trashed *asit.TrashedClient
+	var keys *asit.ClientKeys
+	history := newKVHistoryAppender(clientId)
+	err := r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
+		{
+			key: KEY_TRASHED_CLIENT_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				trashed = &asit.TrashedClient{}
+				found, err := oldValue(trashed)
+				if err != nil {
+					return false, nil, err
+				}
+				if !found {
+					return false, nil, &NotFoundClientByIdError{id: clientId}
+				}
+				keys = &asit.ClientKeys{}
+				keys.Keys, keys.Expires = activeClientKeys(trashed.Keys, trashed.KeysExpires, now)
+				return true, nil, nil
+			},
+		},
+		{
+			key: KEY_CLIENT_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				found, err := oldValue(&asit.Client{})
+				if err != nil {
+					return false, nil, err
+				}
+				if found {
+					return false, nil, &ClientIdConflictError{id: clientId}
+				}
+				trashed.Client.Revision++
+				trashed.Client.LastUpdated = timestamppb.New(time.Now())
+				return true, trashed.Client, nil
+			},
+		},
+		{
+			key: KEY_CLIENT_KEYS_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				if len(keys.Keys) == 0 {
+					return false, nil, nil
+				}
+				return true, keys, nil
+			},
+		},
+		history.command(func() *asit.ClientHistoryEntry {
+			entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_RESTORED)
+			entry.NewClient, entry.NewKeys = trashed.Client, keys.Keys
+			return entry
+		}),
+	},
+		[]string{},
+		func() []SetValueUnlockedCommand {
+			cmds := history.unlockedSets()
+			for _, key := range keys.Keys {
+				cmds = append(cmds, SetValueUnlockedCommand{key: r.clientKeyName(key), newValue: trashed.Client,
+					ttl: clientKeyTTL(keys.Expires, key, now)})
+			}
+			return cmds
+		}, func() []string {
+			res := make([]string, len(trashed.Keys))
+			for i, key := range trashed.Keys {
+				res[i] = r.reservedClientKeyName(key)
+			}
+			return res
+		}, func() []IndexCommand {
+			cmds := []IndexCommand{
+				NewIndexRemoveCommand(KEY_TRASHED_CLIENTS_INDEX, clientId),
+				NewIndexRemoveCommand(KEY_TRASHED_CLIENTS_BY_DELETION_INDEX, trashedClientDeletionMember(trashed)),
+				NewIndexAddCommand(KEY_CLIENTS_INDEX, clientId),
+			}
+			for key, expires := range keys.Expires {
+				cmds = append(cmds, NewIndexAddCommand(KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, r.clientKeyExpiryMember(expires, clientId, key)))
+			}
+			return append(cmds, clientSortIndexCommands(nil, trashed.Client)...)
+		})
+	if err != nil {
+		return nil, fmt.Errorf("can't restore client with Id %s, %w", clientId, err)
+	}
+	return trashed.Client, nil
+}
+
+// PurgeClient permanently removes the trashed client and releases its keys
+func (r *KVClientsRepository) PurgeClient(ctx context.Context, clientId string) error {
+	var trashed *asit.TrashedClient
+	history := newKVHistoryAppender(clientId)
+	err := r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
+		{
+			key: KEY_TRASHED_CLIENT_PREFIX + clientId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				trashed = &asit.TrashedClient{}
+				found, err := oldValue(trashed)
+				if err != nil {
+					return false, nil, err
+				}
+				if !found {
+					return false, nil, &NotFoundClientByIdError{id: clientId}
+				}
+				return true, nil, nil
+			},
+		},
+		history.command(func() *asit.ClientHistoryEntry {
+			entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_PURGED)
+			entry.OldClient, entry.OldKeys = trashed.Client, trashed.Keys
+			return entry
+		}),
+	},
+		[]string{},
+		history.unlockedSets, func() []string {
+			res := make([]string, len(trashed.Keys))
+			for i, key := range trashed.Keys {
+				res[i] = r.reservedClientKeyName(key)
+			}
+			return res
+		}, func() []IndexCommand {
+			return []IndexCommand{
+				NewIndexRemoveCommand(KEY_TRASHED_CLIENTS_INDEX, clientId),
+				NewIndexRemoveCommand(KEY_TRASHED_CLIENTS_BY_DELETION_INDEX, trashedClientDeletionMember(trashed)),
+			}
+		})
+	if err != nil {
+		return fmt.Errorf("can't purge client with Id %s, %w", clientId, err)
+	}
+	return nil
+}
+
+// PurgeTrash purges the clients trashed before the specified time
+func (r *KVClientsRepository) PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, error) {
+	bound := sortableTimestamp(timestamppb.New(deletedBefore))
+	purged := 0
+	for {
+		members, err := r.storage.IndexRange(ctx, KEY_TRASHED_CLIENTS_BY_DELETION_INDEX, "", allClientsPageSize)
+		if err != nil {
+			return purged, fmt.Errorf("can't retrieve db index %s, %w", KEY_TRASHED_CLIENTS_BY_DELETION_INDEX, err)
+		}
+		for _, member := range members {
+			if member >= bound {
+				return purged, nil
+			}
+			err := r.PurgeClient(ctx, kvClientsIndexMemberId(member))
+			var notFoundErr *NotFoundClientByIdError
+			if errors.As(err, &notFoundErr) {
+				// purged or restored concurrently, the index member is outdated
+				err = r.storage.SetAndDeleteAtomically(ctx, nil, nil, func() []SetValueUnlockedCommand { return nil }, func() []string { return nil },
+					func() []IndexCommand {
+						return []IndexCommand{NewIndexRemoveCommand(KEY_TRASHED_CLIENTS_BY_DELETION_INDEX, member)}
+					})
+			} else if err == nil {
+				purged++
+			}
+			if err != nil {
+				return purged, err
+			}
+		}
+		if len(members) < allClientsPageSize {
+			return purged, nil
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package db
+
+import "sync"
+
+// ClientsWatcher wakes up the watchers of the clients on their changes, it's fed by the changes made through
+// NotifyingClientsRepository and by the changes published by other instances, see ClientChanges.
+// The watchers aren't told what has changed, they read the client again and compare it with the last seen one.
+type ClientsWatcher struct {
+	mu       sync.Mutex
+	watchers map[string]map[chan struct{}]struct{}
+}
+
+func NewClientsWatcher() *ClientsWatcher {
+	return &ClientsWatcher{watchers: map[string]map[chan struct{}]struct{}{}}
+}
+
+// Watch returns the channel receiving a value after the changes of the client and the function stopping the watch.
+// The changes made before the previous value is received are merged into one value.
+func (w *ClientsWatcher) Watch(clientId string) (<-chan struct{}, func()) {
+	changes := make(chan struct{}, 1)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	watchers, ok := w.watchers[clientId]
+	if !ok {
+		watchers = map[chan struct{}]struct{}{}
+		w.watchers[clientId] = watchers
+	}
+	watchers[changes] = struct{}{}
+	return changes, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(watchers, changes)
+		if len(w.watchers[clientId]) == 0 {
+			delete(w.watchers, clientId)
+		}
+	}
+}
+
+// Notify wakes up the watchers of the changed client, the empty id wakes up all the watchers
+func (w *ClientsWatcher) Notify(clientId string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if clientId != "" {
+		notifyWatchers(w.watchers[clientId])
+		return
+	}
+	for _, watchers := range w.watchers {
+		notifyWatchers(watchers)
+	}
+}
+
+func notifyWatchers(watchers map[chan struct{}]struct{}) {
+	for changes := range watchers {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// gzipMagic starts every gzip stream. Neither a protobuf value (wire type 7 is invalid)
+// nor a protojson value can start with it, so the compressed values are recognized without a header.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// CompressingCodec compresses the values encoded by the wrapped codec when they are at least minSize bytes long,
+// e.g. the clients with large property maps. The smaller values are kept as is, both kinds are read by Unmarshal.
+type CompressingCodec struct {
+	codec   StorageCodec
+	minSize int
+}
+
+func NewCompressingCodec(codec StorageCodec, minSize int) *CompressingCodec {
+	return &CompressingCodec{codec: codec, minSize: minSize}
+}
+
+func (c *CompressingCodec) Marshal(v proto.Message) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil || len(data) < c.minSize {
+		return data, err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *CompressingCodec) Unmarshal(data []byte, v proto.Message) error {
+	data, err := decompress(data)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(data, v)
+}
+
+// decompress returns the uncompressed value, the values without the gzip magic are returned as is
+func decompress(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("can't decompress value, %w", err)
+	}
+	defer r.Close()
+	uncompressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("can't decompress value, %w", err)
+	}
+	return uncompressed, nil
+}
+
+// CodecEncoding identifies the codec of the value in the envelope header
+type CodecEncoding byte
+
+const (
+	ENCODING_PROTO     CodecEncoding = 1
+	ENCODING_PROTOJSON CodecEncoding = 2
+)
+
+// codecEncodings are the names of the encodings used by the configuration
+var codecEncodings = map[string]CodecEncoding{
+	"proto":     ENCODING_PROTO,
+	"protojson": ENCODING_PROTOJSON,
+}
+
+// ParseCodecEncoding returns the encoding by its name, proto or protojson
+func ParseCodecEncoding(name string) (CodecEncoding, error) {
+	if encoding, ok := codecEncodings[strings.ToLower(name)]; ok {
+		return encoding, nil
+	}
+	return 0, fmt.Errorf("unsupported codec encoding %q, expected proto or protojson", name)
+}
+
+func (e CodecEncoding) codec() (StorageCodec, error) {
+	switch e {
+	case ENCODING_PROTO:
+		return PROTO_CODEC, nil
+	case ENCODING_PROTOJSON:
+		return PROTOJSON_CODEC, nil
+	default:
+		return nil, fmt.Errorf("unsupported codec encoding %d", e)
+	}
+}
+
+// envelopeMagic starts the envelope header. The zero byte can't start a protobuf value (field number 0 is invalid),
+// a protojson value or a gzip stream, so the values written without the envelope are still recognized.
+const envelopeMagic = 0x00
+
+// ENVELOPE_VERSION is the version of the envelope header written by EnvelopeCodec
+const ENVELOPE_VERSION = 1
+
+// envelopeHeaderSize is the size of the magic, version and encoding bytes
+const envelopeHeaderSize = 3
+
+// EnvelopeCodec prefixes the values with a small header containing the encoding,
+// so the values of different encodings can be read side by side during a codec migration.
+// The values are compressed if compressMinSize is positive, see CompressingCodec.
+//
+// The plain proto values without compression are written without the envelope,
+// so they remain readable by the servers using PROTO_CODEC. The values without the envelope
+// are read as (optionally compressed) binary protobuf.
+type EnvelopeCodec struct {
+	encoding CodecEncoding
+	codec    StorageCodec
+	plain    bool
+}
+
+// NewEnvelopeCodec creates the codec writing the values with the encoding, compressMinSize 0 disables the compression
+func NewEnvelopeCodec(encoding CodecEncoding, compressMinSize int) (*EnvelopeCodec, error) {
+	codec, err := encoding.codec()
+	if err != nil {
+		return nil, err
+	}
+	plain := encoding == ENCODING_PROTO && compressMinSize <= 0
+	if compressMinSize > 0 {
+		codec = NewCompressingCodec(codec, compressMinSize)
+	}
+	return &EnvelopeCodec{encoding: encoding, codec: codec, plain: plain}, nil
+}
+
+func (c *EnvelopeCodec) Marshal(v proto.Message) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil || c.plain {
+		return data, err
+	}
+	return append([]byte{envelopeMagic, ENVELOPE_VERSION, byte(c.encoding)}, data...), nil
+}
+
+func (c *EnvelopeCodec) Unmarshal(data []byte, v proto.Message) error {
+	encoding := ENCODING_PROTO
+	if len(data) > 0 && data[0] == envelopeMagic {
+		if len(data) < envelopeHeaderSize {
+			return fmt.Errorf("truncated envelope header")
+		}
+		if data[1] != ENVELOPE_VERSION {
+			return fmt.Errorf("unsupported envelope version %d", data[1])
+		}
+		encoding = CodecEncoding(data[2])
+		data = data[envelopeHeaderSize:]
+	}
+	codec, err := encoding.codec()
+	if err != nil {
+		return err
+	}
+	data, err = decompress(data)
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(data, v)
+}
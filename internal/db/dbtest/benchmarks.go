@@ -0,0 +1,81 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+)
+
+// benchmarkWriters are the numbers of the concurrent writers per GOMAXPROCS
+var benchmarkWriters = []int{1, 8, 32}
+
+// RunClientsRepositoryBenchmarks measures the throughput of the hot paths of db.KVClientsRepository:
+// adding and removing the client keys and upserting the clients by the concurrent writers.
+// The writers of the same client conflict with each other, the writers of the distinct clients don't.
+// The updates failed with db.ConcurrentUpdateError are reported as the conflicts metric.
+func RunClientsRepositoryBenchmarks(b *testing.B, newStorage func(b *testing.B) db.Storage) {
+	benchmarks := []struct {
+		name       string
+		sameClient bool
+		write      func(ctx context.Context, r db.ClientsRepository, clientId string, i int64) error
+	}{
+		{"AddRemoveClientKey", true, addRemoveClientKey},
+		{"AddRemoveClientKeyDistinctClients", false, addRemoveClientKey},
+		{"UpsertClient", true, upsertClient},
+		{"UpsertClientDistinctClients", false, upsertClient},
+	}
+	for _, bb := range benchmarks {
+		for _, writers := range benchmarkWriters {
+			b.Run(bb.name+"/writers="+strconv.Itoa(writers), func(b *testing.B) {
+				s := newStorage(b)
+				b.Cleanup(func() { s.Close() })
+				r := db.NewKVClientsRepository(s)
+				ctx := context.Background()
+				for i := 0; i <= writers*runtime.GOMAXPROCS(0); i++ {
+					if err := r.SetClient(ctx, &asit.Client{Id: strconv.Itoa(i)}); err != nil {
+						b.Fatalf("can't set client %d, %v", i, err)
+					}
+				}
+				var writer, counter, conflicts int64
+				b.SetParallelism(writers)
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					clientId := strconv.FormatInt(atomic.AddInt64(&writer, 1), 10)
+					if bb.sameClient {
+						clientId = "0"
+					}
+					for pb.Next() {
+						err := bb.write(ctx, r, clientId, atomic.AddInt64(&counter, 1))
+						var concurrentErr *db.ConcurrentUpdateError
+						if errors.As(err, &concurrentErr) {
+							atomic.AddInt64(&conflicts, 1)
+						} else if err != nil {
+							b.Errorf("write failed, %v", err)
+							return
+						}
+					}
+				})
+				b.ReportMetric(float64(conflicts)/float64(b.N), "conflicts/op")
+			})
+		}
+	}
+}
+
+func addRemoveClientKey(ctx context.Context, r db.ClientsRepository, clientId string, i int64) error {
+	key := fmt.Sprintf("key-%d", i)
+	if err := r.AddClientKey(ctx, clientId, key); err != nil {
+		return err
+	}
+	return r.RemoveClientKey(ctx, key)
+}
+
+func upsertClient(ctx context.Context, r db.ClientsRepository, clientId string, i int64) error {
+	return r.SetClient(ctx, &asit.Client{Id: clientId, ClientProperties: map[string]string{"counter": strconv.FormatInt(i, 10)}})
+}
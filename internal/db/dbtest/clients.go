@@ -0,0 +1,1580 @@
+package dbtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+)
+
+// RunClientsRepositoryTests checks the db.ClientsRepository contract.
+// newRepository must return an empty repository on every call.
+func RunClientsRepositoryTests(t *testing.T, newRepository func(t *testing.T) db.ClientsRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, r db.ClientsRepository)
+	}{
+		{"SetAndGetClient", testSetAndGetClient},
+		{"GetClientsPages", testGetClientsPages},
+		{"QueryClientsSort", testQueryClientsSort},
+		{"QueryClientsFilters", testQueryClientsFilters},
+		{"InvalidClientsQuery", testInvalidClientsQuery},
+		{"ClientRevisions", testClientRevisions},
+		{"ConcurrentSameRevision", testConcurrentSameRevision},
+		{"PatchClient", testPatchClient},
+		{"ConcurrentPatches", testConcurrentPatches},
+		{"AddClientKey", testAddClientKey},
+		{"AddClientKeyOfMissingClient", testAddClientKeyOfMissingClient},
+		{"NonUniqueClientKey", testNonUniqueClientKey},
+		{"SetClientUpdatesKeyAliases", testSetClientUpdatesKeyAliases},
+		{"RemoveClientKey", testRemoveClientKey},
+		{"RemoveClientRemovesKeys", testRemoveClientRemovesKeys},
+		{"ClientKeyTTL", testClientKeyTTL},
+		{"TrashedClientKeyTTL", testTrashedClientKeyTTL},
+		{"TrashedClients", testTrashedClients},
+		{"RestoreClient", testRestoreClient},
+		{"TrashedClientIdConflict", testTrashedClientIdConflict},
+		{"PurgeTrash", testPurgeTrash},
+		{"ClientHistory", testClientHistory},
+		{"RollbackClient", testRollbackClient},
+		{"ConcurrentSameKey", testConcurrentSameKey},
+		{"ConcurrentClientKeys", testConcurrentClientKeys},
+		{"ConsistentClients", testConsistentClients},
+		{"ExportImport", testExportImport},
+		{"ImportDuplicateKeys", testImportDuplicateKeys},
+		{"ClientChanges", testClientChanges},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepository(t))
+		})
+	}
+}
+
+// RunKVClientsRepositoryTests runs RunClientsRepositoryTests against db.KVClientsRepository
+// and additionally checks the raw storage keys maintained by it.
+func RunKVClientsRepositoryTests(t *testing.T, newStorage func(t *testing.T) db.Storage) {
+	RunClientsRepositoryTests(t, func(t *testing.T) db.ClientsRepository {
+		s := newStorage(t)
+		t.Cleanup(func() { s.Close() })
+		return db.NewKVClientsRepository(s)
+	})
+	t.Run("HashedClientKeys", func(t *testing.T) {
+		RunClientsRepositoryTests(t, func(t *testing.T) db.ClientsRepository {
+			s := newStorage(t)
+			t.Cleanup(func() { s.Close() })
+			return db.NewKVClientsRepositoryWithHashedKeys(s, testHMACKey)
+		})
+	})
+	t.Run("CachedClients", func(t *testing.T) {
+		newRepository := func(t *testing.T) db.ClientsRepository {
+			s := newStorage(t)
+			t.Cleanup(func() { s.Close() })
+			return db.NewKVClientsRepository(s)
+		}
+		RunClientsRepositoryTests(t, func(t *testing.T) db.ClientsRepository {
+			return db.NewCachingClientsRepository(newRepository(t), 100, time.Minute)
+		})
+		t.Run("Invalidation", func(t *testing.T) { testClientsCacheInvalidation(t, newRepository(t)) })
+		t.Run("Eviction", func(t *testing.T) { testClientsCacheEviction(t, newRepository(t)) })
+	})
+
+	t.Run("HashedClientKeysMigration", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStorage(t)
+		t.Cleanup(func() { s.Close() })
+		plain := db.NewKVClientsRepository(s)
+		mustSetClient(t, plain, &asit.Client{Id: "1", Name: "first"})
+		mustSetClient(t, plain, &asit.Client{Id: "2", Name: "second"})
+		mustAddClientKey(t, plain, "1", "k1")
+		mustAddClientKeyWithTTL(t, plain, "1", "k2", time.Hour)
+		mustAddClientKey(t, plain, "2", "k3")
+		if err := plain.RemoveClient(ctx, "2"); err != nil {
+			t.Fatalf("can't remove client, %v", err)
+		}
+
+		r := db.NewKVClientsRepositoryWithHashedKeys(s, testHMACKey)
+		rewritten, err := r.RewriteValues(ctx)
+		if err != nil {
+			t.Fatalf("RewriteValues failed, %v", err)
+		}
+		// client 1 with its keys list and two aliases, trashed client 2 with its reservation
+		if rewritten < 6 {
+			t.Errorf("RewriteValues rewrote %d values, expected at least 6", rewritten)
+		}
+		for _, prefix := range []string{db.KEY_CLIENT_KEY_PREFIX, db.KEY_RESERVED_CLIENT_KEY_PREFIX} {
+			keys, err := s.ScanKeys(ctx, prefix)
+			if err != nil {
+				t.Fatalf("ScanKeys failed, %v", err)
+			}
+			for _, key := range keys {
+				if strings.HasSuffix(key, ":k1") || strings.HasSuffix(key, ":k2") || strings.HasSuffix(key, ":k3") {
+					t.Errorf("the plain key name %s is kept", key)
+				}
+			}
+		}
+		if client, err := r.GetClientByKey(ctx, "k2"); err != nil || client == nil || client.Id != "1" {
+			t.Errorf("GetClientByKey(k2) returned (%v, %v) after the migration, expected client 1", client, err)
+		}
+		mustSetClient(t, r, &asit.Client{Id: "3", Name: "third"})
+		var nonUniqueErr *db.NonUniqueClientKeyError
+		if err := r.AddClientKey(ctx, "3", "k3"); !errors.As(err, &nonUniqueErr) {
+			t.Errorf("AddClientKey of the reserved key returned %v after the migration, expected NonUniqueClientKeyError", err)
+		}
+		report, err := r.CheckConsistency(ctx, false)
+		if err != nil {
+			t.Fatalf("CheckConsistency failed, %v", err)
+		}
+		if len(report.Inconsistencies) != 0 {
+			t.Errorf("CheckConsistency found inconsistencies %q after the migration", inconsistencies(report))
+		}
+	})
+
+	t.Run("LegacyClientListMigration", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStorage(t)
+		t.Cleanup(func() { s.Close() })
+		// the layout of the previous versions: clients are listed in the single value
+		mustStore(t, s, db.KEY_CLIENT_PREFIX+"1", &asit.Client{Id: "1", Name: "first"})
+		mustStore(t, s, db.KEY_CLIENT_PREFIX+"2", &asit.Client{Id: "2", Name: "second"})
+		mustStore(t, s, db.KEY_ALL_CLIENTS, &asit.ClientList{Clients: []*asit.Client{{Id: "2"}, {Id: "1"}}})
+
+		r := db.NewKVClientsRepository(s)
+		mustSetClient(t, r, &asit.Client{Id: "3", Name: "third"})
+		all, err := r.GetAllClients(ctx)
+		if err != nil {
+			t.Fatalf("can't get all clients, %v", err)
+		}
+		if ids := clientIds(all); !slices.Equal(ids, []string{"1", "2", "3"}) {
+			t.Errorf("expected clients [1 2 3], got %v", ids)
+		}
+		if found, err := s.Get(ctx, db.KEY_ALL_CLIENTS, &asit.ClientList{}); err != nil || found {
+			t.Errorf("%s is not removed: (%v, %v)", db.KEY_ALL_CLIENTS, found, err)
+		}
+	})
+
+	t.Run("SortIndexesMigration", func(t *testing.T) {
+		s := newStorage(t)
+		t.Cleanup(func() { s.Close() })
+		// the layout of the previous versions: clients are indexed only by ids
+		mustStore(t, s, db.KEY_CLIENT_PREFIX+"1", &asit.Client{Id: "1", Name: "b"})
+		mustStore(t, s, db.KEY_CLIENT_PREFIX+"2", &asit.Client{Id: "2", Name: "a"})
+		err := s.SetAndDeleteAtomically(context.Background(), nil, nil, noUnlockedSets, noUnlockedDeletes, func() []db.IndexCommand {
+			return []db.IndexCommand{db.NewIndexAddCommand(db.KEY_CLIENTS_INDEX, "1"), db.NewIndexAddCommand(db.KEY_CLIENTS_INDEX, "2")}
+		})
+		if err != nil {
+			t.Fatalf("SetAndDeleteAtomically failed, %v", err)
+		}
+
+		r := db.NewKVClientsRepository(s)
+		if ids := queryAllPages(t, r, db.ClientsQuery{SortBy: db.CLIENTS_SORT_BY_NAME}); !slices.Equal(ids, []string{"2", "1"}) {
+			t.Errorf("expected clients [2 1] sorted by name, got %v", ids)
+		}
+		if ids := queryAllPages(t, r, db.ClientsQuery{SortBy: db.CLIENTS_SORT_BY_LAST_UPDATED}); len(ids) != 2 {
+			t.Errorf("expected 2 clients sorted by lastUpdated, got %v", ids)
+		}
+	})
+
+	t.Run("KeyAliases", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStorage(t)
+		t.Cleanup(func() { s.Close() })
+		r := db.NewKVClientsRepository(s)
+		mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+		mustAddClientKey(t, r, "1", "k1")
+		mustAddClientKey(t, r, "1", "k2")
+
+		mustSetClient(t, r, &asit.Client{Id: "1", Name: "renamed"})
+		for _, key := range []string{"k1", "k2"} {
+			alias := &asit.Client{}
+			if found, err := s.Get(ctx, db.KEY_CLIENT_KEY_PREFIX+key, alias); err != nil || !found || alias.Name != "renamed" {
+				t.Errorf("alias %s is not updated: (%v, %v, %v)", key, alias, found, err)
+			}
+		}
+
+		if err := r.RemoveClient(ctx, "1"); err != nil {
+			t.Fatalf("can't remove client, %v", err)
+		}
+		for _, key := range []string{
+			db.KEY_CLIENT_PREFIX + "1",
+			db.KEY_CLIENT_KEYS_PREFIX + "1",
+			db.KEY_CLIENT_KEY_PREFIX + "k1",
+			db.KEY_CLIENT_KEY_PREFIX + "k2",
+		} {
+			if found, err := s.Get(ctx, key, &asit.Client{}); err != nil || found {
+				t.Errorf("%s is not removed: (%v, %v)", key, found, err)
+			}
+		}
+	})
+
+	t.Run("ExpiredKeysRemoval", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStorage(t)
+		t.Cleanup(func() { s.Close() })
+		r := db.NewKVClientsRepository(s)
+		mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+		mustAddClientKeyWithTTL(t, r, "1", "k1", shortTTL)
+		mustAddClientKeyWithTTL(t, r, "1", "k2", time.Hour)
+		mustAddClientKey(t, r, "1", "k3")
+
+		time.Sleep(2 * shortTTL)
+		expired, err := r.ExpireClientKeys(ctx, time.Now())
+		if err != nil || expired != 1 {
+			t.Fatalf("ExpireClientKeys returned (%d, %v), expected (1, nil)", expired, err)
+		}
+		// the expired key is removed from the list kept in the storage, not only filtered out
+		clientKeys := &asit.ClientKeys{}
+		if found, err := s.Get(ctx, db.KEY_CLIENT_KEYS_PREFIX+"1", clientKeys); err != nil || !found {
+			t.Fatalf("%s is missing: (%v, %v)", db.KEY_CLIENT_KEYS_PREFIX+"1", found, err)
+		}
+		if _, ok := clientKeys.Expires["k1"]; ok || !slices.Equal(clientKeys.Keys, []string{"k2", "k3"}) {
+			t.Errorf("%s is %v, expected keys [k2 k3]", db.KEY_CLIENT_KEYS_PREFIX+"1", clientKeys)
+		}
+		members, err := s.IndexRange(ctx, db.KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, "", 0)
+		if err != nil || len(members) != 1 || !strings.HasSuffix(members[0], "\x00k2") {
+			t.Errorf("index %s contains (%q, %v), expected only k2", db.KEY_CLIENT_KEYS_BY_EXPIRY_INDEX, members, err)
+		}
+
+		if err := r.RemoveClientKey(ctx, "k2"); err != nil {
+			t.Fatalf("can't remove client key, %v", err)
+		}
+		expectIndex(t, s, db.KEY_CLIENT_KEYS_BY_EXPIRY_INDEX)
+	})
+	t.Run("HashedExpiredKeysRemoval", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStorage(t)
+		t.Cleanup(func() { s.Close() })
+		r := db.NewKVClientsRepositoryWithHashedKeys(s, testHMACKey)
+		mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+		mustSetClient(t, r, &asit.Client{Id: "2", Name: "second"})
+		mustAddClientKeyWithTTL(t, r, "1", "k1", shortTTL)
+		mustAddClientKeyWithTTL(t, r, "1", "k2", shortTTL)
+		mustAddClientKey(t, r, "1", "k3")
+
+		time.Sleep(2 * shortTTL)
+		// the expired key could be associated with another client before it's removed from the client keys
+		mustAddClientKey(t, r, "2", "k2")
+		expired, err := r.ExpireClientKeys(ctx, time.Now())
+		if err != nil || expired != 2 {
+			t.Fatalf("ExpireClientKeys returned (%d, %v), expected (2, nil)", expired, err)
+		}
+		expectNoClientByKey(t, r, "k1")
+		expectKeys(t, r, "1", "k3")
+		expectKeys(t, r, "2", "k2")
+		if client, err := r.GetClientByKey(ctx, "k2"); err != nil || client == nil || client.Id != "2" {
+			t.Errorf("GetClientByKey(k2) returned (%v, %v), expected client 2", client, err)
+		}
+		// only the aliases of the active keys are kept
+		keys, err := s.ScanKeys(ctx, db.KEY_CLIENT_KEY_PREFIX)
+		if err != nil || len(keys) != 2 {
+			t.Errorf("ScanKeys(%s) returned (%v, %v), expected the aliases of k2 and k3", db.KEY_CLIENT_KEY_PREFIX, keys, err)
+		}
+		expectIndex(t, s, db.KEY_CLIENT_KEYS_BY_EXPIRY_INDEX)
+	})
+	t.Run("ConsistencyRepair", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStorage(t)
+		t.Cleanup(func() { s.Close() })
+		r := db.NewKVClientsRepository(s)
+		mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+		mustSetClient(t, r, &asit.Client{Id: "2", Name: "second"})
+		mustAddClientKey(t, r, "1", "missing")
+		mustAddClientKey(t, r, "1", "stale")
+		mustAddClientKey(t, r, "1", "duplicate")
+		mustAddClientKeyWithTTL(t, r, "2", "orphaned", time.Hour)
+
+		// the drift left by the failed or concurrent updates
+		stale := mustGetClient(t, r, "1")
+		mustSetClient(t, r, &asit.Client{Id: "1", Name: "renamed"})
+		mustStore(t, s, db.KEY_CLIENT_KEY_PREFIX+"stale", stale)
+		if err := s.Delete(ctx, db.KEY_CLIENT_KEY_PREFIX+"missing"); err != nil {
+			t.Fatalf("can't delete alias, %v", err)
+		}
+		mustStore(t, s, db.KEY_CLIENT_KEYS_PREFIX+"2", &asit.ClientKeys{Keys: []string{"duplicate"}})
+		mustStore(t, s, db.KEY_CLIENT_KEYS_PREFIX+"3", &asit.ClientKeys{Keys: []string{"k3"}})
+		mustStore(t, s, db.KEY_CLIENT_PREFIX+"4", &asit.Client{Id: "4", Name: "unindexed"})
+		err := s.SetAndDeleteAtomically(ctx, nil, nil, noUnlockedSets, noUnlockedDeletes, func() []db.IndexCommand {
+			return []db.IndexCommand{db.NewIndexAddCommand(db.KEY_CLIENTS_INDEX, "5")}
+		})
+		if err != nil {
+			t.Fatalf("SetAndDeleteAtomically failed, %v", err)
+		}
+
+		report, err := r.CheckConsistency(ctx, false)
+		if err != nil {
+			t.Fatalf("can't check consistency, %v", err)
+		}
+		expected := []string{
+			"ORPHANED_INDEX_MEMBER 5 " + db.KEY_CLIENTS_INDEX,
+			"MISSING_INDEX_MEMBER 4 " + db.KEY_CLIENTS_INDEX,
+			"MISSING_INDEX_MEMBER 4 " + db.KEY_CLIENTS_BY_NAME_INDEX,
+			"MISSING_INDEX_MEMBER 4 " + db.KEY_CLIENTS_BY_LAST_UPDATED_INDEX,
+			"ORPHANED_CLIENT_KEYS 3",
+			"DUPLICATE_CLIENT_KEY 2 duplicate",
+			"MISSING_CLIENT_KEY_ALIAS 1 missing",
+			"ORPHANED_CLIENT_KEY_ALIAS 2 orphaned",
+			"STALE_CLIENT_KEY_ALIAS 1 stale",
+		}
+		if actual := inconsistencies(report); !slices.Equal(actual, expected) {
+			t.Errorf("found inconsistencies %q, expected %q", actual, expected)
+		}
+		if report.CheckedClients != 3 || report.CheckedKeys != 4 {
+			t.Errorf("checked %d clients and %d keys, expected 3 and 4", report.CheckedClients, report.CheckedKeys)
+		}
+		// the check doesn't repair anything
+		expectNoClientByKey(t, r, "missing")
+
+		report, err = r.CheckConsistency(ctx, true)
+		if err != nil {
+			t.Fatalf("can't repair consistency, %v", err)
+		}
+		for _, inconsistency := range report.Inconsistencies {
+			if !inconsistency.Repaired {
+				t.Errorf("inconsistency %v is not repaired", inconsistency)
+			}
+		}
+		if report, err := r.CheckConsistency(ctx, false); err != nil || len(report.Inconsistencies) != 0 {
+			t.Errorf("CheckConsistency after the repair returned (%q, %v), expected no inconsistencies", inconsistencies(report), err)
+		}
+
+		for _, key := range []string{"missing", "stale", "duplicate"} {
+			if client, err := r.GetClientByKey(ctx, key); err != nil || client == nil || client.Name != "renamed" {
+				t.Errorf("GetClientByKey(%s) returned (%v, %v), expected the renamed client", key, client, err)
+			}
+		}
+		expectKeys(t, r, "2")
+		expectNoClientByKey(t, r, "orphaned")
+		mustAddClientKey(t, r, "2", "orphaned")
+		expectIndex(t, s, db.KEY_CLIENTS_INDEX, "1", "2", "4")
+		if ids := queryAllPages(t, r, db.ClientsQuery{SortBy: db.CLIENTS_SORT_BY_NAME}); !slices.Equal(ids, []string{"1", "2", "4"}) {
+			t.Errorf("expected clients [1 2 4] sorted by name, got %v", ids)
+		}
+		entries, _, err := r.GetClientHistory(ctx, "2", "", 0)
+		if err != nil || len(entries) == 0 || entries[len(entries)-2].Change != asit.ClientChangeType_CLIENT_KEY_REMOVED {
+			t.Errorf("the removal of the duplicate key is not recorded in the history: (%v, %v)", entries, err)
+		}
+	})
+}
+
+func testSetAndGetClient(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	client, err := r.GetClientById(ctx, "missing")
+	if err != nil || client != nil {
+		t.Fatalf("GetClientById of the missing client returned (%v, %v), expected (nil, nil)", client, err)
+	}
+
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first", ClientProperties: map[string]string{"a": "1"}})
+	mustSetClient(t, r, &asit.Client{Id: "2", Name: "second"})
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first", ClientProperties: map[string]string{"b": "2"}})
+
+	client = mustGetClient(t, r, "1")
+	if client.Name != "first" || len(client.ClientProperties) != 1 || client.ClientProperties["b"] != "2" {
+		t.Errorf("unexpected client %v", client)
+	}
+
+	all, err := r.GetAllClients(ctx)
+	if err != nil {
+		t.Fatalf("can't get all clients, %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expected 2 clients, got %v", all)
+	}
+	for _, c := range all {
+		if len(c.ClientProperties) != 0 {
+			t.Errorf("GetAllClients must not return client properties, got %v", c)
+		}
+	}
+}
+
+func testGetClientsPages(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		mustSetClient(t, r, &asit.Client{Id: strconv.Itoa(i), Name: "client", ClientProperties: map[string]string{"a": "1"}})
+	}
+	if err := r.RemoveClient(ctx, "2"); err != nil {
+		t.Fatalf("can't remove client, %v", err)
+	}
+
+	var pages [][]string
+	cursor := ""
+	for {
+		clients, nextCursor, err := r.GetClients(ctx, cursor, 2)
+		if err != nil {
+			t.Fatalf("can't get clients, %v", err)
+		}
+		for _, c := range clients {
+			if len(c.ClientProperties) != 0 {
+				t.Errorf("GetClients must not return client properties, got %v", c)
+			}
+		}
+		pages = append(pages, clientIds(clients))
+		if nextCursor == "" {
+			break
+		}
+		if len(pages) > 5 {
+			t.Fatalf("too many pages: %v", pages)
+		}
+		cursor = nextCursor
+	}
+
+	var all []string
+	for _, page := range pages {
+		if len(page) > 2 {
+			t.Errorf("page %v is bigger than the limit", page)
+		}
+		all = append(all, page...)
+	}
+	if !slices.Equal(all, []string{"0", "1", "3", "4"}) {
+		t.Errorf("expected clients [0 1 3 4], got pages %v", pages)
+	}
+}
+
+func testQueryClientsSort(t *testing.T, r db.ClientsRepository) {
+	// clients are updated sequentially, so their lastUpdated order is the order of the updates
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "b"})
+	mustSetClient(t, r, &asit.Client{Id: "2", Name: "a"})
+	mustSetClient(t, r, &asit.Client{Id: "3", Name: "c"})
+	mustSetClient(t, r, &asit.Client{Id: "4", Name: "a"})
+	// the updated client must be moved to its new positions
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "d"})
+
+	tests := []struct {
+		sortBy   string
+		expected []string
+	}{
+		{"", []string{"1", "2", "3", "4"}},
+		{db.CLIENTS_SORT_BY_ID, []string{"1", "2", "3", "4"}},
+		{db.CLIENTS_SORT_BY_NAME, []string{"2", "4", "3", "1"}},
+		{db.CLIENTS_SORT_BY_LAST_UPDATED, []string{"2", "3", "4", "1"}},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{0, 1, 3} {
+			actual := queryAllPages(t, r, db.ClientsQuery{SortBy: tt.sortBy, Limit: limit})
+			if !slices.Equal(actual, tt.expected) {
+				t.Errorf("clients sorted by %q with limit %d are %v, expected %v", tt.sortBy, limit, actual, tt.expected)
+			}
+		}
+	}
+}
+
+func testQueryClientsFilters(t *testing.T, r db.ClientsRepository) {
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "test-1", ClientProperties: map[string]string{"a": "1", "b": "1"}})
+	mustSetClient(t, r, &asit.Client{Id: "2", Name: "Test-2", ClientProperties: map[string]string{"a": "1"}})
+	mustSetClient(t, r, &asit.Client{Id: "3", Name: "test", ClientProperties: map[string]string{"a": "2", "b": "1"}})
+	mustSetClient(t, r, &asit.Client{Id: "4", Name: "tes", ClientProperties: map[string]string{"a": "1", "b": "1"}})
+	mustSetClient(t, r, &asit.Client{Id: "5", Name: "test-5", ClientProperties: map[string]string{"a": "1", "b": "1"}})
+
+	tests := []struct {
+		query    db.ClientsQuery
+		expected []string
+	}{
+		{db.ClientsQuery{NamePrefix: "test"}, []string{"1", "3", "5"}},
+		{db.ClientsQuery{NamePrefix: "test", SortBy: db.CLIENTS_SORT_BY_NAME}, []string{"3", "1", "5"}},
+		{db.ClientsQuery{NamePrefix: "test-", SortBy: db.CLIENTS_SORT_BY_LAST_UPDATED}, []string{"1", "5"}},
+		{db.ClientsQuery{NamePrefix: "missing", SortBy: db.CLIENTS_SORT_BY_NAME}, nil},
+		{db.ClientsQuery{Properties: map[string]string{"a": "1"}}, []string{"1", "2", "4", "5"}},
+		{db.ClientsQuery{Properties: map[string]string{"a": "1", "b": "1"}}, []string{"1", "4", "5"}},
+		{db.ClientsQuery{Properties: map[string]string{"c": ""}}, nil},
+		{db.ClientsQuery{NamePrefix: "test", Properties: map[string]string{"a": "1"}, SortBy: db.CLIENTS_SORT_BY_NAME}, []string{"1", "5"}},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{0, 1, 2} {
+			query := tt.query
+			query.Limit = limit
+			actual := queryAllPages(t, r, query)
+			if len(actual) != len(tt.expected) || (len(actual) > 0 && !slices.Equal(actual, tt.expected)) {
+				t.Errorf("query %+v returned %v, expected %v", query, actual, tt.expected)
+			}
+		}
+	}
+}
+
+func testInvalidClientsQuery(t *testing.T, r db.ClientsRepository) {
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+	for _, query := range []db.ClientsQuery{
+		{SortBy: "unknown"},
+		{SortBy: db.CLIENTS_SORT_BY_NAME, Cursor: "no separator"},
+		{SortBy: db.CLIENTS_SORT_BY_LAST_UPDATED, Cursor: "not a number\x001"},
+	} {
+		_, _, err := r.QueryClients(context.Background(), query)
+		var invalidQueryErr *db.InvalidClientsQueryError
+		if !errors.As(err, &invalidQueryErr) {
+			t.Errorf("expected InvalidClientsQueryError for the query %+v, got %v", query, err)
+		}
+	}
+}
+
+func testClientRevisions(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	expectRevisionErr := func(err error, expected bool) {
+		t.Helper()
+		var mismatchErr *db.ClientRevisionMismatchError
+		if errors.As(err, &mismatchErr) != expected {
+			t.Errorf("unexpected error %v, ClientRevisionMismatchError expected: %v", err, expected)
+		}
+	}
+
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first", Revision: 10})
+	mustAddClientKey(t, r, "1", "k1")
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "second"})
+	// the passed revision is ignored, it's incremented by every update
+	if client := mustGetClient(t, r, "1"); client.Revision != 2 {
+		t.Errorf("expected revision 2, got %v", client)
+	}
+
+	err := r.SetClientIfRevision(ctx, &asit.Client{Id: "1", Name: "outdated"}, 1)
+	expectRevisionErr(err, true)
+	client := &asit.Client{Id: "1", Name: "third"}
+	if err := r.SetClientIfRevision(ctx, client, 2); err != nil {
+		t.Fatalf("can't set client with the current revision, %v", err)
+	}
+	if client.Revision != 3 {
+		t.Errorf("the new revision isn't set to the client, got %v", client)
+	}
+	if client := mustGetClient(t, r, "1"); client.Name != "third" || client.Revision != 3 {
+		t.Errorf("unexpected client %v", client)
+	}
+	if client, err := r.GetClientByKey(ctx, "k1"); err != nil || client == nil || client.Revision != 3 {
+		t.Errorf("unexpected client by key (%v, %v)", client, err)
+	}
+
+	var notFoundErr *db.NotFoundClientByIdError
+	if err := r.SetClientIfRevision(ctx, &asit.Client{Id: "missing"}, 0); !errors.As(err, &notFoundErr) {
+		t.Errorf("expected NotFoundClientByIdError, got %v", err)
+	}
+	if err := r.RemoveClientIfRevision(ctx, "missing", 1); !errors.As(err, &notFoundErr) {
+		t.Errorf("expected NotFoundClientByIdError, got %v", err)
+	}
+
+	expectRevisionErr(r.RemoveClientIfRevision(ctx, "1", 2), true)
+	mustGetClient(t, r, "1")
+	if err := r.RemoveClientIfRevision(ctx, "1", 3); err != nil {
+		t.Fatalf("can't remove client with the current revision, %v", err)
+	}
+	if client, err := r.GetClientById(ctx, "1"); err != nil || client != nil {
+		t.Errorf("client is not removed: (%v, %v)", client, err)
+	}
+
+	// EXISTING_CLIENT_REVISION matches any revision of the existing client only, so the removed client isn't created again
+	client = &asit.Client{Id: "2", Name: "existing"}
+	mustSetClient(t, r, client)
+	if err := r.SetClientIfRevision(ctx, &asit.Client{Id: "2", Name: "updated"}, db.EXISTING_CLIENT_REVISION); err != nil {
+		t.Errorf("can't update the existing client, %v", err)
+	}
+	if _, err := r.PatchClient(ctx, "2", db.ClientPatch{}, db.EXISTING_CLIENT_REVISION); err != nil {
+		t.Errorf("can't patch the existing client, %v", err)
+	}
+	if err := r.RemoveClientIfRevision(ctx, "2", db.EXISTING_CLIENT_REVISION); err != nil {
+		t.Errorf("can't remove the existing client, %v", err)
+	}
+	if err := r.SetClientIfRevision(ctx, &asit.Client{Id: "2", Name: "recreated"}, db.EXISTING_CLIENT_REVISION); err == nil {
+		t.Errorf("the removed client is created again")
+	}
+	expectRevisionErr(r.SetClientIfRevision(ctx, &asit.Client{Id: "missing", Name: "created"}, db.EXISTING_CLIENT_REVISION), true)
+	_, err = r.PatchClient(ctx, "missing", db.ClientPatch{}, db.EXISTING_CLIENT_REVISION)
+	expectRevisionErr(err, true)
+	expectRevisionErr(r.RemoveClientIfRevision(ctx, "missing", db.EXISTING_CLIENT_REVISION), true)
+	if client, err := r.GetClientById(ctx, "missing"); err != nil || client != nil {
+		t.Errorf("the missing client is created: (%v, %v)", client, err)
+	}
+}
+
+// testConcurrentSameRevision checks that only one of the concurrent updates of the same revision succeeds
+func testConcurrentSameRevision(t *testing.T, r db.ClientsRepository) {
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "initial"})
+	revision := mustGetClient(t, r, "1").Revision
+
+	var succeeded int64
+	var wg sync.WaitGroup
+	for w := 0; w < ConcurrentWriters; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			err := r.SetClientIfRevision(context.Background(), &asit.Client{Id: "1", Name: strconv.Itoa(w)}, revision)
+			var mismatchErr *db.ClientRevisionMismatchError
+			var concurrentUpdateErr *db.ConcurrentUpdateError
+			switch {
+			case err == nil:
+				atomic.AddInt64(&succeeded, 1)
+			case errors.As(err, &mismatchErr), errors.As(err, &concurrentUpdateErr):
+			default:
+				t.Errorf("unexpected error, %v", err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Errorf("expected exactly one successful update, got %d", succeeded)
+	}
+	if client := mustGetClient(t, r, "1"); client.Revision != revision+1 {
+		t.Errorf("expected revision %d, got %v", revision+1, client)
+	}
+}
+
+func testPatchClient(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first", ClientProperties: map[string]string{"a": "1", "b": "2"}})
+	mustAddClientKey(t, r, "1", "k1")
+
+	name, b, c := "renamed", "22", "3"
+	patched, err := r.PatchClient(ctx, "1", db.ClientPatch{
+		Name:       &name,
+		Properties: map[string]*string{"a": nil, "b": &b, "c": &c, "missing": nil},
+	}, 1)
+	if err != nil {
+		t.Fatalf("can't patch client, %v", err)
+	}
+	expected := &asit.Client{Id: "1", Name: "renamed", ClientProperties: map[string]string{"b": "22", "c": "3"}, Revision: 2}
+	for _, client := range []*asit.Client{patched, mustGetClient(t, r, "1")} {
+		if client.LastUpdated == nil {
+			t.Errorf("lastUpdated isn't set, %v", client)
+		}
+		client.LastUpdated = nil
+		if !proto.Equal(client, expected) {
+			t.Errorf("patched client is %v, expected %v", client, expected)
+		}
+	}
+	// the patch must be propagated to the client key aliases and the indexes
+	if client, err := r.GetClientByKey(ctx, "k1"); err != nil || client == nil || client.Name != "renamed" || client.ClientProperties["c"] != "3" {
+		t.Errorf("the client by key isn't patched: (%v, %v)", client, err)
+	}
+	if ids := queryAllPages(t, r, db.ClientsQuery{SortBy: db.CLIENTS_SORT_BY_NAME, NamePrefix: "renamed"}); !slices.Equal(ids, []string{"1"}) {
+		t.Errorf("the client isn't found by the new name: %v", ids)
+	}
+	if ids := queryAllPages(t, r, db.ClientsQuery{SortBy: db.CLIENTS_SORT_BY_NAME, NamePrefix: "first"}); len(ids) != 0 {
+		t.Errorf("the client is found by the old name: %v", ids)
+	}
+
+	// an empty patch keeps the values
+	if patched, err := r.PatchClient(ctx, "1", db.ClientPatch{}, db.ANY_CLIENT_REVISION); err != nil || patched.Name != "renamed" || len(patched.ClientProperties) != 2 {
+		t.Errorf("unexpected result of the empty patch (%v, %v)", patched, err)
+	}
+	if patched, err := r.PatchClient(ctx, "1", db.ClientPatch{RemoveAllProperties: true, Properties: map[string]*string{"d": &c}}, db.ANY_CLIENT_REVISION); err != nil ||
+		len(patched.ClientProperties) != 1 || patched.ClientProperties["d"] != "3" {
+		t.Errorf("unexpected result of the patch removing all properties (%v, %v)", patched, err)
+	}
+
+	var mismatchErr *db.ClientRevisionMismatchError
+	if _, err := r.PatchClient(ctx, "1", db.ClientPatch{Name: &name}, 1); !errors.As(err, &mismatchErr) {
+		t.Errorf("expected ClientRevisionMismatchError, got %v", err)
+	}
+	var notFoundErr *db.NotFoundClientByIdError
+	if _, err := r.PatchClient(ctx, "missing", db.ClientPatch{Name: &name}, db.ANY_CLIENT_REVISION); !errors.As(err, &notFoundErr) {
+		t.Errorf("expected NotFoundClientByIdError, got %v", err)
+	}
+}
+
+// testConcurrentPatches checks that the concurrent patches of different properties don't overwrite each other
+func testConcurrentPatches(t *testing.T, r db.ClientsRepository) {
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "client"})
+
+	var succeeded int64
+	var wg sync.WaitGroup
+	for w := 0; w < ConcurrentWriters; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			value := strconv.Itoa(w)
+			_, err := r.PatchClient(context.Background(), "1", db.ClientPatch{Properties: map[string]*string{value: &value}}, db.ANY_CLIENT_REVISION)
+			var concurrentUpdateErr *db.ConcurrentUpdateError
+			switch {
+			case err == nil:
+				atomic.AddInt64(&succeeded, 1)
+			case errors.As(err, &concurrentUpdateErr):
+			default:
+				t.Errorf("unexpected error, %v", err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if client := mustGetClient(t, r, "1"); int64(len(client.ClientProperties)) != succeeded {
+		t.Errorf("lost updates: %d successful patches, but the client has properties %v", succeeded, client.ClientProperties)
+	}
+}
+
+func testAddClientKey(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+	mustAddClientKey(t, r, "1", "k1")
+	mustAddClientKey(t, r, "1", "k2")
+	// adding the same key again is not an error
+	mustAddClientKey(t, r, "1", "k1")
+
+	expectKeys(t, r, "1", "k1", "k2")
+	client, err := r.GetClientByKey(ctx, "k2")
+	if err != nil || client == nil || client.Id != "1" {
+		t.Errorf("GetClientByKey returned (%v, %v), expected client 1", client, err)
+	}
+	client, err = r.GetClientByKey(ctx, "missing")
+	if err != nil || client != nil {
+		t.Errorf("GetClientByKey of the missing key returned (%v, %v), expected (nil, nil)", client, err)
+	}
+}
+
+func testAddClientKeyOfMissingClient(t *testing.T, r db.ClientsRepository) {
+	err := r.AddClientKey(context.Background(), "missing", "k1")
+	var notFoundErr *db.NotFoundClientByIdError
+	if !errors.As(err, &notFoundErr) {
+		t.Fatalf("expected NotFoundClientByIdError, got %v", err)
+	}
+	expectNoClientByKey(t, r, "k1")
+}
+
+func testNonUniqueClientKey(t *testing.T, r db.ClientsRepository) {
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+	mustSetClient(t, r, &asit.Client{Id: "2", Name: "second"})
+	mustAddClientKey(t, r, "1", "k1")
+
+	err := r.AddClientKey(context.Background(), "2", "k1")
+	var nonUniqueErr *db.NonUniqueClientKeyError
+	if !errors.As(err, &nonUniqueErr) {
+		t.Fatalf("expected NonUniqueClientKeyError, got %v", err)
+	}
+	expectKeys(t, r, "1", "k1")
+	expectKeys(t, r, "2")
+	client, err := r.GetClientByKey(context.Background(), "k1")
+	if err != nil || client == nil || client.Id != "1" {
+		t.Errorf("GetClientByKey returned (%v, %v), expected client 1", client, err)
+	}
+}
+
+func testSetClientUpdatesKeyAliases(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+	mustAddClientKey(t, r, "1", "k1")
+	mustAddClientKey(t, r, "1", "k2")
+
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first", ClientProperties: map[string]string{"flag": "true"}})
+	for _, key := range []string{"k1", "k2"} {
+		client, err := r.GetClientByKey(ctx, key)
+		if err != nil || client == nil || client.ClientProperties["flag"] != "true" {
+			t.Errorf("GetClientByKey(%s) returned outdated client (%v, %v)", key, client, err)
+		}
+	}
+}
+
+func testRemoveClientKey(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+	mustAddClientKey(t, r, "1", "k1")
+	mustAddClientKey(t, r, "1", "k2")
+
+	if err := r.RemoveClientKey(ctx, "k1"); err != nil {
+		t.Fatalf("can't remove client key, %v", err)
+	}
+	if err := r.RemoveClientKey(ctx, "missing"); err != nil {
+		t.Fatalf("removal of the missing key must not fail, %v", err)
+	}
+	expectNoClientByKey(t, r, "k1")
+	expectKeys(t, r, "1", "k2")
+
+	// the removed key could be associated with another client
+	mustSetClient(t, r, &asit.Client{Id: "2", Name: "second"})
+	mustAddClientKey(t, r, "2", "k1")
+}
+
+func testRemoveClientRemovesKeys(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+	mustSetClient(t, r, &asit.Client{Id: "2", Name: "second"})
+	mustAddClientKey(t, r, "1", "k1")
+	mustAddClientKey(t, r, "1", "k2")
+
+	if err := r.RemoveClient(ctx, "1"); err != nil {
+		t.Fatalf("can't remove client, %v", err)
+	}
+	if client, err := r.GetClientById(ctx, "1"); err != nil || client != nil {
+		t.Errorf("GetClientById of the removed client returned (%v, %v)", client, err)
+	}
+	expectNoClientByKey(t, r, "k1")
+	expectNoClientByKey(t, r, "k2")
+	expectKeys(t, r, "1")
+
+	all, err := r.GetAllClients(ctx)
+	if err != nil {
+		t.Fatalf("can't get all clients, %v", err)
+	}
+	if len(all) != 1 || all[0].Id != "2" {
+		t.Errorf("expected only client 2, got %v", all)
+	}
+
+	// keys of the trashed client stay reserved until it's purged
+	err = r.AddClientKey(ctx, "2", "k1")
+	var nonUniqueErr *db.NonUniqueClientKeyError
+	if !errors.As(err, &nonUniqueErr) {
+		t.Fatalf("expected NonUniqueClientKeyError for the key of the trashed client, got %v", err)
+	}
+	if err := r.PurgeClient(ctx, "1"); err != nil {
+		t.Fatalf("can't purge client, %v", err)
+	}
+	mustAddClientKey(t, r, "2", "k1")
+}
+
+func testClientKeyTTL(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+	mustSetClient(t, r, &asit.Client{Id: "2", Name: "second"})
+	mustAddClientKeyWithTTL(t, r, "1", "k1", shortTTL)
+	mustAddClientKey(t, r, "1", "k2")
+	mustAddClientKeyWithTTL(t, r, "1", "k3", shortTTL)
+	mustAddClientKeyWithTTL(t, r, "1", "k4", shortTTL)
+	// the repeated addition replaces the expiration time
+	mustAddClientKey(t, r, "1", "k3")
+
+	clientKeys, err := r.GetClientKeys(ctx, "1")
+	if err != nil || clientKeys == nil {
+		t.Fatalf("GetClientKeys returned (%v, %v)", clientKeys, err)
+	}
+	if _, ok := clientKeys.Expires["k1"]; !ok || len(clientKeys.Expires) != 2 {
+		t.Errorf("unexpected expiration times %v, expected k1 and k4", clientKeys.Expires)
+	}
+	// the key aliases rewritten by the update keep their TTL
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "updated"})
+	if client, err := r.GetClientByKey(ctx, "k1"); err != nil || client == nil || client.Name != "updated" {
+		t.Errorf("GetClientByKey(k1) returned (%v, %v), expected the updated client", client, err)
+	}
+
+	time.Sleep(2 * shortTTL)
+	expectNoClientByKey(t, r, "k1")
+	expectNoClientByKey(t, r, "k4")
+	expectKeys(t, r, "1", "k2", "k3")
+	if client, err := r.GetClientByKey(ctx, "k3"); err != nil || client == nil || client.Id != "1" {
+		t.Errorf("GetClientByKey(k3) returned (%v, %v), expected client 1", client, err)
+	}
+
+	// the expired key could be associated with another client before it's removed from the client keys
+	mustAddClientKey(t, r, "2", "k1")
+	if _, err := r.ExpireClientKeys(ctx, time.Now()); err != nil {
+		t.Fatalf("can't expire client keys, %v", err)
+	}
+	if expired, err := r.ExpireClientKeys(ctx, time.Now()); err != nil || expired != 0 {
+		t.Errorf("repeated ExpireClientKeys returned (%d, %v), expected (0, nil)", expired, err)
+	}
+	expectKeys(t, r, "1", "k2", "k3")
+	expectKeys(t, r, "2", "k1")
+	if client, err := r.GetClientByKey(ctx, "k1"); err != nil || client == nil || client.Id != "2" {
+		t.Errorf("GetClientByKey(k1) returned (%v, %v), expected client 2", client, err)
+	}
+
+	entries, _, err := r.GetClientHistory(ctx, "1", "", 0)
+	if err != nil {
+		t.Fatalf("can't get client history, %v", err)
+	}
+	var expiredKeys []string
+	for _, entry := range entries {
+		if entry.Change != asit.ClientChangeType_CLIENT_KEY_EXPIRED {
+			continue
+		}
+		expiredKeys = append(expiredKeys, entry.Key)
+		if slices.Contains(entry.NewKeys, entry.Key) || !slices.Contains(entry.OldKeys, entry.Key) {
+			t.Errorf("unexpected key expiration %q: %v -> %v", entry.Key, entry.OldKeys, entry.NewKeys)
+		}
+	}
+	slices.Sort(expiredKeys)
+	if !slices.Equal(expiredKeys, []string{"k1", "k4"}) {
+		t.Errorf("history has expirations of %v, expected [k1 k4]", expiredKeys)
+	}
+}
+
+func testTrashedClientKeyTTL(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+	mustSetClient(t, r, &asit.Client{Id: "2", Name: "second"})
+	mustAddClientKeyWithTTL(t, r, "1", "k1", shortTTL)
+	mustAddClientKey(t, r, "1", "k2")
+	if err := r.RemoveClient(ctx, "1"); err != nil {
+		t.Fatalf("can't remove client, %v", err)
+	}
+	trashed, err := r.GetTrashedClientById(ctx, "1")
+	if err != nil || trashed == nil {
+		t.Fatalf("GetTrashedClientById returned (%v, %v)", trashed, err)
+	}
+	if _, ok := trashed.KeysExpires["k1"]; !ok || len(trashed.Keys) != 2 {
+		t.Errorf("trashed client has keys %v expiring %v, expected k1 and k2 expiring k1", trashed.Keys, trashed.KeysExpires)
+	}
+	var nonUniqueErr *db.NonUniqueClientKeyError
+	if err := r.AddClientKey(ctx, "2", "k1"); !errors.As(err, &nonUniqueErr) {
+		t.Fatalf("expected NonUniqueClientKeyError for the key of the trashed client, got %v", err)
+	}
+
+	// the reservation of the key expires with the key
+	time.Sleep(2 * shortTTL)
+	mustAddClientKey(t, r, "2", "k1")
+	trashed, err = r.GetTrashedClientById(ctx, "1")
+	if err != nil || trashed == nil || !slices.Equal(trashed.Keys, []string{"k2"}) {
+		t.Fatalf("GetTrashedClientById returned (%v, %v), expected the client with the key k2", trashed, err)
+	}
+	if _, err := r.RestoreClient(ctx, "1"); err != nil {
+		t.Fatalf("can't restore client, %v", err)
+	}
+	expectKeys(t, r, "1", "k2")
+	if client, err := r.GetClientByKey(ctx, "k1"); err != nil || client == nil || client.Id != "2" {
+		t.Errorf("GetClientByKey(k1) returned (%v, %v), expected client 2", client, err)
+	}
+}
+
+func testTrashedClients(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	for i := 1; i <= 3; i++ {
+		mustSetClient(t, r, &asit.Client{Id: strconv.Itoa(i), Name: "client", ClientProperties: map[string]string{"a": "1"}})
+	}
+	mustAddClientKey(t, r, "2", "k1")
+	mustAddClientKey(t, r, "2", "k2")
+	removed := time.Now()
+	for _, id := range []string{"3", "2"} {
+		if err := r.RemoveClient(ctx, id); err != nil {
+			t.Fatalf("can't remove client %s, %v", id, err)
+		}
+	}
+
+	trashed, err := r.GetTrashedClientById(ctx, "2")
+	if err != nil || trashed == nil {
+		t.Fatalf("GetTrashedClientById returned (%v, %v)", trashed, err)
+	}
+	if trashed.Client.Name != "client" || trashed.Client.ClientProperties["a"] != "1" {
+		t.Errorf("unexpected trashed client %v", trashed.Client)
+	}
+	keys := append([]string{}, trashed.Keys...)
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"k1", "k2"}) {
+		t.Errorf("trashed client has keys %v, expected [k1 k2]", trashed.Keys)
+	}
+	if trashed.Deleted.AsTime().Before(removed.Add(-time.Second)) {
+		t.Errorf("unexpected deletion time %v", trashed.Deleted.AsTime())
+	}
+	if trashed, err := r.GetTrashedClientById(ctx, "1"); err != nil || trashed != nil {
+		t.Errorf("GetTrashedClientById of the active client returned (%v, %v)", trashed, err)
+	}
+
+	page, nextCursor, err := r.GetTrashedClients(ctx, "", 1)
+	if err != nil || len(page) != 1 || page[0].Client.Id != "2" || nextCursor == "" {
+		t.Fatalf("the first page of the trash is (%v, %q, %v), expected client 2", page, nextCursor, err)
+	}
+	page, nextCursor, err = r.GetTrashedClients(ctx, nextCursor, 1)
+	if err != nil || len(page) != 1 || page[0].Client.Id != "3" {
+		t.Fatalf("the second page of the trash is (%v, %v), expected client 3", page, err)
+	}
+	page, _, err = r.GetTrashedClients(ctx, nextCursor, 1)
+	if err != nil || len(page) != 0 {
+		t.Errorf("the last page of the trash is (%v, %v), expected empty", page, err)
+	}
+
+	// the trashed clients are not found by the queries
+	if ids := queryAllPages(t, r, db.ClientsQuery{Properties: map[string]string{"a": "1"}, Limit: 1}); !slices.Equal(ids, []string{"1"}) {
+		t.Errorf("query returned %v, expected only the active client 1", ids)
+	}
+	if ids := queryAllPages(t, r, db.ClientsQuery{SortBy: db.CLIENTS_SORT_BY_NAME}); !slices.Equal(ids, []string{"1"}) {
+		t.Errorf("query sorted by name returned %v, expected only the active client 1", ids)
+	}
+}
+
+func testRestoreClient(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+	mustAddClientKey(t, r, "1", "k1")
+	mustAddClientKey(t, r, "1", "k2")
+	revision := mustGetClient(t, r, "1").Revision
+	if err := r.RemoveClient(ctx, "1"); err != nil {
+		t.Fatalf("can't remove client, %v", err)
+	}
+
+	restored, err := r.RestoreClient(ctx, "1")
+	if err != nil {
+		t.Fatalf("can't restore client, %v", err)
+	}
+	if restored.Name != "first" || restored.Revision <= revision {
+		t.Errorf("unexpected restored client %v, expected revision greater than %d", restored, revision)
+	}
+	if client := mustGetClient(t, r, "1"); !proto.Equal(client, restored) {
+		t.Errorf("GetClientById returned %v, expected the restored client %v", client, restored)
+	}
+	expectKeys(t, r, "1", "k1", "k2")
+	for _, key := range []string{"k1", "k2"} {
+		client, err := r.GetClientByKey(ctx, key)
+		if err != nil || client == nil || client.Id != "1" {
+			t.Errorf("GetClientByKey(%s) returned (%v, %v), expected the restored client", key, client, err)
+		}
+	}
+	if trashed, err := r.GetTrashedClientById(ctx, "1"); err != nil || trashed != nil {
+		t.Errorf("GetTrashedClientById of the restored client returned (%v, %v)", trashed, err)
+	}
+	if ids := queryAllPages(t, r, db.ClientsQuery{SortBy: db.CLIENTS_SORT_BY_LAST_UPDATED}); !slices.Equal(ids, []string{"1"}) {
+		t.Errorf("query returned %v, expected the restored client", ids)
+	}
+
+	var notFoundErr *db.NotFoundClientByIdError
+	if _, err := r.RestoreClient(ctx, "1"); !errors.As(err, &notFoundErr) {
+		t.Errorf("expected NotFoundClientByIdError for the restore of the active client, got %v", err)
+	}
+	if err := r.PurgeClient(ctx, "1"); !errors.As(err, &notFoundErr) {
+		t.Errorf("expected NotFoundClientByIdError for the purge of the active client, got %v", err)
+	}
+	if _, err := r.RestoreClient(ctx, "missing"); !errors.As(err, &notFoundErr) {
+		t.Errorf("expected NotFoundClientByIdError for the restore of the missing client, got %v", err)
+	}
+}
+
+func testTrashedClientIdConflict(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first"})
+	if err := r.RemoveClient(ctx, "1"); err != nil {
+		t.Fatalf("can't remove client, %v", err)
+	}
+
+	var conflictErr *db.ClientIdConflictError
+	if err := r.SetClient(ctx, &asit.Client{Id: "1", Name: "new"}); !errors.As(err, &conflictErr) {
+		t.Fatalf("expected ClientIdConflictError for the id of the trashed client, got %v", err)
+	}
+	if client, err := r.GetClientById(ctx, "1"); err != nil || client != nil {
+		t.Errorf("GetClientById of the trashed client returned (%v, %v)", client, err)
+	}
+
+	if err := r.PurgeClient(ctx, "1"); err != nil {
+		t.Fatalf("can't purge client, %v", err)
+	}
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "new"})
+	if trashed, _, err := r.GetTrashedClients(ctx, "", 0); err != nil || len(trashed) != 0 {
+		t.Errorf("the trash is (%v, %v), expected empty", trashed, err)
+	}
+}
+
+func testPurgeTrash(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	for i := 1; i <= 3; i++ {
+		mustSetClient(t, r, &asit.Client{Id: strconv.Itoa(i)})
+		mustAddClientKey(t, r, strconv.Itoa(i), "k"+strconv.Itoa(i))
+	}
+	remove := func(id string) {
+		t.Helper()
+		if err := r.RemoveClient(ctx, id); err != nil {
+			t.Fatalf("can't remove client %s, %v", id, err)
+		}
+		// the deletion times must differ
+		time.Sleep(2 * time.Millisecond)
+	}
+	remove("2")
+	remove("1")
+	bound := time.Now()
+	remove("3")
+
+	purged, err := r.PurgeTrash(ctx, bound)
+	if err != nil {
+		t.Fatalf("can't purge trash, %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("purged %d clients, expected 2", purged)
+	}
+	trashed, _, err := r.GetTrashedClients(ctx, "", 0)
+	if err != nil || len(trashed) != 1 || trashed[0].Client.Id != "3" {
+		t.Fatalf("the trash is (%v, %v), expected only client 3", trashed, err)
+	}
+	// the keys of the purged clients are released
+	mustSetClient(t, r, &asit.Client{Id: "4"})
+	mustAddClientKey(t, r, "4", "k1")
+	mustAddClientKey(t, r, "4", "k2")
+
+	if purged, err := r.PurgeTrash(ctx, bound); err != nil || purged != 0 {
+		t.Errorf("the repeated purge returned (%d, %v), expected (0, nil)", purged, err)
+	}
+}
+
+func testClientHistory(t *testing.T, r db.ClientsRepository) {
+	ctx := db.WithAuditInfo(context.Background(), db.AuditInfo{RequestId: "request", Caller: "tester"})
+	steps := []func() error{
+		func() error {
+			return r.SetClient(ctx, &asit.Client{Id: "1", Name: "first", ClientProperties: map[string]string{"a": "1"}})
+		},
+		func() error {
+			_, err := r.PatchClient(ctx, "1", db.ClientPatch{Properties: map[string]*string{"a": nil}}, db.ANY_CLIENT_REVISION)
+			return err
+		},
+		func() error { return r.AddClientKey(ctx, "1", "k1") },
+		// the repeated addition and the removal of the missing key are not recorded
+		func() error { return r.AddClientKey(ctx, "1", "k1") },
+		func() error { return r.AddClientKey(ctx, "1", "k2") },
+		func() error { return r.RemoveClientKey(ctx, "k1") },
+		func() error { return r.RemoveClientKey(ctx, "missing") },
+		func() error { return r.RemoveClient(ctx, "1") },
+		func() error { _, err := r.RestoreClient(ctx, "1"); return err },
+		func() error { return r.RemoveClient(ctx, "1") },
+		func() error { return r.PurgeClient(ctx, "1") },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d failed, %v", i, err)
+		}
+	}
+
+	var entries []*asit.ClientHistoryEntry
+	cursor := ""
+	for {
+		page, nextCursor, err := r.GetClientHistory(context.Background(), "1", cursor, 3)
+		if err != nil {
+			t.Fatalf("can't get client history, %v", err)
+		}
+		if len(page) > 3 {
+			t.Fatalf("history page has %d entries, expected at most 3", len(page))
+		}
+		entries = append(entries, page...)
+		if nextCursor == "" {
+			break
+		}
+		cursor = nextCursor
+	}
+
+	expected := []asit.ClientChangeType{
+		asit.ClientChangeType_CLIENT_CREATED,
+		asit.ClientChangeType_CLIENT_UPDATED,
+		asit.ClientChangeType_CLIENT_KEY_ADDED,
+		asit.ClientChangeType_CLIENT_KEY_ADDED,
+		asit.ClientChangeType_CLIENT_KEY_REMOVED,
+		asit.ClientChangeType_CLIENT_DELETED,
+		asit.ClientChangeType_CLIENT_RESTORED,
+		asit.ClientChangeType_CLIENT_DELETED,
+		asit.ClientChangeType_CLIENT_PURGED,
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("history has %d entries, expected %d: %v", len(entries), len(expected), entries)
+	}
+	for i, entry := range entries {
+		if entry.Change != expected[i] || entry.Sequence != int64(i+1) {
+			t.Errorf("entry %d is %v #%d, expected %v #%d", i, entry.Change, entry.Sequence, expected[i], i+1)
+		}
+		if entry.RequestId != "request" || entry.Caller != "tester" || entry.Time == nil {
+			t.Errorf("entry %d has no audit info: %v", i, entry)
+		}
+	}
+
+	if entries[0].OldClient != nil || entries[0].NewClient.Revision != 1 || entries[0].NewClient.ClientProperties["a"] != "1" {
+		t.Errorf("unexpected values of the creation %v -> %v", entries[0].OldClient, entries[0].NewClient)
+	}
+	if entries[1].OldClient.ClientProperties["a"] != "1" || len(entries[1].NewClient.ClientProperties) != 0 || entries[1].NewClient.Revision != 2 {
+		t.Errorf("unexpected values of the update %v -> %v", entries[1].OldClient, entries[1].NewClient)
+	}
+	if entries[3].Key != "k2" || !slices.Equal(entries[3].OldKeys, []string{"k1"}) || !slices.Equal(entries[3].NewKeys, []string{"k1", "k2"}) {
+		t.Errorf("unexpected key addition %q: %v -> %v", entries[3].Key, entries[3].OldKeys, entries[3].NewKeys)
+	}
+	if entries[4].Key != "k1" || !slices.Equal(entries[4].OldKeys, []string{"k1", "k2"}) || !slices.Equal(entries[4].NewKeys, []string{"k2"}) {
+		t.Errorf("unexpected key removal %q: %v -> %v", entries[4].Key, entries[4].OldKeys, entries[4].NewKeys)
+	}
+	if entries[5].OldClient.GetRevision() != 2 || entries[5].NewClient != nil || !slices.Equal(entries[5].OldKeys, []string{"k2"}) {
+		t.Errorf("unexpected values of the deletion %v, %v", entries[5].OldClient, entries[5].OldKeys)
+	}
+	if entries[6].NewClient.GetRevision() != 3 || !slices.Equal(entries[6].NewKeys, []string{"k2"}) {
+		t.Errorf("unexpected values of the restoration %v, %v", entries[6].NewClient, entries[6].NewKeys)
+	}
+
+	var invalidQueryErr *db.InvalidClientsQueryError
+	if _, _, err := r.GetClientHistory(ctx, "1", "malformed", 1); !errors.As(err, &invalidQueryErr) {
+		t.Errorf("expected InvalidClientsQueryError for the malformed cursor, got %v", err)
+	}
+	if entries, _, err := r.GetClientHistory(ctx, "missing", "", 0); err != nil || len(entries) != 0 {
+		t.Errorf("history of the missing client is (%v, %v), expected empty", entries, err)
+	}
+}
+
+func testRollbackClient(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first", ClientProperties: map[string]string{"a": "1"}})
+	mustAddClientKey(t, r, "1", "k1")
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "second", ClientProperties: map[string]string{"a": "2", "b": "1"}})
+
+	first, err := r.GetClientRevision(ctx, "1", 1)
+	if err != nil || first == nil || first.Name != "first" {
+		t.Fatalf("GetClientRevision returned (%v, %v), expected the first revision", first, err)
+	}
+
+	var mismatchErr *db.ClientRevisionMismatchError
+	if _, err := r.RollbackClient(ctx, "1", 1, 1); !errors.As(err, &mismatchErr) {
+		t.Errorf("expected ClientRevisionMismatchError, got %v", err)
+	}
+	var notFoundRevisionErr *db.NotFoundClientRevisionError
+	if _, err := r.RollbackClient(ctx, "1", 10, db.ANY_CLIENT_REVISION); !errors.As(err, &notFoundRevisionErr) {
+		t.Errorf("expected NotFoundClientRevisionError, got %v", err)
+	}
+
+	client, err := r.RollbackClient(ctx, "1", 1, 2)
+	if err != nil {
+		t.Fatalf("can't roll back client, %v", err)
+	}
+	if client.Revision != 3 || client.Name != "first" || len(client.ClientProperties) != 1 || client.ClientProperties["a"] != "1" {
+		t.Errorf("unexpected rolled back client %v", client)
+	}
+	if stored := mustGetClient(t, r, "1"); !proto.Equal(stored, client) {
+		t.Errorf("GetClientById returned %v, expected the rolled back client %v", stored, client)
+	}
+	expectKeys(t, r, "1", "k1")
+
+	entries, _, err := r.GetClientHistory(ctx, "1", "", 0)
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("can't get client history, %v", err)
+	}
+	last := entries[len(entries)-1]
+	if last.Change != asit.ClientChangeType_CLIENT_ROLLED_BACK || last.OldClient.GetRevision() != 2 || last.NewClient.GetRevision() != 3 {
+		t.Errorf("unexpected last history entry %v", last)
+	}
+}
+
+// testConcurrentSameKey checks that a key is associated with exactly one client
+// even if several clients try to get it concurrently.
+func testConcurrentSameKey(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	for w := 0; w < ConcurrentWriters; w++ {
+		mustSetClient(t, r, &asit.Client{Id: strconv.Itoa(w)})
+	}
+
+	winners := make(chan string, ConcurrentWriters)
+	var wg sync.WaitGroup
+	for w := 0; w < ConcurrentWriters; w++ {
+		wg.Add(1)
+		go func(clientId string) {
+			defer wg.Done()
+			err := r.AddClientKey(ctx, clientId, "shared")
+			var nonUniqueErr *db.NonUniqueClientKeyError
+			var concurrentUpdateErr *db.ConcurrentUpdateError
+			switch {
+			case err == nil:
+				winners <- clientId
+			case errors.As(err, &nonUniqueErr), errors.As(err, &concurrentUpdateErr):
+			default:
+				t.Errorf("unexpected error, %v", err)
+			}
+		}(strconv.Itoa(w))
+	}
+	wg.Wait()
+	close(winners)
+
+	var winnerIds []string
+	for winner := range winners {
+		winnerIds = append(winnerIds, winner)
+	}
+	if len(winnerIds) != 1 {
+		t.Fatalf("expected exactly one client with the shared key, got %v", winnerIds)
+	}
+	client, err := r.GetClientByKey(ctx, "shared")
+	if err != nil || client == nil || client.Id != winnerIds[0] {
+		t.Errorf("GetClientByKey returned (%v, %v), expected client %s", client, err, winnerIds[0])
+	}
+	for w := 0; w < ConcurrentWriters; w++ {
+		if clientId := strconv.Itoa(w); clientId != winnerIds[0] {
+			expectKeys(t, r, clientId)
+		}
+	}
+}
+
+// testConcurrentClientKeys checks that concurrently added keys of the same client are not lost.
+func testConcurrentClientKeys(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1"})
+
+	added := make(chan string, ConcurrentWriters)
+	var wg sync.WaitGroup
+	for w := 0; w < ConcurrentWriters; w++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			err := r.AddClientKey(ctx, "1", key)
+			var concurrentUpdateErr *db.ConcurrentUpdateError
+			switch {
+			case err == nil:
+				added <- key
+			case errors.As(err, &concurrentUpdateErr):
+			default:
+				t.Errorf("unexpected error, %v", err)
+			}
+		}("k" + strconv.Itoa(w))
+	}
+	wg.Wait()
+	close(added)
+
+	var addedKeys []string
+	for key := range added {
+		addedKeys = append(addedKeys, key)
+	}
+	expectKeys(t, r, "1", addedKeys...)
+}
+
+func testConsistentClients(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first", ClientProperties: map[string]string{"env": "dev"}})
+	mustSetClient(t, r, &asit.Client{Id: "2", Name: "second"})
+	mustSetClient(t, r, &asit.Client{Id: "3", Name: "third"})
+	mustAddClientKey(t, r, "1", "k1")
+	mustAddClientKeyWithTTL(t, r, "1", "k2", time.Hour)
+	mustAddClientKey(t, r, "2", "k3")
+	mustAddClientKey(t, r, "3", "k4")
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "renamed"})
+	if err := r.RemoveClientKey(ctx, "k3"); err != nil {
+		t.Fatalf("can't remove client key, %v", err)
+	}
+	if err := r.RemoveClient(ctx, "3"); err != nil {
+		t.Fatalf("can't remove client, %v", err)
+	}
+
+	for _, repair := range []bool{false, true} {
+		report, err := r.CheckConsistency(ctx, repair)
+		if err != nil {
+			t.Fatalf("CheckConsistency(%v) failed, %v", repair, err)
+		}
+		if len(report.Inconsistencies) != 0 {
+			t.Errorf("CheckConsistency(%v) found inconsistencies %q in the consistent clients", repair, inconsistencies(report))
+		}
+		if report.CheckedClients < 2 {
+			t.Errorf("CheckConsistency(%v) checked %d clients, expected at least 2", repair, report.CheckedClients)
+		}
+	}
+	if client := mustGetClient(t, r, "1"); client.Name != "renamed" || client.Revision != 2 {
+		t.Errorf("the repair of the consistent clients changed client %v", client)
+	}
+}
+
+func testExportImport(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "first", ClientProperties: map[string]string{"env": "dev"}})
+	mustSetClient(t, r, &asit.Client{Id: "2", Name: "second"})
+	mustAddClientKey(t, r, "1", "k1")
+	mustAddClientKeyWithTTL(t, r, "1", "k2", time.Hour)
+	mustAddClientKey(t, r, "2", "k3")
+	files := map[string]*bytes.Buffer{}
+	for _, format := range []string{db.EXPORT_FORMAT_NDJSON, db.EXPORT_FORMAT_PROTO} {
+		files[format] = &bytes.Buffer{}
+		writer, err := db.NewExportWriter(files[format], format)
+		if err != nil {
+			t.Fatalf("can't create %s export writer, %v", format, err)
+		}
+		if exported, err := db.ExportClients(ctx, r, writer); err != nil || exported != 2 {
+			t.Fatalf("ExportClients(%s) returned (%d, %v), expected (2, nil)", format, exported, err)
+		}
+	}
+
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "changed"})
+	if err := r.RemoveClientKey(ctx, "k3"); err != nil {
+		t.Fatalf("can't remove client key, %v", err)
+	}
+	mustSetClient(t, r, &asit.Client{Id: "3", Name: "third"})
+	mustAddClientKey(t, r, "3", "k3")
+
+	importFile := func(format string, mode string, dryRun bool) *asit.ImportReport {
+		t.Helper()
+		reader, err := db.NewExportReader(bytes.NewReader(files[format].Bytes()), format)
+		if err != nil {
+			t.Fatalf("can't create %s export reader, %v", format, err)
+		}
+		report, err := db.ImportClients(ctx, r, reader, mode, dryRun)
+		if err != nil {
+			t.Fatalf("ImportClients(%s, %s, %v) failed, %v", format, mode, dryRun, err)
+		}
+		return report
+	}
+	expectTakenK3 := func(report *asit.ImportReport) {
+		t.Helper()
+		if len(report.Conflicts) != 1 || report.Conflicts[0].Type != asit.ImportConflictType_CLIENT_KEY_TAKEN ||
+			report.Conflicts[0].ClientId != "2" || report.Conflicts[0].Key != "k3" {
+			t.Errorf("expected the conflict of key k3 of client 2, got %v", report.Conflicts)
+		}
+	}
+
+	report := importFile(db.EXPORT_FORMAT_NDJSON, db.IMPORT_MODE_MERGE, true)
+	if report.UpdatedClients != 2 || report.CreatedClients != 0 || report.ImportedKeys != 2 {
+		t.Errorf("unexpected dry run report %v", report)
+	}
+	expectTakenK3(report)
+	if client := mustGetClient(t, r, "1"); client.Name != "changed" {
+		t.Errorf("the dry run changed client %v", client)
+	}
+
+	report = importFile(db.EXPORT_FORMAT_PROTO, db.IMPORT_MODE_MERGE, false)
+	if report.UpdatedClients != 2 || report.CreatedClients != 0 || report.ImportedKeys != 2 {
+		t.Errorf("unexpected merge report %v", report)
+	}
+	expectTakenK3(report)
+	if client := mustGetClient(t, r, "1"); client.Name != "first" || client.ClientProperties["env"] != "dev" {
+		t.Errorf("the merge didn't restore client %v", client)
+	}
+	expectKeys(t, r, "1", "k1", "k2")
+	expectKeys(t, r, "2")
+	expectKeys(t, r, "3", "k3")
+
+	report = importFile(db.EXPORT_FORMAT_NDJSON, db.IMPORT_MODE_REPLACE, false)
+	if report.RemovedClients != 3 || report.CreatedClients != 2 || report.ImportedKeys != 3 || len(report.Conflicts) != 0 {
+		t.Errorf("unexpected replace report %v", report)
+	}
+	if client, err := r.GetClientById(ctx, "3"); err != nil || client != nil {
+		t.Errorf("the replace kept client %v, %v", client, err)
+	}
+	expectKeys(t, r, "1", "k1", "k2")
+	expectKeys(t, r, "2", "k3")
+}
+
+func testImportDuplicateKeys(t *testing.T, r db.ClientsRepository) {
+	file := &bytes.Buffer{}
+	writer, err := db.NewExportWriter(file, db.EXPORT_FORMAT_NDJSON)
+	if err != nil {
+		t.Fatalf("can't create export writer, %v", err)
+	}
+	records := []*asit.ExportRecord{
+		{Record: &asit.ExportRecord_Header{Header: &asit.ExportHeader{Version: db.EXPORT_VERSION}}},
+		{Record: &asit.ExportRecord_Client{Client: &asit.ExportedClient{Client: &asit.Client{Id: "1"}, Keys: []string{"k1", "shared"}}}},
+		{Record: &asit.ExportRecord_Client{Client: &asit.ExportedClient{Client: &asit.Client{Id: "2"}, Keys: []string{"shared"}}}},
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatalf("can't write record, %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("can't flush records, %v", err)
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		reader, err := db.NewExportReader(bytes.NewReader(file.Bytes()), db.EXPORT_FORMAT_NDJSON)
+		if err != nil {
+			t.Fatalf("can't create export reader, %v", err)
+		}
+		report, err := db.ImportClients(context.Background(), r, reader, db.IMPORT_MODE_REPLACE, dryRun)
+		if err != nil {
+			t.Fatalf("ImportClients(dryRun %v) failed, %v", dryRun, err)
+		}
+		if report.CreatedClients != 2 || report.ImportedKeys != 2 || len(report.Conflicts) != 1 ||
+			report.Conflicts[0].Type != asit.ImportConflictType_DUPLICATE_IMPORTED_KEY || report.Conflicts[0].ClientId != "2" {
+			t.Errorf("unexpected report of the import (dryRun %v) %v", dryRun, report)
+		}
+	}
+	expectKeys(t, r, "1", "k1", "shared")
+	expectKeys(t, r, "2")
+
+	reader, err := db.NewExportReader(strings.NewReader(`{"client":{"client":{"id":"1"}}}`), db.EXPORT_FORMAT_NDJSON)
+	if err != nil {
+		t.Fatalf("can't create export reader, %v", err)
+	}
+	_, err = db.ImportClients(context.Background(), r, reader, db.IMPORT_MODE_MERGE, false)
+	var invalidFileErr *db.InvalidExportFileError
+	if !errors.As(err, &invalidFileErr) {
+		t.Errorf("ImportClients of the file without the header returned %v, expected InvalidExportFileError", err)
+	}
+}
+
+// testHMACKey is the key of the hashed client key names
+var testHMACKey = []byte("dbtest-hmac-key")
+
+func clientIds(clients []*asit.Client) []string {
+	ids := []string{}
+	for _, c := range clients {
+		ids = append(ids, c.Id)
+	}
+	return ids
+}
+
+// queryAllPages returns ids of the clients of all the pages of the query
+func queryAllPages(t *testing.T, r db.ClientsRepository, query db.ClientsQuery) []string {
+	t.Helper()
+	var ids []string
+	for pages := 0; ; pages++ {
+		clients, nextCursor, err := r.QueryClients(context.Background(), query)
+		if err != nil {
+			t.Fatalf("can't query clients %+v, %v", query, err)
+		}
+		if query.Limit > 0 && len(clients) > query.Limit {
+			t.Errorf("page of the query %+v is bigger than the limit: %v", query, clientIds(clients))
+		}
+		for _, c := range clients {
+			if len(c.ClientProperties) != 0 {
+				t.Errorf("QueryClients must not return client properties, got %v", c)
+			}
+		}
+		ids = append(ids, clientIds(clients)...)
+		if nextCursor == "" {
+			return ids
+		}
+		if pages > 10 {
+			t.Fatalf("too many pages of the query %+v: %v", query, ids)
+		}
+		query.Cursor = nextCursor
+	}
+}
+
+// inconsistencies returns the types, the client ids, the keys and the indexes of the reported inconsistencies
+func inconsistencies(report *asit.ClientsConsistencyReport) []string {
+	res := []string{}
+	for _, inconsistency := range report.GetInconsistencies() {
+		parts := []string{inconsistency.Type.String()}
+		for _, part := range []string{inconsistency.ClientId, inconsistency.Key, inconsistency.Index} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		res = append(res, strings.Join(parts, " "))
+	}
+	return res
+}
+
+func mustSetClient(t *testing.T, r db.ClientsRepository, client *asit.Client) {
+	t.Helper()
+	if err := r.SetClient(context.Background(), client); err != nil {
+		t.Fatalf("can't set client %s, %v", client.Id, err)
+	}
+}
+
+func mustGetClient(t *testing.T, r db.ClientsRepository, id string) *asit.Client {
+	t.Helper()
+	client, err := r.GetClientById(context.Background(), id)
+	if err != nil {
+		t.Fatalf("can't get client %s, %v", id, err)
+	}
+	if client == nil {
+		t.Fatalf("client %s not found", id)
+	}
+	return client
+}
+
+func mustAddClientKey(t *testing.T, r db.ClientsRepository, clientId string, key string) {
+	t.Helper()
+	if err := r.AddClientKey(context.Background(), clientId, key); err != nil {
+		t.Fatalf("can't add key %s to client %s, %v", key, clientId, err)
+	}
+}
+
+func mustAddClientKeyWithTTL(t *testing.T, r db.ClientsRepository, clientId string, key string, ttl time.Duration) {
+	t.Helper()
+	if err := r.AddClientKeyWithTTL(context.Background(), clientId, key, ttl); err != nil {
+		t.Fatalf("can't add key %s to client %s, %v", key, clientId, err)
+	}
+}
+
+func expectKeys(t *testing.T, r db.ClientsRepository, clientId string, expected ...string) {
+	t.Helper()
+	clientKeys, err := r.GetClientKeys(context.Background(), clientId)
+	if err != nil {
+		t.Fatalf("can't get keys of client %s, %v", clientId, err)
+	}
+	var actual []string
+	if clientKeys != nil {
+		actual = append(actual, clientKeys.Keys...)
+	}
+	expected = append([]string{}, expected...)
+	slices.Sort(actual)
+	slices.Sort(expected)
+	if !slices.Equal(actual, expected) {
+		t.Errorf("client %s has keys %v, expected %v", clientId, actual, expected)
+	}
+}
+
+func expectNoClientByKey(t *testing.T, r db.ClientsRepository, key string) {
+	t.Helper()
+	client, err := r.GetClientByKey(context.Background(), key)
+	if err != nil || client != nil {
+		t.Errorf("GetClientByKey(%s) returned (%v, %v), expected (nil, nil)", key, client, err)
+	}
+}
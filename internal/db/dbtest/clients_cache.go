@@ -0,0 +1,92 @@
+package dbtest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+)
+
+// newCachingInstance creates the cache of the instance publishing its changes to other instances and subscribed to their changes
+func newCachingInstance(t *testing.T, r db.ClientsRepository, changes db.ClientChanges, maxAge time.Duration) *db.CachingClientsRepository {
+	cache := db.NewCachingClientsRepository(db.NewNotifyingClientsRepository(r, changes.Publish), 10, maxAge)
+	subscribe(t, changes, cache.Invalidate)
+	return cache
+}
+
+// subscribe returns when the subscription is established, so the changes published after it are received
+func subscribe(t *testing.T, changes db.ClientChanges, changed func(clientId string)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	subscribed := make(chan struct{})
+	var once sync.Once
+	go changes.Subscribe(ctx, func(clientId string) {
+		once.Do(func() { close(subscribed) })
+		changed(clientId)
+	})
+	<-subscribed
+}
+
+// testClientsCacheInvalidation checks the caches of two instances sharing the storage
+func testClientsCacheInvalidation(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	changes := db.NewLocalClientChanges()
+	first := newCachingInstance(t, r, changes, time.Hour)
+	second := newCachingInstance(t, r, changes, time.Hour)
+
+	mustSetClient(t, first, &asit.Client{Id: "1", Name: "first"})
+	mustAddClientKey(t, first, "1", "k1")
+	for i := 0; i < 2; i++ {
+		if client, err := first.GetClientByKey(ctx, "k1"); err != nil || client.GetName() != "first" {
+			t.Fatalf("GetClientByKey(k1) returned (%v, %v), expected client 1", client, err)
+		}
+	}
+	if stats := first.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("cache stats are %v, expected 1 hit, 1 miss and 1 entry", stats)
+	}
+
+	// the change made by another instance is published to the first one
+	mustSetClient(t, second, &asit.Client{Id: "1", Name: "changed"})
+	if client, err := first.GetClientByKey(ctx, "k1"); err != nil || client.GetName() != "changed" {
+		t.Errorf("GetClientByKey(k1) returned (%v, %v) after the change by another instance, expected the changed client", client, err)
+	}
+
+	if err := second.RemoveClientKey(ctx, "k1"); err != nil {
+		t.Fatalf("can't remove key k1, %v", err)
+	}
+	expectNoClientByKey(t, first, "k1")
+
+	// the changes made without the cache are visible after maxAge
+	stale := db.NewCachingClientsRepository(r, 10, 50*time.Millisecond)
+	mustAddClientKey(t, r, "1", "k2")
+	if client, err := stale.GetClientByKey(ctx, "k2"); err != nil || client.GetName() != "changed" {
+		t.Fatalf("GetClientByKey(k2) returned (%v, %v), expected client 1", client, err)
+	}
+	mustSetClient(t, r, &asit.Client{Id: "1", Name: "unpublished"})
+	time.Sleep(100 * time.Millisecond)
+	if client, err := stale.GetClientByKey(ctx, "k2"); err != nil || client.GetName() != "unpublished" {
+		t.Errorf("GetClientByKey(k2) returned (%v, %v) after maxAge, expected the changed client", client, err)
+	}
+}
+
+// testClientsCacheEviction checks that the least recently used clients are evicted
+func testClientsCacheEviction(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	cache := db.NewCachingClientsRepository(r, 2, time.Hour)
+	for _, id := range []string{"1", "2", "3"} {
+		mustSetClient(t, cache, &asit.Client{Id: id})
+		mustAddClientKey(t, cache, id, "k"+id)
+	}
+	for _, key := range []string{"k1", "k2", "k1", "k3", "k1"} {
+		if _, err := cache.GetClientByKey(ctx, key); err != nil {
+			t.Fatalf("GetClientByKey(%s) failed, %v", key, err)
+		}
+	}
+	// k1 is kept as the recently used one, k2 is evicted by k3
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 3 || stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("cache stats are %v, expected 2 hits, 3 misses, 1 eviction and 2 entries", stats)
+	}
+}
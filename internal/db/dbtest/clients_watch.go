@@ -0,0 +1,78 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+)
+
+// testClientChanges checks that the changes of the clients and their keys made through the notifying repository
+// wake up the watchers of the changed clients only
+func testClientChanges(t *testing.T, r db.ClientsRepository) {
+	ctx := context.Background()
+	changes := db.NewLocalClientChanges()
+	watcher := db.NewClientsWatcher()
+	subscribe(t, changes, watcher.Notify)
+	notifying := db.NewNotifyingClientsRepository(r, changes.Publish)
+
+	mustSetClient(t, notifying, &asit.Client{Id: "1", Name: "first"})
+	mustSetClient(t, notifying, &asit.Client{Id: "2", Name: "second"})
+	first, stopFirst := watcher.Watch("1")
+	defer stopFirst()
+	second, stopSecond := watcher.Watch("2")
+	defer stopSecond()
+
+	changesOfFirst := []struct {
+		name   string
+		change func() error
+	}{
+		{"SetClient", func() error { return notifying.SetClient(ctx, &asit.Client{Id: "1", Name: "changed"}) }},
+		{"AddClientKey", func() error { return notifying.AddClientKey(ctx, "1", "k1") }},
+		{"AddClientKeyWithTTL", func() error { return notifying.AddClientKeyWithTTL(ctx, "1", "k2", time.Hour) }},
+		{"RemoveClientKey", func() error { return notifying.RemoveClientKey(ctx, "k1") }},
+		{"RemoveClient", func() error { return notifying.RemoveClient(ctx, "1") }},
+		{"RestoreClient", func() error { _, err := notifying.RestoreClient(ctx, "1"); return err }},
+	}
+	for _, tt := range changesOfFirst {
+		if err := tt.change(); err != nil {
+			t.Fatalf("%s failed, %v", tt.name, err)
+		}
+		select {
+		case <-first:
+		case <-time.After(time.Second):
+			t.Errorf("the watcher of client 1 isn't woken up by %s", tt.name)
+		}
+	}
+	select {
+	case <-second:
+		t.Errorf("the watcher of client 2 is woken up by the changes of client 1")
+	default:
+	}
+
+	// the removal of the missing key doesn't change any client
+	if err := notifying.RemoveClientKey(ctx, "missing"); err != nil {
+		t.Fatalf("can't remove missing key, %v", err)
+	}
+	stopFirst()
+	mustSetClient(t, notifying, &asit.Client{Id: "1", Name: "unwatched"})
+	select {
+	case <-first:
+		t.Errorf("the stopped watcher of client 1 is woken up")
+	default:
+	}
+
+	// the change made already isn't failed by the failed notification
+	failing := db.NewNotifyingClientsRepository(r, func(ctx context.Context, clientId string) error {
+		return errors.New("notification failed")
+	})
+	if err := failing.SetClient(ctx, &asit.Client{Id: "2", Name: "not notified"}); err != nil {
+		t.Errorf("SetClient with the failed notification returned %v", err)
+	}
+	if client := mustGetClient(t, r, "2"); client.Name != "not notified" {
+		t.Errorf("the change with the failed notification isn't made, %v", client)
+	}
+}
@@ -0,0 +1,205 @@
+package dbtest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/proto"
+)
+
+// RunStorageCodecTests checks that the values written by every codec are read back by the same codec,
+// and that the envelope codecs read the values written by the other ones, as required during a codec migration.
+func RunStorageCodecTests(t *testing.T) {
+	codecs := map[string]db.StorageCodec{
+		"Proto":     db.PROTO_CODEC,
+		"ProtoJSON": db.PROTOJSON_CODEC,
+		"Gzip":      db.NewCompressingCodec(db.PROTO_CODEC, 64),
+	}
+	for _, encoding := range []string{"proto", "protojson"} {
+		for _, compressMinSize := range []int{0, 64} {
+			codec := mustEnvelopeCodec(t, encoding, compressMinSize)
+			codecs["Envelope"+encoding+strconv.Itoa(compressMinSize)] = codec
+		}
+	}
+	values := []*asit.Client{
+		{},
+		{Id: "1", Name: "small"},
+		{Id: "2", Name: "large", ClientProperties: map[string]string{"payload": strings.Repeat("a", 1024)}},
+	}
+
+	for name, codec := range codecs {
+		t.Run(name+"RoundTrip", func(t *testing.T) {
+			for _, value := range values {
+				expectDecoded(t, codec, codec, value)
+			}
+		})
+	}
+	for _, encoding := range []string{"proto", "protojson"} {
+		reader := mustEnvelopeCodec(t, encoding, 0)
+		t.Run("EnvelopeReads"+encoding, func(t *testing.T) {
+			for name, writer := range codecs {
+				if name == "ProtoJSON" {
+					// plain protojson values are not written by the envelope codecs
+					continue
+				}
+				for _, value := range values {
+					expectDecoded(t, writer, reader, value)
+				}
+			}
+		})
+	}
+
+	t.Run("EncryptionKeyRotation", func(t *testing.T) {
+		oldKeyring := mustKeyring(t, "old=MDEyMzQ1Njc4OWFiY2RlZg==")
+		newKeyring := mustKeyring(t, "old=MDEyMzQ1Njc4OWFiY2RlZg==\nnew=ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=")
+		plain := mustEnvelopeCodec(t, "protojson", 64)
+		oldCodec := db.NewEncryptingCodec(plain, oldKeyring)
+		newCodec := db.NewEncryptingCodec(plain, newKeyring)
+		for _, value := range values {
+			expectDecoded(t, plain, newCodec, value)
+			expectDecoded(t, oldCodec, newCodec, value)
+			expectDecoded(t, newCodec, newCodec, value)
+		}
+
+		data, err := newCodec.Marshal(values[1])
+		if err != nil {
+			t.Fatalf("can't marshal value, %v", err)
+		}
+		if strings.Contains(string(data), values[1].Name) {
+			t.Errorf("the encrypted value %q contains the plain name", data)
+		}
+		if err := oldCodec.Unmarshal(data, &asit.Client{}); err == nil {
+			t.Errorf("the value encrypted with the new key is decrypted without it")
+		}
+		data[len(data)-1] ^= 1
+		if err := newCodec.Unmarshal(data, &asit.Client{}); err == nil {
+			t.Errorf("the tampered value is decrypted")
+		}
+	})
+
+	t.Run("EncryptionKeyBinding", func(t *testing.T) {
+		keyring := mustKeyring(t, "old=MDEyMzQ1Njc4OWFiY2RlZg==")
+		plain := mustEnvelopeCodec(t, "proto", 0)
+		codec := db.NewEncryptingCodec(plain, keyring)
+		data, err := codec.MarshalKey("client:1", values[1])
+		if err != nil {
+			t.Fatalf("can't marshal value, %v", err)
+		}
+		decoded := &asit.Client{}
+		if err := codec.UnmarshalKey("client:1", data, decoded); err != nil || !proto.Equal(decoded, values[1]) {
+			t.Errorf("UnmarshalKey returned (%v, %v), expected %v", decoded, err, values[1])
+		}
+		if err := codec.UnmarshalKey("client:2", data, &asit.Client{}); err == nil {
+			t.Errorf("the value of client:1 is decrypted as the value of client:2")
+		}
+		if err := codec.Unmarshal(data, &asit.Client{}); err == nil {
+			t.Errorf("the value of client:1 is decrypted without its key")
+		}
+
+		legacy := sealLegacyEncryptedValue(t, "old", []byte("0123456789abcdef"), mustMarshal(t, plain, values[1]))
+		if err := codec.UnmarshalKey("client:2", legacy, decoded); err != nil || !proto.Equal(decoded, values[1]) {
+			t.Errorf("UnmarshalKey of the value encrypted without its key returned (%v, %v), expected %v", decoded, err, values[1])
+		}
+	})
+
+	t.Run("RequiredEncryption", func(t *testing.T) {
+		keyring := mustKeyring(t, "old=MDEyMzQ1Njc4OWFiY2RlZg==")
+		plain := mustEnvelopeCodec(t, "proto", 0)
+		codec := db.NewEncryptingCodec(plain, keyring)
+		codec.SetEncryptionRequired(true)
+		data, err := codec.MarshalKey("client:1", values[1])
+		if err != nil {
+			t.Fatalf("can't marshal value, %v", err)
+		}
+		decoded := &asit.Client{}
+		if err := codec.UnmarshalKey("client:1", data, decoded); err != nil || !proto.Equal(decoded, values[1]) {
+			t.Errorf("UnmarshalKey returned (%v, %v), expected %v", decoded, err, values[1])
+		}
+		if err := codec.UnmarshalKey("client:1", mustMarshal(t, plain, values[1]), &asit.Client{}); err == nil {
+			t.Errorf("the plain value is read when the encryption is required")
+		}
+		legacy := sealLegacyEncryptedValue(t, "old", []byte("0123456789abcdef"), mustMarshal(t, plain, values[1]))
+		if err := codec.UnmarshalKey("client:1", legacy, &asit.Client{}); err == nil {
+			t.Errorf("the value encrypted without its key is read when the encryption is required")
+		}
+	})
+
+	t.Run("CompressedLargeValues", func(t *testing.T) {
+		plain, _ := db.PROTO_CODEC.Marshal(values[2])
+		compressed, err := codecs["Gzip"].Marshal(values[2])
+		if err != nil {
+			t.Fatalf("can't marshal value, %v", err)
+		}
+		if len(compressed) >= len(plain) {
+			t.Errorf("the compressed value has %d bytes, the plain one has %d bytes", len(compressed), len(plain))
+		}
+	})
+}
+
+// sealLegacyEncryptedValue encrypts the value the way it was encrypted before the storage keys were authenticated,
+// only the header of the magic byte and the key id is authenticated
+func sealLegacyEncryptedValue(t *testing.T, id string, key []byte, data []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("can't create cipher, %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("can't create AEAD, %v", err)
+	}
+	header := append([]byte{0x01, byte(len(id))}, id...)
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(append(append([]byte{}, header...), nonce...), nonce, data, header)
+}
+
+func mustMarshal(t *testing.T, codec db.StorageCodec, value proto.Message) []byte {
+	t.Helper()
+	data, err := codec.Marshal(value)
+	if err != nil {
+		t.Fatalf("can't marshal %v, %v", value, err)
+	}
+	return data
+}
+
+func mustKeyring(t *testing.T, keys string) *db.EncryptionKeyring {
+	t.Helper()
+	keyring, err := db.ParseEncryptionKeyring(keys, "")
+	if err != nil {
+		t.Fatalf("can't parse encryption keys, %v", err)
+	}
+	return keyring
+}
+
+func mustEnvelopeCodec(t *testing.T, encodingName string, compressMinSize int) db.StorageCodec {
+	t.Helper()
+	encoding, err := db.ParseCodecEncoding(encodingName)
+	if err != nil {
+		t.Fatalf("can't parse encoding, %v", err)
+	}
+	codec, err := db.NewEnvelopeCodec(encoding, compressMinSize)
+	if err != nil {
+		t.Fatalf("can't create envelope codec, %v", err)
+	}
+	return codec
+}
+
+func expectDecoded(t *testing.T, writer db.StorageCodec, reader db.StorageCodec, value *asit.Client) {
+	t.Helper()
+	data, err := writer.Marshal(value)
+	if err != nil {
+		t.Fatalf("can't marshal %v, %v", value, err)
+	}
+	decoded := &asit.Client{}
+	if err := reader.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("can't unmarshal %v, %v", value, err)
+	}
+	if !proto.Equal(decoded, value) {
+		t.Errorf("decoded value %v, expected %v", decoded, value)
+	}
+}
@@ -0,0 +1,529 @@
+// Package dbtest contains conformance tests for db.Storage and db.ClientsRepository implementations.
+// Every implementation is expected to pass them, e.g.:
+//
+//	func TestMemoryStorage(t *testing.T) {
+//		dbtest.RunStorageTests(t, func(t *testing.T) db.Storage {
+//			return db.NewMemoryStorage(db.PROTO_CODEC)
+//		})
+//	}
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+)
+
+// ConcurrentWriters is the number of goroutines used by the concurrent scenarios
+const ConcurrentWriters = 8
+
+// shortTTL is the TTL of the values expiring during the tests, the tests wait for twice as long
+const shortTTL = 100 * time.Millisecond
+
+// RunStorageTests checks the db.Storage contract. newStorage must return an empty storage on every call.
+func RunStorageTests(t *testing.T, newStorage func(t *testing.T) db.Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s db.Storage)
+	}{
+		{"SetGetDelete", testSetGetDelete},
+		{"EmptyValue", testEmptyValue},
+		{"LockedSets", testLockedSets},
+		{"LockedDeletes", testLockedDeletes},
+		{"UnlockedCommandsAfterUpdaters", testUnlockedCommandsAfterUpdaters},
+		{"UpdaterError", testUpdaterError},
+		{"RetryOnConflict", testRetryOnConflict},
+		{"RetriesExhausted", testRetriesExhausted},
+		{"ConcurrentWriters", testConcurrentWriters},
+		{"Indexes", testIndexes},
+		{"IndexRange", testIndexRange},
+		{"ValueTTL", testValueTTL},
+		{"ScanKeys", testScanKeys},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage(t)
+			t.Cleanup(func() { s.Close() })
+			tt.test(t, s)
+		})
+	}
+}
+
+func testSetGetDelete(t *testing.T, s db.Storage) {
+	ctx := context.Background()
+	client := &asit.Client{}
+	found, err := s.Get(ctx, "missing", client)
+	if err != nil || found {
+		t.Fatalf("Get of the missing key returned (%v, %v), expected (false, nil)", found, err)
+	}
+
+	mustStore(t, s, "k1", &asit.Client{Id: "1", Name: "first"})
+	mustStore(t, s, "k2", &asit.Client{Id: "2", Name: "second"})
+	expectClient(t, s, "k1", &asit.Client{Id: "1", Name: "first"})
+
+	mustStore(t, s, "k1", &asit.Client{Id: "1", Name: "updated"})
+	expectClient(t, s, "k1", &asit.Client{Id: "1", Name: "updated"})
+
+	if err := s.Delete(ctx, "k1", "k2", "missing"); err != nil {
+		t.Fatalf("can't delete keys, %v", err)
+	}
+	expectMissing(t, s, "k1")
+	expectMissing(t, s, "k2")
+}
+
+func testEmptyValue(t *testing.T, s db.Storage) {
+	mustStore(t, s, "empty", &asit.ClientKeys{})
+	found, err := s.Get(context.Background(), "empty", &asit.ClientKeys{})
+	if err != nil || !found {
+		t.Fatalf("Get of the empty value returned (%v, %v), expected (true, nil)", found, err)
+	}
+
+	err = s.SetAndDeleteAtomically(context.Background(), []db.SetValueCommand{
+		db.NewSetValueCommand("empty", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			found, err := oldValue(&asit.ClientKeys{})
+			if !found {
+				t.Errorf("the empty value is not passed to the updater")
+			}
+			return false, nil, err
+		}),
+	}, nil, noUnlockedSets, noUnlockedDeletes, nil)
+	if err != nil {
+		t.Fatalf("SetAndDeleteAtomically failed, %v", err)
+	}
+}
+
+func testLockedSets(t *testing.T, s db.Storage) {
+	mustStore(t, s, "existing", &asit.Client{Id: "1", Name: "old"})
+	mustStore(t, s, "kept", &asit.Client{Id: "2", Name: "kept"})
+	mustStore(t, s, "removed", &asit.Client{Id: "3", Name: "removed"})
+
+	err := s.SetAndDeleteAtomically(context.Background(), []db.SetValueCommand{
+		db.NewSetValueCommand("existing", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			old := &asit.Client{}
+			found, err := oldValue(old)
+			if err != nil || !found || old.Name != "old" {
+				t.Errorf("unexpected old value (%v, %v, %v)", old, found, err)
+			}
+			return true, &asit.Client{Id: old.Id, Name: "new"}, nil
+		}),
+		db.NewSetValueCommand("created", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			found, err := oldValue(&asit.Client{})
+			if err != nil || found {
+				t.Errorf("unexpected old value of the missing key (%v, %v)", found, err)
+			}
+			return true, &asit.Client{Id: "4", Name: "created"}, nil
+		}),
+		db.NewSetValueCommand("kept", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			// the returned value must be ignored if the update is not required
+			return false, &asit.Client{Id: "2", Name: "ignored"}, nil
+		}),
+		db.NewSetValueCommand("removed", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			return true, nil, nil
+		}),
+	}, nil, noUnlockedSets, noUnlockedDeletes, nil)
+	if err != nil {
+		t.Fatalf("SetAndDeleteAtomically failed, %v", err)
+	}
+
+	expectClient(t, s, "existing", &asit.Client{Id: "1", Name: "new"})
+	expectClient(t, s, "created", &asit.Client{Id: "4", Name: "created"})
+	expectClient(t, s, "kept", &asit.Client{Id: "2", Name: "kept"})
+	expectMissing(t, s, "removed")
+}
+
+func testLockedDeletes(t *testing.T, s db.Storage) {
+	mustStore(t, s, "deleted", &asit.Client{Id: "1"})
+	err := s.SetAndDeleteAtomically(context.Background(), nil, []string{"deleted", "missing"}, noUnlockedSets, noUnlockedDeletes, nil)
+	if err != nil {
+		t.Fatalf("SetAndDeleteAtomically failed, %v", err)
+	}
+	expectMissing(t, s, "deleted")
+}
+
+func testUnlockedCommandsAfterUpdaters(t *testing.T, s db.Storage) {
+	mustStore(t, s, "alias1", &asit.Client{Id: "old"})
+	mustStore(t, s, "alias2", &asit.Client{Id: "old"})
+
+	var aliases []string
+	err := s.SetAndDeleteAtomically(context.Background(), []db.SetValueCommand{
+		db.NewSetValueCommand("main", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			// the unlocked commands depend on the values calculated by the updaters
+			aliases = []string{"alias1", "alias3"}
+			return true, &asit.Client{Id: "new"}, nil
+		}),
+	}, nil, func() []db.SetValueUnlockedCommand {
+		if aliases == nil {
+			t.Errorf("unlocked sets are requested before the updaters are called")
+		}
+		cmds := []db.SetValueUnlockedCommand{}
+		for _, alias := range aliases {
+			cmds = append(cmds, db.NewSetValueUnlockedCommand(alias, &asit.Client{Id: "new"}))
+		}
+		return cmds
+	}, func() []string {
+		if aliases == nil {
+			t.Errorf("unlocked deletes are requested before the updaters are called")
+		}
+		return []string{"alias2"}
+	}, nil)
+	if err != nil {
+		t.Fatalf("SetAndDeleteAtomically failed, %v", err)
+	}
+
+	expectClient(t, s, "main", &asit.Client{Id: "new"})
+	expectClient(t, s, "alias1", &asit.Client{Id: "new"})
+	expectClient(t, s, "alias3", &asit.Client{Id: "new"})
+	expectMissing(t, s, "alias2")
+}
+
+func testUpdaterError(t *testing.T, s db.Storage) {
+	mustStore(t, s, "first", &asit.Client{Id: "1"})
+	mustStore(t, s, "deleted", &asit.Client{Id: "2"})
+	updaterErr := errors.New("updater error")
+
+	err := s.SetAndDeleteAtomically(context.Background(), []db.SetValueCommand{
+		db.NewSetValueCommand("first", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			return true, &asit.Client{Id: "changed"}, nil
+		}),
+		db.NewSetValueCommand("second", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			return false, nil, updaterErr
+		}),
+	}, []string{"deleted"}, func() []db.SetValueUnlockedCommand {
+		return []db.SetValueUnlockedCommand{db.NewSetValueUnlockedCommand("unlocked", &asit.Client{Id: "3"})}
+	}, noUnlockedDeletes, func() []db.IndexCommand {
+		return []db.IndexCommand{db.NewIndexAddCommand("index", "member")}
+	})
+	if !errors.Is(err, updaterErr) {
+		t.Fatalf("expected the updater error, got %v", err)
+	}
+
+	// nothing must be changed
+	expectClient(t, s, "first", &asit.Client{Id: "1"})
+	expectClient(t, s, "deleted", &asit.Client{Id: "2"})
+	expectMissing(t, s, "unlocked")
+	expectIndex(t, s, "index")
+}
+
+func testRetryOnConflict(t *testing.T, s db.Storage) {
+	ctx := context.Background()
+	mustStore(t, s, "counter", &asit.Client{Id: "0"})
+
+	calls := 0
+	err := s.SetAndDeleteAtomically(ctx, []db.SetValueCommand{
+		db.NewSetValueCommand("counter", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			calls++
+			old := &asit.Client{}
+			if _, err := oldValue(old); err != nil {
+				return false, nil, err
+			}
+			if calls == 1 {
+				// a concurrent writer changes the locked key during the first attempt
+				if err := s.Set(ctx, "counter", &asit.Client{Id: "10"}); err != nil {
+					return false, nil, err
+				}
+			}
+			return true, &asit.Client{Id: old.Id + "+1"}, nil
+		}),
+	}, nil, noUnlockedSets, noUnlockedDeletes, nil)
+	if err != nil {
+		t.Fatalf("SetAndDeleteAtomically failed, %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 updater calls, got %d", calls)
+	}
+	// the retry must see the concurrently written value
+	expectClient(t, s, "counter", &asit.Client{Id: "10+1"})
+}
+
+func testRetriesExhausted(t *testing.T, s db.Storage) {
+	ctx := context.Background()
+	calls := 0
+	err := s.SetAndDeleteAtomically(ctx, []db.SetValueCommand{
+		db.NewSetValueCommand("contended", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			calls++
+			// a concurrent writer changes the locked key during every attempt
+			if err := s.Set(ctx, "contended", &asit.Client{Id: strconv.Itoa(calls)}); err != nil {
+				return false, nil, err
+			}
+			return true, &asit.Client{Id: "never written"}, nil
+		}),
+	}, nil, noUnlockedSets, noUnlockedDeletes, nil)
+
+	var concurrentUpdateErr *db.ConcurrentUpdateError
+	if !errors.As(err, &concurrentUpdateErr) {
+		t.Fatalf("expected ConcurrentUpdateError, got %v", err)
+	}
+	if calls < 2 {
+		t.Errorf("expected retries, got %d updater calls", calls)
+	}
+	expectClient(t, s, "contended", &asit.Client{Id: strconv.Itoa(calls)})
+}
+
+// testConcurrentWriters checks that no updates are lost: every successful update appends one element,
+// so the final number of elements must be equal to the number of successful updates.
+// ConcurrentUpdateError is allowed, because the optimistic locking can exhaust retries under contention.
+func testConcurrentWriters(t *testing.T, s db.Storage) {
+	ctx := context.Background()
+	const updatesPerWriter = 10
+	var succeeded int64
+	var wg sync.WaitGroup
+	for w := 0; w < ConcurrentWriters; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < updatesPerWriter; i++ {
+				element := strconv.Itoa(w) + "-" + strconv.Itoa(i)
+				err := s.SetAndDeleteAtomically(ctx, []db.SetValueCommand{
+					db.NewSetValueCommand("list", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+						list := &asit.ClientKeys{}
+						if _, err := oldValue(list); err != nil {
+							return false, nil, err
+						}
+						return true, &asit.ClientKeys{Keys: append(list.Keys, element)}, nil
+					}),
+				}, nil, noUnlockedSets, noUnlockedDeletes, nil)
+				var concurrentUpdateErr *db.ConcurrentUpdateError
+				switch {
+				case err == nil:
+					atomic.AddInt64(&succeeded, 1)
+				case errors.As(err, &concurrentUpdateErr):
+				default:
+					t.Errorf("unexpected error, %v", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	list := &asit.ClientKeys{}
+	if _, err := s.Get(ctx, "list", list); err != nil {
+		t.Fatalf("can't get the list, %v", err)
+	}
+	if int64(len(list.Keys)) != succeeded {
+		t.Errorf("lost updates: %d successful updates, but %d elements stored", succeeded, len(list.Keys))
+	}
+	if succeeded == 0 {
+		t.Errorf("all the concurrent updates failed")
+	}
+}
+
+func testIndexes(t *testing.T, s db.Storage) {
+	ctx := context.Background()
+	updateIndex := func(cmds ...db.IndexCommand) {
+		t.Helper()
+		err := s.SetAndDeleteAtomically(ctx, []db.SetValueCommand{
+			db.NewSetValueCommand("value", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				return true, &asit.Client{Id: "value"}, nil
+			}),
+		}, nil, noUnlockedSets, noUnlockedDeletes, func() []db.IndexCommand { return cmds })
+		if err != nil {
+			t.Fatalf("SetAndDeleteAtomically failed, %v", err)
+		}
+	}
+
+	expectIndex(t, s, "index")
+	updateIndex(db.NewIndexAddCommand("index", "b"), db.NewIndexAddCommand("index", "a"), db.NewIndexAddCommand("index", "c"))
+	// adding an existing member is not an error
+	updateIndex(db.NewIndexAddCommand("index", "a"), db.NewIndexAddCommand("other", "z"))
+	expectIndex(t, s, "index", "a", "b", "c")
+	expectIndex(t, s, "other", "z")
+
+	// removal of a missing member is not an error
+	updateIndex(db.NewIndexRemoveCommand("index", "b"), db.NewIndexRemoveCommand("index", "missing"), db.NewIndexRemoveCommand("missing", "a"))
+	expectIndex(t, s, "index", "a", "c")
+
+	// indexes and values don't affect each other
+	if err := s.Delete(ctx, "index"); err != nil {
+		t.Fatalf("can't delete key, %v", err)
+	}
+	expectIndex(t, s, "index", "a", "c")
+	expectClient(t, s, "value", &asit.Client{Id: "value"})
+}
+
+func testIndexRange(t *testing.T, s db.Storage) {
+	ctx := context.Background()
+	members := []string{"a", "b", "b\x00", "ba", "c", "d"}
+	err := s.SetAndDeleteAtomically(ctx, nil, []string{"value"}, noUnlockedSets, noUnlockedDeletes, func() []db.IndexCommand {
+		cmds := []db.IndexCommand{}
+		for _, member := range members {
+			cmds = append(cmds, db.NewIndexAddCommand("index", member))
+		}
+		return cmds
+	})
+	if err != nil {
+		t.Fatalf("SetAndDeleteAtomically failed, %v", err)
+	}
+
+	tests := []struct {
+		after    string
+		limit    int
+		expected []string
+	}{
+		{"", 0, members},
+		{"", 2, []string{"a", "b"}},
+		{"b", 2, []string{"b\x00", "ba"}},
+		{"bb", 10, []string{"c", "d"}},
+		{"d", 10, []string{}},
+	}
+	for _, tt := range tests {
+		actual, err := s.IndexRange(ctx, "index", tt.after, tt.limit)
+		if err != nil {
+			t.Fatalf("IndexRange failed, %v", err)
+		}
+		if !slices.Equal(actual, tt.expected) {
+			t.Errorf("IndexRange(%q, %d) returned %q, expected %q", tt.after, tt.limit, actual, tt.expected)
+		}
+	}
+}
+
+func testValueTTL(t *testing.T, s db.Storage) {
+	ctx := context.Background()
+	for _, k := range []string{"expiring", "persisted", "negative"} {
+		if err := s.SetWithTTL(ctx, k, &asit.Client{Id: k}, shortTTL); err != nil {
+			t.Fatalf("can't set %s, %v", k, err)
+		}
+	}
+	// the value written without TTL never expires
+	mustStore(t, s, "persisted", &asit.Client{Id: "persisted"})
+	// the negative TTL means no expiry too, the TTL of the previous value isn't kept
+	if err := s.SetWithTTL(ctx, "negative", &asit.Client{Id: "negative"}, -time.Second); err != nil {
+		t.Fatalf("can't set negative, %v", err)
+	}
+	err := s.SetAndDeleteAtomically(ctx, []db.SetValueCommand{
+		db.NewSetValueCommandWithTTL("locked", shortTTL, func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			return true, &asit.Client{Id: "locked"}, nil
+		}),
+	}, nil, func() []db.SetValueUnlockedCommand {
+		return []db.SetValueUnlockedCommand{db.NewSetValueUnlockedCommandWithTTL("unlocked", &asit.Client{Id: "unlocked"}, shortTTL)}
+	}, noUnlockedDeletes, nil)
+	if err != nil {
+		t.Fatalf("SetAndDeleteAtomically failed, %v", err)
+	}
+	for _, k := range []string{"expiring", "persisted", "locked", "unlocked"} {
+		expectClient(t, s, k, &asit.Client{Id: k})
+	}
+
+	time.Sleep(2 * shortTTL)
+	expectMissing(t, s, "expiring")
+	expectMissing(t, s, "locked")
+	expectMissing(t, s, "unlocked")
+	expectClient(t, s, "persisted", &asit.Client{Id: "persisted"})
+	expectClient(t, s, "negative", &asit.Client{Id: "negative"})
+
+	// the updaters see the expired values as missing ones
+	err = s.SetAndDeleteAtomically(ctx, []db.SetValueCommand{
+		db.NewSetValueCommand("expiring", func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+			found, err := oldValue(&asit.Client{})
+			if err != nil || found {
+				t.Errorf("unexpected old value of the expired key (%v, %v)", found, err)
+			}
+			return true, &asit.Client{Id: "recreated"}, nil
+		}),
+	}, nil, noUnlockedSets, noUnlockedDeletes, nil)
+	if err != nil {
+		t.Fatalf("SetAndDeleteAtomically failed, %v", err)
+	}
+	expectClient(t, s, "expiring", &asit.Client{Id: "recreated"})
+}
+
+func testScanKeys(t *testing.T, s db.Storage) {
+	ctx := context.Background()
+	for _, k := range []string{"b:2", "a:1", "b:1", "b", "c:1"} {
+		mustStore(t, s, k, &asit.Client{Id: k})
+	}
+	if err := s.SetWithTTL(ctx, "b:expiring", &asit.Client{Id: "b:expiring"}, shortTTL); err != nil {
+		t.Fatalf("can't set b:expiring, %v", err)
+	}
+	// the indexes are not values
+	err := s.SetAndDeleteAtomically(ctx, nil, nil, noUnlockedSets, noUnlockedDeletes, func() []db.IndexCommand {
+		return []db.IndexCommand{db.NewIndexAddCommand("b:index", "b:member")}
+	})
+	if err != nil {
+		t.Fatalf("SetAndDeleteAtomically failed, %v", err)
+	}
+
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{"b:", []string{"b:1", "b:2", "b:expiring"}},
+		{"a:", []string{"a:1"}},
+		{"d:", []string{}},
+		{"", []string{"a:1", "b", "b:1", "b:2", "b:expiring", "c:1"}},
+	}
+	for _, tt := range tests {
+		actual, err := s.ScanKeys(ctx, tt.prefix)
+		if err != nil {
+			t.Fatalf("ScanKeys(%q) failed, %v", tt.prefix, err)
+		}
+		if len(actual) != len(tt.expected) || (len(actual) > 0 && !slices.Equal(actual, tt.expected)) {
+			t.Errorf("ScanKeys(%q) returned %q, expected %q", tt.prefix, actual, tt.expected)
+		}
+	}
+
+	time.Sleep(2 * shortTTL)
+	if actual, err := s.ScanKeys(ctx, "b:"); err != nil || !slices.Equal(actual, []string{"b:1", "b:2"}) {
+		t.Errorf("ScanKeys returned (%q, %v) after the expiration, expected [b:1 b:2]", actual, err)
+	}
+}
+
+func noUnlockedSets() []db.SetValueUnlockedCommand {
+	return nil
+}
+
+func noUnlockedDeletes() []string {
+	return nil
+}
+
+func mustStore(t *testing.T, s db.Storage, k string, v proto.Message) {
+	t.Helper()
+	if err := s.Set(context.Background(), k, v); err != nil {
+		t.Fatalf("can't set %s, %v", k, err)
+	}
+}
+
+func expectClient(t *testing.T, s db.Storage, k string, expected *asit.Client) {
+	t.Helper()
+	actual := &asit.Client{}
+	found, err := s.Get(context.Background(), k, actual)
+	if err != nil {
+		t.Fatalf("can't get %s, %v", k, err)
+	}
+	if !found {
+		t.Fatalf("%s not found, expected %v", k, expected)
+	}
+	if !proto.Equal(actual, expected) {
+		t.Errorf("%s is %v, expected %v", k, actual, expected)
+	}
+}
+
+func expectIndex(t *testing.T, s db.Storage, index string, expected ...string) {
+	t.Helper()
+	actual, err := s.IndexRange(context.Background(), index, "", 0)
+	if err != nil {
+		t.Fatalf("can't get index %s, %v", index, err)
+	}
+	if len(actual) != len(expected) || (len(expected) > 0 && !slices.Equal(actual, expected)) {
+		t.Errorf("index %s contains %q, expected %q", index, actual, expected)
+	}
+}
+
+func expectMissing(t *testing.T, s db.Storage, k string) {
+	t.Helper()
+	found, err := s.Get(context.Background(), k, &asit.Client{})
+	if err != nil {
+		t.Fatalf("can't get %s, %v", k, err)
+	}
+	if found {
+		t.Errorf("%s is expected to be missing", k)
+	}
+}
@@ -0,0 +1,522 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+)
+
+// RunTestRunsRepositoryTests checks the db.TestRunsRepository contract.
+// newRepository must return an empty repository on every call.
+func RunTestRunsRepositoryTests(t *testing.T, newRepository func(t *testing.T) db.TestRunsRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, r db.TestRunsRepository)
+	}{
+		{"CreateAndGetTestRun", testCreateAndGetTestRun},
+		{"GetTestRunsPages", testGetTestRunsPages},
+		{"TestStepTransitions", testTestStepTransitions},
+		{"FailedTestStep", testFailedTestStep},
+		{"ConcurrentTestStepTransitions", testConcurrentTestStepTransitions},
+		{"GetActiveTestRuns", testGetActiveTestRuns},
+		{"ClaimNextAction", testClaimNextAction},
+		{"ConcurrentClaimNextAction", testConcurrentClaimNextAction},
+		{"ExpiredClaimNextAction", testExpiredClaimNextAction},
+		{"NotifyingTestRunsRepository", testNotifyingTestRunsRepository},
+		{"ReportActionResult", testReportActionResult},
+		{"FailedActionResult", testFailedActionResult},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepository(t))
+		})
+	}
+}
+
+func testCreateAndGetTestRun(t *testing.T, r db.TestRunsRepository) {
+	ctx := context.Background()
+	client := &asit.Client{Id: "client", ClientProperties: map[string]string{"env": "test"}}
+	run := db.NewTestRun("run", newTestSuite("smoke", "login", "logout"), client)
+	if run.Status != asit.TestRunStatus_STARTED || len(run.State.StepRuns) != 2 ||
+		run.State.StepRuns[0].Status != asit.TestStepRunStatus_ACTIVE || run.State.StepRuns[1].Status != asit.TestStepRunStatus_CREATED {
+		t.Fatalf("unexpected new test run %v", run)
+	}
+	client.ClientProperties["env"] = "changed"
+	if run.State.ClientProperties["env"] != "test" {
+		t.Errorf("the client properties aren't copied to the run state")
+	}
+
+	mustCreateTestRun(t, r, run)
+	stored, err := r.GetTestRun(ctx, "run")
+	if err != nil {
+		t.Fatalf("can't get test run, %v", err)
+	}
+	if stored.LastUpdated == nil {
+		t.Errorf("lastUpdated of the test run isn't set")
+	}
+	stored.LastUpdated = run.LastUpdated
+	if !proto.Equal(stored, run) {
+		t.Errorf("test run %v, expected %v", stored, run)
+	}
+
+	var conflictErr *db.TestRunIdConflictError
+	if err := r.CreateTestRun(ctx, run); !errors.As(err, &conflictErr) {
+		t.Errorf("CreateTestRun of the existing run returned %v, expected TestRunIdConflictError", err)
+	}
+	if missing, err := r.GetTestRun(ctx, "missing"); err != nil || missing != nil {
+		t.Errorf("GetTestRun of the missing run returned %v, %v", missing, err)
+	}
+}
+
+func testGetTestRunsPages(t *testing.T, r db.TestRunsRepository) {
+	ctx := context.Background()
+	suite := newTestSuite("smoke", "login")
+	for i := 0; i < 5; i++ {
+		clientId := "even"
+		if i%2 == 1 {
+			clientId = "odd"
+		}
+		mustCreateTestRun(t, r, db.NewTestRun("run"+strconv.Itoa(i), suite, &asit.Client{Id: clientId}))
+	}
+
+	pages := map[string][]string{
+		"":     {"run0", "run1", "run2", "run3", "run4"},
+		"even": {"run0", "run2", "run4"},
+		"odd":  {"run1", "run3"},
+	}
+	for clientId, expected := range pages {
+		var ids []string
+		cursor := ""
+		for pages := 0; pages < 10; pages++ {
+			runs, nextCursor, err := r.GetTestRuns(ctx, clientId, cursor, 2)
+			if err != nil {
+				t.Fatalf("GetTestRuns failed, %v", err)
+			}
+			for _, run := range runs {
+				if run.State != nil || run.TestSuite != nil {
+					t.Errorf("GetTestRuns returned run %s with the state or the suite", run.Id)
+				}
+				ids = append(ids, run.Id)
+			}
+			if nextCursor == "" {
+				break
+			}
+			cursor = nextCursor
+		}
+		if !slices.Equal(ids, expected) {
+			t.Errorf("GetTestRuns of client %q returned %v, expected %v", clientId, ids, expected)
+		}
+	}
+}
+
+func testTestStepTransitions(t *testing.T, r db.TestRunsRepository) {
+	ctx := context.Background()
+	engine := db.NewTestRunEngine(r)
+	mustCreateTestRun(t, r, db.NewTestRun("run", newTestSuite("smoke", "login", "logout"), &asit.Client{Id: "client"}))
+
+	var illegalErr *db.IllegalTestStepTransitionError
+	if _, err := engine.TransitionStep(ctx, "run", "logout-step", 0, asit.TestStepRunStatus_ACTION_STARTED, ""); !errors.As(err, &illegalErr) {
+		t.Errorf("the transition of the step which isn't current returned %v, expected IllegalTestStepTransitionError", err)
+	}
+	if _, err := engine.TransitionStep(ctx, "run", "login-step", 0, asit.TestStepRunStatus_ACTION_FINISHED, ""); !errors.As(err, &illegalErr) {
+		t.Errorf("the transition from ACTIVE to ACTION_FINISHED returned %v, expected IllegalTestStepTransitionError", err)
+	}
+	var notFoundStepErr *db.NotFoundTestStepRunError
+	if _, err := engine.TransitionStep(ctx, "run", "missing", 0, asit.TestStepRunStatus_ACTION_STARTED, ""); !errors.As(err, &notFoundStepErr) {
+		t.Errorf("the transition of the missing step returned %v, expected NotFoundTestStepRunError", err)
+	}
+	var notFoundRunErr *db.NotFoundTestRunError
+	if _, err := engine.TransitionStep(ctx, "missing", "login-step", 0, asit.TestStepRunStatus_ACTION_STARTED, ""); !errors.As(err, &notFoundRunErr) {
+		t.Errorf("the transition in the missing run returned %v, expected NotFoundTestRunError", err)
+	}
+
+	var run *asit.TestRun
+	for _, stepId := range []string{"login-step", "logout-step"} {
+		for _, status := range []asit.TestStepRunStatus{
+			asit.TestStepRunStatus_ACTION_STARTED,
+			asit.TestStepRunStatus_ACTION_FINISHED,
+			asit.TestStepRunStatus_VERIFICATION_SUCCESS,
+		} {
+			run = mustTransitionStep(t, engine, "run", stepId, status)
+		}
+		if stepId == "login-step" && (run.Status != asit.TestRunStatus_STARTED || run.State.CurrentStepIndex != 1 ||
+			run.State.StepRuns[1].Status != asit.TestStepRunStatus_ACTIVE) {
+			t.Errorf("the verified step doesn't activate the next one, %v", run)
+		}
+	}
+	if run.Status != asit.TestRunStatus_SUCCESS {
+		t.Errorf("the run with all the verified steps has status %s", run.Status)
+	}
+	if _, err := engine.TransitionStep(ctx, "run", "logout-step", 0, asit.TestStepRunStatus_ACTION_STARTED, ""); !errors.As(err, &illegalErr) {
+		t.Errorf("the transition in the finished run returned %v, expected IllegalTestStepTransitionError", err)
+	}
+	stored, err := r.GetTestRun(ctx, "run")
+	if err != nil || !proto.Equal(stored, run) {
+		t.Errorf("stored run %v, expected %v, %v", stored, run, err)
+	}
+}
+
+func testFailedTestStep(t *testing.T, r db.TestRunsRepository) {
+	ctx := context.Background()
+	engine := db.NewTestRunEngine(r)
+	mustCreateTestRun(t, r, db.NewTestRun("run", newTestSuite("smoke", "login", "logout"), &asit.Client{Id: "client"}))
+	mustTransitionStep(t, engine, "run", "login-step", asit.TestStepRunStatus_ACTION_STARTED)
+	run, err := engine.TransitionStep(ctx, "run", "login-step", 0, asit.TestStepRunStatus_ACTION_FAILED, "connection refused")
+	if err != nil {
+		t.Fatalf("can't fail the step, %v", err)
+	}
+	stepRun := run.State.StepRuns[0]
+	if run.Status != asit.TestRunStatus_FAIL || stepRun.StatusDescription != "connection refused" || run.State.CurrentStepIndex != 0 {
+		t.Errorf("unexpected run after the failed action, %v", run)
+	}
+	if run.State.StepRuns[1].Status != asit.TestStepRunStatus_CREATED {
+		t.Errorf("the step after the failed one is %s", run.State.StepRuns[1].Status)
+	}
+}
+
+// testConcurrentTestStepTransitions checks that only one of the concurrent reports of the same transition succeeds
+func testConcurrentTestStepTransitions(t *testing.T, r db.TestRunsRepository) {
+	ctx := context.Background()
+	engine := db.NewTestRunEngine(r)
+	mustCreateTestRun(t, r, db.NewTestRun("run", newTestSuite("smoke", "login"), &asit.Client{Id: "client"}))
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := engine.TransitionStep(ctx, "run", "login-step", 0, asit.TestStepRunStatus_ACTION_STARTED, "")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	succeeded := 0
+	for err := range errs {
+		var illegalErr *db.IllegalTestStepTransitionError
+		var concurrentErr *db.ConcurrentUpdateError
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.As(err, &illegalErr), errors.As(err, &concurrentErr):
+		default:
+			t.Fatalf("unexpected transition error, %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d concurrent transitions succeeded, expected 1", succeeded)
+	}
+}
+
+func testGetActiveTestRuns(t *testing.T, r db.TestRunsRepository) {
+	ctx := context.Background()
+	engine := db.NewTestRunEngine(r)
+	suite := newTestSuite("smoke", "login")
+	for _, id := range []string{"run2", "run0", "run1"} {
+		mustCreateTestRun(t, r, db.NewTestRun(id, suite, &asit.Client{Id: "client"}))
+	}
+	mustCreateTestRun(t, r, db.NewTestRun("other", suite, &asit.Client{Id: "other"}))
+	mustTransitionStep(t, engine, "run1", "login-step", asit.TestStepRunStatus_ACTION_STARTED)
+	mustTransitionStep(t, engine, "run1", "login-step", asit.TestStepRunStatus_ACTION_FAILED)
+
+	runs, err := r.GetActiveTestRuns(ctx, "client")
+	if err != nil {
+		t.Fatalf("GetActiveTestRuns failed, %v", err)
+	}
+	var ids []string
+	for _, run := range runs {
+		if run.State == nil || run.TestSuite == nil {
+			t.Errorf("GetActiveTestRuns returned run %s without the state or the suite", run.Id)
+		}
+		ids = append(ids, run.Id)
+	}
+	if !slices.Equal(ids, []string{"run0", "run2"}) {
+		t.Errorf("GetActiveTestRuns returned %v, expected [run0 run2]", ids)
+	}
+	if runs, err := r.GetActiveTestRuns(ctx, "missing"); err != nil || len(runs) != 0 {
+		t.Errorf("GetActiveTestRuns of the client without runs returned %v, %v", runs, err)
+	}
+}
+
+func testClaimNextAction(t *testing.T, r db.TestRunsRepository) {
+	ctx := context.Background()
+	engine := db.NewTestRunEngine(r)
+	mustCreateTestRun(t, r, db.NewTestRun("run", newTestSuite("smoke", "login", "logout"), &asit.Client{Id: "client"}))
+
+	claimed := time.Now()
+	action := mustClaimNextAction(t, engine, "client")
+	if action == nil || action.ClaimExpires == nil || action.ClaimExpires.AsTime().Before(claimed.Add(db.TEST_ACTION_CLAIM_LEASE)) {
+		t.Fatalf("claimed action %v has no claim lease", action)
+	}
+	expected := &asit.PendingTestAction{TestRunId: "run", TestStepId: "login-step", Action: newTestCase("smoke", "login").Steps[0].Action,
+		ClaimExpires: action.ClaimExpires, ClaimAttempt: 1}
+	if !proto.Equal(action, expected) {
+		t.Errorf("claimed action %v, expected %v", action, expected)
+	}
+	if action := mustClaimNextAction(t, engine, "client"); action != nil {
+		t.Errorf("the started action is claimed again, %v", action)
+	}
+	if action := mustClaimNextAction(t, engine, "other"); action != nil {
+		t.Errorf("the action of the other client is claimed, %v", action)
+	}
+	run, err := r.GetTestRun(ctx, "run")
+	if err != nil || run.State.StepRuns[0].Status != asit.TestStepRunStatus_ACTION_STARTED {
+		t.Errorf("the claimed step isn't started, %v, %v", run, err)
+	}
+
+	var illegalErr *db.IllegalTestStepTransitionError
+	if _, err := engine.TransitionStep(ctx, "run", "login-step", 0, asit.TestStepRunStatus_ACTION_FINISHED, ""); !errors.As(err, &illegalErr) {
+		t.Errorf("the transition of the claimed step without its claim attempt returned %v, expected IllegalTestStepTransitionError", err)
+	}
+	for _, status := range []asit.TestStepRunStatus{asit.TestStepRunStatus_ACTION_FINISHED, asit.TestStepRunStatus_VERIFICATION_SUCCESS} {
+		if _, err := engine.TransitionStep(ctx, "run", "login-step", action.ClaimAttempt, status, ""); err != nil {
+			t.Fatalf("can't change status of the claimed step to %s, %v", status, err)
+		}
+	}
+	if action := mustClaimNextAction(t, engine, "client"); action == nil || action.TestStepId != "logout-step" {
+		t.Errorf("claimed action %v after the verified step, expected logout-step", action)
+	}
+}
+
+// testExpiredClaimNextAction checks that the action is claimed again when the agent doesn't report its result until the claim lease expires,
+// then the result of the first agent is rejected and the result of the second one is applied
+func testExpiredClaimNextAction(t *testing.T, r db.TestRunsRepository) {
+	ctx := context.Background()
+	lease := 20 * time.Millisecond
+	engine := db.NewTestRunEngineWithClaimLease(r, lease)
+	mustCreateTestRun(t, r, db.NewTestRun("run", newTestSuite("smoke", "login", "logout"), &asit.Client{Id: "client"}))
+
+	first := mustClaimNextAction(t, engine, "client")
+	if action := mustClaimNextAction(t, engine, "client"); action != nil {
+		t.Errorf("the action is claimed again before the claim lease expires, %v", action)
+	}
+	time.Sleep(2 * lease)
+	second := mustClaimNextAction(t, engine, "client")
+	if second == nil || second.TestStepId != "login-step" || !first.ClaimExpires.AsTime().Before(second.ClaimExpires.AsTime()) ||
+		second.ClaimAttempt != first.ClaimAttempt+1 {
+		t.Fatalf("the action of the expired claim %v is claimed again as %v", first, second)
+	}
+	if action := mustClaimNextAction(t, engine, "client"); action != nil {
+		t.Errorf("the action claimed again is claimed once more before the claim lease expires, %v", action)
+	}
+
+	// both agents report, the first one after its claim has expired
+	var illegalErr *db.IllegalTestStepTransitionError
+	stale := &asit.TestActionResult{Success: false, StatusDescription: "late", ClaimAttempt: first.ClaimAttempt}
+	if _, err := engine.ReportActionResult(ctx, "run", "login-step", stale); !errors.As(err, &illegalErr) {
+		t.Errorf("the result of the expired claim returned %v, expected IllegalTestStepTransitionError", err)
+	}
+	if _, err := engine.TransitionStep(ctx, "run", "login-step", first.ClaimAttempt, asit.TestStepRunStatus_ACTION_FAILED, "late"); !errors.As(err, &illegalErr) {
+		t.Errorf("the status of the expired claim returned %v, expected IllegalTestStepTransitionError", err)
+	}
+	run, err := engine.ReportActionResult(ctx, "run", "login-step",
+		&asit.TestActionResult{Success: true, Data: map[string]string{"status": "200"}, ClaimAttempt: second.ClaimAttempt})
+	if err != nil {
+		t.Fatalf("can't report the action result, %v", err)
+	}
+	if run.Status != asit.TestRunStatus_STARTED || run.State.StepRuns[0].Status != asit.TestStepRunStatus_VERIFICATION_SUCCESS {
+		t.Errorf("unexpected run after the result of the last claim, %v", run)
+	}
+	if _, err := engine.ReportActionResult(ctx, "run", "login-step", stale); !errors.As(err, &illegalErr) {
+		t.Errorf("the result of the expired claim after the last one returned %v, expected IllegalTestStepTransitionError", err)
+	}
+	if _, err := engine.ReportActionResult(ctx, "run", "login-step", &asit.TestActionResult{Success: true, ClaimAttempt: second.ClaimAttempt}); !errors.As(err, &illegalErr) {
+		t.Errorf("the repeated result of the last claim returned %v, expected IllegalTestStepTransitionError", err)
+	}
+	time.Sleep(2 * lease)
+	if action := mustClaimNextAction(t, engine, "client"); action != nil && action.TestStepId == "login-step" {
+		t.Errorf("the action is claimed again after its result is reported, %v", action)
+	}
+}
+
+// testConcurrentClaimNextAction checks that every action is claimed only once by the concurrent agents
+func testConcurrentClaimNextAction(t *testing.T, r db.TestRunsRepository) {
+	ctx := context.Background()
+	engine := db.NewTestRunEngine(r)
+	suite := newTestSuite("smoke", "login")
+	const runs = 3
+	for i := 0; i < runs; i++ {
+		mustCreateTestRun(t, r, db.NewTestRun("run"+strconv.Itoa(i), suite, &asit.Client{Id: "client"}))
+	}
+	const agents = 8
+	var wg sync.WaitGroup
+	claimed := make(chan *asit.PendingTestAction, agents)
+	errs := make(chan error, agents)
+	for i := 0; i < agents; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			action, err := engine.ClaimNextAction(ctx, "client")
+			if err != nil {
+				errs <- err
+				return
+			}
+			claimed <- action
+		}()
+	}
+	wg.Wait()
+	close(claimed)
+	close(errs)
+	for err := range errs {
+		t.Fatalf("can't claim the action, %v", err)
+	}
+	runIds := map[string]bool{}
+	for action := range claimed {
+		if action == nil {
+			continue
+		}
+		if runIds[action.TestRunId] {
+			t.Errorf("the action of run %s is claimed twice", action.TestRunId)
+		}
+		runIds[action.TestRunId] = true
+	}
+	// the agents losing all the races return nil, so the rest of the actions are claimed sequentially
+	for action := mustClaimNextAction(t, engine, "client"); action != nil; action = mustClaimNextAction(t, engine, "client") {
+		if runIds[action.TestRunId] {
+			t.Fatalf("the action of run %s is claimed twice", action.TestRunId)
+		}
+		runIds[action.TestRunId] = true
+	}
+	if len(runIds) != runs {
+		t.Errorf("claimed the actions of %d runs, expected %d", len(runIds), runs)
+	}
+}
+
+func testNotifyingTestRunsRepository(t *testing.T, r db.TestRunsRepository) {
+	var notified []string
+	r = db.NewNotifyingTestRunsRepository(r, func(ctx context.Context, clientId string) error {
+		notified = append(notified, clientId)
+		return errors.New("notification failed")
+	})
+	engine := db.NewTestRunEngine(r)
+	mustCreateTestRun(t, r, db.NewTestRun("run", newTestSuite("smoke", "login"), &asit.Client{Id: "client"}))
+	mustTransitionStep(t, engine, "run", "login-step", asit.TestStepRunStatus_ACTION_STARTED)
+	if _, err := engine.TransitionStep(context.Background(), "run", "login-step", 0, asit.TestStepRunStatus_ACTION_STARTED, ""); err == nil {
+		t.Errorf("the illegal transition succeeded")
+	}
+	if !slices.Equal(notified, []string{"client", "client"}) {
+		t.Errorf("notified clients %v, expected the client of the run on the create and the successful update", notified)
+	}
+}
+
+func testReportActionResult(t *testing.T, r db.TestRunsRepository) {
+	ctx := context.Background()
+	engine := db.NewTestRunEngine(r)
+	suite := newTestSuite("smoke", "login", "logout")
+	suite.Tests[0].Steps[0].Verification = &asit.TestVerification{Checks: []*asit.TestCheck{
+		{Function: "data.equals", Arguments: map[string]string{"key": "status", "value": "200"}},
+		{Function: "data.matches", Arguments: map[string]string{"key": "token", "pattern": "^[a-z]+$"}},
+	}}
+	suite.Tests[1].Steps[0].Verification = &asit.TestVerification{Checks: []*asit.TestCheck{
+		{Function: "data.exists", Arguments: map[string]string{"key": "login-step.token"}},
+		{Function: "data.contains", Arguments: map[string]string{"key": "status", "value": "20"}},
+	}}
+	mustCreateTestRun(t, r, db.NewTestRun("run", suite, &asit.Client{Id: "client"}))
+
+	result := &asit.TestActionResult{Success: true, Logs: []string{"GET /login 200"}, Data: map[string]string{"status": "200", "token": "abc"}}
+	var illegalErr *db.IllegalTestStepTransitionError
+	if _, err := engine.ReportActionResult(ctx, "run", "login-step", result); !errors.As(err, &illegalErr) {
+		t.Errorf("the result of the action which isn't started returned %v, expected IllegalTestStepTransitionError", err)
+	}
+	result.ClaimAttempt = mustClaimNextAction(t, engine, "client").ClaimAttempt
+	run, err := engine.ReportActionResult(ctx, "run", "login-step", result)
+	if err != nil {
+		t.Fatalf("can't report the action result, %v", err)
+	}
+	stepRun := run.State.StepRuns[0]
+	if stepRun.Status != asit.TestStepRunStatus_VERIFICATION_SUCCESS || !slices.Equal(stepRun.Logs, result.Logs) ||
+		stepRun.Data["token"] != "abc" || run.State.Data["login-step.token"] != "abc" || run.State.Data["login-step.status"] != "200" {
+		t.Errorf("unexpected step run after the verified result, %v, state data %v", stepRun, run.State.Data)
+	}
+	if run.State.CurrentStepIndex != 1 || run.State.StepRuns[1].Status != asit.TestStepRunStatus_ACTIVE {
+		t.Errorf("the verified step doesn't activate the next one, %v", run)
+	}
+	if _, err := engine.ReportActionResult(ctx, "run", "login-step", result); !errors.As(err, &illegalErr) {
+		t.Errorf("the repeated result returned %v, expected IllegalTestStepTransitionError", err)
+	}
+
+	claimAttempt := mustClaimNextAction(t, engine, "client").ClaimAttempt
+	run, err = engine.ReportActionResult(ctx, "run", "logout-step",
+		&asit.TestActionResult{Success: true, Data: map[string]string{"status": "204"}, ClaimAttempt: claimAttempt})
+	if err != nil {
+		t.Fatalf("can't report the action result, %v", err)
+	}
+	if run.Status != asit.TestRunStatus_SUCCESS || run.State.Data["logout-step.status"] != "204" || run.State.Data["login-step.status"] != "200" {
+		t.Errorf("unexpected run after the last verified step, %v", run)
+	}
+}
+
+func testFailedActionResult(t *testing.T, r db.TestRunsRepository) {
+	ctx := context.Background()
+	engine := db.NewTestRunEngine(r)
+	suite := newTestSuite("smoke", "login")
+	suite.Tests[0].Steps[0].Verification = &asit.TestVerification{Checks: []*asit.TestCheck{
+		{Function: "data.equals", Arguments: map[string]string{"key": "status", "value": "200"}},
+	}}
+	for _, id := range []string{"failed", "unverified", "missing"} {
+		mustCreateTestRun(t, r, db.NewTestRun(id, suite, &asit.Client{Id: id}))
+		mustClaimNextAction(t, engine, id)
+	}
+
+	// the first claim of every run has the attempt 1
+	run, err := engine.ReportActionResult(ctx, "failed", "login-step",
+		&asit.TestActionResult{StatusDescription: "connection refused", Logs: []string{"dial tcp"}, ClaimAttempt: 1})
+	if err != nil {
+		t.Fatalf("can't report the failed action, %v", err)
+	}
+	if run.Status != asit.TestRunStatus_FAIL || run.State.StepRuns[0].Status != asit.TestStepRunStatus_ACTION_FAILED ||
+		run.State.StepRuns[0].StatusDescription != "connection refused" || len(run.State.StepRuns[0].Logs) != 1 {
+		t.Errorf("unexpected run after the failed action, %v", run)
+	}
+
+	run, err = engine.ReportActionResult(ctx, "unverified", "login-step",
+		&asit.TestActionResult{Success: true, Data: map[string]string{"status": "500"}, ClaimAttempt: 1})
+	if err != nil {
+		t.Fatalf("can't report the action result, %v", err)
+	}
+	if run.Status != asit.TestRunStatus_FAIL || run.State.StepRuns[0].Status != asit.TestStepRunStatus_VERIFICATION_FAILED {
+		t.Errorf("unexpected run after the failed check, %v", run)
+	}
+
+	run, err = engine.ReportActionResult(ctx, "missing", "login-step", &asit.TestActionResult{Success: true, ClaimAttempt: 1})
+	if err != nil {
+		t.Fatalf("can't report the action result, %v", err)
+	}
+	if run.State.StepRuns[0].Status != asit.TestStepRunStatus_VERIFICATION_FAILED {
+		t.Errorf("the check of the missing data passed, %v", run)
+	}
+}
+
+func mustClaimNextAction(t *testing.T, engine *db.TestRunEngine, clientId string) *asit.PendingTestAction {
+	t.Helper()
+	action, err := engine.ClaimNextAction(context.Background(), clientId)
+	if err != nil {
+		t.Fatalf("can't claim the action of client %s, %v", clientId, err)
+	}
+	return action
+}
+
+func mustTransitionStep(t *testing.T, engine *db.TestRunEngine, runId string, stepId string, status asit.TestStepRunStatus) *asit.TestRun {
+	t.Helper()
+	run, err := engine.TransitionStep(context.Background(), runId, stepId, 0, status, "")
+	if err != nil {
+		t.Fatalf("can't change status of step %s to %s, %v", stepId, status, err)
+	}
+	return run
+}
+
+func mustCreateTestRun(t *testing.T, r db.TestRunsRepository, run *asit.TestRun) {
+	t.Helper()
+	if err := r.CreateTestRun(context.Background(), run); err != nil {
+		t.Fatalf("can't create test run %s, %v", run.Id, err)
+	}
+}
@@ -0,0 +1,256 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+)
+
+// RunTestSuitesRepositoryTests checks the db.TestSuitesRepository contract.
+// newRepository must return an empty repository on every call.
+func RunTestSuitesRepositoryTests(t *testing.T, newRepository func(t *testing.T) db.TestSuitesRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, r db.TestSuitesRepository)
+	}{
+		{"SetAndGetTestSuite", testSetAndGetTestSuite},
+		{"GetTestSuitesPages", testGetTestSuitesPages},
+		{"InvalidTestSuites", testInvalidTestSuites},
+		{"TestCases", testTestCases},
+		{"ConcurrentTestCases", testConcurrentTestCases},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepository(t))
+		})
+	}
+}
+
+func testSetAndGetTestSuite(t *testing.T, r db.TestSuitesRepository) {
+	ctx := context.Background()
+	suite := newTestSuite("smoke", "login", "logout")
+	mustSetTestSuite(t, r, suite)
+	expectTestSuite(t, r, suite)
+
+	suite.Name = "renamed"
+	suite.Tests = suite.Tests[:1]
+	mustSetTestSuite(t, r, suite)
+	expectTestSuite(t, r, suite)
+
+	if err := r.RemoveTestSuite(ctx, "smoke"); err != nil {
+		t.Fatalf("can't remove test suite, %v", err)
+	}
+	if found, err := r.GetTestSuite(ctx, "smoke"); err != nil || found != nil {
+		t.Errorf("GetTestSuite of the removed suite returned %v, %v", found, err)
+	}
+	var notFoundErr *db.NotFoundTestSuiteError
+	if err := r.RemoveTestSuite(ctx, "smoke"); !errors.As(err, &notFoundErr) {
+		t.Errorf("RemoveTestSuite of the missing suite returned %v, expected NotFoundTestSuiteError", err)
+	}
+}
+
+func testGetTestSuitesPages(t *testing.T, r db.TestSuitesRepository) {
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		mustSetTestSuite(t, r, newTestSuite("suite"+strconv.Itoa(i), "case"))
+	}
+	if err := r.RemoveTestSuite(ctx, "suite2"); err != nil {
+		t.Fatalf("can't remove test suite, %v", err)
+	}
+
+	var ids []string
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		suites, nextCursor, err := r.GetTestSuites(ctx, cursor, 2)
+		if err != nil {
+			t.Fatalf("GetTestSuites failed, %v", err)
+		}
+		for _, suite := range suites {
+			if len(suite.Tests) != 0 {
+				t.Errorf("GetTestSuites returned suite %s with test cases", suite.Id)
+			}
+			ids = append(ids, suite.Id)
+		}
+		if nextCursor == "" {
+			break
+		}
+		cursor = nextCursor
+	}
+	expected := []string{"suite0", "suite1", "suite3", "suite4"}
+	if !slices.Equal(ids, expected) {
+		t.Errorf("GetTestSuites pages returned %v, expected %v", ids, expected)
+	}
+
+	all, nextCursor, err := r.GetTestSuites(ctx, "", 0)
+	if err != nil || len(all) != 4 || nextCursor != "" {
+		t.Errorf("GetTestSuites without limit returned %d suites and cursor %q, %v", len(all), nextCursor, err)
+	}
+}
+
+func testInvalidTestSuites(t *testing.T, r db.TestSuitesRepository) {
+	ctx := context.Background()
+	invalid := map[string]func(suite *asit.TestSuite){
+		"EmptyId":           func(suite *asit.TestSuite) { suite.Id = "" },
+		"UnsafeId":          func(suite *asit.TestSuite) { suite.Id = "a/b" },
+		"NoName":            func(suite *asit.TestSuite) { suite.Name = "" },
+		"NoSteps":           func(suite *asit.TestSuite) { suite.Tests[0].Steps = nil },
+		"NoAction":          func(suite *asit.TestSuite) { suite.Tests[0].Steps[0].Action = nil },
+		"NoActionFunction":  func(suite *asit.TestSuite) { suite.Tests[0].Steps[0].Action.Function = "" },
+		"NoCheckFunction":   func(suite *asit.TestSuite) { suite.Tests[0].Steps[0].Verification.Checks[0].Function = "" },
+		"UnknownCheck":      func(suite *asit.TestSuite) { suite.Tests[0].Steps[0].Verification.Checks[0].Function = "status.equals" },
+		"DuplicateCaseId":   func(suite *asit.TestSuite) { suite.Tests[1].Id = suite.Tests[0].Id },
+		"DuplicateStepId":   func(suite *asit.TestSuite) { suite.Tests[1].Steps[0].Id = suite.Tests[0].Steps[0].Id },
+		"InvalidFunction":   func(suite *asit.TestSuite) { suite.Tests[0].Steps[0].Action.Function = "1 + 1" },
+		"EmptyArgumentName": func(suite *asit.TestSuite) { suite.Tests[0].Steps[0].Action.Arguments[""] = "value" },
+	}
+	for name, change := range invalid {
+		suite := newTestSuite("invalid", "first", "second")
+		change(suite)
+		var invalidErr *db.InvalidTestSuiteError
+		if err := r.SetTestSuite(ctx, suite); !errors.As(err, &invalidErr) {
+			t.Errorf("SetTestSuite of the suite with %s returned %v, expected InvalidTestSuiteError", name, err)
+		}
+	}
+	if found, err := r.GetTestSuite(ctx, "invalid"); err != nil || found != nil {
+		t.Errorf("the invalid suite is stored, %v, %v", found, err)
+	}
+}
+
+func testTestCases(t *testing.T, r db.TestSuitesRepository) {
+	ctx := context.Background()
+	suite := newTestSuite("smoke", "login")
+	mustSetTestSuite(t, r, suite)
+
+	added := newTestCase("smoke", "logout")
+	updated, err := r.SetTestCase(ctx, "smoke", added)
+	if err != nil {
+		t.Fatalf("can't add test case, %v", err)
+	}
+	suite.Tests = append(suite.Tests, added)
+	if !proto.Equal(updated, suite) {
+		t.Errorf("SetTestCase returned %v, expected %v", updated, suite)
+	}
+	expectTestSuite(t, r, suite)
+
+	replaced := newTestCase("smoke", "login")
+	replaced.Name = "changed"
+	if _, err := r.SetTestCase(ctx, "smoke", replaced); err != nil {
+		t.Fatalf("can't replace test case, %v", err)
+	}
+	suite.Tests[0] = replaced
+	expectTestSuite(t, r, suite)
+
+	// the step ids must be unique within the suite
+	duplicate := newTestCase("smoke", "other")
+	duplicate.Steps[0].Id = added.Steps[0].Id
+	var invalidErr *db.InvalidTestSuiteError
+	if _, err := r.SetTestCase(ctx, "smoke", duplicate); !errors.As(err, &invalidErr) {
+		t.Errorf("SetTestCase with the duplicate step id returned %v, expected InvalidTestSuiteError", err)
+	}
+
+	if err := r.RemoveTestCase(ctx, "smoke", "login"); err != nil {
+		t.Fatalf("can't remove test case, %v", err)
+	}
+	suite.Tests = suite.Tests[1:]
+	expectTestSuite(t, r, suite)
+
+	var notFoundCaseErr *db.NotFoundTestCaseError
+	if err := r.RemoveTestCase(ctx, "smoke", "login"); !errors.As(err, &notFoundCaseErr) {
+		t.Errorf("RemoveTestCase of the missing case returned %v, expected NotFoundTestCaseError", err)
+	}
+	var notFoundSuiteErr *db.NotFoundTestSuiteError
+	if _, err := r.SetTestCase(ctx, "missing", newTestCase("missing", "login")); !errors.As(err, &notFoundSuiteErr) {
+		t.Errorf("SetTestCase of the missing suite returned %v, expected NotFoundTestSuiteError", err)
+	}
+	if err := r.RemoveTestCase(ctx, "missing", "login"); !errors.As(err, &notFoundSuiteErr) {
+		t.Errorf("RemoveTestCase of the missing suite returned %v, expected NotFoundTestSuiteError", err)
+	}
+}
+
+// testConcurrentTestCases checks that the test cases added concurrently to the same suite aren't lost
+func testConcurrentTestCases(t *testing.T, r db.TestSuitesRepository) {
+	ctx := context.Background()
+	mustSetTestSuite(t, r, newTestSuite("smoke", "initial"))
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := r.SetTestCase(ctx, "smoke", newTestCase("smoke", "case"+strconv.Itoa(i)))
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	added := 0
+	for err := range errs {
+		var concurrentErr *db.ConcurrentUpdateError
+		if errors.As(err, &concurrentErr) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("can't add test case, %v", err)
+		}
+		added++
+	}
+	suite, err := r.GetTestSuite(ctx, "smoke")
+	if err != nil || suite == nil {
+		t.Fatalf("can't get test suite, %v", err)
+	}
+	if len(suite.Tests) != added+1 {
+		t.Errorf("suite has %d test cases after %d successful additions", len(suite.Tests), added)
+	}
+}
+
+func newTestSuite(id string, caseIds ...string) *asit.TestSuite {
+	suite := &asit.TestSuite{Id: id, Name: "suite " + id}
+	for _, caseId := range caseIds {
+		suite.Tests = append(suite.Tests, newTestCase(id, caseId))
+	}
+	return suite
+}
+
+// newTestCase creates the case with the single step, its id is unique within the suite
+func newTestCase(suiteId string, id string) *asit.TestCase {
+	return &asit.TestCase{
+		Id:   id,
+		Name: "case " + id,
+		Steps: []*asit.TestStep{
+			{
+				Id:     id + "-step",
+				Name:   "step of " + id,
+				Action: &asit.TestAction{Function: "http.get", Arguments: map[string]string{"url": "/" + suiteId + "/" + id}},
+				Verification: &asit.TestVerification{Checks: []*asit.TestCheck{
+					{Function: "data.equals", Arguments: map[string]string{"key": "status", "value": "200"}},
+				}},
+			},
+		},
+	}
+}
+
+func mustSetTestSuite(t *testing.T, r db.TestSuitesRepository, suite *asit.TestSuite) {
+	t.Helper()
+	if err := r.SetTestSuite(context.Background(), suite); err != nil {
+		t.Fatalf("can't set test suite %s, %v", suite.Id, err)
+	}
+}
+
+func expectTestSuite(t *testing.T, r db.TestSuitesRepository, expected *asit.TestSuite) {
+	t.Helper()
+	suite, err := r.GetTestSuite(context.Background(), expected.Id)
+	if err != nil {
+		t.Fatalf("can't get test suite %s, %v", expected.Id, err)
+	}
+	if !proto.Equal(suite, expected) {
+		t.Errorf("test suite %v, expected %v", suite, expected)
+	}
+}
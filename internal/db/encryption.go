@@ -0,0 +1,200 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// EncryptionKeyring keeps the AES keys by their ids, the values are encrypted with the active key
+// and decrypted with the key whose id is written with the value, so the keys can be rotated.
+type EncryptionKeyring struct {
+	activeId string
+	aeads    map[string]cipher.AEAD
+}
+
+// maxEncryptionKeyIdLength is the limit of the one byte id length of the encrypted values
+const maxEncryptionKeyIdLength = 255
+
+// NewEncryptionKeyring creates the keyring of the AES-128, AES-192 or AES-256 keys, activeId must be one of the ids
+func NewEncryptionKeyring(activeId string, keys map[string][]byte) (*EncryptionKeyring, error) {
+	aeads := map[string]cipher.AEAD{}
+	for id, key := range keys {
+		if id == "" || len(id) > maxEncryptionKeyIdLength {
+			return nil, fmt.Errorf("encryption key id %q must have from 1 to %d bytes", id, maxEncryptionKeyIdLength)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %s, %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %s, %w", id, err)
+		}
+		aeads[id] = aead
+	}
+	if _, ok := aeads[activeId]; !ok {
+		return nil, fmt.Errorf("active encryption key %q is not found", activeId)
+	}
+	return &EncryptionKeyring{activeId: activeId, aeads: aeads}, nil
+}
+
+// ParseEncryptionKeyring parses the keys separated by new lines or commas, e.g. "2023-01=<base64 key>,2023-02=<base64 key>".
+// The empty lines and the lines starting with # are skipped. The last key is active if activeId is empty.
+func ParseEncryptionKeyring(text string, activeId string) (*EncryptionKeyring, error) {
+	keys := map[string][]byte{}
+	lastId := ""
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == ',' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed encryption key %q, expected <id>=<base64 key>", line)
+		}
+		id = strings.TrimSpace(id)
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("malformed encryption key %s, %w", id, err)
+		}
+		if _, ok := keys[id]; ok {
+			return nil, fmt.Errorf("duplicate encryption key %s", id)
+		}
+		keys[id] = key
+		lastId = id
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no encryption keys specified")
+	}
+	if activeId == "" {
+		activeId = lastId
+	}
+	return NewEncryptionKeyring(activeId, keys)
+}
+
+// LoadEncryptionKeyringFile reads the keys in the ParseEncryptionKeyring format from the keyfile
+func LoadEncryptionKeyringFile(path string, activeId string) (*EncryptionKeyring, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read encryption keyfile, %w", err)
+	}
+	return ParseEncryptionKeyring(string(text), activeId)
+}
+
+const (
+	// encryptedMagic starts the values encrypted before they were bound to their storage keys, only their header
+	// is authenticated. The byte can't start a protobuf value (field number 0 is invalid), a protojson value,
+	// a gzip stream or an envelope, so the values written before the encryption are still readable.
+	encryptedMagic = 0x01
+	// keyBoundEncryptedMagic starts the encrypted values whose storage keys are authenticated with their header,
+	// it can't start the other values for the same reasons
+	keyBoundEncryptedMagic = 0x02
+)
+
+// EncryptingCodec encrypts the values encoded by the wrapped codec with AES-GCM.
+// The encrypted value is the magic byte, the key id length and the key id followed by the nonce and the sealed value,
+// the magic byte, the key id and the storage key of the value are authenticated too, so the value can't be moved
+// to another storage key. The values written without the encryption or before the storage keys were authenticated
+// are read as is unless the encryption is required. They and the values encrypted with the previous keys are encrypted
+// with the active key when they are written again, see KVClientsRepository.RewriteValues.
+type EncryptingCodec struct {
+	codec   StorageCodec
+	keyring *EncryptionKeyring
+	// required rejects the values which aren't encrypted or aren't bound to their storage keys
+	required bool
+}
+
+func NewEncryptingCodec(codec StorageCodec, keyring *EncryptionKeyring) *EncryptingCodec {
+	return &EncryptingCodec{codec: codec, keyring: keyring}
+}
+
+// SetEncryptionRequired rejects the plain values and the values which aren't bound to their storage keys,
+// so they can't be planted by writing to the storage. It must be set only after all the values are rewritten.
+func (c *EncryptingCodec) SetEncryptionRequired(required bool) {
+	c.required = required
+}
+
+// Marshal encrypts the value bound to the empty storage key
+func (c *EncryptingCodec) Marshal(v proto.Message) ([]byte, error) {
+	return c.MarshalKey("", v)
+}
+
+// Unmarshal decrypts the value bound to the empty storage key
+func (c *EncryptingCodec) Unmarshal(data []byte, v proto.Message) error {
+	return c.UnmarshalKey("", data, v)
+}
+
+func (c *EncryptingCodec) MarshalKey(key string, v proto.Message) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	id := c.keyring.activeId
+	aead := c.keyring.aeads[id]
+	header := append([]byte{keyBoundEncryptedMagic, byte(len(id))}, id...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("can't generate nonce, %w", err)
+	}
+	res := make([]byte, 0, len(header)+len(nonce)+len(data)+aead.Overhead())
+	res = append(append(res, header...), nonce...)
+	return aead.Seal(res, nonce, data, encryptedValueAdditionalData(header, key)), nil
+}
+
+func (c *EncryptingCodec) UnmarshalKey(key string, data []byte, v proto.Message) error {
+	if len(data) == 0 || (data[0] != encryptedMagic && data[0] != keyBoundEncryptedMagic) {
+		if c.required {
+			return fmt.Errorf("the value of %s isn't encrypted", key)
+		}
+		return c.codec.Unmarshal(data, v)
+	}
+	if data[0] == encryptedMagic && c.required {
+		return fmt.Errorf("the encrypted value of %s isn't bound to its key", key)
+	}
+	if len(data) < 2 || len(data) < 2+int(data[1]) {
+		return fmt.Errorf("truncated encrypted value")
+	}
+	headerSize := 2 + int(data[1])
+	id := string(data[2:headerSize])
+	aead, ok := c.keyring.aeads[id]
+	if !ok {
+		return fmt.Errorf("the value is encrypted with unknown key %s", id)
+	}
+	if len(data) < headerSize+aead.NonceSize() {
+		return fmt.Errorf("truncated encrypted value")
+	}
+	additionalData := data[:headerSize]
+	if data[0] == keyBoundEncryptedMagic {
+		additionalData = encryptedValueAdditionalData(additionalData, key)
+	}
+	nonce := data[headerSize : headerSize+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, data[headerSize+aead.NonceSize():], additionalData)
+	if err != nil {
+		return fmt.Errorf("can't decrypt value of %s with key %s, %w", key, id, err)
+	}
+	return c.codec.Unmarshal(plain, v)
+}
+
+// encryptedValueAdditionalData is the authenticated header followed by the storage key,
+// the header has its own length, so the boundary of the key is unambiguous
+func encryptedValueAdditionalData(header []byte, key string) []byte {
+	return append(append(make([]byte, 0, len(header)+len(key)), header...), key...)
+}
+
+// hashClientKey returns the hex encoded HMAC-SHA256 of the client key
+func hashClientKey(hmacKey []byte, key string) string {
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,90 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+)
+
+// TestRequiredEncryptionAfterRewrite checks that the values written before the encryption are read in the required
+// encryption mode once they are rewritten, and that the encrypted values can't be moved to the other keys.
+// The storages share the same SQLite db, so the raw values are changed by SQL.
+func TestRequiredEncryptionAfterRewrite(t *testing.T) {
+	ctx := context.Background()
+	sqlDB, err := db.OpenSQLite(filepath.Join(t.TempDir(), "asit.sqlite"))
+	if err != nil {
+		t.Fatalf("can't open sqlite db, %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	keyring, err := db.ParseEncryptionKeyring("k1=MDEyMzQ1Njc4OWFiY2RlZg==", "")
+	if err != nil {
+		t.Fatalf("can't parse encryption keys, %v", err)
+	}
+	encrypting := db.NewEncryptingCodec(db.PROTO_CODEC, keyring)
+	required := db.NewEncryptingCodec(db.PROTO_CODEC, keyring)
+	required.SetEncryptionRequired(true)
+
+	plainStorage := newSQLStorage(t, sqlDB, db.PROTO_CODEC)
+	clients := db.NewKVClientsRepository(plainStorage)
+	if err := clients.SetClient(ctx, &asit.Client{Id: "1", Name: "first"}); err != nil {
+		t.Fatalf("can't set client, %v", err)
+	}
+	suites := db.NewKVTestSuitesRepository(plainStorage)
+	for _, id := range []string{"s1", "s2"} {
+		if err := suites.SetTestSuite(ctx, &asit.TestSuite{Id: id, Name: "suite " + id}); err != nil {
+			t.Fatalf("can't set test suite, %v", err)
+		}
+	}
+	if err := db.NewKVTestRunsRepository(plainStorage).CreateTestRun(ctx, &asit.TestRun{Id: "r1", ClientId: "1"}); err != nil {
+		t.Fatalf("can't create test run, %v", err)
+	}
+
+	requiredStorage := newSQLStorage(t, sqlDB, required)
+	if _, err := db.NewKVTestSuitesRepository(requiredStorage).GetTestSuite(ctx, "s1"); err == nil {
+		t.Errorf("the plain test suite is read when the encryption is required")
+	}
+
+	encryptingStorage := newSQLStorage(t, sqlDB, encrypting)
+	rewriters := []func(ctx context.Context) (int, error){
+		db.NewKVClientsRepository(encryptingStorage).RewriteValues,
+		db.NewKVTestSuitesRepository(encryptingStorage).RewriteValues,
+		db.NewKVTestRunsRepository(encryptingStorage).RewriteValues,
+	}
+	for _, rewrite := range rewriters {
+		if _, err := rewrite(ctx); err != nil {
+			t.Fatalf("RewriteValues failed, %v", err)
+		}
+	}
+
+	if client, err := db.NewKVClientsRepository(requiredStorage).GetClientById(ctx, "1"); err != nil || client == nil {
+		t.Errorf("GetClientById returned (%v, %v) after the rewrite, expected client 1", client, err)
+	}
+	requiredSuites := db.NewKVTestSuitesRepository(requiredStorage)
+	if suite, err := requiredSuites.GetTestSuite(ctx, "s1"); err != nil || suite == nil || suite.Name != "suite s1" {
+		t.Errorf("GetTestSuite returned (%v, %v) after the rewrite, expected suite s1", suite, err)
+	}
+	if run, err := db.NewKVTestRunsRepository(requiredStorage).GetTestRun(ctx, "r1"); err != nil || run == nil {
+		t.Errorf("GetTestRun returned (%v, %v) after the rewrite, expected run r1", run, err)
+	}
+
+	if _, err := sqlDB.Exec("UPDATE kv_values SET v = (SELECT v FROM kv_values WHERE k = ?) WHERE k = ?",
+		db.KEY_TEST_SUITE_PREFIX+"s1", db.KEY_TEST_SUITE_PREFIX+"s2"); err != nil {
+		t.Fatalf("can't copy the value, %v", err)
+	}
+	if suite, err := requiredSuites.GetTestSuite(ctx, "s2"); err == nil {
+		t.Errorf("the value of suite s1 is read as suite s2 %v", suite)
+	}
+}
+
+func newSQLStorage(t *testing.T, sqlDB *sql.DB, codec db.StorageCodec) db.Storage {
+	t.Helper()
+	s, err := db.NewSQLStorage(sqlDB, db.SQLITE_DIALECT, codec)
+	if err != nil {
+		t.Fatalf("can't create sql storage, %v", err)
+	}
+	return s
+}
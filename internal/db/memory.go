@@ -0,0 +1,252 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+)
+
+// MemoryStorage is an in-process Storage implementation.
+// Values are kept encoded with the configured codec, so it behaves the same way as
+// the persistent implementations do. It is intended for local runs and unit tests.
+// Expired values are treated as missing and are removed when their keys are written again.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	codec   StorageCodec
+	values  map[string][]byte
+	version map[string]uint64
+	expires map[string]time.Time
+	counter uint64
+	// indexes keep sorted members
+	indexes map[string][]string
+}
+
+func NewMemoryStorage(codec StorageCodec) *MemoryStorage {
+	return &MemoryStorage{
+		codec:   codec,
+		values:  map[string][]byte{},
+		version: map[string]uint64{},
+		expires: map[string]time.Time{},
+		indexes: map[string][]string{},
+	}
+}
+
+func (s *MemoryStorage) Set(ctx context.Context, k string, v proto.Message) error {
+	return s.SetWithTTL(ctx, k, v, 0)
+}
+
+func (s *MemoryStorage) SetWithTTL(ctx context.Context, k string, v proto.Message, ttl time.Duration) error {
+	bytes, err := marshalValue(s.codec, k, v)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(k, bytes, ttl)
+	return nil
+}
+
+func (s *MemoryStorage) Get(ctx context.Context, k string, v proto.Message) (found bool, err error) {
+	s.mu.RLock()
+	bytes, ok := s.get(k)
+	s.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+
+	err = unmarshalValue(s.codec, k, bytes, v)
+	return true, err
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, k ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range k {
+		s.delete(key)
+	}
+	return nil
+}
+
+func (s *MemoryStorage) Close() error {
+	return nil
+}
+
+func (s *MemoryStorage) SetAndDeleteAtomically(ctx context.Context, lockedSets []SetValueCommand, lockedDeleteKeys []string, unlockedSets func() []SetValueUnlockedCommand, unlockedDeleteKeys func() []string, indexUpdates func() []IndexCommand) error {
+	allKeys := make([]string, 0, len(lockedDeleteKeys)+len(lockedSets))
+	allKeys = append(allKeys, lockedDeleteKeys...)
+	for _, set := range lockedSets {
+		allKeys = append(allKeys, set.key)
+	}
+
+	for i := 0; i < maxRetries; i++ {
+		// remember versions of all the locked keys, it's the analogue of the redis WATCH
+		watched := make([]uint64, len(allKeys))
+		oldBytes := make([][]byte, len(lockedSets))
+		s.mu.RLock()
+		for j, key := range allKeys {
+			watched[j] = s.version[key]
+		}
+		for j, set := range lockedSets {
+			oldBytes[j], _ = s.get(set.key)
+		}
+		s.mu.RUnlock()
+
+		type write struct {
+			key    string
+			bytes  []byte
+			ttl    time.Duration
+			delete bool
+		}
+		var writes []write
+		for j, set := range lockedSets {
+			requiresUpdate, newVal, err := set.updater(func(m proto.Message) (bool, error) {
+				oldBytesCurrent := oldBytes[j]
+				if oldBytesCurrent == nil {
+					return false, nil
+				}
+				return true, unmarshalValue(s.codec, set.key, oldBytesCurrent, m)
+			})
+			if err != nil {
+				return err
+			}
+			if !requiresUpdate {
+				continue
+			}
+			if newVal == nil {
+				writes = append(writes, write{key: set.key, delete: true})
+				continue
+			}
+			newValBytes, err := marshalValue(s.codec, set.key, newVal)
+			if err != nil {
+				return err
+			}
+			writes = append(writes, write{key: set.key, bytes: newValBytes, ttl: set.ttl})
+		}
+		for _, deleteKey := range lockedDeleteKeys {
+			writes = append(writes, write{key: deleteKey, delete: true})
+		}
+		for _, set := range unlockedSets() {
+			newValBytes, err := marshalValue(s.codec, set.key, set.newValue)
+			if err != nil {
+				return err
+			}
+			writes = append(writes, write{key: set.key, bytes: newValBytes, ttl: set.ttl})
+		}
+		for _, deleteKey := range unlockedDeleteKeys() {
+			writes = append(writes, write{key: deleteKey, delete: true})
+		}
+		var indexCmds []IndexCommand
+		if indexUpdates != nil {
+			indexCmds = indexUpdates()
+		}
+
+		s.mu.Lock()
+		changed := false
+		for j, key := range allKeys {
+			if s.version[key] != watched[j] {
+				changed = true
+				break
+			}
+		}
+		if changed {
+			// Optimistic lock lost. Retry.
+			s.mu.Unlock()
+			continue
+		}
+		for _, w := range writes {
+			if w.delete {
+				s.delete(w.key)
+			} else {
+				s.set(w.key, w.bytes, w.ttl)
+			}
+		}
+		for _, cmd := range indexCmds {
+			s.updateIndex(cmd)
+		}
+		s.mu.Unlock()
+		return nil
+	}
+
+	return &ConcurrentUpdateError{keys: allKeys}
+}
+
+func (s *MemoryStorage) IndexRange(ctx context.Context, index string, after string, limit int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	members := s.indexes[index]
+	i, found := slices.BinarySearch(members, after)
+	if found {
+		i++
+	}
+	end := len(members)
+	if limit > 0 && i+limit < end {
+		end = i + limit
+	}
+	return append([]string{}, members[i:end]...), nil
+}
+
+func (s *MemoryStorage) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := []string{}
+	for k := range s.values {
+		if _, ok := s.get(k); ok && strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// updateIndex must be called with the write lock held
+func (s *MemoryStorage) updateIndex(cmd IndexCommand) {
+	members := s.indexes[cmd.index]
+	i, found := slices.BinarySearch(members, cmd.member)
+	switch {
+	case cmd.remove && found:
+		members = slices.Delete(members, i, i+1)
+	case !cmd.remove && !found:
+		members = slices.Insert(members, i, cmd.member)
+	}
+	if len(members) == 0 {
+		delete(s.indexes, cmd.index)
+		return
+	}
+	s.indexes[cmd.index] = members
+}
+
+// get returns nil for the missing and expired values, it must be called with the lock held
+func (s *MemoryStorage) get(k string) ([]byte, bool) {
+	if expires, ok := s.expires[k]; ok && !time.Now().Before(expires) {
+		return nil, false
+	}
+	bytes, ok := s.values[k]
+	return bytes, ok
+}
+
+// set must be called with the write lock held
+func (s *MemoryStorage) set(k string, bytes []byte, ttl time.Duration) {
+	if bytes == nil {
+		// empty messages are marshalled to nil, but nil means a missing value here
+		bytes = []byte{}
+	}
+	s.counter++
+	s.values[k] = bytes
+	s.version[k] = s.counter
+	if ttl > 0 {
+		s.expires[k] = time.Now().Add(ttl)
+	} else {
+		delete(s.expires, k)
+	}
+}
+
+// delete must be called with the write lock held
+func (s *MemoryStorage) delete(k string) {
+	delete(s.values, k)
+	delete(s.version, k)
+	delete(s.expires, k)
+}
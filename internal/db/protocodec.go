@@ -1,6 +1,7 @@
 package db
 
 import (
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -10,9 +11,24 @@ func (c ProtoCodec) Marshal(v proto.Message) ([]byte, error) {
 	return proto.Marshal(v)
 }
 
-// Unmarshal decodes a gob value into a Go value.
+// Unmarshal decodes a binary protobuf value into a Go value.
 func (c ProtoCodec) Unmarshal(data []byte, v proto.Message) error {
 	return proto.Unmarshal(data, v)
 }
 
 var PROTO_CODEC = ProtoCodec{}
+
+// ProtoJSONCodec keeps the values readable while debugging, e.g. by redis-cli GET,
+// at the cost of the size and the speed of the encoding.
+type ProtoJSONCodec struct{}
+
+func (c ProtoJSONCodec) Marshal(v proto.Message) ([]byte, error) {
+	return protojson.Marshal(v)
+}
+
+// Unmarshal decodes a protojson value, the unknown fields written by the newer versions are skipped.
+func (c ProtoJSONCodec) Unmarshal(data []byte, v proto.Message) error {
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, v)
+}
+
+var PROTOJSON_CODEC = ProtoJSONCodec{}
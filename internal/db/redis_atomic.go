@@ -0,0 +1,348 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+// RedisAtomicUpdates is the way RedisStorage.SetAndDeleteAtomically checks that the locked keys aren't changed concurrently
+type RedisAtomicUpdates int32
+
+const (
+	// REDIS_ATOMIC_UPDATES_SCRIPT computes the updates from the locked values last seen by the storage and writes them
+	// by the Lua script, which checks that the locked values are unchanged. So the updates of the recently used keys
+	// take one round trip and no dedicated connection, the unseen values are read by MGET first.
+	// The WATCH updates are used instead if Redis rejects the scripts, e.g. they are forbidden by ACL.
+	REDIS_ATOMIC_UPDATES_SCRIPT RedisAtomicUpdates = 0
+	// REDIS_ATOMIC_UPDATES_WATCH reads the locked keys after WATCH and writes the updates by MULTI/EXEC
+	REDIS_ATOMIC_UPDATES_WATCH RedisAtomicUpdates = 1
+)
+
+// redisAtomicUpdates are the names of the atomic updates used by the configuration
+var redisAtomicUpdates = map[string]RedisAtomicUpdates{
+	"script": REDIS_ATOMIC_UPDATES_SCRIPT,
+	"watch":  REDIS_ATOMIC_UPDATES_WATCH,
+}
+
+// ParseRedisAtomicUpdates returns the atomic updates by their name, script or watch
+func ParseRedisAtomicUpdates(name string) (RedisAtomicUpdates, error) {
+	if updates, ok := redisAtomicUpdates[strings.ToLower(name)]; ok {
+		return updates, nil
+	}
+	return 0, fmt.Errorf("unsupported Redis atomic updates %q, expected script or watch", name)
+}
+
+// SetAtomicUpdates changes the way the locked keys are checked, the scripts are used by default
+func (s *RedisStorage) SetAtomicUpdates(updates RedisAtomicUpdates) {
+	atomic.StoreInt32(&s.atomicUpdates, int32(updates))
+}
+
+func (s *RedisStorage) scriptedUpdates() bool {
+	return RedisAtomicUpdates(atomic.LoadInt32(&s.atomicUpdates)) == REDIS_ATOMIC_UPDATES_SCRIPT
+}
+
+func (s *RedisStorage) disableScriptedUpdates() {
+	s.SetAtomicUpdates(REDIS_ATOMIC_UPDATES_WATCH)
+}
+
+// isScriptingUnavailable checks if Redis rejects the scripts, not the updates made by them
+func isScriptingUnavailable(err error) bool {
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) {
+		return false
+	}
+	message := redisErr.Error()
+	return strings.HasPrefix(message, "NOPERM") || strings.Contains(message, "unknown command")
+}
+
+// redisMissingValue is the checked value of the missing key, the present values are checked by their SHA1
+// written in hex, so the missing key can't be confused with the empty value
+const redisMissingValue = "-"
+
+// redisUpdateScript checks that the values of KEYS[1..ARGV[1]] have the SHA1 hashes ARGV[2..ARGV[1]+1]
+// and applies the writes following them to the rest of KEYS in order. A write is its command name
+// followed by its arguments: set <value> <ttl in ms, 0 means no expiry>, del, zadd <member> or zrem <member>.
+// It returns 1 if the writes are applied. If any checked value has changed, it writes nothing
+// and returns 0 followed by the current checked values, nil for the missing ones.
+var redisUpdateScript = redis.NewScript(`
+local checked = tonumber(ARGV[1])
+local current = {0}
+local changed = false
+for i = 1, checked do
+	local value = redis.call('GET', KEYS[i])
+	local expected = ARGV[i + 1]
+	current[i + 1] = value
+	if value then
+		if expected == '-' or redis.sha1hex(value) ~= expected then
+			changed = true
+		end
+	elseif expected ~= '-' then
+		changed = true
+	end
+end
+if changed then
+	return current
+end
+local arg = checked + 2
+for i = checked + 1, #KEYS do
+	local command = ARGV[arg]
+	if command == 'set' then
+		local ttl = tonumber(ARGV[arg + 2])
+		if ttl > 0 then
+			redis.call('SET', KEYS[i], ARGV[arg + 1], 'PX', ttl)
+		else
+			redis.call('SET', KEYS[i], ARGV[arg + 1])
+		end
+		arg = arg + 3
+	elseif command == 'del' then
+		redis.call('DEL', KEYS[i])
+		arg = arg + 1
+	elseif command == 'zadd' then
+		redis.call('ZADD', KEYS[i], 0, ARGV[arg + 1])
+		arg = arg + 2
+	else
+		redis.call('ZREM', KEYS[i], ARGV[arg + 1])
+		arg = arg + 2
+	end
+end
+return 1
+`)
+
+// setAndDeleteByScript computes the updates from the locked values last seen by the storage, so the script checks
+// and writes them in one round trip. The unseen values are read by MGET first. redis.TxFailedErr is returned
+// if the locked values have been changed like in the WATCH updates, the script returns the current values,
+// so the retry takes one round trip too.
+func (s *RedisStorage) setAndDeleteByScript(ctx context.Context, lockedKeys []string, writeUpdates func(w redisWriter, oldBytes [][]byte) error) error {
+	oldBytes, seen := s.seenValues.get(lockedKeys)
+	if !seen {
+		var err error
+		if oldBytes, err = s.readLockedValues(ctx, lockedKeys); err != nil {
+			return err
+		}
+	}
+	w := newScriptWriter(lockedKeys, oldBytes)
+	err := writeUpdates(w, oldBytes)
+	if err != nil && seen {
+		// the error may be caused by the outdated seen values, e.g. the client is missing in them
+		if oldBytes, err = s.readLockedValues(ctx, lockedKeys); err != nil {
+			return err
+		}
+		seen = false
+		w = newScriptWriter(lockedKeys, oldBytes)
+		err = writeUpdates(w, oldBytes)
+	}
+	if err != nil {
+		return err
+	}
+	if !seen && len(w.keys) == len(lockedKeys) {
+		// nothing to write, the values read by MGET are consistent
+		return nil
+	}
+
+	result, err := redisUpdateScript.Run(ctx, s.client, w.keys, w.args...).Result()
+	if err != nil {
+		return err
+	}
+	current, ok := result.([]interface{})
+	if !ok {
+		s.seenValues.put(w.written)
+		return nil
+	}
+	changed := make(map[string][]byte, len(lockedKeys))
+	for i, key := range lockedKeys {
+		changed[key] = redisValueBytes(current[i+1])
+	}
+	s.seenValues.put(changed)
+	return redis.TxFailedErr
+}
+
+// readLockedValues reads the locked values by MGET and remembers them as the seen ones
+func (s *RedisStorage) readLockedValues(ctx context.Context, lockedKeys []string) ([][]byte, error) {
+	oldBytes := make([][]byte, len(lockedKeys))
+	if len(lockedKeys) == 0 {
+		return oldBytes, nil
+	}
+	values, err := s.client.MGet(ctx, lockedKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	read := make(map[string][]byte, len(lockedKeys))
+	for i, value := range values {
+		oldBytes[i] = redisValueBytes(value)
+		read[lockedKeys[i]] = oldBytes[i]
+	}
+	s.seenValues.put(read)
+	return oldBytes, nil
+}
+
+// redisValueBytes converts the value returned by MGET or by the script, nil is the missing value
+func redisValueBytes(value interface{}) []byte {
+	if value == nil {
+		return nil
+	}
+	return []byte(value.(string))
+}
+
+// redisSeenValuesSize is the max number of the values remembered by redisSeenValues
+const redisSeenValuesSize = 4096
+
+// redisSeenValues are the values of the keys last read or written by the scripted updates, nil is the missing value.
+// They may be outdated, the script checks them before the writes. The least recently used values are forgotten.
+type redisSeenValues struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru keeps the most recently used entries at the front
+	lru *list.List
+}
+
+type redisSeenValue struct {
+	key   string
+	value []byte
+}
+
+func newRedisSeenValues() *redisSeenValues {
+	return &redisSeenValues{entries: map[string]*list.Element{}, lru: list.New()}
+}
+
+// get returns the seen values of the keys, ok is false if any of them isn't seen
+func (v *redisSeenValues) get(keys []string) (values [][]byte, ok bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	values = make([][]byte, len(keys))
+	for i, key := range keys {
+		element, found := v.entries[key]
+		if !found {
+			return nil, false
+		}
+		v.lru.MoveToFront(element)
+		values[i] = element.Value.(*redisSeenValue).value
+	}
+	return values, true
+}
+
+func (v *redisSeenValues) put(values map[string][]byte) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for key, value := range values {
+		if element, found := v.entries[key]; found {
+			element.Value.(*redisSeenValue).value = value
+			v.lru.MoveToFront(element)
+			continue
+		}
+		v.entries[key] = v.lru.PushFront(&redisSeenValue{key: key, value: value})
+	}
+	for v.lru.Len() > redisSeenValuesSize {
+		delete(v.entries, v.lru.Remove(v.lru.Back()).(*redisSeenValue).key)
+	}
+}
+
+// redisWriter collects the writes of SetAndDeleteAtomically, they are applied by MULTI/EXEC or by the Lua script
+type redisWriter interface {
+	set(key string, value []byte, ttl time.Duration)
+	del(key string)
+	zadd(index string, member string)
+	zrem(index string, member string)
+}
+
+type pipelineWriter struct {
+	ctx  context.Context
+	pipe redis.Pipeliner
+}
+
+func (w *pipelineWriter) set(key string, value []byte, ttl time.Duration) {
+	w.pipe.Set(w.ctx, key, value, ttl)
+}
+
+func (w *pipelineWriter) del(key string) {
+	w.pipe.Del(w.ctx, key)
+}
+
+func (w *pipelineWriter) zadd(index string, member string) {
+	// all the members have the same score, so they are ordered lexicographically
+	w.pipe.ZAdd(w.ctx, index, redis.Z{Score: 0, Member: member})
+}
+
+func (w *pipelineWriter) zrem(index string, member string) {
+	w.pipe.ZRem(w.ctx, index, member)
+}
+
+// scriptWriter collects the keys and the arguments of redisUpdateScript
+type scriptWriter struct {
+	keys []string
+	args []interface{}
+	// written are the values written by the script, nil for the deleted ones
+	written map[string][]byte
+}
+
+// newScriptWriter creates the writer checking that the locked keys have the old values
+func newScriptWriter(lockedKeys []string, oldBytes [][]byte) *scriptWriter {
+	w := &scriptWriter{keys: append([]string{}, lockedKeys...), args: []interface{}{len(lockedKeys)}, written: map[string][]byte{}}
+	for _, old := range oldBytes {
+		if old == nil {
+			w.args = append(w.args, redisMissingValue)
+			continue
+		}
+		hash := sha1.Sum(old)
+		w.args = append(w.args, hex.EncodeToString(hash[:]))
+	}
+	return w
+}
+
+func (w *scriptWriter) set(key string, value []byte, ttl time.Duration) {
+	ttlMillis := ttl.Milliseconds()
+	if ttl > 0 && ttlMillis == 0 {
+		ttlMillis = 1
+	}
+	w.keys = append(w.keys, key)
+	w.args = append(w.args, "set", value, ttlMillis)
+	w.written[key] = value
+}
+
+func (w *scriptWriter) del(key string) {
+	w.keys = append(w.keys, key)
+	w.args = append(w.args, "del")
+	w.written[key] = nil
+}
+
+func (w *scriptWriter) zadd(index string, member string) {
+	w.keys = append(w.keys, index)
+	w.args = append(w.args, "zadd", member)
+}
+
+func (w *scriptWriter) zrem(index string, member string) {
+	w.keys = append(w.keys, index)
+	w.args = append(w.args, "zrem", member)
+}
+
+const (
+	minRetryBackoff = time.Millisecond
+	maxRetryBackoff = 64 * time.Millisecond
+)
+
+// sleepBeforeRetry waits for the random time up to the exponentially growing backoff,
+// so the concurrent writers of the same keys don't conflict again immediately
+func sleepBeforeRetry(ctx context.Context, retry int) error {
+	backoff := maxRetryBackoff
+	if retry < 7 {
+		backoff = minRetryBackoff << retry
+	}
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff))))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,76 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/internal/db/dbtest"
+	"github.com/go-redis/redis/v9"
+)
+
+// redisAtomicUpdates are the ways of the atomic updates of RedisStorage tested by the conformance kit
+var redisAtomicUpdates = map[string]db.RedisAtomicUpdates{
+	"Script": db.REDIS_ATOMIC_UPDATES_SCRIPT,
+	"Watch":  db.REDIS_ATOMIC_UPDATES_WATCH,
+}
+
+// newRedisStorage returns the storage of the new in-memory Redis, its clock follows the real time, so the values expire
+func newRedisStorage(tb testing.TB, updates db.RedisAtomicUpdates) db.Storage {
+	server := miniredis.RunT(tb)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				server.FastForward(10 * time.Millisecond)
+			}
+		}
+	}()
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	tb.Cleanup(func() {
+		ticker.Stop()
+		close(done)
+		client.Close()
+	})
+	s := db.NewRedisStorageWithNamespace(client, db.PROTO_CODEC, "asit", "test")
+	s.SetAtomicUpdates(updates)
+	return s
+}
+
+func TestRedisStorage(t *testing.T) {
+	for name, updates := range redisAtomicUpdates {
+		updates := updates
+		t.Run(name, func(t *testing.T) {
+			dbtest.RunStorageTests(t, func(t *testing.T) db.Storage {
+				return newRedisStorage(t, updates)
+			})
+		})
+	}
+}
+
+func TestRedisKVClientsRepository(t *testing.T) {
+	for name, updates := range redisAtomicUpdates {
+		updates := updates
+		t.Run(name, func(t *testing.T) {
+			dbtest.RunKVClientsRepositoryTests(t, func(t *testing.T) db.Storage {
+				return newRedisStorage(t, updates)
+			})
+		})
+	}
+}
+
+func BenchmarkRedisClientsRepository(b *testing.B) {
+	for name, updates := range redisAtomicUpdates {
+		updates := updates
+		b.Run(name, func(b *testing.B) {
+			dbtest.RunClientsRepositoryBenchmarks(b, func(b *testing.B) db.Storage {
+				return newRedisStorage(b, updates)
+			})
+		})
+	}
+}
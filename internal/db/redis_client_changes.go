@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v9"
+)
+
+// redisClientChangesChannel is the pub/sub channel of the client changes, it's prefixed like the keys of the storage
+const redisClientChangesChannel = "client_changes"
+
+// redisTestRunChangesChannel is the pub/sub channel of the changes of the test runs
+const redisTestRunChangesChannel = "test_run_changes"
+
+// RedisClientChanges publishes the changes of the clients by Redis pub/sub.
+// The messages are the ids of the changed clients, the empty message notifies about all the clients.
+type RedisClientChanges struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+// NewRedisClientChanges creates the changes of the instances sharing the hash tag and the namespace
+func NewRedisClientChanges(client redis.UniversalClient, hashTag string, namespace string) *RedisClientChanges {
+	return &RedisClientChanges{client: client, channel: RedisKeyPrefix(hashTag, namespace) + redisClientChangesChannel}
+}
+
+// NewRedisTestRunChanges creates the changes of the test runs of the instances sharing the hash tag and the namespace,
+// the messages are the ids of the clients whose runs have changed
+func NewRedisTestRunChanges(client redis.UniversalClient, hashTag string, namespace string) *RedisClientChanges {
+	return &RedisClientChanges{client: client, channel: RedisKeyPrefix(hashTag, namespace) + redisTestRunChangesChannel}
+}
+
+func (c *RedisClientChanges) Publish(ctx context.Context, clientId string) error {
+	return c.client.Publish(ctx, c.channel, clientId).Err()
+}
+
+// Subscribe relies on the reconnects of go-redis, every (re)subscription notifies about all the clients
+func (c *RedisClientChanges) Subscribe(ctx context.Context, changed func(clientId string)) error {
+	pubsub := c.client.Subscribe(ctx, c.channel)
+	defer pubsub.Close()
+	messages := pubsub.ChannelWithSubscriptions()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case message, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			switch m := message.(type) {
+			case *redis.Subscription:
+				changed("")
+			case *redis.Message:
+				changed(m.Payload)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v9"
+)
+
+// redisKeyPatterns matches all the keys written by KVClientsRepository without a hash tag
+var redisKeyPatterns = []string{
+	KEY_ALL_CLIENTS,
+	redisIndexPrefix + "*",
+	KEY_CLIENT_PREFIX + "*",
+	KEY_CLIENT_KEY_PREFIX + "*",
+	KEY_CLIENT_KEYS_PREFIX + "*",
+	KEY_TRASHED_CLIENT_PREFIX + "*",
+	KEY_RESERVED_CLIENT_KEY_PREFIX + "*",
+	KEY_CLIENT_HISTORY_PREFIX + "*",
+	KEY_CLIENT_HISTORY_ENTRY_PREFIX + "*",
+	KEY_TEST_SUITE_PREFIX + "*",
+	KEY_TEST_RUN_PREFIX + "*",
+}
+
+// MigrateRedisKeysToHashTag moves the keys of the single-node layout to the {hashTag} layout
+// used by NewRedisStorageWithHashTag. Every key is copied with its TTL and then the old key is deleted.
+// ASIT servers must be stopped during the migration.
+// Keys which already exist in the new layout are not overwritten and are reported as skipped.
+func MigrateRedisKeysToHashTag(ctx context.Context, client redis.UniversalClient, hashTag string) (migrated int, skipped []string, err error) {
+	prefix := RedisHashTagPrefix(hashTag)
+	if prefix == "" {
+		return 0, nil, fmt.Errorf("hash tag must not be empty")
+	}
+	return copyRedisKeys(ctx, client, "", prefix, true)
+}
+
+// copyRedisKeys copies all the keys of the layout with the fromPrefix to the layout with the toPrefix,
+// the copied keys are deleted if move is true. Keys which already exist in the new layout are not overwritten
+// and are reported as skipped.
+func copyRedisKeys(ctx context.Context, client redis.UniversalClient, fromPrefix string, toPrefix string, move bool) (copied int, skipped []string, err error) {
+	patterns := make([]string, len(redisKeyPatterns))
+	for i, pattern := range redisKeyPatterns {
+		patterns[i] = fromPrefix + pattern
+	}
+	keys, err := scanRedisKeys(ctx, client, patterns)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, key := range keys {
+		if fromPrefix == "" && strings.HasPrefix(key, "{") {
+			continue
+		}
+		newKey := toPrefix + strings.TrimPrefix(key, fromPrefix)
+		ok, err := copyRedisKey(ctx, client, key, newKey)
+		if err != nil {
+			return copied, skipped, err
+		}
+		if !ok {
+			skipped = append(skipped, key)
+			continue
+		}
+		if move {
+			if err := client.Del(ctx, key).Err(); err != nil {
+				return copied, skipped, fmt.Errorf("can't delete moved key %s, %w", key, err)
+			}
+		}
+		copied++
+	}
+	return copied, skipped, nil
+}
+
+// copyRedisKey copies the string or sorted set value with its TTL if the new key doesn't exist yet.
+// Separate commands are used instead of RENAME, because the keys could be in different cluster slots.
+func copyRedisKey(ctx context.Context, client redis.UniversalClient, key string, newKey string) (bool, error) {
+	exists, err := client.Exists(ctx, newKey).Result()
+	if err != nil {
+		return false, fmt.Errorf("can't check key %s, %w", newKey, err)
+	}
+	if exists > 0 {
+		return false, nil
+	}
+	ttl, err := client.PTTL(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("can't get ttl of key %s, %w", key, err)
+	}
+
+	keyType, err := client.Type(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("can't get type of key %s, %w", key, err)
+	}
+	switch keyType {
+	case "string":
+		value, err := client.Get(ctx, key).Bytes()
+		if err != nil {
+			return false, fmt.Errorf("can't get key %s, %w", key, err)
+		}
+		if err := client.Set(ctx, newKey, value, 0).Err(); err != nil {
+			return false, fmt.Errorf("can't set key %s, %w", newKey, err)
+		}
+	case "zset":
+		members, err := client.ZRangeWithScores(ctx, key, 0, -1).Result()
+		if err != nil {
+			return false, fmt.Errorf("can't get key %s, %w", key, err)
+		}
+		if len(members) > 0 {
+			if err := client.ZAdd(ctx, newKey, members...).Err(); err != nil {
+				return false, fmt.Errorf("can't set key %s, %w", newKey, err)
+			}
+		}
+	case "none":
+		// removed concurrently
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported type %s of key %s", keyType, key)
+	}
+
+	if ttl > 0 {
+		if err := client.PExpire(ctx, newKey, ttl).Err(); err != nil {
+			return false, fmt.Errorf("can't set ttl of key %s, %w", newKey, err)
+		}
+	}
+	return true, nil
+}
+
+// scanRedisKeys returns all the keys matching the patterns, it scans every master node of the cluster
+func scanRedisKeys(ctx context.Context, client redis.UniversalClient, patterns []string) ([]string, error) {
+	var mu sync.Mutex
+	var keys []string
+	scanNode := func(ctx context.Context, node redis.UniversalClient) error {
+		for _, pattern := range patterns {
+			iter := node.Scan(ctx, 0, pattern, 1000).Iterator()
+			for iter.Next(ctx) {
+				mu.Lock()
+				keys = append(keys, iter.Val())
+				mu.Unlock()
+			}
+			if err := iter.Err(); err != nil {
+				return fmt.Errorf("can't scan keys %s, %w", pattern, err)
+			}
+		}
+		return nil
+	}
+
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+			return scanNode(ctx, master)
+		})
+		return keys, err
+	}
+	err := scanNode(ctx, client)
+	return keys, err
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-redis/redis/v9"
+)
+
+// redisNamespacePattern excludes the separator and the glob characters, so the keys of a namespace can be scanned
+var redisNamespacePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ValidateRedisNamespace checks that the keys of the namespace can't be confused with the keys of other namespaces.
+// The empty namespace is the layout without a namespace.
+func ValidateRedisNamespace(namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+	if !redisNamespacePattern.MatchString(namespace) {
+		return fmt.Errorf("namespace %q must contain only letters, digits, '_', '.' and '-'", namespace)
+	}
+	for _, pattern := range redisKeyPatterns {
+		if strings.HasPrefix(pattern, namespace+":") {
+			return fmt.Errorf("namespace %q is reserved, its keys would match the keys without a namespace", namespace)
+		}
+	}
+	return nil
+}
+
+// RedisKeyPrefix returns the prefix of all the keys written by RedisStorage, it's {hashTag}:namespace:
+// with the parts omitted if they are empty
+func RedisKeyPrefix(hashTag string, namespace string) string {
+	if namespace == "" {
+		return RedisHashTagPrefix(hashTag)
+	}
+	return RedisHashTagPrefix(hashTag) + namespace + ":"
+}
+
+// CopyRedisNamespace copies all the keys of one namespace to another one with the same hash tag,
+// the keys are moved if move is true. Every key is copied with its TTL.
+// ASIT servers using the namespaces must be stopped during the copying.
+// Keys which already exist in the target namespace are not overwritten and are reported as skipped.
+func CopyRedisNamespace(ctx context.Context, client redis.UniversalClient, hashTag string, from string, to string, move bool) (copied int, skipped []string, err error) {
+	for _, namespace := range []string{from, to} {
+		if err := ValidateRedisNamespace(namespace); err != nil {
+			return 0, nil, err
+		}
+	}
+	if from == to {
+		return 0, nil, fmt.Errorf("source and target namespaces must differ")
+	}
+	return copyRedisKeys(ctx, client, RedisKeyPrefix(hashTag, from), RedisKeyPrefix(hashTag, to), move)
+}
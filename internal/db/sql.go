@@ -0,0 +1,362 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	_ "modernc.org/sqlite"
+)
+
+// SQLDialect describes the differences between the supported SQL databases.
+// Queries are written with "?" placeholders and rebound for the databases using another syntax.
+type SQLDialect struct {
+	Name string
+	// BlobType is the column type used to store encoded values
+	BlobType string
+	// NumberedPlaceholders rebinds "?" placeholders to $1, $2, ... (Postgres style)
+	NumberedPlaceholders bool
+}
+
+var SQLITE_DIALECT = SQLDialect{
+	Name:     "sqlite",
+	BlobType: "BLOB",
+}
+
+func (d SQLDialect) rebind(query string) string {
+	if !d.NumberedPlaceholders {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// OpenSQLite opens (and creates if required) the SQLite database file with the settings required by ASIT.
+func OpenSQLite(path string) (*sql.DB, error) {
+	sqlDB, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, fmt.Errorf("can't open sqlite db %s, %w", path, err)
+	}
+	// SQLite allows only one writer, so the single connection prevents SQLITE_BUSY errors
+	sqlDB.SetMaxOpenConns(1)
+	return sqlDB, nil
+}
+
+// sqlAddedColumn is a column missing in the tables created by the previous ASIT versions
+type sqlAddedColumn struct {
+	name       string
+	definition string
+}
+
+// addSQLColumns adds the columns missing in the table
+func addSQLColumns(sqlDB *sql.DB, table string, columns []sqlAddedColumn) error {
+	for _, column := range columns {
+		if _, err := sqlDB.Exec("SELECT " + column.name + " FROM " + table + " WHERE 1 = 0"); err == nil {
+			continue
+		}
+		if _, err := sqlDB.Exec("ALTER TABLE " + table + " ADD COLUMN " + column.name + " " + column.definition); err != nil {
+			return fmt.Errorf("can't add %s to %s schema, %w", column.name, table, err)
+		}
+	}
+	return nil
+}
+
+// SQLStorage is a Storage implementation which keeps encoded values in the kv_values table.
+// Every value has a version which is used for the optimistic locking in SetAndDeleteAtomically.
+// Expired values are kept in the table until their keys are written again.
+type SQLStorage struct {
+	db      *sql.DB
+	dialect SQLDialect
+	codec   StorageCodec
+}
+
+func NewSQLStorage(sqlDB *sql.DB, dialect SQLDialect, codec StorageCodec) (*SQLStorage, error) {
+	for _, statement := range []string{
+		`CREATE TABLE IF NOT EXISTS kv_values (
+			k TEXT PRIMARY KEY,
+			v ` + dialect.BlobType + ` NOT NULL,
+			version BIGINT NOT NULL,
+			expires BIGINT
+		)`,
+		`CREATE TABLE IF NOT EXISTS kv_indexes (
+			idx TEXT NOT NULL,
+			member TEXT NOT NULL,
+			PRIMARY KEY (idx, member)
+		)`,
+	} {
+		if _, err := sqlDB.Exec(statement); err != nil {
+			return nil, fmt.Errorf("can't create kv storage schema, %w", err)
+		}
+	}
+	// expires is the unix nanos when the value expires, it's NULL for the values without TTL
+	if err := addSQLColumns(sqlDB, "kv_values", []sqlAddedColumn{{"expires", "BIGINT"}}); err != nil {
+		return nil, err
+	}
+	return &SQLStorage{db: sqlDB, dialect: dialect, codec: codec}, nil
+}
+
+func (s *SQLStorage) Set(ctx context.Context, k string, v proto.Message) error {
+	return s.SetWithTTL(ctx, k, v, 0)
+}
+
+func (s *SQLStorage) SetWithTTL(ctx context.Context, k string, v proto.Message, ttl time.Duration) error {
+	bytes, err := marshalValue(s.codec, k, v)
+	if err != nil {
+		return err
+	}
+	return s.put(ctx, s.db, k, bytes, ttl)
+}
+
+func (s *SQLStorage) Get(ctx context.Context, k string, v proto.Message) (found bool, err error) {
+	bytes, _, found, err := s.get(ctx, s.db, k)
+	if err != nil || !found {
+		return false, err
+	}
+
+	err = unmarshalValue(s.codec, k, bytes, v)
+	return true, err
+}
+
+func (s *SQLStorage) Delete(ctx context.Context, k ...string) error {
+	for _, key := range k {
+		if _, err := s.db.ExecContext(ctx, s.dialect.rebind("DELETE FROM kv_values WHERE k = ?"), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close does nothing, the *sql.DB is owned by the caller
+func (s *SQLStorage) Close() error {
+	return nil
+}
+
+func (s *SQLStorage) SetAndDeleteAtomically(ctx context.Context, lockedSets []SetValueCommand, lockedDeleteKeys []string, unlockedSets func() []SetValueUnlockedCommand, unlockedDeleteKeys func() []string, indexUpdates func() []IndexCommand) error {
+	allKeys := make([]string, 0, len(lockedDeleteKeys)+len(lockedSets))
+	allKeys = append(allKeys, lockedDeleteKeys...)
+	for _, set := range lockedSets {
+		allKeys = append(allKeys, set.key)
+	}
+
+	for i := 0; i < maxRetries; i++ {
+		// version 0 means the missing value, values are compared as well
+		// because a deleted and then recreated value starts from the first version again
+		watched := make([]int64, len(allKeys))
+		watchedBytes := make([][]byte, len(allKeys))
+		watchedFound := make([]bool, len(allKeys))
+		for j, key := range allKeys {
+			bytes, version, found, err := s.get(ctx, s.db, key)
+			if err != nil {
+				return err
+			}
+			watched[j], watchedBytes[j], watchedFound[j] = version, bytes, found
+		}
+
+		type write struct {
+			key    string
+			bytes  []byte
+			ttl    time.Duration
+			delete bool
+		}
+		var writes []write
+		for j, set := range lockedSets {
+			requiresUpdate, newVal, err := set.updater(func(m proto.Message) (bool, error) {
+				oldIndex := len(lockedDeleteKeys) + j
+				if !watchedFound[oldIndex] {
+					return false, nil
+				}
+				return true, unmarshalValue(s.codec, set.key, watchedBytes[oldIndex], m)
+			})
+			if err != nil {
+				return err
+			}
+			if !requiresUpdate {
+				continue
+			}
+			if newVal == nil {
+				writes = append(writes, write{key: set.key, delete: true})
+				continue
+			}
+			newValBytes, err := marshalValue(s.codec, set.key, newVal)
+			if err != nil {
+				return err
+			}
+			writes = append(writes, write{key: set.key, bytes: newValBytes, ttl: set.ttl})
+		}
+		for _, deleteKey := range lockedDeleteKeys {
+			writes = append(writes, write{key: deleteKey, delete: true})
+		}
+		for _, set := range unlockedSets() {
+			newValBytes, err := marshalValue(s.codec, set.key, set.newValue)
+			if err != nil {
+				return err
+			}
+			writes = append(writes, write{key: set.key, bytes: newValBytes, ttl: set.ttl})
+		}
+		for _, deleteKey := range unlockedDeleteKeys() {
+			writes = append(writes, write{key: deleteKey, delete: true})
+		}
+		var indexCmds []IndexCommand
+		if indexUpdates != nil {
+			indexCmds = indexUpdates()
+		}
+
+		committed, err := runInSQLTx(ctx, s.db, func(tx *sql.Tx) (bool, error) {
+			for j, key := range allKeys {
+				bytes, version, _, err := s.get(ctx, tx, key)
+				if err != nil {
+					return false, err
+				}
+				if version != watched[j] || string(bytes) != string(watchedBytes[j]) {
+					return false, nil
+				}
+			}
+			for _, w := range writes {
+				var err error
+				if w.delete {
+					_, err = tx.ExecContext(ctx, s.dialect.rebind("DELETE FROM kv_values WHERE k = ?"), w.key)
+				} else {
+					err = s.put(ctx, tx, w.key, w.bytes, w.ttl)
+				}
+				if err != nil {
+					return false, err
+				}
+			}
+			for _, cmd := range indexCmds {
+				var err error
+				if cmd.remove {
+					_, err = tx.ExecContext(ctx, s.dialect.rebind("DELETE FROM kv_indexes WHERE idx = ? AND member = ?"), cmd.index, cmd.member)
+				} else {
+					_, err = tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO kv_indexes (idx, member) VALUES (?, ?)
+						ON CONFLICT (idx, member) DO NOTHING`), cmd.index, cmd.member)
+				}
+				if err != nil {
+					return false, err
+				}
+			}
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+		if committed {
+			return nil
+		}
+		// Optimistic lock lost. Retry.
+	}
+
+	return &ConcurrentUpdateError{keys: allKeys}
+}
+
+func (s *SQLStorage) IndexRange(ctx context.Context, index string, after string, limit int) ([]string, error) {
+	query := "SELECT member FROM kv_indexes WHERE idx = ? AND member > ? ORDER BY member"
+	args := []any{index, after}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []string{}
+	for rows.Next() {
+		var member string
+		if err := rows.Scan(&member); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+func (s *SQLStorage) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	// LIKE isn't used, because it's case-insensitive in SQLite and requires escaping of the prefix
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT k FROM kv_values
+		WHERE substr(k, 1, length(?)) = ? AND (expires IS NULL OR expires > ?) ORDER BY k`), prefix, prefix, time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+type sqlQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// get returns the version of the expired value, so it's watched as the missing one
+func (s *SQLStorage) get(ctx context.Context, q sqlQueryer, k string) ([]byte, int64, bool, error) {
+	var bytes []byte
+	var version int64
+	var expires sql.NullInt64
+	err := q.QueryRowContext(ctx, s.dialect.rebind("SELECT v, version, expires FROM kv_values WHERE k = ?"), k).Scan(&bytes, &version, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if expires.Valid && expires.Int64 <= time.Now().UnixNano() {
+		return nil, version, false, nil
+	}
+	return bytes, version, true, nil
+}
+
+func (s *SQLStorage) put(ctx context.Context, q sqlQueryer, k string, v []byte, ttl time.Duration) error {
+	if v == nil {
+		// empty messages are marshalled to nil
+		v = []byte{}
+	}
+	var expires sql.NullInt64
+	if ttl > 0 {
+		expires = sql.NullInt64{Int64: time.Now().Add(ttl).UnixNano(), Valid: true}
+	}
+	_, err := q.ExecContext(ctx, s.dialect.rebind(`INSERT INTO kv_values (k, v, version, expires) VALUES (?, ?, 1, ?)
+		ON CONFLICT (k) DO UPDATE SET v = excluded.v, version = kv_values.version + 1, expires = excluded.expires`), k, v, expires)
+	return err
+}
+
+// runInSQLTx runs f in a transaction, the transaction is committed only if f returns true
+func runInSQLTx(ctx context.Context, sqlDB *sql.DB, f func(tx *sql.Tx) (bool, error)) (bool, error) {
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	commit, err := f(tx)
+	if err != nil || !commit {
+		tx.Rollback()
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,499 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var sqlClientsSchema = []string{
+	`CREATE TABLE IF NOT EXISTS clients (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		last_updated BIGINT NOT NULL,
+		revision BIGINT NOT NULL DEFAULT 0,
+		deleted BIGINT
+	)`,
+	`CREATE TABLE IF NOT EXISTS client_properties (
+		client_id TEXT NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (client_id, name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS client_keys (
+		client_key TEXT PRIMARY KEY,
+		client_id TEXT NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+		added BIGINT NOT NULL,
+		expires BIGINT
+	)`,
+	`CREATE INDEX IF NOT EXISTS client_keys_client_id ON client_keys (client_id)`,
+	`CREATE INDEX IF NOT EXISTS clients_name ON clients (name, id)`,
+	`CREATE INDEX IF NOT EXISTS clients_last_updated ON clients (last_updated, id)`,
+}
+
+var sqlClientsAddedColumns = []sqlAddedColumn{
+	{"revision", "BIGINT NOT NULL DEFAULT 0"},
+	// deleted is the time when the client has been moved to the trash, it's NULL for the active clients
+	{"deleted", "BIGINT"},
+}
+
+var sqlClientKeysAddedColumns = []sqlAddedColumn{
+	// expires is the time when the key is released, it's NULL for the keys without TTL
+	{"expires", "BIGINT"},
+}
+
+// SQLClientsRepository keeps clients, their properties and keys in separate tables.
+// The uniqueness of client keys is enforced by the primary key of the client_keys table.
+type SQLClientsRepository struct {
+	db      *sql.DB
+	dialect SQLDialect
+}
+
+func NewSQLClientsRepository(sqlDB *sql.DB, dialect SQLDialect) (*SQLClientsRepository, error) {
+	for _, statement := range append(sqlClientsSchema, sqlClientHistorySchema(dialect)) {
+		if _, err := sqlDB.Exec(statement); err != nil {
+			return nil, fmt.Errorf("can't create clients schema, %w", err)
+		}
+	}
+	if err := addSQLColumns(sqlDB, "clients", sqlClientsAddedColumns); err != nil {
+		return nil, err
+	}
+	if err := addSQLColumns(sqlDB, "client_keys", sqlClientKeysAddedColumns); err != nil {
+		return nil, err
+	}
+	return &SQLClientsRepository{db: sqlDB, dialect: dialect}, nil
+}
+
+func (r *SQLClientsRepository) GetAllClients(ctx context.Context) ([]*asit.Client, error) {
+	clients, _, err := r.GetClients(ctx, "", 0)
+	return clients, err
+}
+
+func (r *SQLClientsRepository) GetClients(ctx context.Context, cursor string, limit int) ([]*asit.Client, string, error) {
+	return r.QueryClients(ctx, ClientsQuery{Cursor: cursor, Limit: limit})
+}
+
+// sqlClientsSortColumns are the columns used by the sort orders of ClientsQuery, the id is always the last one
+var sqlClientsSortColumns = map[string]string{
+	CLIENTS_SORT_BY_NAME:         "name",
+	CLIENTS_SORT_BY_LAST_UPDATED: "last_updated",
+}
+
+func (r *SQLClientsRepository) QueryClients(ctx context.Context, query ClientsQuery) ([]*asit.Client, string, error) {
+	if err := query.validate(); err != nil {
+		return nil, "", err
+	}
+
+	sortBy := query.sortBy()
+	conditions := []string{"deleted IS NULL"}
+	args := []any{}
+	orderBy := "id"
+	if column, ok := sqlClientsSortColumns[sortBy]; ok {
+		orderBy = column + ", id"
+	}
+	if query.Cursor != "" {
+		value, id, _ := parseClientsCursor(sortBy, query.Cursor)
+		switch sortBy {
+		case CLIENTS_SORT_BY_ID:
+			conditions = append(conditions, "id > ?")
+			args = append(args, id)
+		default:
+			var cursorValue any = value
+			if sortBy == CLIENTS_SORT_BY_LAST_UPDATED {
+				cursorValue, _ = strconv.ParseInt(value, 10, 64)
+			}
+			column := sqlClientsSortColumns[sortBy]
+			conditions = append(conditions, "("+column+" > ? OR ("+column+" = ? AND id > ?))")
+			args = append(args, cursorValue, cursorValue, id)
+		}
+	}
+	if query.NamePrefix != "" {
+		// LIKE isn't used, because it's case-insensitive in SQLite and requires escaping of the prefix
+		conditions = append(conditions, "substr(name, 1, length(?)) = ?")
+		args = append(args, query.NamePrefix, query.NamePrefix)
+	}
+	for name, value := range query.Properties {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM client_properties p WHERE p.client_id = clients.id AND p.name = ? AND p.value = ?)")
+		args = append(args, name, value)
+	}
+
+	statement := "SELECT id, name, last_updated, revision FROM clients WHERE " + strings.Join(conditions, " AND ")
+	statement += " ORDER BY " + orderBy
+	if query.Limit > 0 {
+		statement += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind(statement), args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't retrieve clients, %w", err)
+	}
+	defer rows.Close()
+
+	clients := []*asit.Client{}
+	for rows.Next() {
+		var lastUpdated int64
+		client := &asit.Client{}
+		if err := rows.Scan(&client.Id, &client.Name, &lastUpdated, &client.Revision); err != nil {
+			return nil, "", fmt.Errorf("can't retrieve clients, %w", err)
+		}
+		client.LastUpdated = timestamppb.New(time.Unix(0, lastUpdated))
+		clients = append(clients, client)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("can't retrieve clients, %w", err)
+	}
+
+	nextCursor := ""
+	if query.Limit > 0 && len(clients) == query.Limit {
+		nextCursor = clientsCursor(sortBy, clients[len(clients)-1])
+	}
+	return clients, nextCursor, nil
+}
+
+func (r *SQLClientsRepository) GetClientById(ctx context.Context, id string) (*asit.Client, error) {
+	var client *asit.Client
+	// the read-only transaction is never committed, it just provides a consistent view of the tables
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		var err error
+		client, err = r.getClient(ctx, tx, id)
+		return false, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't retrieve client %s, %w", id, err)
+	}
+	return client, nil
+}
+
+func (r *SQLClientsRepository) SetClient(ctx context.Context, client *asit.Client) error {
+	return r.SetClientIfRevision(ctx, client, ANY_CLIENT_REVISION)
+}
+
+func (r *SQLClientsRepository) SetClientIfRevision(ctx context.Context, client *asit.Client, revision int64) error {
+	_, err := r.updateClient(ctx, client.Id, asit.ClientChangeType_CLIENT_UPDATED, func(current *asit.Client) (*asit.Client, error) {
+		if err := checkClientRevision(client.Id, current, revision); err != nil {
+			return nil, err
+		}
+		return client, nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't set client with Id %s, %w", client.Id, err)
+	}
+	return nil
+}
+
+func (r *SQLClientsRepository) PatchClient(ctx context.Context, clientId string, patch ClientPatch, revision int64) (*asit.Client, error) {
+	client, err := r.updateClient(ctx, clientId, asit.ClientChangeType_CLIENT_UPDATED, func(current *asit.Client) (*asit.Client, error) {
+		if err := checkClientRevision(clientId, current, revision); err != nil {
+			return nil, err
+		}
+		if current == nil {
+			return nil, &NotFoundClientByIdError{id: clientId}
+		}
+		patch.apply(current)
+		return current, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't patch client with Id %s, %w", clientId, err)
+	}
+	return client, nil
+}
+
+// updateClient replaces the client with the value returned by the update function in a single transaction.
+// The update function is called with the current value of the client (nil if it's missing), it may change the passed value.
+// The change is recorded in the client history, CLIENT_UPDATED of the missing client is recorded as CLIENT_CREATED.
+func (r *SQLClientsRepository) updateClient(ctx context.Context, clientId string, change asit.ClientChangeType,
+	update func(current *asit.Client) (*asit.Client, error)) (*asit.Client, error) {
+	var client *asit.Client
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		current, err := r.getClient(ctx, tx, clientId)
+		if err != nil {
+			return false, err
+		}
+		exists := current != nil
+		if !exists {
+			// the trashed client can't be created again until it's purged
+			trashed, err := r.getTrashedClient(ctx, tx, clientId)
+			if err != nil {
+				return false, err
+			}
+			if trashed != nil {
+				return false, &ClientIdConflictError{id: clientId}
+			}
+		}
+		entry := newClientHistoryEntry(ctx, change)
+		if exists {
+			entry.OldClient = proto.Clone(current).(*asit.Client)
+		} else if change == asit.ClientChangeType_CLIENT_UPDATED {
+			entry.Change = asit.ClientChangeType_CLIENT_CREATED
+		}
+		currentRevision := current.GetRevision()
+		client, err = update(current)
+		if err != nil {
+			return false, err
+		}
+		client.LastUpdated = timestamppb.New(time.Now())
+
+		if !exists {
+			client.Revision = 1
+			_, err = tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO clients (id, name, last_updated, revision) VALUES (?, ?, ?, ?)"),
+				clientId, client.Name, client.LastUpdated.AsTime().UnixNano(), client.Revision)
+			if err != nil {
+				return false, err
+			}
+		} else {
+			client.Revision = currentRevision + 1
+			// the revision condition protects from the concurrent updates if the transactions aren't serializable
+			result, err := tx.ExecContext(ctx, r.dialect.rebind("UPDATE clients SET name = ?, last_updated = ?, revision = ? WHERE id = ? AND revision = ?"),
+				client.Name, client.LastUpdated.AsTime().UnixNano(), client.Revision, clientId, currentRevision)
+			if err != nil {
+				return false, err
+			}
+			if updated, err := result.RowsAffected(); err != nil || updated == 0 {
+				return false, &ConcurrentUpdateError{keys: []string{clientId}}
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM client_properties WHERE client_id = ?"), clientId)
+		if err != nil {
+			return false, err
+		}
+		for name, value := range client.ClientProperties {
+			_, err = tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO client_properties (client_id, name, value) VALUES (?, ?, ?)"),
+				clientId, name, value)
+			if err != nil {
+				return false, err
+			}
+		}
+		entry.NewClient = client
+		return true, r.appendHistory(ctx, tx, clientId, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// getRevision returns the client with only id and revision set or nil if the client doesn't exist
+func (r *SQLClientsRepository) getRevision(ctx context.Context, q sqlQueryer, id string) (*asit.Client, error) {
+	client := &asit.Client{Id: id}
+	err := q.QueryRowContext(ctx, r.dialect.rebind("SELECT revision FROM clients WHERE id = ? AND deleted IS NULL"), id).Scan(&client.Revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (r *SQLClientsRepository) GetClientKeys(ctx context.Context, clientId string) (*asit.ClientKeys, error) {
+	keys, expires, err := r.selectKeys(ctx, r.db, `SELECT k.client_key, k.expires FROM client_keys k JOIN clients c ON c.id = k.client_id
+		WHERE k.client_id = ? AND c.deleted IS NULL AND (k.expires IS NULL OR k.expires > ?) ORDER BY k.added, k.client_key`,
+		clientId, time.Now().UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("can't retrieve keys of client %s, %w", clientId, err)
+	}
+	if keys == nil {
+		return nil, nil
+	}
+	return &asit.ClientKeys{Keys: keys, Expires: expires}, nil
+}
+
+func (r *SQLClientsRepository) AddClientKey(ctx context.Context, clientId string, key string) error {
+	return r.AddClientKeyWithTTL(ctx, clientId, key, 0)
+}
+
+// AddClientKeyWithTTL releases the expired key before it's added, so the key can be associated with another client
+// before ExpireClientKeys removes it
+func (r *SQLClientsRepository) AddClientKeyWithTTL(ctx context.Context, clientId string, key string, ttl time.Duration) error {
+	now := time.Now()
+	var expires sql.NullInt64
+	if ttl > 0 {
+		expires = sql.NullInt64{Int64: now.Add(ttl).UnixNano(), Valid: true}
+	}
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		var exists int
+		err := tx.QueryRowContext(ctx, r.dialect.rebind("SELECT 1 FROM clients WHERE id = ? AND deleted IS NULL"), clientId).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, &NotFoundClientByIdError{id: clientId}
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if _, err := r.expireClientKeys(ctx, tx, now, "k.client_key = ?", key); err != nil {
+			return false, err
+		}
+		result, err := tx.ExecContext(ctx, r.dialect.rebind(`INSERT INTO client_keys (client_key, client_id, added, expires) VALUES (?, ?, ?, ?)
+			ON CONFLICT (client_key) DO NOTHING`), key, clientId, now.UnixNano(), expires)
+		if err != nil {
+			return false, err
+		}
+		added, err := result.RowsAffected()
+		if err != nil {
+			return false, err
+		}
+		// the key is either just added or has already been associated with some client
+		var ownerId string
+		err = tx.QueryRowContext(ctx, r.dialect.rebind("SELECT client_id FROM client_keys WHERE client_key = ?"), key).Scan(&ownerId)
+		if err != nil {
+			return false, err
+		}
+		if ownerId != clientId {
+			return false, &NonUniqueClientKeyError{key: key}
+		}
+		if added == 0 {
+			// the key is already associated with the client, only its expiration time is replaced
+			_, err := tx.ExecContext(ctx, r.dialect.rebind("UPDATE client_keys SET expires = ? WHERE client_key = ?"), expires, key)
+			return err == nil, err
+		}
+		entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_KEY_ADDED)
+		entry.Key = key
+		entry.NewKeys, _, err = r.getKeys(ctx, tx, clientId)
+		if err != nil {
+			return false, err
+		}
+		for _, k := range entry.NewKeys {
+			if k != key {
+				entry.OldKeys = append(entry.OldKeys, k)
+			}
+		}
+		return true, r.appendHistory(ctx, tx, clientId, entry)
+	})
+	if err != nil {
+		return fmt.Errorf("can't add client key %s, %w", key, err)
+	}
+	return nil
+}
+
+func (r *SQLClientsRepository) GetClientByKey(ctx context.Context, key string) (*asit.Client, error) {
+	var client *asit.Client
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		var clientId string
+		err := tx.QueryRowContext(ctx, r.dialect.rebind("SELECT client_id FROM client_keys WHERE client_key = ? AND (expires IS NULL OR expires > ?)"),
+			key, time.Now().UnixNano()).Scan(&clientId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		client, err = r.getClient(ctx, tx, clientId)
+		return false, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't retrieve client by key %s, %w", key, err)
+	}
+	return client, nil
+}
+
+func (r *SQLClientsRepository) RemoveClientKey(ctx context.Context, key string) error {
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		var clientId string
+		// keys of the trashed clients stay reserved
+		err := tx.QueryRowContext(ctx, r.dialect.rebind(`SELECT k.client_id FROM client_keys k JOIN clients c ON c.id = k.client_id
+			WHERE k.client_key = ? AND c.deleted IS NULL AND (k.expires IS NULL OR k.expires > ?)`), key, time.Now().UnixNano()).Scan(&clientId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_KEY_REMOVED)
+		entry.Key = key
+		entry.OldKeys, _, err = r.getKeys(ctx, tx, clientId)
+		if err != nil {
+			return false, err
+		}
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM client_keys WHERE client_key = ?"), key); err != nil {
+			return false, err
+		}
+		for _, k := range entry.OldKeys {
+			if k != key {
+				entry.NewKeys = append(entry.NewKeys, k)
+			}
+		}
+		return true, r.appendHistory(ctx, tx, clientId, entry)
+	})
+	if err != nil {
+		return fmt.Errorf("can't delete client key %s, %w", key, err)
+	}
+	return nil
+}
+
+// getKeys returns the active keys of the client in the order of their addition with the expiration times of the keys with TTL
+func (r *SQLClientsRepository) getKeys(ctx context.Context, q sqlQueryer, id string) ([]string, map[string]*timestamppb.Timestamp, error) {
+	return r.selectKeys(ctx, q, "SELECT client_key, expires FROM client_keys WHERE client_id = ? AND (expires IS NULL OR expires > ?) ORDER BY added, client_key",
+		id, time.Now().UnixNano())
+}
+
+// selectKeys returns the keys and the expiration times selected by the query
+func (r *SQLClientsRepository) selectKeys(ctx context.Context, q sqlQueryer, query string, args ...any) ([]string, map[string]*timestamppb.Timestamp, error) {
+	rows, err := q.QueryContext(ctx, r.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var keys []string
+	var expires map[string]*timestamppb.Timestamp
+	for rows.Next() {
+		var key string
+		var keyExpires sql.NullInt64
+		if err := rows.Scan(&key, &keyExpires); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+		if keyExpires.Valid {
+			if expires == nil {
+				expires = map[string]*timestamppb.Timestamp{}
+			}
+			expires[key] = timestamppb.New(time.Unix(0, keyExpires.Int64))
+		}
+	}
+	return keys, expires, rows.Err()
+}
+
+// getClient returns the active client with its properties or nil if there is no active client with the id
+func (r *SQLClientsRepository) getClient(ctx context.Context, q sqlQueryer, id string) (*asit.Client, error) {
+	return r.selectClient(ctx, q, "SELECT id, name, last_updated, revision FROM clients WHERE id = ? AND deleted IS NULL", id)
+}
+
+func (r *SQLClientsRepository) selectClient(ctx context.Context, q sqlQueryer, query string, id string) (*asit.Client, error) {
+	var lastUpdated int64
+	client := &asit.Client{}
+	err := q.QueryRowContext(ctx, r.dialect.rebind(query), id).
+		Scan(&client.Id, &client.Name, &lastUpdated, &client.Revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	client.LastUpdated = timestamppb.New(time.Unix(0, lastUpdated))
+
+	rows, err := q.QueryContext(ctx, r.dialect.rebind("SELECT name, value FROM client_properties WHERE client_id = ?"), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		if client.ClientProperties == nil {
+			client.ClientProperties = map[string]string{}
+		}
+		client.ClientProperties[name] = value
+	}
+	return client, rows.Err()
+}
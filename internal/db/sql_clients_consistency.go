@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+)
+
+// sqlOrphanedRows are the rows of the client children tables which could outlive their clients
+// when the foreign keys aren't enforced by the database
+var sqlOrphanedRows = []struct {
+	table         string
+	inconsistency asit.ClientsInconsistencyType
+}{
+	{"client_keys", asit.ClientsInconsistencyType_ORPHANED_CLIENT_KEYS},
+	{"client_properties", asit.ClientsInconsistencyType_ORPHANED_CLIENT_PROPERTIES},
+}
+
+// CheckConsistency reports the rows of the missing clients. The uniqueness of the keys and the indexes
+// are maintained by the database, so the rows of the children tables are the only values which could drift apart.
+func (r *SQLClientsRepository) CheckConsistency(ctx context.Context, repair bool) (*asit.ClientsConsistencyReport, error) {
+	var report *asit.ClientsConsistencyReport
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		report = &asit.ClientsConsistencyReport{}
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM clients").Scan(&report.CheckedClients); err != nil {
+			return false, err
+		}
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM client_keys").Scan(&report.CheckedKeys); err != nil {
+			return false, err
+		}
+		for _, orphaned := range sqlOrphanedRows {
+			ids, err := r.selectIds(ctx, tx, "SELECT DISTINCT client_id FROM "+orphaned.table+
+				" t WHERE NOT EXISTS (SELECT 1 FROM clients c WHERE c.id = t.client_id) ORDER BY client_id")
+			if err != nil {
+				return false, err
+			}
+			for _, id := range ids {
+				report.Inconsistencies = append(report.Inconsistencies, &asit.ClientsInconsistency{
+					Type:        orphaned.inconsistency,
+					ClientId:    id,
+					Description: "the client is missing, but it has rows in the " + orphaned.table + " table",
+					Repaired:    repair,
+				})
+				if !repair {
+					continue
+				}
+				if _, err := tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM "+orphaned.table+" WHERE client_id = ?"), id); err != nil {
+					return false, err
+				}
+			}
+		}
+		return repair, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't check clients consistency, %w", err)
+	}
+	return report, nil
+}
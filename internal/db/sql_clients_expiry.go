@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+)
+
+// ExpireClientKeys deletes the expired rows of the client_keys table. The expired keys are already filtered out
+// by the queries, so it only cleans up the table and records the expirations in the client history.
+// The expired keys of the trashed clients are deleted when the keys are added again or the clients are purged.
+func (r *SQLClientsRepository) ExpireClientKeys(ctx context.Context, now time.Time) (int, error) {
+	expired := 0
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		var err error
+		expired, err = r.expireClientKeys(ctx, tx, now, "EXISTS (SELECT 1 FROM clients c WHERE c.id = k.client_id AND c.deleted IS NULL)")
+		return true, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("can't expire client keys, %w", err)
+	}
+	return expired, nil
+}
+
+// expireClientKeys deletes the keys expired before now and matching the condition on the client_keys k table
+func (r *SQLClientsRepository) expireClientKeys(ctx context.Context, tx *sql.Tx, now time.Time, condition string, args ...any) (int, error) {
+	rows, err := tx.QueryContext(ctx, r.dialect.rebind("SELECT k.client_id, k.client_key FROM client_keys k WHERE k.expires <= ? AND "+condition),
+		append([]any{now.UnixNano()}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	type clientKey struct {
+		clientId string
+		key      string
+	}
+	var expired []clientKey
+	for rows.Next() {
+		var k clientKey
+		if err := rows.Scan(&k.clientId, &k.key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, k := range expired {
+		entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_KEY_EXPIRED)
+		entry.Key = k.key
+		// the old keys are the keys active right before the expiration
+		entry.OldKeys, _, err = r.selectKeys(ctx, tx, `SELECT client_key, expires FROM client_keys
+			WHERE client_id = ? AND (expires IS NULL OR expires > ? OR client_key = ?) ORDER BY added, client_key`,
+			k.clientId, now.UnixNano(), k.key)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM client_keys WHERE client_key = ?"), k.key); err != nil {
+			return 0, err
+		}
+		entry.NewKeys = withoutClientKey(entry.OldKeys, k.key)
+		if err := r.appendHistory(ctx, tx, k.clientId, entry); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/proto"
+)
+
+// sqlClientHistorySchema returns the history table, it has no foreign key because the history is kept after the client is purged
+func sqlClientHistorySchema(dialect SQLDialect) string {
+	return `CREATE TABLE IF NOT EXISTS client_history (
+		client_id TEXT NOT NULL,
+		sequence BIGINT NOT NULL,
+		entry ` + dialect.BlobType + ` NOT NULL,
+		PRIMARY KEY (client_id, sequence)
+	)`
+}
+
+// appendHistory appends the entry to the client history in the transaction of the recorded change.
+// The concurrent appends conflict on the primary key, so one of the transactions fails.
+func (r *SQLClientsRepository) appendHistory(ctx context.Context, tx *sql.Tx, clientId string, entry *asit.ClientHistoryEntry) error {
+	err := tx.QueryRowContext(ctx, r.dialect.rebind("SELECT COALESCE(MAX(sequence), 0) + 1 FROM client_history WHERE client_id = ?"), clientId).
+		Scan(&entry.Sequence)
+	if err != nil {
+		return err
+	}
+	bytes, err := proto.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO client_history (client_id, sequence, entry) VALUES (?, ?, ?)"),
+		clientId, entry.Sequence, bytes)
+	return err
+}
+
+func (r *SQLClientsRepository) GetClientHistory(ctx context.Context, clientId string, cursor string, limit int) ([]*asit.ClientHistoryEntry, string, error) {
+	after, err := parseClientHistoryCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	statement := "SELECT entry FROM client_history WHERE client_id = ? AND sequence > ? ORDER BY sequence"
+	args := []any{clientId, after}
+	if limit > 0 {
+		// the extra entry shows if there is the next page
+		statement += " LIMIT ?"
+		args = append(args, limit+1)
+	}
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind(statement), args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't retrieve history of client %s, %w", clientId, err)
+	}
+	defer rows.Close()
+
+	entries := []*asit.ClientHistoryEntry{}
+	for rows.Next() {
+		var bytes []byte
+		if err := rows.Scan(&bytes); err != nil {
+			return nil, "", fmt.Errorf("can't retrieve history of client %s, %w", clientId, err)
+		}
+		entry := &asit.ClientHistoryEntry{}
+		if err := proto.Unmarshal(bytes, entry); err != nil {
+			return nil, "", fmt.Errorf("can't decode history of client %s, %w", clientId, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("can't retrieve history of client %s, %w", clientId, err)
+	}
+
+	nextCursor := ""
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+		nextCursor = strconv.FormatInt(entries[limit-1].Sequence, 10)
+	}
+	return entries, nextCursor, nil
+}
+
+func (r *SQLClientsRepository) GetClientRevision(ctx context.Context, clientId string, revision int64) (*asit.Client, error) {
+	return clientAtRevision(ctx, r, clientId, revision)
+}
+
+func (r *SQLClientsRepository) RollbackClient(ctx context.Context, clientId string, revision int64, ifRevision int64) (*asit.Client, error) {
+	target, err := r.GetClientRevision(ctx, clientId, revision)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, &NotFoundClientRevisionError{id: clientId, revision: revision}
+	}
+	client, err := r.updateClient(ctx, clientId, asit.ClientChangeType_CLIENT_ROLLED_BACK, rollbackUpdate(clientId, target, ifRevision))
+	if err != nil {
+		return nil, fmt.Errorf("can't roll back client with Id %s, %w", clientId, err)
+	}
+	return client, nil
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// RemoveClient moves the client to the trash. The trashed client keeps its rows in the client_keys table,
+// so its keys can't be associated with other clients until it's purged or the keys expire.
+func (r *SQLClientsRepository) RemoveClient(ctx context.Context, clientId string) error {
+	return r.RemoveClientIfRevision(ctx, clientId, ANY_CLIENT_REVISION)
+}
+
+func (r *SQLClientsRepository) RemoveClientIfRevision(ctx context.Context, clientId string, revision int64) error {
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		current, err := r.getRevision(ctx, tx, clientId)
+		if err != nil {
+			return false, err
+		}
+		if err := checkClientRevision(clientId, current, revision); err != nil {
+			return false, err
+		}
+		if current == nil {
+			// nothing to remove
+			return false, nil
+		}
+		_, err = tx.ExecContext(ctx, r.dialect.rebind("UPDATE clients SET deleted = ? WHERE id = ? AND deleted IS NULL"),
+			time.Now().UnixNano(), clientId)
+		if err != nil {
+			return false, err
+		}
+		trashed, err := r.getTrashedClient(ctx, tx, clientId)
+		if err != nil {
+			return false, err
+		}
+		entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_DELETED)
+		entry.OldClient, entry.OldKeys = trashed.Client, trashed.Keys
+		return true, r.appendHistory(ctx, tx, clientId, entry)
+	})
+	if err != nil {
+		return fmt.Errorf("can't delete client %s, %w", clientId, err)
+	}
+	return nil
+}
+
+func (r *SQLClientsRepository) GetTrashedClients(ctx context.Context, cursor string, limit int) ([]*asit.TrashedClient, string, error) {
+	statement := "SELECT id FROM clients WHERE deleted IS NOT NULL AND id > ? ORDER BY id"
+	args := []any{cursor}
+	if limit > 0 {
+		statement += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	var clients []*asit.TrashedClient
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		clients = nil
+		ids, err := r.selectIds(ctx, tx, statement, args...)
+		if err != nil {
+			return false, err
+		}
+		for _, id := range ids {
+			trashed, err := r.getTrashedClient(ctx, tx, id)
+			if err != nil {
+				return false, err
+			}
+			clients = append(clients, trashed)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("can't retrieve trashed clients, %w", err)
+	}
+
+	nextCursor := ""
+	if limit > 0 && len(clients) == limit {
+		nextCursor = clients[len(clients)-1].Client.Id
+	}
+	return clients, nextCursor, nil
+}
+
+func (r *SQLClientsRepository) GetTrashedClientById(ctx context.Context, id string) (*asit.TrashedClient, error) {
+	var trashed *asit.TrashedClient
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		var err error
+		trashed, err = r.getTrashedClient(ctx, tx, id)
+		return false, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't retrieve trashed client %s, %w", id, err)
+	}
+	return trashed, nil
+}
+
+// RestoreClient moves the client from the trash back, its keys are associated with it again
+// because they have never been released
+func (r *SQLClientsRepository) RestoreClient(ctx context.Context, clientId string) (*asit.Client, error) {
+	var client *asit.Client
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		res, err := tx.ExecContext(ctx, r.dialect.rebind(`UPDATE clients SET deleted = NULL, revision = revision + 1, last_updated = ?
+			WHERE id = ? AND deleted IS NOT NULL`), time.Now().UnixNano(), clientId)
+		if err != nil {
+			return false, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return false, err
+		}
+		if affected == 0 {
+			return false, &NotFoundClientByIdError{id: clientId}
+		}
+		client, err = r.getClient(ctx, tx, clientId)
+		if err != nil {
+			return false, err
+		}
+		entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_RESTORED)
+		entry.NewClient = client
+		entry.NewKeys, _, err = r.getKeys(ctx, tx, clientId)
+		if err != nil {
+			return false, err
+		}
+		return true, r.appendHistory(ctx, tx, clientId, entry)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't restore client with Id %s, %w", clientId, err)
+	}
+	return client, nil
+}
+
+// PurgeClient permanently removes the trashed client and releases its keys
+func (r *SQLClientsRepository) PurgeClient(ctx context.Context, clientId string) error {
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		return true, r.purgeClient(ctx, tx, clientId)
+	})
+	if err != nil {
+		return fmt.Errorf("can't purge client with Id %s, %w", clientId, err)
+	}
+	return nil
+}
+
+// PurgeTrash purges the clients trashed before the specified time
+func (r *SQLClientsRepository) PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, error) {
+	purged := 0
+	_, err := runInSQLTx(ctx, r.db, func(tx *sql.Tx) (bool, error) {
+		ids, err := r.selectIds(ctx, tx, "SELECT id FROM clients WHERE deleted < ?", deletedBefore.UnixNano())
+		if err != nil {
+			return false, err
+		}
+		for _, id := range ids {
+			if err := r.purgeClient(ctx, tx, id); err != nil {
+				return false, err
+			}
+		}
+		purged = len(ids)
+		return true, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("can't purge trashed clients, %w", err)
+	}
+	return purged, nil
+}
+
+// getTrashedClient returns the trashed client with its keys or nil if there is no trashed client with the id
+func (r *SQLClientsRepository) getTrashedClient(ctx context.Context, q sqlQueryer, id string) (*asit.TrashedClient, error) {
+	var deleted int64
+	err := q.QueryRowContext(ctx, r.dialect.rebind("SELECT deleted FROM clients WHERE id = ? AND deleted IS NOT NULL"), id).Scan(&deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	client, err := r.selectClient(ctx, q, "SELECT id, name, last_updated, revision FROM clients WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	keys, expires, err := r.getKeys(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+	return &asit.TrashedClient{Client: client, Keys: keys, Deleted: timestamppb.New(time.Unix(0, deleted)), KeysExpires: expires}, nil
+}
+
+// purgeClient deletes the trashed client with its keys and properties, the history of the client is kept
+func (r *SQLClientsRepository) purgeClient(ctx context.Context, tx *sql.Tx, clientId string) error {
+	trashed, err := r.getTrashedClient(ctx, tx, clientId)
+	if err != nil {
+		return err
+	}
+	if trashed == nil {
+		return &NotFoundClientByIdError{id: clientId}
+	}
+	// children are deleted explicitly to not depend on the foreign keys support settings
+	for _, query := range []string{
+		"DELETE FROM client_keys WHERE client_id = ?",
+		"DELETE FROM client_properties WHERE client_id = ?",
+		"DELETE FROM clients WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind(query), clientId); err != nil {
+			return err
+		}
+	}
+	entry := newClientHistoryEntry(ctx, asit.ClientChangeType_CLIENT_PURGED)
+	entry.OldClient, entry.OldKeys = trashed.Client, trashed.Keys
+	return r.appendHistory(ctx, tx, clientId, entry)
+}
+
+// selectIds returns the values of the single string column selected by the query
+func (r *SQLClientsRepository) selectIds(ctx context.Context, q sqlQueryer, query string, args ...any) ([]string, error) {
+	rows, err := q.QueryContext(ctx, r.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -3,8 +3,11 @@ package db
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v9"
+	"golang.org/x/exp/slices"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -13,14 +16,50 @@ const maxRetries = 10
 // Storage is an abstraction for different key-value store implementations.
 // A store must be able to store, retrieve and delete key-value pairs,
 // with the key being a string and the value being any Go interface{}.
+// The ttl of the commands is the time to live of the written value, the value never expires if the ttl is 0.
 type SetValueCommand struct {
 	key     string
 	updater func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error)
+	ttl     time.Duration
 }
 
 type SetValueUnlockedCommand struct {
 	key      string
 	newValue proto.Message
+	ttl      time.Duration
+}
+
+func NewSetValueCommand(key string, updater func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error)) SetValueCommand {
+	return SetValueCommand{key: key, updater: updater}
+}
+
+func NewSetValueCommandWithTTL(key string, ttl time.Duration, updater func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error)) SetValueCommand {
+	return SetValueCommand{key: key, updater: updater, ttl: ttl}
+}
+
+func NewSetValueUnlockedCommand(key string, newValue proto.Message) SetValueUnlockedCommand {
+	return SetValueUnlockedCommand{key: key, newValue: newValue}
+}
+
+func NewSetValueUnlockedCommandWithTTL(key string, newValue proto.Message, ttl time.Duration) SetValueUnlockedCommand {
+	return SetValueUnlockedCommand{key: key, newValue: newValue, ttl: ttl}
+}
+
+// IndexCommand adds the member to the sorted index or removes it from the index.
+// Indexes are sets of strings ordered lexicographically, they allow to iterate
+// over big collections without reading and rewriting them as a single value.
+type IndexCommand struct {
+	index  string
+	member string
+	remove bool
+}
+
+func NewIndexAddCommand(index string, member string) IndexCommand {
+	return IndexCommand{index: index, member: member}
+}
+
+func NewIndexRemoveCommand(index string, member string) IndexCommand {
+	return IndexCommand{index: index, member: member, remove: true}
 }
 
 type Storage interface {
@@ -28,13 +67,29 @@ type Storage interface {
 	// The implementation automatically marshalls the value.
 	// The marshalling format depends on the implementation. It can be JSON, gob etc.
 	// The key must not be "" and the value must not be nil.
+	// The value never expires, the TTL of the previous value is discarded.
 	Set(ctx context.Context, k string, v proto.Message) error
 
+	// SetWithTTL stores the value which expires after the ttl, the ttl <= 0 means no expiry.
+	// The expired values are missing for Get and for the updaters of SetAndDeleteAtomically.
+	SetWithTTL(ctx context.Context, k string, v proto.Message, ttl time.Duration) error
+
 	// Updates multiple values specified by SetValueCommand, deleteKeys and afterRemovalKeysSupplier atomically
 	// Deletes keys sepcified in deleteKeys
 	// If keys used lockedSets and lockedDeleteKeys not changed during update, rocesses all commands provided by the unlockedSets func
 	// Not that unlockedSets will be processed even if values with the same keys changed/set/removed during the lockedSets, lockedDeleteKeys processing
-	SetAndDeleteAtomically(ctx context.Context, lockedSets []SetValueCommand, lockedDeleteKeys []string, unlockedSets func() []SetValueUnlockedCommand, unlockedDeleteKeys func() []string) error
+	// Index commands provided by the indexUpdates func are applied in the same transaction as unlocked ones, indexUpdates could be nil
+	// Values are written with the TTLs of their commands, every write discards the TTL of the previous value
+	SetAndDeleteAtomically(ctx context.Context, lockedSets []SetValueCommand, lockedDeleteKeys []string, unlockedSets func() []SetValueUnlockedCommand, unlockedDeleteKeys func() []string, indexUpdates func() []IndexCommand) error
+
+	// IndexRange returns up to limit members of the index which are greater than after, in lexicographical order.
+	// The empty after starts from the first member. Missing index is the same as the empty one.
+	// Indexes don't share names with values, so Get and Delete don't affect them.
+	IndexRange(ctx context.Context, index string, after string, limit int) ([]string, error)
+
+	// ScanKeys returns the keys of all the values starting with the prefix in lexicographical order, the expired values are skipped.
+	// It's intended for the maintenance tasks like consistency checks, the values may be changed concurrently after the scan.
+	ScanKeys(ctx context.Context, prefix string) ([]string, error)
 
 	// Get retrieves the value for the given key.
 	// The implementation automatically unmarshalls the value.
@@ -68,31 +123,117 @@ type Storage interface {
 	Close() error
 }
 
+// ConcurrentUpdateError is returned by SetAndDeleteAtomically when the keys
+// have been changed concurrently more than maxRetries times in a row.
+type ConcurrentUpdateError struct {
+	keys []string
+}
+
+func (e *ConcurrentUpdateError) Error() string {
+	return fmt.Sprintf("concurrent updates of the keys %v", e.keys)
+}
+
 type StorageCodec interface {
 	Marshal(v proto.Message) ([]byte, error)
 	Unmarshal(data []byte, v proto.Message) error
 }
 
+// KeyBoundCodec binds the values to their storage keys, e.g. by authenticating the keys with the encrypted values,
+// so a value copied to another key can't be read. The keys are passed without the storage prefixes, e.g. the Redis
+// namespace, so the values can be copied between the namespaces.
+type KeyBoundCodec interface {
+	StorageCodec
+	MarshalKey(key string, v proto.Message) ([]byte, error)
+	UnmarshalKey(key string, data []byte, v proto.Message) error
+}
+
+// marshalValue encodes the value of the key, it's bound to the key if the codec is KeyBoundCodec
+func marshalValue(codec StorageCodec, key string, v proto.Message) ([]byte, error) {
+	if keyBound, ok := codec.(KeyBoundCodec); ok {
+		return keyBound.MarshalKey(key, v)
+	}
+	return codec.Marshal(v)
+}
+
+// unmarshalValue decodes the value of the key, it's checked to be bound to the key if the codec is KeyBoundCodec
+func unmarshalValue(codec StorageCodec, key string, data []byte, v proto.Message) error {
+	if keyBound, ok := codec.(KeyBoundCodec); ok {
+		return keyBound.UnmarshalKey(key, data, v)
+	}
+	return codec.Unmarshal(data, v)
+}
+
 type RedisStorage struct {
-	client redis.UniversalClient
-	codec  StorageCodec
+	client    redis.UniversalClient
+	codec     StorageCodec
+	keyPrefix string
+	// atomicUpdates is RedisAtomicUpdates, it's changed to the WATCH updates when the scripts are rejected by Redis
+	atomicUpdates int32
+	// seenValues are the old values of the scripted updates
+	seenValues *redisSeenValues
 }
 
 func NewRedisStorage(client redis.UniversalClient, codec StorageCodec) *RedisStorage {
-	return &RedisStorage{client: client, codec: codec}
+	return NewRedisStorageWithNamespace(client, codec, "", "")
+}
+
+// NewRedisStorageWithHashTag creates the storage which puts all the keys in the same Redis Cluster slot
+// by prefixing them with the {hashTag} hash tag.
+// It's required for the Redis Cluster, because SetAndDeleteAtomically watches and updates
+// several keys in one transaction, e.g. client:<id>, client_keys:<id> and client_key:<key>.
+// The single slot keeps the uniqueness of client keys checked inside the same transaction.
+func NewRedisStorageWithHashTag(client redis.UniversalClient, codec StorageCodec, hashTag string) *RedisStorage {
+	return NewRedisStorageWithNamespace(client, codec, hashTag, "")
+}
+
+// NewRedisStorageWithNamespace creates the storage which prefixes all the keys with the namespace after the hash tag,
+// so several ASIT instances could share the same Redis. The namespace must be checked by ValidateRedisNamespace.
+func NewRedisStorageWithNamespace(client redis.UniversalClient, codec StorageCodec, hashTag string, namespace string) *RedisStorage {
+	return &RedisStorage{client: client, codec: codec, keyPrefix: RedisKeyPrefix(hashTag, namespace), seenValues: newRedisSeenValues()}
+}
+
+func RedisHashTagPrefix(hashTag string) string {
+	if hashTag == "" {
+		return ""
+	}
+	return "{" + hashTag + "}:"
+}
+
+func (s *RedisStorage) key(k string) string {
+	return s.keyPrefix + k
+}
+
+// redisIndexPrefix separates sorted sets of indexes from the values
+const redisIndexPrefix = "index:"
+
+func (s *RedisStorage) indexKey(index string) string {
+	return s.keyPrefix + redisIndexPrefix + index
 }
 
 func (s *RedisStorage) Set(ctx context.Context, k string, v proto.Message) error {
-	bytes, err := s.codec.Marshal(v)
+	return s.SetWithTTL(ctx, k, v, 0)
+}
+
+// SetWithTTL relies on the Redis key expiration
+func (s *RedisStorage) SetWithTTL(ctx context.Context, k string, v proto.Message, ttl time.Duration) error {
+	bytes, err := marshalValue(s.codec, k, v)
 	if err != nil {
 		return err
 	}
-	s.client.Set(ctx, k, bytes, 0)
-	return nil
+	return s.client.Set(ctx, s.key(k), bytes, redisTTL(ttl)).Err()
+}
+
+// redisTTL converts the ttl to the go-redis expiration. The ttl <= 0 means no expiry, like in the Storage contract,
+// but go-redis keeps the current TTL of the key for the negative expirations (KEEPTTL), so they are mapped to 0.
+func redisTTL(ttl time.Duration) time.Duration {
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
 }
 
 func (s *RedisStorage) Get(ctx context.Context, k string, v proto.Message) (found bool, err error) {
-	bytes, err := s.client.Get(ctx, k).Bytes()
+	bytes, err := s.client.Get(ctx, s.key(k)).Bytes()
 	if err == redis.Nil {
 		err = nil
 		bytes = nil
@@ -104,34 +245,93 @@ func (s *RedisStorage) Get(ctx context.Context, k string, v proto.Message) (foun
 		return false, nil
 	}
 
-	err = s.codec.Unmarshal(bytes, v)
+	err = unmarshalValue(s.codec, k, bytes, v)
 	return true, err
 }
 
 func (s *RedisStorage) Delete(ctx context.Context, k ...string) error {
-	return s.client.Del(ctx, k...).Err()
+	keys := make([]string, len(k))
+	for i, key := range k {
+		keys[i] = s.key(key)
+	}
+	return s.client.Del(ctx, keys...).Err()
 }
 
 func (s *RedisStorage) Close() error {
 	return s.client.Close()
 }
 
-func (s *RedisStorage) SetAndDeleteAtomically(ctx context.Context, lockedSets []SetValueCommand, lockedDeleteKeys []string, unlockedSets func() []SetValueUnlockedCommand, unlockedDeleteKeys func() []string) error {
+// SetAndDeleteAtomically checks that the locked keys aren't changed concurrently by the Lua script or by WATCH,
+// see RedisAtomicUpdates. The conflicting updates are retried with the randomized exponential backoff.
+func (s *RedisStorage) SetAndDeleteAtomically(ctx context.Context, lockedSets []SetValueCommand, lockedDeleteKeys []string, unlockedSets func() []SetValueUnlockedCommand, unlockedDeleteKeys func() []string, indexUpdates func() []IndexCommand) error {
 	deleteKeysCount := len(lockedDeleteKeys)
 	setsKeysCount := len(lockedSets)
 	allKeys := make([]string, deleteKeysCount+setsKeysCount)
 	for i := 0; i < deleteKeysCount; i++ {
-		allKeys[i] = lockedDeleteKeys[i]
+		allKeys[i] = s.key(lockedDeleteKeys[i])
 	}
 
 	for i := 0; i < setsKeysCount; i++ {
-		allKeys[deleteKeysCount+i] = lockedSets[i].key
+		allKeys[deleteKeysCount+i] = s.key(lockedSets[i].key)
 	}
 
-	txf := func(tx *redis.Tx) error {
-		oldBytes := make([][]byte, len(lockedSets))
+	writeUpdates := func(w redisWriter, oldBytes [][]byte) error {
 		for i, set := range lockedSets {
-			bytes, err := tx.Get(ctx, set.key).Bytes()
+			requiresUpdate, newVal, errx := set.updater(func(m proto.Message) (bool, error) {
+				oldBytesCurrent := oldBytes[deleteKeysCount+i]
+				if oldBytesCurrent == nil {
+					return false, nil
+				}
+				return true, unmarshalValue(s.codec, set.key, oldBytesCurrent, m)
+			})
+			if errx != nil {
+				return errx
+			}
+			if requiresUpdate {
+				if newVal == nil {
+					w.del(s.key(set.key))
+					continue
+				}
+				newValBytes, err := marshalValue(s.codec, set.key, newVal)
+				if err != nil {
+					return err
+				}
+				w.set(s.key(set.key), newValBytes, redisTTL(set.ttl))
+			}
+		}
+		for _, deleteKey := range lockedDeleteKeys {
+			w.del(s.key(deleteKey))
+		}
+		sets := unlockedSets()
+		for _, set := range sets {
+			newValBytes, err := marshalValue(s.codec, set.key, set.newValue)
+			if err != nil {
+				return err
+			}
+			w.set(s.key(set.key), newValBytes, redisTTL(set.ttl))
+		}
+
+		afterRemovalKeys := unlockedDeleteKeys()
+		for _, deleteKey := range afterRemovalKeys {
+			w.del(s.key(deleteKey))
+		}
+
+		if indexUpdates != nil {
+			for _, cmd := range indexUpdates() {
+				if cmd.remove {
+					w.zrem(s.indexKey(cmd.index), cmd.member)
+				} else {
+					w.zadd(s.indexKey(cmd.index), cmd.member)
+				}
+			}
+		}
+		return nil
+	}
+
+	txf := func(tx *redis.Tx) error {
+		oldBytes := make([][]byte, len(allKeys))
+		for i, key := range allKeys[deleteKeysCount:] {
+			bytes, err := tx.Get(ctx, key).Bytes()
 			if err == redis.Nil {
 				err = nil
 				bytes = nil
@@ -139,56 +339,31 @@ func (s *RedisStorage) SetAndDeleteAtomically(ctx context.Context, lockedSets []
 			if err != nil {
 				return err
 			}
-			oldBytes[i] = bytes
+			oldBytes[deleteKeysCount+i] = bytes
 		}
 		// Operation is commited only if the watched keys remain unchanged.
 		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
-			for i, set := range lockedSets {
-				requiresUpdate, newVal, errx := set.updater(func(m proto.Message) (bool, error) {
-					oldBytesCurrent := oldBytes[i]
-					if oldBytesCurrent == nil {
-						return false, nil
-					}
-					return true, proto.Unmarshal(oldBytesCurrent, m)
-				})
-				if errx != nil {
-					return errx
-				}
-				if requiresUpdate {
-					if newVal == nil {
-						pipe.Del(ctx, set.key)
-						continue
-					}
-					newValBytes, err := s.codec.Marshal(newVal)
-					if err != nil {
-						return err
-					}
-					pipe.Set(ctx, set.key, newValBytes, 0)
-				}
-			}
-			for _, deleteKey := range lockedDeleteKeys {
-				pipe.Del(ctx, deleteKey)
-			}
-			sets := unlockedSets()
-			for _, set := range sets {
-				newValBytes, err := s.codec.Marshal(set.newValue)
-				if err != nil {
-					return err
-				}
-				pipe.Set(ctx, set.key, newValBytes, 0)
-			}
-
-			afterRemovalKeys := unlockedDeleteKeys()
-			for _, deleteKey := range afterRemovalKeys {
-				pipe.Del(ctx, deleteKey)
-			}
-			return nil
+			return writeUpdates(&pipelineWriter{ctx: ctx, pipe: pipe}, oldBytes)
 		})
 		return err
 	}
 
 	for i := 0; i < maxRetries; i++ {
-		err := s.client.Watch(ctx, txf, allKeys...)
+		if i > 0 {
+			if err := sleepBeforeRetry(ctx, i); err != nil {
+				return err
+			}
+		}
+		var err error
+		if s.scriptedUpdates() {
+			err = s.setAndDeleteByScript(ctx, allKeys, writeUpdates)
+			if isScriptingUnavailable(err) {
+				s.disableScriptedUpdates()
+				err = s.client.Watch(ctx, txf, allKeys...)
+			}
+		} else {
+			err = s.client.Watch(ctx, txf, allKeys...)
+		}
 		if err == nil {
 			// Success.
 			return nil
@@ -201,5 +376,38 @@ func (s *RedisStorage) SetAndDeleteAtomically(ctx context.Context, lockedSets []
 		return err
 	}
 
-	return fmt.Errorf("concurrent updates of the keys %v", allKeys)
+	return &ConcurrentUpdateError{keys: allKeys}
+}
+
+func (s *RedisStorage) IndexRange(ctx context.Context, index string, after string, limit int) ([]string, error) {
+	min := "-"
+	if after != "" {
+		min = "(" + after
+	}
+	return s.client.ZRangeByLex(ctx, s.indexKey(index), &redis.ZRangeBy{
+		Min:   min,
+		Max:   "+",
+		Count: int64(limit),
+	}).Result()
+}
+
+// ScanKeys uses SCAN, so it doesn't block Redis, but it reads the keys of all the cluster nodes
+func (s *RedisStorage) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := scanRedisKeys(ctx, s.client, []string{redisGlobEscaper.Replace(s.keyPrefix+prefix) + "*"})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(keys))
+	for _, key := range keys {
+		key = strings.TrimPrefix(key, s.keyPrefix)
+		if !strings.HasPrefix(key, redisIndexPrefix) {
+			res = append(res, key)
+		}
+	}
+	// SCAN may return the same key several times
+	slices.Sort(res)
+	return slices.Compact(res), nil
 }
+
+// redisGlobEscaper escapes the special characters of the patterns of SCAN and KEYS
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
@@ -0,0 +1,52 @@
+package db_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/internal/db/dbtest"
+)
+
+// storages are the backends of the key-value storage tested by the conformance kit, every call returns an empty storage
+var storages = map[string]func(t *testing.T) db.Storage{
+	"Memory": func(t *testing.T) db.Storage {
+		return db.NewMemoryStorage(db.PROTO_CODEC)
+	},
+	"Bolt": func(t *testing.T) db.Storage {
+		s, err := db.NewBoltStorage(filepath.Join(t.TempDir(), "asit.db"), db.PROTO_CODEC)
+		if err != nil {
+			t.Fatalf("can't open bolt storage, %v", err)
+		}
+		return s
+	},
+	"SQL": func(t *testing.T) db.Storage {
+		sqlDB, err := db.OpenSQLite(filepath.Join(t.TempDir(), "asit.sqlite"))
+		if err != nil {
+			t.Fatalf("can't open sqlite db, %v", err)
+		}
+		s, err := db.NewSQLStorage(sqlDB, db.SQLITE_DIALECT, db.PROTO_CODEC)
+		if err != nil {
+			sqlDB.Close()
+			t.Fatalf("can't create sql storage, %v", err)
+		}
+		return s
+	},
+}
+
+func TestStorages(t *testing.T) {
+	for name, newStorage := range storages {
+		newStorage := newStorage
+		t.Run(name, func(t *testing.T) {
+			dbtest.RunStorageTests(t, func(t *testing.T) db.Storage {
+				s := newStorage(t)
+				t.Cleanup(func() { s.Close() })
+				return s
+			})
+		})
+	}
+}
+
+func TestStorageCodecs(t *testing.T) {
+	dbtest.RunStorageCodecTests(t)
+}
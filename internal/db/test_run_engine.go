@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TEST_ACTION_CLAIM_LEASE is the time the agent has to report the result of the claimed action,
+// after it the action may be claimed again, e.g. by another agent if the first one has crashed
+const TEST_ACTION_CLAIM_LEASE = 5 * time.Minute
+
+// testStepTransitions are the legal transitions of the step statuses reported to the engine.
+// CREATED steps become ACTIVE when the previous step is verified, the steps without transitions are final.
+var testStepTransitions = map[asit.TestStepRunStatus][]asit.TestStepRunStatus{
+	asit.TestStepRunStatus_ACTIVE:          {asit.TestStepRunStatus_ACTION_STARTED},
+	asit.TestStepRunStatus_ACTION_STARTED:  {asit.TestStepRunStatus_ACTION_FINISHED, asit.TestStepRunStatus_ACTION_FAILED},
+	asit.TestStepRunStatus_ACTION_FINISHED: {asit.TestStepRunStatus_VERIFICATION_SUCCESS, asit.TestStepRunStatus_VERIFICATION_FAILED},
+}
+
+// IllegalTestStepTransitionError is returned when the step can't change its status, e.g. it's not the current step
+// of the run, the run is finished or the status doesn't follow the current one
+type IllegalTestStepTransitionError struct {
+	reason string
+}
+
+func (e *IllegalTestStepTransitionError) Error() string {
+	return fmt.Sprintf("illegal test step transition, %s", e.reason)
+}
+
+type NotFoundTestStepRunError struct {
+	runId  string
+	stepId string
+}
+
+func (e *NotFoundTestStepRunError) Error() string {
+	return fmt.Sprintf("not found test step %s in test run %s", e.stepId, e.runId)
+}
+
+// FindTestStepRun returns the index of the step in the run state or -1 if the run doesn't contain it
+func FindTestStepRun(run *asit.TestRun, stepId string) int {
+	for i, stepRun := range run.GetState().GetStepRuns() {
+		if stepRun.TestStepId == stepId {
+			return i
+		}
+	}
+	return -1
+}
+
+// TransitionTestStep changes the status of the current step of the started run and rolls it up to the run:
+// the verified step activates the next one or finishes the run with SUCCESS if it's the last one,
+// the failed action or verification finishes the run with FAIL. It returns IllegalTestStepTransitionError
+// or NotFoundTestStepRunError without changing the run if the transition isn't legal.
+func TransitionTestStep(run *asit.TestRun, stepId string, status asit.TestStepRunStatus, description string) error {
+	index := FindTestStepRun(run, stepId)
+	if index < 0 {
+		return &NotFoundTestStepRunError{runId: run.Id, stepId: stepId}
+	}
+	if run.Status != asit.TestRunStatus_STARTED {
+		return &IllegalTestStepTransitionError{reason: fmt.Sprintf("test run %s is finished with %s", run.Id, run.Status)}
+	}
+	state := run.State
+	if int(state.CurrentStepIndex) != index {
+		return &IllegalTestStepTransitionError{reason: fmt.Sprintf("test step %s isn't the current step of test run %s", stepId, run.Id)}
+	}
+	stepRun := state.StepRuns[index]
+	if !isTestStepTransition(stepRun.Status, status) {
+		return &IllegalTestStepTransitionError{reason: fmt.Sprintf("test step %s can't change status from %s to %s", stepId, stepRun.Status, status)}
+	}
+
+	stepRun.Status = status
+	stepRun.StatusDescription = description
+	switch status {
+	case asit.TestStepRunStatus_ACTION_FAILED, asit.TestStepRunStatus_VERIFICATION_FAILED:
+		run.Status = asit.TestRunStatus_FAIL
+		run.StatusDescription = fmt.Sprintf("test step %s is finished with %s", stepId, status)
+		if description != "" {
+			run.StatusDescription += ": " + description
+		}
+	case asit.TestStepRunStatus_VERIFICATION_SUCCESS:
+		if index == len(state.StepRuns)-1 {
+			run.Status = asit.TestRunStatus_SUCCESS
+			run.StatusDescription = "all test steps are verified"
+			break
+		}
+		state.CurrentStepIndex++
+		state.StepRuns[index+1].Status = asit.TestStepRunStatus_ACTIVE
+	}
+	return nil
+}
+
+// ClaimTestStep starts the action of the current step of the run until now plus the lease and increments its claim attempt.
+// The started action is claimed again if its claim has expired at now, then only the agent of the new claim can report it.
+// It returns the errors of TransitionTestStep without changing the run if the action can't be claimed.
+func ClaimTestStep(run *asit.TestRun, stepId string, now time.Time, lease time.Duration) error {
+	index := FindTestStepRun(run, stepId)
+	if index < 0 || !isTestStepClaimExpired(run.State.StepRuns[index], now) {
+		if err := TransitionTestStep(run, stepId, asit.TestStepRunStatus_ACTION_STARTED, ""); err != nil {
+			return err
+		}
+	} else if run.Status != asit.TestRunStatus_STARTED || int(run.State.CurrentStepIndex) != index {
+		return &IllegalTestStepTransitionError{reason: fmt.Sprintf("test step %s of test run %s can't be claimed again", stepId, run.Id)}
+	}
+	stepRun := run.State.StepRuns[index]
+	stepRun.ClaimExpires = timestamppb.New(now.Add(lease))
+	stepRun.ClaimAttempt++
+	return nil
+}
+
+// checkTestStepClaim returns IllegalTestStepTransitionError if the claim attempt isn't the last claim attempt of the step,
+// e.g. the agent reports the action claimed again by another agent after its claim has expired.
+// The steps which haven't been claimed are changed with the attempt 0.
+func checkTestStepClaim(run *asit.TestRun, stepId string, claimAttempt int32) error {
+	index := FindTestStepRun(run, stepId)
+	if index < 0 {
+		return nil
+	}
+	if current := run.State.StepRuns[index].ClaimAttempt; current != claimAttempt {
+		return &IllegalTestStepTransitionError{reason: fmt.Sprintf("claim attempt %d of test step %s of test run %s is stale, the current one is %d",
+			claimAttempt, stepId, run.Id, current)}
+	}
+	return nil
+}
+
+// isTestStepClaimExpired checks if the action of the step is started by the claim expired at now,
+// the actions started by the transitions have no deadline
+func isTestStepClaimExpired(stepRun *asit.TestStepRun, now time.Time) bool {
+	return stepRun.Status == asit.TestStepRunStatus_ACTION_STARTED && stepRun.ClaimExpires != nil &&
+		!now.Before(stepRun.ClaimExpires.AsTime())
+}
+
+func isTestStepTransition(from asit.TestStepRunStatus, to asit.TestStepRunStatus) bool {
+	for _, status := range testStepTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TestRunEngine changes the state of the runs, every change is applied atomically,
+// so the concurrent reports of the same run can't overwrite each other
+type TestRunEngine struct {
+	testRunsRepository TestRunsRepository
+	// claimLease is the time the agent has to report the result of the claimed action
+	claimLease time.Duration
+}
+
+func NewTestRunEngine(testRunsRepository TestRunsRepository) *TestRunEngine {
+	return NewTestRunEngineWithClaimLease(testRunsRepository, TEST_ACTION_CLAIM_LEASE)
+}
+
+// NewTestRunEngineWithClaimLease creates the engine whose claimed actions may be claimed again after the lease
+func NewTestRunEngineWithClaimLease(testRunsRepository TestRunsRepository, claimLease time.Duration) *TestRunEngine {
+	return &TestRunEngine{testRunsRepository: testRunsRepository, claimLease: claimLease}
+}
+
+// TransitionStep applies TransitionTestStep to the stored run if the claim attempt is the last one of the step
+// and returns the updated run. It returns NotFoundTestRunError if the run is missing.
+func (e *TestRunEngine) TransitionStep(ctx context.Context, runId string, stepId string, claimAttempt int32, status asit.TestStepRunStatus, description string) (*asit.TestRun, error) {
+	return e.testRunsRepository.UpdateTestRun(ctx, runId, func(run *asit.TestRun) error {
+		if err := checkTestStepClaim(run, stepId, claimAttempt); err != nil {
+			return err
+		}
+		return TransitionTestStep(run, stepId, status, description)
+	})
+}
+
+// ReportActionResult applies ApplyTestActionResult to the stored run and returns the updated run.
+// It returns NotFoundTestRunError if the run is missing.
+func (e *TestRunEngine) ReportActionResult(ctx context.Context, runId string, stepId string, result *asit.TestActionResult) (*asit.TestRun, error) {
+	return e.testRunsRepository.UpdateTestRun(ctx, runId, func(run *asit.TestRun) error {
+		return ApplyTestActionResult(run, stepId, result)
+	})
+}
+
+// ClaimNextAction starts the action of the first ACTIVE current step of the STARTED runs of the client
+// and returns it, nil is returned if there are no such steps. Every step is claimed only once until
+// its claim lease expires, then it's claimed again, see ClaimTestStep. The steps claimed concurrently
+// by other agents are skipped.
+func (e *TestRunEngine) ClaimNextAction(ctx context.Context, clientId string) (*asit.PendingTestAction, error) {
+	runs, err := e.testRunsRepository.GetActiveTestRuns(ctx, clientId)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, run := range runs {
+		stepRun := run.State.StepRuns[run.State.CurrentStepIndex]
+		if stepRun.Status != asit.TestStepRunStatus_ACTIVE && !isTestStepClaimExpired(stepRun, now) {
+			continue
+		}
+		claimed, err := e.testRunsRepository.UpdateTestRun(ctx, run.Id, func(run *asit.TestRun) error {
+			return ClaimTestStep(run, stepRun.TestStepId, now, e.claimLease)
+		})
+		var illegalErr *IllegalTestStepTransitionError
+		var concurrentErr *ConcurrentUpdateError
+		var notFoundErr *NotFoundTestRunError
+		if errors.As(err, &illegalErr) || errors.As(err, &concurrentErr) || errors.As(err, &notFoundErr) {
+			// claimed or changed by another agent
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		step := FindTestStep(claimed.TestSuite, stepRun.TestStepId)
+		if step == nil {
+			return nil, fmt.Errorf("test suite snapshot of test run %s doesn't contain step %s", run.Id, stepRun.TestStepId)
+		}
+		claimedStepRun := claimed.State.StepRuns[claimed.State.CurrentStepIndex]
+		return &asit.PendingTestAction{TestRunId: run.Id, TestStepId: step.Id, Action: step.Action,
+			ClaimExpires: claimedStepRun.ClaimExpires, ClaimAttempt: claimedStepRun.ClaimAttempt}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+)
+
+const (
+	MAX_TEST_LOG_LINES     = 1000
+	MAX_TEST_LOG_LINE_SIZE = 4096
+	// MAX_TEST_LOGS_SIZE is the max total size of the log lines of the step
+	MAX_TEST_LOGS_SIZE = 256 * 1024
+	MAX_TEST_DATA      = 100
+	// MAX_TEST_DATA_SIZE is the max total size of the key and the value of every data entry
+	MAX_TEST_DATA_SIZE = 4096
+)
+
+type InvalidTestActionResultError struct {
+	reason string
+}
+
+func (e *InvalidTestActionResultError) Error() string {
+	return fmt.Sprintf("invalid test action result, %s", e.reason)
+}
+
+func invalidTestActionResult(format string, args ...interface{}) error {
+	return &InvalidTestActionResultError{reason: fmt.Sprintf(format, args...)}
+}
+
+// ValidateTestActionResult checks the size limits of the description, the logs and the data of the result,
+// it returns InvalidTestActionResultError describing the first found problem
+func ValidateTestActionResult(result *asit.TestActionResult) error {
+	if len(result.StatusDescription) > MAX_TEST_DESCRIPTION_SIZE {
+		return invalidTestActionResult("statusDescription is longer than %d bytes", MAX_TEST_DESCRIPTION_SIZE)
+	}
+	if len(result.Logs) > MAX_TEST_LOG_LINES {
+		return invalidTestActionResult("there are more than %d log lines", MAX_TEST_LOG_LINES)
+	}
+	logsSize := 0
+	for i, line := range result.Logs {
+		if len(line) > MAX_TEST_LOG_LINE_SIZE {
+			return invalidTestActionResult("log line %d is longer than %d bytes", i, MAX_TEST_LOG_LINE_SIZE)
+		}
+		logsSize += len(line)
+	}
+	if logsSize > MAX_TEST_LOGS_SIZE {
+		return invalidTestActionResult("log lines are longer than %d bytes", MAX_TEST_LOGS_SIZE)
+	}
+	if len(result.Data) > MAX_TEST_DATA {
+		return invalidTestActionResult("there are more than %d data entries", MAX_TEST_DATA)
+	}
+	for key, value := range result.Data {
+		if key == "" {
+			return invalidTestActionResult("data entry has no key")
+		}
+		if len(key)+len(value) > MAX_TEST_DATA_SIZE {
+			return invalidTestActionResult("data entry %s is longer than %d bytes", key, MAX_TEST_DATA_SIZE)
+		}
+	}
+	return nil
+}
+
+// TestStepDataKey is the key of the data entry of the step in the run state, the steps can't overwrite the data of each other
+func TestStepDataKey(stepId string, key string) string {
+	return stepId + "." + key
+}
+
+// ApplyTestActionResult finishes the started action of the current step of the run with the result.
+// The logs and the data are kept in the step run, the data is merged into the run state by TestStepDataKey.
+// The finished action is verified at once by the checks of the step, see VerifyTestStep.
+// It returns the errors of TransitionTestStep without changing the run if the action isn't started
+// or IllegalTestStepTransitionError if the result doesn't belong to the last claim of the action.
+func ApplyTestActionResult(run *asit.TestRun, stepId string, result *asit.TestActionResult) error {
+	if err := checkTestStepClaim(run, stepId, result.ClaimAttempt); err != nil {
+		return err
+	}
+	status := asit.TestStepRunStatus_ACTION_FINISHED
+	if !result.Success {
+		status = asit.TestStepRunStatus_ACTION_FAILED
+	}
+	if err := TransitionTestStep(run, stepId, status, result.StatusDescription); err != nil {
+		return err
+	}
+	stepRun := run.State.StepRuns[FindTestStepRun(run, stepId)]
+	stepRun.Logs = append(stepRun.Logs, result.Logs...)
+	stepRun.Data = map[string]string{}
+	if run.State.Data == nil {
+		run.State.Data = map[string]string{}
+	}
+	for key, value := range result.Data {
+		stepRun.Data[key] = value
+		run.State.Data[TestStepDataKey(stepId, key)] = value
+	}
+	if !result.Success {
+		return nil
+	}
+
+	step := FindTestStep(run.TestSuite, stepId)
+	if step == nil {
+		return fmt.Errorf("test suite snapshot of test run %s doesn't contain step %s", run.Id, stepId)
+	}
+	status = asit.TestStepRunStatus_VERIFICATION_SUCCESS
+	description := VerifyTestStep(step, run.State, stepRun)
+	if description != "" {
+		status = asit.TestStepRunStatus_VERIFICATION_FAILED
+	}
+	return TransitionTestStep(run, stepId, status, description)
+}
+
+// TestCheckFunction checks the data of the step, it returns the description of the failure or the empty string if the check passes.
+// data returns the value of the key reported by the step or, if it's missing, of the key of the run state,
+// so the checks can compare the data of the previous steps by TestStepDataKey.
+type TestCheckFunction func(arguments map[string]string, data func(key string) (string, bool)) string
+
+// testCheckFunctions are the functions of the checks verified by the server
+var testCheckFunctions = map[string]TestCheckFunction{
+	"data.exists": func(arguments map[string]string, data func(key string) (string, bool)) string {
+		if _, ok := data(arguments["key"]); !ok {
+			return fmt.Sprintf("data %s is missing", arguments["key"])
+		}
+		return ""
+	},
+	"data.equals": func(arguments map[string]string, data func(key string) (string, bool)) string {
+		return checkTestData(arguments, data, func(value string) bool { return value == arguments["value"] },
+			"isn't equal to "+arguments["value"])
+	},
+	"data.contains": func(arguments map[string]string, data func(key string) (string, bool)) string {
+		return checkTestData(arguments, data, func(value string) bool { return strings.Contains(value, arguments["value"]) },
+			"doesn't contain "+arguments["value"])
+	},
+	"data.matches": func(arguments map[string]string, data func(key string) (string, bool)) string {
+		pattern, err := regexp.Compile(arguments["pattern"])
+		if err != nil {
+			return fmt.Sprintf("invalid pattern %s, %v", arguments["pattern"], err)
+		}
+		return checkTestData(arguments, data, pattern.MatchString, "doesn't match "+arguments["pattern"])
+	},
+}
+
+// checkTestData checks the value of the data specified by the key argument
+func checkTestData(arguments map[string]string, data func(key string) (string, bool), check func(value string) bool, failure string) string {
+	key := arguments["key"]
+	value, ok := data(key)
+	if !ok {
+		return fmt.Sprintf("data %s is missing", key)
+	}
+	if !check(value) {
+		return fmt.Sprintf("data %s %q %s", key, value, failure)
+	}
+	return ""
+}
+
+// VerifyTestStep runs the checks of the step in order and returns the description of the first failed check,
+// the empty string is returned if all the checks pass. The checks with unknown functions fail,
+// they are rejected by ValidateTestSuite, but the snapshots of the runs started before may contain them.
+func VerifyTestStep(step *asit.TestStep, state *asit.TestState, stepRun *asit.TestStepRun) string {
+	data := func(key string) (string, bool) {
+		if value, ok := stepRun.Data[key]; ok {
+			return value, true
+		}
+		value, ok := state.Data[key]
+		return value, ok
+	}
+	for i, check := range step.GetVerification().GetChecks() {
+		checkFunction, ok := testCheckFunctions[check.Function]
+		if !ok {
+			return fmt.Sprintf("check %d has unknown function %s", i, check.Function)
+		}
+		if failure := checkFunction(check.Arguments, data); failure != "" {
+			return fmt.Sprintf("check %d %s failed, %s", i, check.Function, failure)
+		}
+	}
+	return ""
+}
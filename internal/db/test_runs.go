@@ -0,0 +1,229 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	KEY_TEST_RUNS_INDEX               = "test_runs_index"
+	KEY_CLIENT_TEST_RUNS_INDEX_PREFIX = "client_test_runs_index:"
+	// KEY_CLIENT_ACTIVE_TEST_RUNS_INDEX_PREFIX are the STARTED runs of the client
+	KEY_CLIENT_ACTIVE_TEST_RUNS_INDEX_PREFIX = "client_active_test_runs_index:"
+	KEY_TEST_RUN_PREFIX                      = "test_run:"
+)
+
+// TestRunsRepository stores the runs of the test suites, every run keeps the snapshot of its suite and its state
+type TestRunsRepository interface {
+	// CreateTestRun stores the new run, it returns TestRunIdConflictError if the run with the same id exists
+	CreateTestRun(ctx context.Context, run *asit.TestRun) error
+	// GetTestRun returns the run or nil if it's missing
+	GetTestRun(ctx context.Context, runId string) (*asit.TestRun, error)
+	// GetTestRuns returns up to limit runs (without the suite snapshot and the state) ordered by id and starting after the cursor.
+	// The empty clientId returns the runs of all the clients. The returned next cursor is empty if there are no more runs.
+	GetTestRuns(ctx context.Context, clientId string, cursor string, limit int) (runs []*asit.TestRun, nextCursor string, err error)
+	// GetActiveTestRuns returns the STARTED runs of the client ordered by id
+	GetActiveTestRuns(ctx context.Context, clientId string) ([]*asit.TestRun, error)
+	// UpdateTestRun atomically applies the update to the run and returns the updated run, the update may be called several times on conflicts.
+	// The run isn't changed if the update returns an error. It returns NotFoundTestRunError if the run is missing.
+	UpdateTestRun(ctx context.Context, runId string, update func(run *asit.TestRun) error) (*asit.TestRun, error)
+}
+
+type NotFoundTestRunError struct {
+	id string
+}
+
+func (e *NotFoundTestRunError) Error() string {
+	return fmt.Sprintf("not found test run with id %s", e.id)
+}
+
+type TestRunIdConflictError struct {
+	id string
+}
+
+func (e *TestRunIdConflictError) Error() string {
+	return fmt.Sprintf("test run with id %s already exists", e.id)
+}
+
+// NewTestRun creates the started run of the suite for the client. The steps of all the test cases are run in order,
+// the first step is active. The client properties are copied to the state, so the run isn't affected by the client changes.
+func NewTestRun(id string, suite *asit.TestSuite, client *asit.Client) *asit.TestRun {
+	state := &asit.TestState{
+		ClientProperties: map[string]string{},
+		Data:             map[string]string{},
+	}
+	for name, value := range client.ClientProperties {
+		state.ClientProperties[name] = value
+	}
+	for _, testCase := range suite.Tests {
+		for _, step := range testCase.Steps {
+			state.StepRuns = append(state.StepRuns, &asit.TestStepRun{TestStepId: step.Id, Status: asit.TestStepRunStatus_CREATED})
+		}
+	}
+	if len(state.StepRuns) > 0 {
+		state.StepRuns[0].Status = asit.TestStepRunStatus_ACTIVE
+	}
+	return &asit.TestRun{
+		Id:          id,
+		TestSuiteId: suite.Id,
+		ClientId:    client.Id,
+		Status:      asit.TestRunStatus_STARTED,
+		State:       state,
+		TestSuite:   proto.Clone(suite).(*asit.TestSuite),
+		LastUpdated: timestamppb.New(time.Now()),
+	}
+}
+
+type KVTestRunsRepository struct {
+	storage Storage
+}
+
+func NewKVTestRunsRepository(store Storage) *KVTestRunsRepository {
+	return &KVTestRunsRepository{storage: store}
+}
+
+// RewriteValues writes all the test runs again with the current storage codec, e.g. to encrypt them with the active key.
+// It returns the number of the rewritten values.
+func (r *KVTestRunsRepository) RewriteValues(ctx context.Context) (int, error) {
+	return rewriteStorageValues(ctx, r.storage, KEY_TEST_RUN_PREFIX, func() proto.Message { return &asit.TestRun{} })
+}
+
+func (r *KVTestRunsRepository) CreateTestRun(ctx context.Context, run *asit.TestRun) error {
+	_, err := r.updateTestRun(ctx, run.Id, func(current *asit.TestRun) (*asit.TestRun, error) {
+		if current != nil {
+			return nil, &TestRunIdConflictError{id: run.Id}
+		}
+		return run, nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't create test run with Id %s, %w", run.Id, err)
+	}
+	return nil
+}
+
+func (r *KVTestRunsRepository) GetTestRun(ctx context.Context, runId string) (*asit.TestRun, error) {
+	run := &asit.TestRun{}
+	ok, err := r.storage.Get(ctx, KEY_TEST_RUN_PREFIX+runId, run)
+	if err != nil {
+		return nil, fmt.Errorf("can't retrieve db key %s, %w", KEY_TEST_RUN_PREFIX+runId, err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return run, nil
+}
+
+func (r *KVTestRunsRepository) GetTestRuns(ctx context.Context, clientId string, cursor string, limit int) ([]*asit.TestRun, string, error) {
+	index := KEY_TEST_RUNS_INDEX
+	if clientId != "" {
+		index = KEY_CLIENT_TEST_RUNS_INDEX_PREFIX + clientId
+	}
+	ids, err := r.storage.IndexRange(ctx, index, cursor, limit)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't retrieve db index %s, %w", index, err)
+	}
+	runs := make([]*asit.TestRun, 0, len(ids))
+	for _, id := range ids {
+		run, err := r.GetTestRun(ctx, id)
+		if err != nil {
+			return nil, "", err
+		}
+		if run == nil {
+			continue
+		}
+		run.TestSuite = nil
+		run.State = nil
+		runs = append(runs, run)
+	}
+	nextCursor := ""
+	if limit > 0 && len(ids) == limit {
+		nextCursor = ids[len(ids)-1]
+	}
+	return runs, nextCursor, nil
+}
+
+func (r *KVTestRunsRepository) GetActiveTestRuns(ctx context.Context, clientId string) ([]*asit.TestRun, error) {
+	index := KEY_CLIENT_ACTIVE_TEST_RUNS_INDEX_PREFIX + clientId
+	ids, err := r.storage.IndexRange(ctx, index, "", 0)
+	if err != nil {
+		return nil, fmt.Errorf("can't retrieve db index %s, %w", index, err)
+	}
+	runs := make([]*asit.TestRun, 0, len(ids))
+	for _, id := range ids {
+		run, err := r.GetTestRun(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if run == nil || run.Status != asit.TestRunStatus_STARTED {
+			// finished concurrently
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+func (r *KVTestRunsRepository) UpdateTestRun(ctx context.Context, runId string, update func(run *asit.TestRun) error) (*asit.TestRun, error) {
+	run, err := r.updateTestRun(ctx, runId, func(current *asit.TestRun) (*asit.TestRun, error) {
+		if current == nil {
+			return nil, &NotFoundTestRunError{id: runId}
+		}
+		if err := update(current); err != nil {
+			return nil, err
+		}
+		return current, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't update test run with Id %s, %w", runId, err)
+	}
+	return run, nil
+}
+
+// updateTestRun atomically replaces the run with the value returned by the update function and sets its lastUpdated.
+// The update function is called with the current value of the run (nil if it's missing) and may be called several times on conflicts.
+func (r *KVTestRunsRepository) updateTestRun(ctx context.Context, runId string,
+	update func(current *asit.TestRun) (*asit.TestRun, error)) (*asit.TestRun, error) {
+	var run *asit.TestRun
+	err := r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
+		{
+			key: KEY_TEST_RUN_PREFIX + runId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				old := &asit.TestRun{}
+				found, err := oldValue(old)
+				if err != nil {
+					return false, nil, err
+				}
+				var current *asit.TestRun
+				if found {
+					current = old
+				}
+				run, err = update(current)
+				if err != nil {
+					return false, nil, err
+				}
+				run.LastUpdated = timestamppb.New(time.Now())
+				return true, run, nil
+			},
+		},
+	}, []string{}, func() []SetValueUnlockedCommand { return nil }, func() []string { return nil },
+		func() []IndexCommand {
+			active := NewIndexAddCommand(KEY_CLIENT_ACTIVE_TEST_RUNS_INDEX_PREFIX+run.ClientId, runId)
+			if run.Status != asit.TestRunStatus_STARTED {
+				active = NewIndexRemoveCommand(KEY_CLIENT_ACTIVE_TEST_RUNS_INDEX_PREFIX+run.ClientId, runId)
+			}
+			return []IndexCommand{
+				NewIndexAddCommand(KEY_TEST_RUNS_INDEX, runId),
+				NewIndexAddCommand(KEY_CLIENT_TEST_RUNS_INDEX_PREFIX+run.ClientId, runId),
+				active,
+			}
+		})
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+)
+
+// NotifyingTestRunsRepository calls notify with the client id of the run after every successful change of the run
+// made through the repository. The changes are delivered between the instances like the client changes, see ClientChanges.
+// The failed notifications are ignored, because the changes are made already and the agents of the clients
+// read their runs again periodically, so the lost notification only delays them.
+type NotifyingTestRunsRepository struct {
+	TestRunsRepository
+	notify func(ctx context.Context, clientId string) error
+}
+
+// NewNotifyingTestRunsRepository creates the repository notifying about the changes of the runs, e.g. by ClientChanges.Publish
+func NewNotifyingTestRunsRepository(r TestRunsRepository, notify func(ctx context.Context, clientId string) error) *NotifyingTestRunsRepository {
+	return &NotifyingTestRunsRepository{TestRunsRepository: r, notify: notify}
+}
+
+func (r *NotifyingTestRunsRepository) CreateTestRun(ctx context.Context, run *asit.TestRun) error {
+	if err := r.TestRunsRepository.CreateTestRun(ctx, run); err != nil {
+		return err
+	}
+	_ = r.notify(ctx, run.ClientId)
+	return nil
+}
+
+func (r *NotifyingTestRunsRepository) UpdateTestRun(ctx context.Context, runId string, update func(run *asit.TestRun) error) (*asit.TestRun, error) {
+	run, err := r.TestRunsRepository.UpdateTestRun(ctx, runId, update)
+	if err != nil {
+		return nil, err
+	}
+	_ = r.notify(ctx, run.ClientId)
+	return run, nil
+}
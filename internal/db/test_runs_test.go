@@ -0,0 +1,34 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/derbylock/async-integration-testing/internal/db"
+	"github.com/derbylock/async-integration-testing/internal/db/dbtest"
+)
+
+func TestKVTestSuitesRepository(t *testing.T) {
+	for name, newStorage := range storages {
+		newStorage := newStorage
+		t.Run(name, func(t *testing.T) {
+			dbtest.RunTestSuitesRepositoryTests(t, func(t *testing.T) db.TestSuitesRepository {
+				s := newStorage(t)
+				t.Cleanup(func() { s.Close() })
+				return db.NewKVTestSuitesRepository(s)
+			})
+		})
+	}
+}
+
+func TestKVTestRunsRepository(t *testing.T) {
+	for name, newStorage := range storages {
+		newStorage := newStorage
+		t.Run(name, func(t *testing.T) {
+			dbtest.RunTestRunsRepositoryTests(t, func(t *testing.T) db.TestRunsRepository {
+				s := newStorage(t)
+				t.Cleanup(func() { s.Close() })
+				return db.NewKVTestRunsRepository(s)
+			})
+		})
+	}
+}
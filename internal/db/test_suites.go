@@ -0,0 +1,235 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	KEY_TEST_SUITES_INDEX = "test_suites_index"
+	KEY_TEST_SUITE_PREFIX = "test_suite:"
+)
+
+// TestSuitesRepository stores the test suites, the test cases are stored inside their suites,
+// so every change of a test case is an atomic change of its suite
+type TestSuitesRepository interface {
+	// GetTestSuites returns up to limit test suites (without test cases) ordered by id and starting after the cursor.
+	// The empty cursor starts from the first suite. The returned next cursor is empty if there are no more suites.
+	GetTestSuites(ctx context.Context, cursor string, limit int) (suites []*asit.TestSuite, nextCursor string, err error)
+	// GetTestSuite returns the suite with its test cases or nil if it's missing
+	GetTestSuite(ctx context.Context, suiteId string) (*asit.TestSuite, error)
+	// SetTestSuite creates or replaces the suite, it returns InvalidTestSuiteError if the suite isn't valid
+	SetTestSuite(ctx context.Context, suite *asit.TestSuite) error
+	// RemoveTestSuite removes the suite with its test cases, it returns NotFoundTestSuiteError if the suite is missing
+	RemoveTestSuite(ctx context.Context, suiteId string) error
+	// SetTestCase adds the test case to the end of the suite or replaces the case with the same id and returns the updated suite.
+	// It returns NotFoundTestSuiteError if the suite is missing and InvalidTestSuiteError if the updated suite isn't valid.
+	SetTestCase(ctx context.Context, suiteId string, testCase *asit.TestCase) (*asit.TestSuite, error)
+	// RemoveTestCase removes the test case from the suite, it returns NotFoundTestSuiteError or NotFoundTestCaseError if they are missing
+	RemoveTestCase(ctx context.Context, suiteId string, caseId string) error
+}
+
+type NotFoundTestSuiteError struct {
+	id string
+}
+
+func (e *NotFoundTestSuiteError) Error() string {
+	return fmt.Sprintf("not found test suite with id %s", e.id)
+}
+
+type NotFoundTestCaseError struct {
+	suiteId string
+	id      string
+}
+
+func (e *NotFoundTestCaseError) Error() string {
+	return fmt.Sprintf("not found test case with id %s in test suite %s", e.id, e.suiteId)
+}
+
+// FindTestCase returns the test case of the suite or nil if the suite doesn't contain it
+func FindTestCase(suite *asit.TestSuite, caseId string) *asit.TestCase {
+	for _, testCase := range suite.GetTests() {
+		if testCase.Id == caseId {
+			return testCase
+		}
+	}
+	return nil
+}
+
+// FindTestStep returns the step of any test case of the suite or nil if the suite doesn't contain it
+func FindTestStep(suite *asit.TestSuite, stepId string) *asit.TestStep {
+	for _, testCase := range suite.GetTests() {
+		for _, step := range testCase.Steps {
+			if step.Id == stepId {
+				return step
+			}
+		}
+	}
+	return nil
+}
+
+type KVTestSuitesRepository struct {
+	storage Storage
+}
+
+func NewKVTestSuitesRepository(store Storage) *KVTestSuitesRepository {
+	return &KVTestSuitesRepository{storage: store}
+}
+
+// RewriteValues writes all the test suites again with the current storage codec, e.g. to encrypt them with the active key.
+// It returns the number of the rewritten values.
+func (r *KVTestSuitesRepository) RewriteValues(ctx context.Context) (int, error) {
+	return rewriteStorageValues(ctx, r.storage, KEY_TEST_SUITE_PREFIX, func() proto.Message { return &asit.TestSuite{} })
+}
+
+func (r *KVTestSuitesRepository) GetTestSuites(ctx context.Context, cursor string, limit int) ([]*asit.TestSuite, string, error) {
+	ids, err := r.storage.IndexRange(ctx, KEY_TEST_SUITES_INDEX, cursor, limit)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't retrieve db index %s, %w", KEY_TEST_SUITES_INDEX, err)
+	}
+	suites := make([]*asit.TestSuite, 0, len(ids))
+	for _, id := range ids {
+		suite, err := r.GetTestSuite(ctx, id)
+		if err != nil {
+			return nil, "", err
+		}
+		if suite == nil {
+			// removed concurrently
+			continue
+		}
+		suite.Tests = nil
+		suites = append(suites, suite)
+	}
+	nextCursor := ""
+	if limit > 0 && len(ids) == limit {
+		nextCursor = ids[len(ids)-1]
+	}
+	return suites, nextCursor, nil
+}
+
+func (r *KVTestSuitesRepository) GetTestSuite(ctx context.Context, suiteId string) (*asit.TestSuite, error) {
+	suite := &asit.TestSuite{}
+	ok, err := r.storage.Get(ctx, KEY_TEST_SUITE_PREFIX+suiteId, suite)
+	if err != nil {
+		return nil, fmt.Errorf("can't retrieve db key %s, %w", KEY_TEST_SUITE_PREFIX+suiteId, err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return suite, nil
+}
+
+func (r *KVTestSuitesRepository) SetTestSuite(ctx context.Context, suite *asit.TestSuite) error {
+	if err := ValidateTestSuite(suite); err != nil {
+		return err
+	}
+	_, err := r.updateTestSuite(ctx, suite.Id, func(current *asit.TestSuite) (*asit.TestSuite, error) {
+		return suite, nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't set test suite with Id %s, %w", suite.Id, err)
+	}
+	return nil
+}
+
+func (r *KVTestSuitesRepository) RemoveTestSuite(ctx context.Context, suiteId string) error {
+	_, err := r.updateTestSuite(ctx, suiteId, func(current *asit.TestSuite) (*asit.TestSuite, error) {
+		if current == nil {
+			return nil, &NotFoundTestSuiteError{id: suiteId}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't remove test suite with Id %s, %w", suiteId, err)
+	}
+	return nil
+}
+
+func (r *KVTestSuitesRepository) SetTestCase(ctx context.Context, suiteId string, testCase *asit.TestCase) (*asit.TestSuite, error) {
+	suite, err := r.updateTestSuite(ctx, suiteId, func(current *asit.TestSuite) (*asit.TestSuite, error) {
+		if current == nil {
+			return nil, &NotFoundTestSuiteError{id: suiteId}
+		}
+		replaced := false
+		for i, existing := range current.Tests {
+			if existing.Id == testCase.Id {
+				current.Tests[i] = testCase
+				replaced = true
+			}
+		}
+		if !replaced {
+			current.Tests = append(current.Tests, testCase)
+		}
+		if err := ValidateTestSuite(current); err != nil {
+			return nil, err
+		}
+		return current, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't set test case with Id %s, %w", testCase.Id, err)
+	}
+	return suite, nil
+}
+
+func (r *KVTestSuitesRepository) RemoveTestCase(ctx context.Context, suiteId string, caseId string) error {
+	_, err := r.updateTestSuite(ctx, suiteId, func(current *asit.TestSuite) (*asit.TestSuite, error) {
+		if current == nil {
+			return nil, &NotFoundTestSuiteError{id: suiteId}
+		}
+		for i, testCase := range current.Tests {
+			if testCase.Id == caseId {
+				current.Tests = append(current.Tests[:i], current.Tests[i+1:]...)
+				return current, nil
+			}
+		}
+		return nil, &NotFoundTestCaseError{suiteId: suiteId, id: caseId}
+	})
+	if err != nil {
+		return fmt.Errorf("can't remove test case with Id %s, %w", caseId, err)
+	}
+	return nil
+}
+
+// updateTestSuite atomically replaces the suite with the value returned by the update function, the nil value removes the suite.
+// The update function is called with the current value of the suite (nil if it's missing) and may be called several times on conflicts.
+func (r *KVTestSuitesRepository) updateTestSuite(ctx context.Context, suiteId string,
+	update func(current *asit.TestSuite) (*asit.TestSuite, error)) (*asit.TestSuite, error) {
+	var suite *asit.TestSuite
+	err := r.storage.SetAndDeleteAtomically(ctx, []SetValueCommand{
+		{
+			key: KEY_TEST_SUITE_PREFIX + suiteId,
+			updater: func(oldValue func(proto.Message) (bool, error)) (bool, proto.Message, error) {
+				old := &asit.TestSuite{}
+				found, err := oldValue(old)
+				if err != nil {
+					return false, nil, err
+				}
+				var current *asit.TestSuite
+				if found {
+					current = old
+				}
+				suite, err = update(current)
+				if err != nil {
+					return false, nil, err
+				}
+				if suite == nil {
+					return found, nil, nil
+				}
+				return true, suite, nil
+			},
+		},
+	}, []string{}, func() []SetValueUnlockedCommand { return nil }, func() []string { return nil },
+		func() []IndexCommand {
+			if suite == nil {
+				return []IndexCommand{NewIndexRemoveCommand(KEY_TEST_SUITES_INDEX, suiteId)}
+			}
+			return []IndexCommand{NewIndexAddCommand(KEY_TEST_SUITES_INDEX, suiteId)}
+		})
+	if err != nil {
+		return nil, err
+	}
+	return suite, nil
+}
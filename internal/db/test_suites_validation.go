@@ -0,0 +1,163 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/derbylock/async-integration-testing/pkg/asit"
+)
+
+const (
+	MAX_TEST_ID_SIZE          = 128
+	MAX_TEST_NAME_SIZE        = 256
+	MAX_TEST_DESCRIPTION_SIZE = 4096
+	MAX_TEST_CASES            = 1000
+	MAX_TEST_STEPS            = 1000
+	MAX_TEST_CHECKS           = 100
+	MAX_TEST_ARGUMENTS        = 100
+	MAX_TEST_ARGUMENT_SIZE    = 4096
+)
+
+// testIdPattern keeps the ids usable in the URL paths and the db key names
+var testIdPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// testFunctionPattern is the name of the function of the action or the check, e.g. http.get
+var testFunctionPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+type InvalidTestSuiteError struct {
+	reason string
+}
+
+func (e *InvalidTestSuiteError) Error() string {
+	return fmt.Sprintf("invalid test suite, %s", e.reason)
+}
+
+func invalidTestSuite(format string, args ...interface{}) error {
+	return &InvalidTestSuiteError{reason: fmt.Sprintf(format, args...)}
+}
+
+// ValidateTestId checks that the id of the suite, the case or the step is non-empty and safe to be used in the paths
+func ValidateTestId(kind string, id string) error {
+	if id == "" {
+		return invalidTestSuite("%s id is required", kind)
+	}
+	if len(id) > MAX_TEST_ID_SIZE {
+		return invalidTestSuite("%s id is longer than %d bytes", kind, MAX_TEST_ID_SIZE)
+	}
+	if !testIdPattern.MatchString(id) {
+		return invalidTestSuite("%s id %q must contain only letters, digits, '_', '.' and '-'", kind, id)
+	}
+	return nil
+}
+
+// ValidateTestSuite checks the structure of the suite: every case has at least one step,
+// every step has an action, the ids of the cases and the steps are unique within the suite.
+// It returns InvalidTestSuiteError describing the first found problem.
+func ValidateTestSuite(suite *asit.TestSuite) error {
+	if err := validateTestItem("test suite", suite.Id, suite.Name, suite.Description); err != nil {
+		return err
+	}
+	if len(suite.Tests) > MAX_TEST_CASES {
+		return invalidTestSuite("test suite %s has more than %d test cases", suite.Id, MAX_TEST_CASES)
+	}
+	caseIds := map[string]struct{}{}
+	stepIds := map[string]struct{}{}
+	for _, testCase := range suite.Tests {
+		if testCase == nil {
+			return invalidTestSuite("test suite %s contains an empty test case", suite.Id)
+		}
+		if err := validateTestItem("test case", testCase.Id, testCase.Name, testCase.Description); err != nil {
+			return err
+		}
+		if _, ok := caseIds[testCase.Id]; ok {
+			return invalidTestSuite("duplicate test case id %s", testCase.Id)
+		}
+		caseIds[testCase.Id] = struct{}{}
+		if err := validateTestSteps(testCase, stepIds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTestSteps checks the steps of the case, stepIds are the ids of the steps of the suite checked before
+func validateTestSteps(testCase *asit.TestCase, stepIds map[string]struct{}) error {
+	if len(testCase.Steps) == 0 {
+		return invalidTestSuite("test case %s has no steps", testCase.Id)
+	}
+	if len(testCase.Steps) > MAX_TEST_STEPS {
+		return invalidTestSuite("test case %s has more than %d steps", testCase.Id, MAX_TEST_STEPS)
+	}
+	for _, step := range testCase.Steps {
+		if step == nil {
+			return invalidTestSuite("test case %s contains an empty step", testCase.Id)
+		}
+		if err := validateTestItem("test step", step.Id, step.Name, step.Description); err != nil {
+			return err
+		}
+		if _, ok := stepIds[step.Id]; ok {
+			return invalidTestSuite("duplicate test step id %s", step.Id)
+		}
+		stepIds[step.Id] = struct{}{}
+		if step.Action == nil {
+			return invalidTestSuite("test step %s has no action", step.Id)
+		}
+		if err := validateTestFunction("action of test step "+step.Id, step.Action.Function, step.Action.Arguments); err != nil {
+			return err
+		}
+		checks := step.GetVerification().GetChecks()
+		if len(checks) > MAX_TEST_CHECKS {
+			return invalidTestSuite("verification of test step %s has more than %d checks", step.Id, MAX_TEST_CHECKS)
+		}
+		for i, check := range checks {
+			if check == nil {
+				return invalidTestSuite("verification of test step %s contains an empty check", step.Id)
+			}
+			owner := fmt.Sprintf("check %d of test step %s", i, step.Id)
+			if err := validateTestFunction(owner, check.Function, check.Arguments); err != nil {
+				return err
+			}
+			if _, ok := testCheckFunctions[check.Function]; !ok {
+				return invalidTestSuite("%s has unknown function %s", owner, check.Function)
+			}
+		}
+	}
+	return nil
+}
+
+func validateTestItem(kind string, id string, name string, description string) error {
+	if err := ValidateTestId(kind, id); err != nil {
+		return err
+	}
+	if name == "" {
+		return invalidTestSuite("%s %s has no name", kind, id)
+	}
+	if len(name) > MAX_TEST_NAME_SIZE {
+		return invalidTestSuite("name of %s %s is longer than %d bytes", kind, id, MAX_TEST_NAME_SIZE)
+	}
+	if len(description) > MAX_TEST_DESCRIPTION_SIZE {
+		return invalidTestSuite("description of %s %s is longer than %d bytes", kind, id, MAX_TEST_DESCRIPTION_SIZE)
+	}
+	return nil
+}
+
+func validateTestFunction(owner string, function string, arguments map[string]string) error {
+	if function == "" {
+		return invalidTestSuite("%s has no function", owner)
+	}
+	if len(function) > MAX_TEST_NAME_SIZE || !testFunctionPattern.MatchString(function) {
+		return invalidTestSuite("%s has invalid function name %q", owner, function)
+	}
+	if len(arguments) > MAX_TEST_ARGUMENTS {
+		return invalidTestSuite("%s has more than %d arguments", owner, MAX_TEST_ARGUMENTS)
+	}
+	for name, value := range arguments {
+		if name == "" {
+			return invalidTestSuite("%s has an argument without a name", owner)
+		}
+		if len(name)+len(value) > MAX_TEST_ARGUMENT_SIZE {
+			return invalidTestSuite("argument %s of %s is longer than %d bytes", name, owner, MAX_TEST_ARGUMENT_SIZE)
+		}
+	}
+	return nil
+}
@@ -1,25 +1,226 @@
 package main
 
 import (
+	"encoding/base64"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/derbylock/async-integration-testing/cmd/admin"
 	"github.com/derbylock/async-integration-testing/cmd/server"
+	"github.com/derbylock/async-integration-testing/internal/db"
 )
 
 const (
+	STORAGE_TYPE = "STORAGE_TYPE"
+	// REDIS_ADDRS are the comma separated addresses of Redis, several addresses connect to Redis Cluster
 	REDIS_ADDRS    = "REDIS_ADDRS"
 	REDIS_PASSWORD = "REDIS_PASSWORD"
+	// REDIS_HASH_TAG puts all the keys in the same Redis Cluster slot, so on a single node, see README
+	REDIS_HASH_TAG = "REDIS_HASH_TAG"
+	BOLT_FILE      = "BOLT_FILE"
+	SQLITE_FILE    = "SQLITE_FILE"
+	// TRASH_RETENTION is the time after which the removed clients are purged, e.g. 72h, 0 disables the purge
+	TRASH_RETENTION = "TRASH_RETENTION"
+	// REDIS_NAMESPACE separates the keys of ASIT instances sharing the same Redis, e.g. staging and dev
+	REDIS_NAMESPACE = "REDIS_NAMESPACE"
+	// STORAGE_CODEC is the encoding of the written values, proto or protojson, the values of both encodings are read.
+	// SQLite uses it only for the test suites and runs, the clients are kept in the tables.
+	STORAGE_CODEC = "STORAGE_CODEC"
+	// STORAGE_COMPRESSION_MIN_SIZE is the size in bytes starting from which the written values are compressed, 0 disables the compression
+	STORAGE_COMPRESSION_MIN_SIZE = "STORAGE_COMPRESSION_MIN_SIZE"
+	// ENCRYPTION_KEYS are the AES keys of the stored values, e.g. "2023-01=<base64 key>,2023-02=<base64 key>", SQLite doesn't support them
+	ENCRYPTION_KEYS = "ENCRYPTION_KEYS"
+	// ENCRYPTION_KEYFILE is the file with the AES keys in the ENCRYPTION_KEYS format, one key per line
+	ENCRYPTION_KEYFILE = "ENCRYPTION_KEYFILE"
+	// ENCRYPTION_ACTIVE_KEY is the id of the key encrypting the written values, the last key by default
+	ENCRYPTION_ACTIVE_KEY = "ENCRYPTION_ACTIVE_KEY"
+	// ENCRYPTION_REQUIRED rejects the values which aren't encrypted or bound to their keys, set it to true
+	// only after the existing values are encrypted by the rewrite-values admin command
+	ENCRYPTION_REQUIRED = "ENCRYPTION_REQUIRED"
+	// CLIENT_KEYS_HMAC_KEY is the base64 encoded key hashing the client keys in the key names, SQLite doesn't support it
+	CLIENT_KEYS_HMAC_KEY = "CLIENT_KEYS_HMAC_KEY"
+	// REDIS_ATOMIC_UPDATES is script to update the keys by Lua scripts or watch to update them by WATCH/MULTI/EXEC transactions
+	REDIS_ATOMIC_UPDATES = "REDIS_ATOMIC_UPDATES"
+	// CLIENTS_CACHE_SIZE is the number of the clients found by their keys cached in memory, 0 disables the cache
+	CLIENTS_CACHE_SIZE = "CLIENTS_CACHE_SIZE"
+	// CLIENTS_CACHE_MAX_AGE is the time after which the cached clients are read again, e.g. 10s
+	CLIENTS_CACHE_MAX_AGE = "CLIENTS_CACHE_MAX_AGE"
+	// ADMIN_ADDR is the address of the admin API separated from the public API, e.g. 127.0.0.1:9581, the admin API is disabled by default
+	ADMIN_ADDR = "ADMIN_ADDR"
+)
+
+const (
+	STORAGE_TYPE_REDIS  = "redis"
+	STORAGE_TYPE_MEMORY = "memory"
+	STORAGE_TYPE_BOLT   = "bolt"
+	STORAGE_TYPE_SQLITE = "sqlite"
+)
+
+const (
+	defaultBoltFile   = "asit.db"
+	defaultSQLiteFile = "asit.sqlite"
+	// defaultClientsCacheMaxAge bounds the staleness of the cached clients when an invalidation is lost
+	defaultClientsCacheMaxAge = 10 * time.Second
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		if err := admin.Run(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	log.Println("Starting HTTP server")
 
-	redisAddrs := requireEnv(REDIS_ADDRS)
-	redisPassword := os.Getenv(REDIS_PASSWORD)
+	var asitServer *server.Server
+	switch storageType := os.Getenv(STORAGE_TYPE); storageType {
+	case "", STORAGE_TYPE_REDIS:
+		redisAddrs := requireEnv(REDIS_ADDRS)
+		redisPassword := os.Getenv(REDIS_PASSWORD)
+		redisHashTag := os.Getenv(REDIS_HASH_TAG)
+		redisNamespace := os.Getenv(REDIS_NAMESPACE)
+		if err := db.ValidateRedisNamespace(redisNamespace); err != nil {
+			log.Printf("invalid %s environment variable value, %v", REDIS_NAMESPACE, err)
+			os.Exit(1)
+		}
+		asitServer = server.NewRedisBackedServer(redisAddrs, redisPassword, redisHashTag, redisNamespace, kvStorageOptions())
+	case STORAGE_TYPE_MEMORY:
+		log.Println("Using in-memory storage, all the data will be lost on exit")
+		asitServer = server.NewMemoryBackedServer(kvStorageOptions())
+	case STORAGE_TYPE_BOLT:
+		boltFile := os.Getenv(BOLT_FILE)
+		if boltFile == "" {
+			boltFile = defaultBoltFile
+		}
+		var err error
+		asitServer, err = server.NewBoltBackedServer(boltFile, kvStorageOptions())
+		if err != nil {
+			log.Fatal(err)
+		}
+	case STORAGE_TYPE_SQLITE:
+		sqliteFile := os.Getenv(SQLITE_FILE)
+		if sqliteFile == "" {
+			sqliteFile = defaultSQLiteFile
+		}
+		// the clients tables keep the client properties and the client keys in the plain columns
+		rejectEnv(STORAGE_TYPE_SQLITE, ENCRYPTION_KEYS, ENCRYPTION_KEYFILE, ENCRYPTION_REQUIRED, CLIENT_KEYS_HMAC_KEY)
+		var err error
+		asitServer, err = server.NewSQLiteBackedServer(sqliteFile, kvStorageOptions())
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Printf("unsupported %s environment variable value %s", STORAGE_TYPE, storageType)
+		os.Exit(1)
+	}
+
+	if trashRetention := os.Getenv(TRASH_RETENTION); trashRetention != "" {
+		retention, err := time.ParseDuration(trashRetention)
+		if err != nil || retention < 0 {
+			log.Printf("invalid %s environment variable value %s", TRASH_RETENTION, trashRetention)
+			os.Exit(1)
+		}
+		asitServer.SetTrashRetention(retention)
+	}
+
+	if cacheSize := os.Getenv(CLIENTS_CACHE_SIZE); cacheSize != "" {
+		maxEntries, err := strconv.Atoi(cacheSize)
+		if err != nil || maxEntries < 0 {
+			log.Printf("invalid %s environment variable value %s", CLIENTS_CACHE_SIZE, cacheSize)
+			os.Exit(1)
+		}
+		maxAge := defaultClientsCacheMaxAge
+		if cacheMaxAge := os.Getenv(CLIENTS_CACHE_MAX_AGE); cacheMaxAge != "" {
+			if maxAge, err = time.ParseDuration(cacheMaxAge); err != nil || maxAge <= 0 {
+				log.Printf("invalid %s environment variable value %s", CLIENTS_CACHE_MAX_AGE, cacheMaxAge)
+				os.Exit(1)
+			}
+		}
+		asitServer.SetClientsCache(maxEntries, maxAge)
+	}
+
+	asitServer.SetAdminAddr(os.Getenv(ADMIN_ADDR))
+
+	log.Fatal(asitServer.ListenAndServe())
+}
+
+// kvStorageOptions returns the options of the key-value storages configured by the environment variables
+func kvStorageOptions() server.KVStorageOptions {
+	encoding := db.ENCODING_PROTO
+	if name := os.Getenv(STORAGE_CODEC); name != "" {
+		var err error
+		if encoding, err = db.ParseCodecEncoding(name); err != nil {
+			log.Printf("invalid %s environment variable value, %v", STORAGE_CODEC, err)
+			os.Exit(1)
+		}
+	}
+	compressMinSize := 0
+	if value := os.Getenv(STORAGE_COMPRESSION_MIN_SIZE); value != "" {
+		var err error
+		if compressMinSize, err = strconv.Atoi(value); err != nil || compressMinSize < 0 {
+			log.Printf("invalid %s environment variable value %s", STORAGE_COMPRESSION_MIN_SIZE, value)
+			os.Exit(1)
+		}
+	}
+	codec, err := db.NewEnvelopeCodec(encoding, compressMinSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+	options := server.KVStorageOptions{Codec: codec}
+	if name := os.Getenv(REDIS_ATOMIC_UPDATES); name != "" {
+		if options.RedisAtomicUpdates, err = db.ParseRedisAtomicUpdates(name); err != nil {
+			log.Printf("invalid %s environment variable value, %v", REDIS_ATOMIC_UPDATES, err)
+			os.Exit(1)
+		}
+	}
+
+	var keyring *db.EncryptionKeyring
+	if keyfile := os.Getenv(ENCRYPTION_KEYFILE); keyfile != "" {
+		keyring, err = db.LoadEncryptionKeyringFile(keyfile, os.Getenv(ENCRYPTION_ACTIVE_KEY))
+	} else if keys := os.Getenv(ENCRYPTION_KEYS); keys != "" {
+		keyring, err = db.ParseEncryptionKeyring(keys, os.Getenv(ENCRYPTION_ACTIVE_KEY))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	encryptionRequired := false
+	if value := os.Getenv(ENCRYPTION_REQUIRED); value != "" {
+		if encryptionRequired, err = strconv.ParseBool(value); err != nil {
+			log.Printf("invalid %s environment variable value %s", ENCRYPTION_REQUIRED, value)
+			os.Exit(1)
+		}
+	}
+	if keyring != nil {
+		log.Println("Encrypting the stored values")
+		encryptingCodec := db.NewEncryptingCodec(codec, keyring)
+		encryptingCodec.SetEncryptionRequired(encryptionRequired)
+		options.Codec = encryptingCodec
+	} else if encryptionRequired {
+		log.Printf("the %s environment variable requires %s or %s", ENCRYPTION_REQUIRED, ENCRYPTION_KEYS, ENCRYPTION_KEYFILE)
+		os.Exit(1)
+	}
 
-	server := server.NewRedisBackedServer(redisAddrs, redisPassword)
-	log.Fatal(server.ListenAndServe())
+	if hmacKey := os.Getenv(CLIENT_KEYS_HMAC_KEY); hmacKey != "" {
+		if options.ClientKeysHMACKey, err = base64.StdEncoding.DecodeString(hmacKey); err != nil || len(options.ClientKeysHMACKey) == 0 {
+			log.Printf("invalid %s environment variable value, it must be base64 encoded", CLIENT_KEYS_HMAC_KEY)
+			os.Exit(1)
+		}
+		log.Println("Hashing the client keys in the key names")
+	}
+	return options
+}
+
+// rejectEnv exits if any of the environment variables is specified, because the storage type can't use it
+func rejectEnv(storageType string, names ...string) {
+	for _, name := range names {
+		if os.Getenv(name) != "" {
+			log.Printf("the %s environment variable is not supported by the %s storage", name, storageType)
+			os.Exit(1)
+		}
+	}
 }
 
 func requireEnv(name string) string {
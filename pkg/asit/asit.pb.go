@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.27.1
+// 	protoc-gen-go v1.28.1
 // 	protoc        v3.18.1
 // source: proto/asit.proto
 
@@ -21,6 +21,206 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type ClientChangeType int32
+
+const (
+	ClientChangeType_CLIENT_CHANGE_UNSPECIFIED ClientChangeType = 0
+	ClientChangeType_CLIENT_CREATED            ClientChangeType = 1
+	ClientChangeType_CLIENT_UPDATED            ClientChangeType = 2
+	ClientChangeType_CLIENT_DELETED            ClientChangeType = 3
+	ClientChangeType_CLIENT_RESTORED           ClientChangeType = 4
+	ClientChangeType_CLIENT_PURGED             ClientChangeType = 5
+	ClientChangeType_CLIENT_KEY_ADDED          ClientChangeType = 6
+	ClientChangeType_CLIENT_KEY_REMOVED        ClientChangeType = 7
+	ClientChangeType_CLIENT_ROLLED_BACK        ClientChangeType = 8
+	ClientChangeType_CLIENT_KEY_EXPIRED        ClientChangeType = 9
+)
+
+// Enum value maps for ClientChangeType.
+var (
+	ClientChangeType_name = map[int32]string{
+		0: "CLIENT_CHANGE_UNSPECIFIED",
+		1: "CLIENT_CREATED",
+		2: "CLIENT_UPDATED",
+		3: "CLIENT_DELETED",
+		4: "CLIENT_RESTORED",
+		5: "CLIENT_PURGED",
+		6: "CLIENT_KEY_ADDED",
+		7: "CLIENT_KEY_REMOVED",
+		8: "CLIENT_ROLLED_BACK",
+		9: "CLIENT_KEY_EXPIRED",
+	}
+	ClientChangeType_value = map[string]int32{
+		"CLIENT_CHANGE_UNSPECIFIED": 0,
+		"CLIENT_CREATED":            1,
+		"CLIENT_UPDATED":            2,
+		"CLIENT_DELETED":            3,
+		"CLIENT_RESTORED":           4,
+		"CLIENT_PURGED":             5,
+		"CLIENT_KEY_ADDED":          6,
+		"CLIENT_KEY_REMOVED":        7,
+		"CLIENT_ROLLED_BACK":        8,
+		"CLIENT_KEY_EXPIRED":        9,
+	}
+)
+
+func (x ClientChangeType) Enum() *ClientChangeType {
+	p := new(ClientChangeType)
+	*p = x
+	return p
+}
+
+func (x ClientChangeType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ClientChangeType) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_asit_proto_enumTypes[0].Descriptor()
+}
+
+func (ClientChangeType) Type() protoreflect.EnumType {
+	return &file_proto_asit_proto_enumTypes[0]
+}
+
+func (x ClientChangeType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ClientChangeType.Descriptor instead.
+func (ClientChangeType) EnumDescriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{0}
+}
+
+type ClientsInconsistencyType int32
+
+const (
+	ClientsInconsistencyType_CLIENTS_INCONSISTENCY_UNSPECIFIED ClientsInconsistencyType = 0
+	// the client isn't a member of the index
+	ClientsInconsistencyType_MISSING_INDEX_MEMBER ClientsInconsistencyType = 1
+	// the index member refers to the missing client or to the outdated value of the client
+	ClientsInconsistencyType_ORPHANED_INDEX_MEMBER ClientsInconsistencyType = 2
+	// the keys list or the key rows of the missing client
+	ClientsInconsistencyType_ORPHANED_CLIENT_KEYS ClientsInconsistencyType = 3
+	// the key listed in the client keys isn't associated with the client
+	ClientsInconsistencyType_MISSING_CLIENT_KEY_ALIAS ClientsInconsistencyType = 4
+	// the key is associated with an outdated copy of its client
+	ClientsInconsistencyType_STALE_CLIENT_KEY_ALIAS ClientsInconsistencyType = 5
+	// the key is associated with the client which doesn't list it
+	ClientsInconsistencyType_ORPHANED_CLIENT_KEY_ALIAS ClientsInconsistencyType = 6
+	// the key is listed in the keys of several clients
+	ClientsInconsistencyType_DUPLICATE_CLIENT_KEY ClientsInconsistencyType = 7
+	// the client properties of the missing client
+	ClientsInconsistencyType_ORPHANED_CLIENT_PROPERTIES ClientsInconsistencyType = 8
+)
+
+// Enum value maps for ClientsInconsistencyType.
+var (
+	ClientsInconsistencyType_name = map[int32]string{
+		0: "CLIENTS_INCONSISTENCY_UNSPECIFIED",
+		1: "MISSING_INDEX_MEMBER",
+		2: "ORPHANED_INDEX_MEMBER",
+		3: "ORPHANED_CLIENT_KEYS",
+		4: "MISSING_CLIENT_KEY_ALIAS",
+		5: "STALE_CLIENT_KEY_ALIAS",
+		6: "ORPHANED_CLIENT_KEY_ALIAS",
+		7: "DUPLICATE_CLIENT_KEY",
+		8: "ORPHANED_CLIENT_PROPERTIES",
+	}
+	ClientsInconsistencyType_value = map[string]int32{
+		"CLIENTS_INCONSISTENCY_UNSPECIFIED": 0,
+		"MISSING_INDEX_MEMBER":              1,
+		"ORPHANED_INDEX_MEMBER":             2,
+		"ORPHANED_CLIENT_KEYS":              3,
+		"MISSING_CLIENT_KEY_ALIAS":          4,
+		"STALE_CLIENT_KEY_ALIAS":            5,
+		"ORPHANED_CLIENT_KEY_ALIAS":         6,
+		"DUPLICATE_CLIENT_KEY":              7,
+		"ORPHANED_CLIENT_PROPERTIES":        8,
+	}
+)
+
+func (x ClientsInconsistencyType) Enum() *ClientsInconsistencyType {
+	p := new(ClientsInconsistencyType)
+	*p = x
+	return p
+}
+
+func (x ClientsInconsistencyType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ClientsInconsistencyType) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_asit_proto_enumTypes[1].Descriptor()
+}
+
+func (ClientsInconsistencyType) Type() protoreflect.EnumType {
+	return &file_proto_asit_proto_enumTypes[1]
+}
+
+func (x ClientsInconsistencyType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ClientsInconsistencyType.Descriptor instead.
+func (ClientsInconsistencyType) EnumDescriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{1}
+}
+
+type ImportConflictType int32
+
+const (
+	ImportConflictType_IMPORT_CONFLICT_UNSPECIFIED ImportConflictType = 0
+	// the key is listed by several imported clients, it's imported only for the first one
+	ImportConflictType_DUPLICATE_IMPORTED_KEY ImportConflictType = 1
+	// the key is associated with another existing client or reserved by a trashed client
+	ImportConflictType_CLIENT_KEY_TAKEN ImportConflictType = 2
+	// the client with the same id is in the trash, the client isn't imported
+	ImportConflictType_TRASHED_CLIENT_ID ImportConflictType = 3
+)
+
+// Enum value maps for ImportConflictType.
+var (
+	ImportConflictType_name = map[int32]string{
+		0: "IMPORT_CONFLICT_UNSPECIFIED",
+		1: "DUPLICATE_IMPORTED_KEY",
+		2: "CLIENT_KEY_TAKEN",
+		3: "TRASHED_CLIENT_ID",
+	}
+	ImportConflictType_value = map[string]int32{
+		"IMPORT_CONFLICT_UNSPECIFIED": 0,
+		"DUPLICATE_IMPORTED_KEY":      1,
+		"CLIENT_KEY_TAKEN":            2,
+		"TRASHED_CLIENT_ID":           3,
+	}
+)
+
+func (x ImportConflictType) Enum() *ImportConflictType {
+	p := new(ImportConflictType)
+	*p = x
+	return p
+}
+
+func (x ImportConflictType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ImportConflictType) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_asit_proto_enumTypes[2].Descriptor()
+}
+
+func (ImportConflictType) Type() protoreflect.EnumType {
+	return &file_proto_asit_proto_enumTypes[2]
+}
+
+func (x ImportConflictType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ImportConflictType.Descriptor instead.
+func (ImportConflictType) EnumDescriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{2}
+}
+
 type TestRunStatus int32
 
 const (
@@ -54,11 +254,11 @@ func (x TestRunStatus) String() string {
 }
 
 func (TestRunStatus) Descriptor() protoreflect.EnumDescriptor {
-	return file_proto_asit_proto_enumTypes[0].Descriptor()
+	return file_proto_asit_proto_enumTypes[3].Descriptor()
 }
 
 func (TestRunStatus) Type() protoreflect.EnumType {
-	return &file_proto_asit_proto_enumTypes[0]
+	return &file_proto_asit_proto_enumTypes[3]
 }
 
 func (x TestRunStatus) Number() protoreflect.EnumNumber {
@@ -67,7 +267,7 @@ func (x TestRunStatus) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use TestRunStatus.Descriptor instead.
 func (TestRunStatus) EnumDescriptor() ([]byte, []int) {
-	return file_proto_asit_proto_rawDescGZIP(), []int{0}
+	return file_proto_asit_proto_rawDescGZIP(), []int{3}
 }
 
 type TestStepRunStatus int32
@@ -115,11 +315,11 @@ func (x TestStepRunStatus) String() string {
 }
 
 func (TestStepRunStatus) Descriptor() protoreflect.EnumDescriptor {
-	return file_proto_asit_proto_enumTypes[1].Descriptor()
+	return file_proto_asit_proto_enumTypes[4].Descriptor()
 }
 
 func (TestStepRunStatus) Type() protoreflect.EnumType {
-	return &file_proto_asit_proto_enumTypes[1]
+	return &file_proto_asit_proto_enumTypes[4]
 }
 
 func (x TestStepRunStatus) Number() protoreflect.EnumNumber {
@@ -128,7 +328,7 @@ func (x TestStepRunStatus) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use TestStepRunStatus.Descriptor instead.
 func (TestStepRunStatus) EnumDescriptor() ([]byte, []int) {
-	return file_proto_asit_proto_rawDescGZIP(), []int{1}
+	return file_proto_asit_proto_rawDescGZIP(), []int{4}
 }
 
 type ClientList struct {
@@ -187,6 +387,8 @@ type Client struct {
 	Name             string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
 	LastUpdated      *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=lastUpdated,proto3" json:"lastUpdated,omitempty"`
 	ClientProperties map[string]string      `protobuf:"bytes,4,rep,name=clientProperties,proto3" json:"clientProperties,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// revision is incremented by every update of the client, it's used as the ETag of the client
+	Revision int64 `protobuf:"varint,5,opt,name=revision,proto3" json:"revision,omitempty"`
 }
 
 func (x *Client) Reset() {
@@ -249,19 +451,29 @@ func (x *Client) GetClientProperties() map[string]string {
 	return nil
 }
 
-type TestCase struct {
+func (x *Client) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+// TrashedClient is the removed client which can be restored until it's purged.
+// Keys of the trashed client stay reserved, so they can't be associated with another client.
+type TrashedClient struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Id          string      `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name        string      `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Description string      `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
-	Steps       []*TestStep `protobuf:"bytes,4,rep,name=steps,proto3" json:"steps,omitempty"`
+	Client  *Client                `protobuf:"bytes,1,opt,name=client,proto3" json:"client,omitempty"`
+	Keys    []string               `protobuf:"bytes,2,rep,name=keys,proto3" json:"keys,omitempty"`
+	Deleted *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	// keysExpires are the expiration times of the keys added with TTL
+	KeysExpires map[string]*timestamppb.Timestamp `protobuf:"bytes,4,rep,name=keysExpires,proto3" json:"keysExpires,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 }
 
-func (x *TestCase) Reset() {
-	*x = TestCase{}
+func (x *TrashedClient) Reset() {
+	*x = TrashedClient{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_proto_asit_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -269,13 +481,13 @@ func (x *TestCase) Reset() {
 	}
 }
 
-func (x *TestCase) String() string {
+func (x *TrashedClient) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TestCase) ProtoMessage() {}
+func (*TrashedClient) ProtoMessage() {}
 
-func (x *TestCase) ProtoReflect() protoreflect.Message {
+func (x *TrashedClient) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_asit_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -287,52 +499,62 @@ func (x *TestCase) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TestCase.ProtoReflect.Descriptor instead.
-func (*TestCase) Descriptor() ([]byte, []int) {
+// Deprecated: Use TrashedClient.ProtoReflect.Descriptor instead.
+func (*TrashedClient) Descriptor() ([]byte, []int) {
 	return file_proto_asit_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *TestCase) GetId() string {
+func (x *TrashedClient) GetClient() *Client {
 	if x != nil {
-		return x.Id
+		return x.Client
 	}
-	return ""
+	return nil
 }
 
-func (x *TestCase) GetName() string {
+func (x *TrashedClient) GetKeys() []string {
 	if x != nil {
-		return x.Name
+		return x.Keys
 	}
-	return ""
+	return nil
 }
 
-func (x *TestCase) GetDescription() string {
+func (x *TrashedClient) GetDeleted() *timestamppb.Timestamp {
 	if x != nil {
-		return x.Description
+		return x.Deleted
 	}
-	return ""
+	return nil
 }
 
-func (x *TestCase) GetSteps() []*TestStep {
+func (x *TrashedClient) GetKeysExpires() map[string]*timestamppb.Timestamp {
 	if x != nil {
-		return x.Steps
+		return x.KeysExpires
 	}
 	return nil
 }
 
-type TestSuite struct {
+// ClientHistoryEntry is an append-only record of a client change.
+// The client changes have the old and new client values, the key changes have the old and new keys of the client.
+type ClientHistoryEntry struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Id          string      `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name        string      `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Description string      `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
-	Tests       []*TestCase `protobuf:"bytes,4,rep,name=tests,proto3" json:"tests,omitempty"`
-}
-
-func (x *TestSuite) Reset() {
-	*x = TestSuite{}
+	// sequence is the number of the entry in the client history starting from 1
+	Sequence  int64                  `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Change    ClientChangeType       `protobuf:"varint,2,opt,name=change,proto3,enum=asit.ClientChangeType" json:"change,omitempty"`
+	Time      *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	RequestId string                 `protobuf:"bytes,4,opt,name=requestId,proto3" json:"requestId,omitempty"`
+	Caller    string                 `protobuf:"bytes,5,opt,name=caller,proto3" json:"caller,omitempty"`
+	OldClient *Client                `protobuf:"bytes,6,opt,name=oldClient,proto3" json:"oldClient,omitempty"`
+	NewClient *Client                `protobuf:"bytes,7,opt,name=newClient,proto3" json:"newClient,omitempty"`
+	// key is the added, removed or expired client key
+	Key     string   `protobuf:"bytes,8,opt,name=key,proto3" json:"key,omitempty"`
+	OldKeys []string `protobuf:"bytes,9,rep,name=oldKeys,proto3" json:"oldKeys,omitempty"`
+	NewKeys []string `protobuf:"bytes,10,rep,name=newKeys,proto3" json:"newKeys,omitempty"`
+}
+
+func (x *ClientHistoryEntry) Reset() {
+	*x = ClientHistoryEntry{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_proto_asit_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -340,13 +562,13 @@ func (x *TestSuite) Reset() {
 	}
 }
 
-func (x *TestSuite) String() string {
+func (x *ClientHistoryEntry) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TestSuite) ProtoMessage() {}
+func (*ClientHistoryEntry) ProtoMessage() {}
 
-func (x *TestSuite) ProtoReflect() protoreflect.Message {
+func (x *ClientHistoryEntry) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_asit_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -358,53 +580,99 @@ func (x *TestSuite) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TestSuite.ProtoReflect.Descriptor instead.
-func (*TestSuite) Descriptor() ([]byte, []int) {
+// Deprecated: Use ClientHistoryEntry.ProtoReflect.Descriptor instead.
+func (*ClientHistoryEntry) Descriptor() ([]byte, []int) {
 	return file_proto_asit_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *TestSuite) GetId() string {
+func (x *ClientHistoryEntry) GetSequence() int64 {
 	if x != nil {
-		return x.Id
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *ClientHistoryEntry) GetChange() ClientChangeType {
+	if x != nil {
+		return x.Change
+	}
+	return ClientChangeType_CLIENT_CHANGE_UNSPECIFIED
+}
+
+func (x *ClientHistoryEntry) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *ClientHistoryEntry) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
 	}
 	return ""
 }
 
-func (x *TestSuite) GetName() string {
+func (x *ClientHistoryEntry) GetCaller() string {
 	if x != nil {
-		return x.Name
+		return x.Caller
 	}
 	return ""
 }
 
-func (x *TestSuite) GetDescription() string {
+func (x *ClientHistoryEntry) GetOldClient() *Client {
 	if x != nil {
-		return x.Description
+		return x.OldClient
+	}
+	return nil
+}
+
+func (x *ClientHistoryEntry) GetNewClient() *Client {
+	if x != nil {
+		return x.NewClient
+	}
+	return nil
+}
+
+func (x *ClientHistoryEntry) GetKey() string {
+	if x != nil {
+		return x.Key
 	}
 	return ""
 }
 
-func (x *TestSuite) GetTests() []*TestCase {
+func (x *ClientHistoryEntry) GetOldKeys() []string {
 	if x != nil {
-		return x.Tests
+		return x.OldKeys
 	}
 	return nil
 }
 
-type TestStep struct {
+func (x *ClientHistoryEntry) GetNewKeys() []string {
+	if x != nil {
+		return x.NewKeys
+	}
+	return nil
+}
+
+// ClientsInconsistency is a mismatch between the stored values describing the clients
+type ClientsInconsistency struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Id           string            `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name         string            `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Description  string            `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
-	Action       *TestAction       `protobuf:"bytes,4,opt,name=action,proto3" json:"action,omitempty"`
-	Verification *TestVerification `protobuf:"bytes,5,opt,name=verification,proto3" json:"verification,omitempty"`
+	Type        ClientsInconsistencyType `protobuf:"varint,1,opt,name=type,proto3,enum=asit.ClientsInconsistencyType" json:"type,omitempty"`
+	ClientId    string                   `protobuf:"bytes,2,opt,name=clientId,proto3" json:"clientId,omitempty"`
+	Key         string                   `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
+	Index       string                   `protobuf:"bytes,4,opt,name=index,proto3" json:"index,omitempty"`
+	Member      string                   `protobuf:"bytes,5,opt,name=member,proto3" json:"member,omitempty"`
+	Description string                   `protobuf:"bytes,6,opt,name=description,proto3" json:"description,omitempty"`
+	// repaired is true if the inconsistency has been repaired or has disappeared before the repair
+	Repaired bool `protobuf:"varint,7,opt,name=repaired,proto3" json:"repaired,omitempty"`
 }
 
-func (x *TestStep) Reset() {
-	*x = TestStep{}
+func (x *ClientsInconsistency) Reset() {
+	*x = ClientsInconsistency{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_proto_asit_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -412,13 +680,13 @@ func (x *TestStep) Reset() {
 	}
 }
 
-func (x *TestStep) String() string {
+func (x *ClientsInconsistency) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TestStep) ProtoMessage() {}
+func (*ClientsInconsistency) ProtoMessage() {}
 
-func (x *TestStep) ProtoReflect() protoreflect.Message {
+func (x *ClientsInconsistency) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_asit_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -430,57 +698,72 @@ func (x *TestStep) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TestStep.ProtoReflect.Descriptor instead.
-func (*TestStep) Descriptor() ([]byte, []int) {
+// Deprecated: Use ClientsInconsistency.ProtoReflect.Descriptor instead.
+func (*ClientsInconsistency) Descriptor() ([]byte, []int) {
 	return file_proto_asit_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *TestStep) GetId() string {
+func (x *ClientsInconsistency) GetType() ClientsInconsistencyType {
 	if x != nil {
-		return x.Id
+		return x.Type
+	}
+	return ClientsInconsistencyType_CLIENTS_INCONSISTENCY_UNSPECIFIED
+}
+
+func (x *ClientsInconsistency) GetClientId() string {
+	if x != nil {
+		return x.ClientId
 	}
 	return ""
 }
 
-func (x *TestStep) GetName() string {
+func (x *ClientsInconsistency) GetKey() string {
 	if x != nil {
-		return x.Name
+		return x.Key
 	}
 	return ""
 }
 
-func (x *TestStep) GetDescription() string {
+func (x *ClientsInconsistency) GetIndex() string {
 	if x != nil {
-		return x.Description
+		return x.Index
 	}
 	return ""
 }
 
-func (x *TestStep) GetAction() *TestAction {
+func (x *ClientsInconsistency) GetMember() string {
 	if x != nil {
-		return x.Action
+		return x.Member
 	}
-	return nil
+	return ""
 }
 
-func (x *TestStep) GetVerification() *TestVerification {
+func (x *ClientsInconsistency) GetDescription() string {
 	if x != nil {
-		return x.Verification
+		return x.Description
 	}
-	return nil
+	return ""
 }
 
-type TestAction struct {
+func (x *ClientsInconsistency) GetRepaired() bool {
+	if x != nil {
+		return x.Repaired
+	}
+	return false
+}
+
+type ClientsConsistencyReport struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Function  string            `protobuf:"bytes,1,opt,name=function,proto3" json:"function,omitempty"`
-	Arguments map[string]string `protobuf:"bytes,2,rep,name=arguments,proto3" json:"arguments,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	CheckedClients  int64                   `protobuf:"varint,1,opt,name=checkedClients,proto3" json:"checkedClients,omitempty"`
+	CheckedKeys     int64                   `protobuf:"varint,2,opt,name=checkedKeys,proto3" json:"checkedKeys,omitempty"`
+	Inconsistencies []*ClientsInconsistency `protobuf:"bytes,3,rep,name=inconsistencies,proto3" json:"inconsistencies,omitempty"`
 }
 
-func (x *TestAction) Reset() {
-	*x = TestAction{}
+func (x *ClientsConsistencyReport) Reset() {
+	*x = ClientsConsistencyReport{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_proto_asit_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -488,13 +771,13 @@ func (x *TestAction) Reset() {
 	}
 }
 
-func (x *TestAction) String() string {
+func (x *ClientsConsistencyReport) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TestAction) ProtoMessage() {}
+func (*ClientsConsistencyReport) ProtoMessage() {}
 
-func (x *TestAction) ProtoReflect() protoreflect.Message {
+func (x *ClientsConsistencyReport) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_asit_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -506,36 +789,45 @@ func (x *TestAction) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TestAction.ProtoReflect.Descriptor instead.
-func (*TestAction) Descriptor() ([]byte, []int) {
+// Deprecated: Use ClientsConsistencyReport.ProtoReflect.Descriptor instead.
+func (*ClientsConsistencyReport) Descriptor() ([]byte, []int) {
 	return file_proto_asit_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *TestAction) GetFunction() string {
+func (x *ClientsConsistencyReport) GetCheckedClients() int64 {
 	if x != nil {
-		return x.Function
+		return x.CheckedClients
 	}
-	return ""
+	return 0
 }
 
-func (x *TestAction) GetArguments() map[string]string {
+func (x *ClientsConsistencyReport) GetCheckedKeys() int64 {
 	if x != nil {
-		return x.Arguments
+		return x.CheckedKeys
+	}
+	return 0
+}
+
+func (x *ClientsConsistencyReport) GetInconsistencies() []*ClientsInconsistency {
+	if x != nil {
+		return x.Inconsistencies
 	}
 	return nil
 }
 
-type TestCheck struct {
+// ExportHeader is the first record of the export file
+type ExportHeader struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Function  string            `protobuf:"bytes,1,opt,name=function,proto3" json:"function,omitempty"`
-	Arguments map[string]string `protobuf:"bytes,2,rep,name=arguments,proto3" json:"arguments,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// version is the version of the export file format
+	Version int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Created *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=created,proto3" json:"created,omitempty"`
 }
 
-func (x *TestCheck) Reset() {
-	*x = TestCheck{}
+func (x *ExportHeader) Reset() {
+	*x = ExportHeader{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_proto_asit_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -543,13 +835,13 @@ func (x *TestCheck) Reset() {
 	}
 }
 
-func (x *TestCheck) String() string {
+func (x *ExportHeader) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TestCheck) ProtoMessage() {}
+func (*ExportHeader) ProtoMessage() {}
 
-func (x *TestCheck) ProtoReflect() protoreflect.Message {
+func (x *ExportHeader) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_asit_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -561,35 +853,39 @@ func (x *TestCheck) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TestCheck.ProtoReflect.Descriptor instead.
-func (*TestCheck) Descriptor() ([]byte, []int) {
+// Deprecated: Use ExportHeader.ProtoReflect.Descriptor instead.
+func (*ExportHeader) Descriptor() ([]byte, []int) {
 	return file_proto_asit_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *TestCheck) GetFunction() string {
+func (x *ExportHeader) GetVersion() int32 {
 	if x != nil {
-		return x.Function
+		return x.Version
 	}
-	return ""
+	return 0
 }
 
-func (x *TestCheck) GetArguments() map[string]string {
+func (x *ExportHeader) GetCreated() *timestamppb.Timestamp {
 	if x != nil {
-		return x.Arguments
+		return x.Created
 	}
 	return nil
 }
 
-type TestVerification struct {
+// ExportedClient is the client with its properties and the keys which are not expired at the time of the export
+type ExportedClient struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Checks []*TestCheck `protobuf:"bytes,1,rep,name=checks,proto3" json:"checks,omitempty"`
+	Client *Client  `protobuf:"bytes,1,opt,name=client,proto3" json:"client,omitempty"`
+	Keys   []string `protobuf:"bytes,2,rep,name=keys,proto3" json:"keys,omitempty"`
+	// keysExpires are the expiration times of the keys added with TTL
+	KeysExpires map[string]*timestamppb.Timestamp `protobuf:"bytes,3,rep,name=keysExpires,proto3" json:"keysExpires,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 }
 
-func (x *TestVerification) Reset() {
-	*x = TestVerification{}
+func (x *ExportedClient) Reset() {
+	*x = ExportedClient{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_proto_asit_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -597,13 +893,13 @@ func (x *TestVerification) Reset() {
 	}
 }
 
-func (x *TestVerification) String() string {
+func (x *ExportedClient) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TestVerification) ProtoMessage() {}
+func (*ExportedClient) ProtoMessage() {}
 
-func (x *TestVerification) ProtoReflect() protoreflect.Message {
+func (x *ExportedClient) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_asit_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -615,20 +911,759 @@ func (x *TestVerification) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TestVerification.ProtoReflect.Descriptor instead.
-func (*TestVerification) Descriptor() ([]byte, []int) {
+// Deprecated: Use ExportedClient.ProtoReflect.Descriptor instead.
+func (*ExportedClient) Descriptor() ([]byte, []int) {
 	return file_proto_asit_proto_rawDescGZIP(), []int{7}
 }
 
-func (x *TestVerification) GetChecks() []*TestCheck {
+func (x *ExportedClient) GetClient() *Client {
 	if x != nil {
-		return x.Checks
+		return x.Client
 	}
 	return nil
 }
 
-type TestRun struct {
-	state         protoimpl.MessageState
+func (x *ExportedClient) GetKeys() []string {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+func (x *ExportedClient) GetKeysExpires() map[string]*timestamppb.Timestamp {
+	if x != nil {
+		return x.KeysExpires
+	}
+	return nil
+}
+
+// ExportRecord is a record of the export file, the file is a stream of records starting with the header
+type ExportRecord struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Record:
+	//	*ExportRecord_Header
+	//	*ExportRecord_Client
+	Record isExportRecord_Record `protobuf_oneof:"record"`
+}
+
+func (x *ExportRecord) Reset() {
+	*x = ExportRecord{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExportRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportRecord) ProtoMessage() {}
+
+func (x *ExportRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportRecord.ProtoReflect.Descriptor instead.
+func (*ExportRecord) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{8}
+}
+
+func (m *ExportRecord) GetRecord() isExportRecord_Record {
+	if m != nil {
+		return m.Record
+	}
+	return nil
+}
+
+func (x *ExportRecord) GetHeader() *ExportHeader {
+	if x, ok := x.GetRecord().(*ExportRecord_Header); ok {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *ExportRecord) GetClient() *ExportedClient {
+	if x, ok := x.GetRecord().(*ExportRecord_Client); ok {
+		return x.Client
+	}
+	return nil
+}
+
+type isExportRecord_Record interface {
+	isExportRecord_Record()
+}
+
+type ExportRecord_Header struct {
+	Header *ExportHeader `protobuf:"bytes,1,opt,name=header,proto3,oneof"`
+}
+
+type ExportRecord_Client struct {
+	Client *ExportedClient `protobuf:"bytes,2,opt,name=client,proto3,oneof"`
+}
+
+func (*ExportRecord_Header) isExportRecord_Record() {}
+
+func (*ExportRecord_Client) isExportRecord_Record() {}
+
+// ImportConflict is the imported value skipped by the import
+type ImportConflict struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type        ImportConflictType `protobuf:"varint,1,opt,name=type,proto3,enum=asit.ImportConflictType" json:"type,omitempty"`
+	ClientId    string             `protobuf:"bytes,2,opt,name=clientId,proto3" json:"clientId,omitempty"`
+	Key         string             `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
+	Description string             `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+}
+
+func (x *ImportConflict) Reset() {
+	*x = ImportConflict{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImportConflict) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportConflict) ProtoMessage() {}
+
+func (x *ImportConflict) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportConflict.ProtoReflect.Descriptor instead.
+func (*ImportConflict) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ImportConflict) GetType() ImportConflictType {
+	if x != nil {
+		return x.Type
+	}
+	return ImportConflictType_IMPORT_CONFLICT_UNSPECIFIED
+}
+
+func (x *ImportConflict) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *ImportConflict) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *ImportConflict) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+type ImportReport struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Mode           string `protobuf:"bytes,1,opt,name=mode,proto3" json:"mode,omitempty"`
+	DryRun         bool   `protobuf:"varint,2,opt,name=dryRun,proto3" json:"dryRun,omitempty"`
+	CreatedClients int64  `protobuf:"varint,3,opt,name=createdClients,proto3" json:"createdClients,omitempty"`
+	UpdatedClients int64  `protobuf:"varint,4,opt,name=updatedClients,proto3" json:"updatedClients,omitempty"`
+	// removedClients are the clients purged by the replace mode
+	RemovedClients int64             `protobuf:"varint,5,opt,name=removedClients,proto3" json:"removedClients,omitempty"`
+	ImportedKeys   int64             `protobuf:"varint,6,opt,name=importedKeys,proto3" json:"importedKeys,omitempty"`
+	Conflicts      []*ImportConflict `protobuf:"bytes,7,rep,name=conflicts,proto3" json:"conflicts,omitempty"`
+}
+
+func (x *ImportReport) Reset() {
+	*x = ImportReport{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImportReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportReport) ProtoMessage() {}
+
+func (x *ImportReport) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportReport.ProtoReflect.Descriptor instead.
+func (*ImportReport) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ImportReport) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *ImportReport) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *ImportReport) GetCreatedClients() int64 {
+	if x != nil {
+		return x.CreatedClients
+	}
+	return 0
+}
+
+func (x *ImportReport) GetUpdatedClients() int64 {
+	if x != nil {
+		return x.UpdatedClients
+	}
+	return 0
+}
+
+func (x *ImportReport) GetRemovedClients() int64 {
+	if x != nil {
+		return x.RemovedClients
+	}
+	return 0
+}
+
+func (x *ImportReport) GetImportedKeys() int64 {
+	if x != nil {
+		return x.ImportedKeys
+	}
+	return 0
+}
+
+func (x *ImportReport) GetConflicts() []*ImportConflict {
+	if x != nil {
+		return x.Conflicts
+	}
+	return nil
+}
+
+// ClientsCacheStats are the statistics of the GetClientByKey cache of the ASIT instance since its start
+type ClientsCacheStats struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hits   int64 `protobuf:"varint,1,opt,name=hits,proto3" json:"hits,omitempty"`
+	Misses int64 `protobuf:"varint,2,opt,name=misses,proto3" json:"misses,omitempty"`
+	// evictions are the entries removed to keep the size of the cache
+	Evictions int64 `protobuf:"varint,3,opt,name=evictions,proto3" json:"evictions,omitempty"`
+	// invalidations are the local and the received invalidations of the changed clients
+	Invalidations int64   `protobuf:"varint,4,opt,name=invalidations,proto3" json:"invalidations,omitempty"`
+	Entries       int64   `protobuf:"varint,5,opt,name=entries,proto3" json:"entries,omitempty"`
+	MaxEntries    int64   `protobuf:"varint,6,opt,name=maxEntries,proto3" json:"maxEntries,omitempty"`
+	MaxAgeSeconds float64 `protobuf:"fixed64,7,opt,name=maxAgeSeconds,proto3" json:"maxAgeSeconds,omitempty"`
+}
+
+func (x *ClientsCacheStats) Reset() {
+	*x = ClientsCacheStats{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientsCacheStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientsCacheStats) ProtoMessage() {}
+
+func (x *ClientsCacheStats) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientsCacheStats.ProtoReflect.Descriptor instead.
+func (*ClientsCacheStats) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ClientsCacheStats) GetHits() int64 {
+	if x != nil {
+		return x.Hits
+	}
+	return 0
+}
+
+func (x *ClientsCacheStats) GetMisses() int64 {
+	if x != nil {
+		return x.Misses
+	}
+	return 0
+}
+
+func (x *ClientsCacheStats) GetEvictions() int64 {
+	if x != nil {
+		return x.Evictions
+	}
+	return 0
+}
+
+func (x *ClientsCacheStats) GetInvalidations() int64 {
+	if x != nil {
+		return x.Invalidations
+	}
+	return 0
+}
+
+func (x *ClientsCacheStats) GetEntries() int64 {
+	if x != nil {
+		return x.Entries
+	}
+	return 0
+}
+
+func (x *ClientsCacheStats) GetMaxEntries() int64 {
+	if x != nil {
+		return x.MaxEntries
+	}
+	return 0
+}
+
+func (x *ClientsCacheStats) GetMaxAgeSeconds() float64 {
+	if x != nil {
+		return x.MaxAgeSeconds
+	}
+	return 0
+}
+
+type TestCase struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id          string      `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name        string      `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description string      `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Steps       []*TestStep `protobuf:"bytes,4,rep,name=steps,proto3" json:"steps,omitempty"`
+}
+
+func (x *TestCase) Reset() {
+	*x = TestCase{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TestCase) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TestCase) ProtoMessage() {}
+
+func (x *TestCase) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TestCase.ProtoReflect.Descriptor instead.
+func (*TestCase) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *TestCase) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *TestCase) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TestCase) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *TestCase) GetSteps() []*TestStep {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+type TestSuite struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id          string      `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name        string      `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description string      `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Tests       []*TestCase `protobuf:"bytes,4,rep,name=tests,proto3" json:"tests,omitempty"`
+}
+
+func (x *TestSuite) Reset() {
+	*x = TestSuite{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TestSuite) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TestSuite) ProtoMessage() {}
+
+func (x *TestSuite) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TestSuite.ProtoReflect.Descriptor instead.
+func (*TestSuite) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *TestSuite) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *TestSuite) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TestSuite) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *TestSuite) GetTests() []*TestCase {
+	if x != nil {
+		return x.Tests
+	}
+	return nil
+}
+
+type TestStep struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id           string            `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name         string            `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description  string            `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Action       *TestAction       `protobuf:"bytes,4,opt,name=action,proto3" json:"action,omitempty"`
+	Verification *TestVerification `protobuf:"bytes,5,opt,name=verification,proto3" json:"verification,omitempty"`
+}
+
+func (x *TestStep) Reset() {
+	*x = TestStep{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TestStep) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TestStep) ProtoMessage() {}
+
+func (x *TestStep) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TestStep.ProtoReflect.Descriptor instead.
+func (*TestStep) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *TestStep) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *TestStep) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TestStep) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *TestStep) GetAction() *TestAction {
+	if x != nil {
+		return x.Action
+	}
+	return nil
+}
+
+func (x *TestStep) GetVerification() *TestVerification {
+	if x != nil {
+		return x.Verification
+	}
+	return nil
+}
+
+type TestAction struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Function  string            `protobuf:"bytes,1,opt,name=function,proto3" json:"function,omitempty"`
+	Arguments map[string]string `protobuf:"bytes,2,rep,name=arguments,proto3" json:"arguments,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *TestAction) Reset() {
+	*x = TestAction{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TestAction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TestAction) ProtoMessage() {}
+
+func (x *TestAction) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TestAction.ProtoReflect.Descriptor instead.
+func (*TestAction) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *TestAction) GetFunction() string {
+	if x != nil {
+		return x.Function
+	}
+	return ""
+}
+
+func (x *TestAction) GetArguments() map[string]string {
+	if x != nil {
+		return x.Arguments
+	}
+	return nil
+}
+
+type TestCheck struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Function  string            `protobuf:"bytes,1,opt,name=function,proto3" json:"function,omitempty"`
+	Arguments map[string]string `protobuf:"bytes,2,rep,name=arguments,proto3" json:"arguments,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *TestCheck) Reset() {
+	*x = TestCheck{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TestCheck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TestCheck) ProtoMessage() {}
+
+func (x *TestCheck) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TestCheck.ProtoReflect.Descriptor instead.
+func (*TestCheck) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *TestCheck) GetFunction() string {
+	if x != nil {
+		return x.Function
+	}
+	return ""
+}
+
+func (x *TestCheck) GetArguments() map[string]string {
+	if x != nil {
+		return x.Arguments
+	}
+	return nil
+}
+
+type TestVerification struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Checks []*TestCheck `protobuf:"bytes,1,rep,name=checks,proto3" json:"checks,omitempty"`
+}
+
+func (x *TestVerification) Reset() {
+	*x = TestVerification{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TestVerification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TestVerification) ProtoMessage() {}
+
+func (x *TestVerification) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TestVerification.ProtoReflect.Descriptor instead.
+func (*TestVerification) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *TestVerification) GetChecks() []*TestCheck {
+	if x != nil {
+		return x.Checks
+	}
+	return nil
+}
+
+type TestRun struct {
+	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
@@ -638,25 +1673,290 @@ type TestRun struct {
 	State             *TestState             `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"`
 	StatusDescription string                 `protobuf:"bytes,5,opt,name=statusDescription,proto3" json:"statusDescription,omitempty"`
 	LastUpdated       *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=lastUpdated,proto3" json:"lastUpdated,omitempty"`
+	ClientId          string                 `protobuf:"bytes,7,opt,name=clientId,proto3" json:"clientId,omitempty"`
+	// testSuite is the snapshot of the suite taken at the start, so the changes of the suite don't affect the started runs
+	TestSuite *TestSuite `protobuf:"bytes,8,opt,name=testSuite,proto3" json:"testSuite,omitempty"`
+}
+
+func (x *TestRun) Reset() {
+	*x = TestRun{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TestRun) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TestRun) ProtoMessage() {}
+
+func (x *TestRun) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TestRun.ProtoReflect.Descriptor instead.
+func (*TestRun) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *TestRun) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *TestRun) GetTestSuiteId() string {
+	if x != nil {
+		return x.TestSuiteId
+	}
+	return ""
+}
+
+func (x *TestRun) GetStatus() TestRunStatus {
+	if x != nil {
+		return x.Status
+	}
+	return TestRunStatus_STARTED
+}
+
+func (x *TestRun) GetState() *TestState {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+func (x *TestRun) GetStatusDescription() string {
+	if x != nil {
+		return x.StatusDescription
+	}
+	return ""
+}
+
+func (x *TestRun) GetLastUpdated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastUpdated
+	}
+	return nil
+}
+
+func (x *TestRun) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *TestRun) GetTestSuite() *TestSuite {
+	if x != nil {
+		return x.TestSuite
+	}
+	return nil
+}
+
+// PendingTestAction is the action of the step claimed by the agent of the client, the agent reports its result to the step of the run
+type PendingTestAction struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TestRunId  string      `protobuf:"bytes,1,opt,name=testRunId,proto3" json:"testRunId,omitempty"`
+	TestStepId string      `protobuf:"bytes,2,opt,name=testStepId,proto3" json:"testStepId,omitempty"`
+	Action     *TestAction `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	// claimExpires is the deadline of the result of the action, after it the action may be claimed again
+	ClaimExpires *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=claimExpires,proto3" json:"claimExpires,omitempty"`
+	// claimAttempt identifies the claim, the agent reports the result and the status of the step with it
+	ClaimAttempt int32 `protobuf:"varint,5,opt,name=claimAttempt,proto3" json:"claimAttempt,omitempty"`
+}
+
+func (x *PendingTestAction) Reset() {
+	*x = PendingTestAction{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PendingTestAction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PendingTestAction) ProtoMessage() {}
+
+func (x *PendingTestAction) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PendingTestAction.ProtoReflect.Descriptor instead.
+func (*PendingTestAction) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *PendingTestAction) GetTestRunId() string {
+	if x != nil {
+		return x.TestRunId
+	}
+	return ""
+}
+
+func (x *PendingTestAction) GetTestStepId() string {
+	if x != nil {
+		return x.TestStepId
+	}
+	return ""
+}
+
+func (x *PendingTestAction) GetAction() *TestAction {
+	if x != nil {
+		return x.Action
+	}
+	return nil
+}
+
+func (x *PendingTestAction) GetClaimExpires() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ClaimExpires
+	}
+	return nil
+}
+
+func (x *PendingTestAction) GetClaimAttempt() int32 {
+	if x != nil {
+		return x.ClaimAttempt
+	}
+	return 0
+}
+
+// TestActionResult is the result of the claimed action reported by the agent, the data is verified by the checks of the step
+type TestActionResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Success           bool              `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	StatusDescription string            `protobuf:"bytes,2,opt,name=statusDescription,proto3" json:"statusDescription,omitempty"`
+	Logs              []string          `protobuf:"bytes,3,rep,name=logs,proto3" json:"logs,omitempty"`
+	Data              map[string]string `protobuf:"bytes,4,rep,name=data,proto3" json:"data,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// claimAttempt is the attempt of the claimed action, the results of the stale claims are rejected
+	ClaimAttempt int32 `protobuf:"varint,5,opt,name=claimAttempt,proto3" json:"claimAttempt,omitempty"`
+}
+
+func (x *TestActionResult) Reset() {
+	*x = TestActionResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TestActionResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TestActionResult) ProtoMessage() {}
+
+func (x *TestActionResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TestActionResult.ProtoReflect.Descriptor instead.
+func (*TestActionResult) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *TestActionResult) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *TestActionResult) GetStatusDescription() string {
+	if x != nil {
+		return x.StatusDescription
+	}
+	return ""
+}
+
+func (x *TestActionResult) GetLogs() []string {
+	if x != nil {
+		return x.Logs
+	}
+	return nil
+}
+
+func (x *TestActionResult) GetData() map[string]string {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *TestActionResult) GetClaimAttempt() int32 {
+	if x != nil {
+		return x.ClaimAttempt
+	}
+	return 0
+}
+
+// StartTestRunRequest starts the run of the suite for the client specified by its id or by one of its keys
+type StartTestRunRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TestSuiteId string `protobuf:"bytes,1,opt,name=testSuiteId,proto3" json:"testSuiteId,omitempty"`
+	ClientId    string `protobuf:"bytes,2,opt,name=clientId,proto3" json:"clientId,omitempty"`
+	ClientKey   string `protobuf:"bytes,3,opt,name=clientKey,proto3" json:"clientKey,omitempty"`
 }
 
-func (x *TestRun) Reset() {
-	*x = TestRun{}
+func (x *StartTestRunRequest) Reset() {
+	*x = StartTestRunRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_asit_proto_msgTypes[8]
+		mi := &file_proto_asit_proto_msgTypes[21]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *TestRun) String() string {
+func (x *StartTestRunRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TestRun) ProtoMessage() {}
+func (*StartTestRunRequest) ProtoMessage() {}
 
-func (x *TestRun) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_asit_proto_msgTypes[8]
+func (x *StartTestRunRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[21]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -667,51 +1967,30 @@ func (x *TestRun) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TestRun.ProtoReflect.Descriptor instead.
-func (*TestRun) Descriptor() ([]byte, []int) {
-	return file_proto_asit_proto_rawDescGZIP(), []int{8}
-}
-
-func (x *TestRun) GetId() string {
-	if x != nil {
-		return x.Id
-	}
-	return ""
+// Deprecated: Use StartTestRunRequest.ProtoReflect.Descriptor instead.
+func (*StartTestRunRequest) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{21}
 }
 
-func (x *TestRun) GetTestSuiteId() string {
+func (x *StartTestRunRequest) GetTestSuiteId() string {
 	if x != nil {
 		return x.TestSuiteId
 	}
 	return ""
 }
 
-func (x *TestRun) GetStatus() TestRunStatus {
-	if x != nil {
-		return x.Status
-	}
-	return TestRunStatus_STARTED
-}
-
-func (x *TestRun) GetState() *TestState {
-	if x != nil {
-		return x.State
-	}
-	return nil
-}
-
-func (x *TestRun) GetStatusDescription() string {
+func (x *StartTestRunRequest) GetClientId() string {
 	if x != nil {
-		return x.StatusDescription
+		return x.ClientId
 	}
 	return ""
 }
 
-func (x *TestRun) GetLastUpdated() *timestamppb.Timestamp {
+func (x *StartTestRunRequest) GetClientKey() string {
 	if x != nil {
-		return x.LastUpdated
+		return x.ClientKey
 	}
-	return nil
+	return ""
 }
 
 type TestStepRun struct {
@@ -724,12 +2003,17 @@ type TestStepRun struct {
 	Logs              []string          `protobuf:"bytes,3,rep,name=logs,proto3" json:"logs,omitempty"`
 	StatusDescription string            `protobuf:"bytes,4,opt,name=statusDescription,proto3" json:"statusDescription,omitempty"`
 	Data              map[string]string `protobuf:"bytes,5,rep,name=data,proto3" json:"data,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// claimExpires is the deadline of the result of the action claimed by the agent, the expired action may be claimed again
+	ClaimExpires *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=claimExpires,proto3" json:"claimExpires,omitempty"`
+	// claimAttempt is the number of the claims of the action, 0 if it isn't claimed. The step changes only by the reports
+	// of the last claim, so the agent reporting after its claim has expired doesn't overwrite the result of the next agent.
+	ClaimAttempt int32 `protobuf:"varint,7,opt,name=claimAttempt,proto3" json:"claimAttempt,omitempty"`
 }
 
 func (x *TestStepRun) Reset() {
 	*x = TestStepRun{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_asit_proto_msgTypes[9]
+		mi := &file_proto_asit_proto_msgTypes[22]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -742,7 +2026,7 @@ func (x *TestStepRun) String() string {
 func (*TestStepRun) ProtoMessage() {}
 
 func (x *TestStepRun) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_asit_proto_msgTypes[9]
+	mi := &file_proto_asit_proto_msgTypes[22]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -755,7 +2039,7 @@ func (x *TestStepRun) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TestStepRun.ProtoReflect.Descriptor instead.
 func (*TestStepRun) Descriptor() ([]byte, []int) {
-	return file_proto_asit_proto_rawDescGZIP(), []int{9}
+	return file_proto_asit_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *TestStepRun) GetTestStepId() string {
@@ -793,6 +2077,20 @@ func (x *TestStepRun) GetData() map[string]string {
 	return nil
 }
 
+func (x *TestStepRun) GetClaimExpires() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ClaimExpires
+	}
+	return nil
+}
+
+func (x *TestStepRun) GetClaimAttempt() int32 {
+	if x != nil {
+		return x.ClaimAttempt
+	}
+	return 0
+}
+
 type TestState struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -807,7 +2105,7 @@ type TestState struct {
 func (x *TestState) Reset() {
 	*x = TestState{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_asit_proto_msgTypes[10]
+		mi := &file_proto_asit_proto_msgTypes[23]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -820,7 +2118,7 @@ func (x *TestState) String() string {
 func (*TestState) ProtoMessage() {}
 
 func (x *TestState) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_asit_proto_msgTypes[10]
+	mi := &file_proto_asit_proto_msgTypes[23]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -833,7 +2131,7 @@ func (x *TestState) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TestState.ProtoReflect.Descriptor instead.
 func (*TestState) Descriptor() ([]byte, []int) {
-	return file_proto_asit_proto_rawDescGZIP(), []int{10}
+	return file_proto_asit_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *TestState) GetCurrentStepIndex() int32 {
@@ -871,12 +2169,14 @@ type ClientKeys struct {
 	unknownFields protoimpl.UnknownFields
 
 	Keys []string `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	// expires are the expiration times of the keys added with TTL
+	Expires map[string]*timestamppb.Timestamp `protobuf:"bytes,2,rep,name=expires,proto3" json:"expires,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 }
 
 func (x *ClientKeys) Reset() {
 	*x = ClientKeys{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_asit_proto_msgTypes[11]
+		mi := &file_proto_asit_proto_msgTypes[24]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -889,7 +2189,7 @@ func (x *ClientKeys) String() string {
 func (*ClientKeys) ProtoMessage() {}
 
 func (x *ClientKeys) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_asit_proto_msgTypes[11]
+	mi := &file_proto_asit_proto_msgTypes[24]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -902,7 +2202,7 @@ func (x *ClientKeys) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ClientKeys.ProtoReflect.Descriptor instead.
 func (*ClientKeys) Descriptor() ([]byte, []int) {
-	return file_proto_asit_proto_rawDescGZIP(), []int{11}
+	return file_proto_asit_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *ClientKeys) GetKeys() []string {
@@ -912,6 +2212,60 @@ func (x *ClientKeys) GetKeys() []string {
 	return nil
 }
 
+func (x *ClientKeys) GetExpires() map[string]*timestamppb.Timestamp {
+	if x != nil {
+		return x.Expires
+	}
+	return nil
+}
+
+type ClientHistoryHead struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LastSequence int64 `protobuf:"varint,1,opt,name=lastSequence,proto3" json:"lastSequence,omitempty"`
+}
+
+func (x *ClientHistoryHead) Reset() {
+	*x = ClientHistoryHead{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_asit_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientHistoryHead) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientHistoryHead) ProtoMessage() {}
+
+func (x *ClientHistoryHead) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_asit_proto_msgTypes[25]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientHistoryHead.ProtoReflect.Descriptor instead.
+func (*ClientHistoryHead) Descriptor() ([]byte, []int) {
+	return file_proto_asit_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *ClientHistoryHead) GetLastSequence() int64 {
+	if x != nil {
+		return x.LastSequence
+	}
+	return 0
+}
+
 var File_proto_asit_proto protoreflect.FileDescriptor
 
 var file_proto_asit_proto_rawDesc = []byte{
@@ -922,7 +2276,7 @@ var file_proto_asit_proto_rawDesc = []byte{
 	0x65, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x26, 0x0a, 0x07, 0x63, 0x6c, 0x69, 0x65, 0x6e,
 	0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e,
 	0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x52, 0x07, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x22,
-	0xff, 0x01, 0x0a, 0x06, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64,
+	0x9b, 0x02, 0x0a, 0x06, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64,
 	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
 	0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x3c,
 	0x0a, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20,
@@ -933,135 +2287,374 @@ var file_proto_asit_proto_rawDesc = []byte{
 	0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x43, 0x6c,
 	0x69, 0x65, 0x6e, 0x74, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x50, 0x72, 0x6f, 0x70, 0x65,
 	0x72, 0x74, 0x69, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x10, 0x63, 0x6c, 0x69, 0x65,
-	0x6e, 0x74, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69, 0x65, 0x73, 0x1a, 0x43, 0x0a, 0x15,
-	0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69, 0x65, 0x73,
-	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
-	0x01, 0x22, 0x76, 0x0a, 0x08, 0x54, 0x65, 0x73, 0x74, 0x43, 0x61, 0x73, 0x65, 0x12, 0x0e, 0x0a,
-	0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a,
-	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d,
-	0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e,
-	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74,
-	0x69, 0x6f, 0x6e, 0x12, 0x24, 0x0a, 0x05, 0x73, 0x74, 0x65, 0x70, 0x73, 0x18, 0x04, 0x20, 0x03,
-	0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74,
-	0x65, 0x70, 0x52, 0x05, 0x73, 0x74, 0x65, 0x70, 0x73, 0x22, 0x77, 0x0a, 0x09, 0x54, 0x65, 0x73,
-	0x74, 0x53, 0x75, 0x69, 0x74, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65,
-	0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x24, 0x0a, 0x05,
-	0x74, 0x65, 0x73, 0x74, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x61, 0x73,
-	0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x43, 0x61, 0x73, 0x65, 0x52, 0x05, 0x74, 0x65, 0x73,
-	0x74, 0x73, 0x22, 0xb6, 0x01, 0x0a, 0x08, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65, 0x70, 0x12,
-	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12,
-	0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e,
-	0x61, 0x6d, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69,
-	0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69,
-	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x28, 0x0a, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18,
-	0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73,
-	0x74, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12,
-	0x3a, 0x0a, 0x0c, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18,
-	0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73,
-	0x74, 0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0c, 0x76,
-	0x65, 0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0xa5, 0x01, 0x0a, 0x0a,
-	0x54, 0x65, 0x73, 0x74, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x75,
-	0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x75,
-	0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3d, 0x0a, 0x09, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65,
-	0x6e, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x61, 0x73, 0x69, 0x74,
-	0x2e, 0x54, 0x65, 0x73, 0x74, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x41, 0x72, 0x67, 0x75,
-	0x6d, 0x65, 0x6e, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x09, 0x61, 0x72, 0x67, 0x75,
-	0x6d, 0x65, 0x6e, 0x74, 0x73, 0x1a, 0x3c, 0x0a, 0x0e, 0x41, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e,
-	0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
-	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
-	0x02, 0x38, 0x01, 0x22, 0xa3, 0x01, 0x0a, 0x09, 0x54, 0x65, 0x73, 0x74, 0x43, 0x68, 0x65, 0x63,
-	0x6b, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3c, 0x0a,
-	0x09, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
-	0x32, 0x1e, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x43, 0x68, 0x65, 0x63,
-	0x6b, 0x2e, 0x41, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
-	0x52, 0x09, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x1a, 0x3c, 0x0a, 0x0e, 0x41,
-	0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a,
-	0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
-	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
-	0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x3b, 0x0a, 0x10, 0x54, 0x65, 0x73,
-	0x74, 0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x27, 0x0a,
-	0x06, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0f, 0x2e,
-	0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x52, 0x06,
-	0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x22, 0xfb, 0x01, 0x0a, 0x07, 0x54, 0x65, 0x73, 0x74, 0x52,
-	0x75, 0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02,
-	0x69, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x74, 0x65, 0x73, 0x74, 0x53, 0x75, 0x69, 0x74, 0x65, 0x49,
-	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x74, 0x65, 0x73, 0x74, 0x53, 0x75, 0x69,
-	0x74, 0x65, 0x49, 0x64, 0x12, 0x2b, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x03,
-	0x20, 0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74,
-	0x52, 0x75, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75,
-	0x73, 0x12, 0x25, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x0f, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x61, 0x74,
-	0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x2c, 0x0a, 0x11, 0x73, 0x74, 0x61, 0x74,
-	0x75, 0x73, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20,
+	0x6e, 0x74, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69, 0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08,
+	0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
+	0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x1a, 0x43, 0x0a, 0x15, 0x43, 0x6c, 0x69, 0x65,
+	0x6e, 0x74, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xa3, 0x02,
+	0x0a, 0x0d, 0x54, 0x72, 0x61, 0x73, 0x68, 0x65, 0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x12,
+	0x24, 0x0a, 0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0c, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x52, 0x06, 0x63,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x02, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x12, 0x34, 0x0a, 0x07, 0x64, 0x65, 0x6c,
+	0x65, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x07, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x12,
+	0x46, 0x0a, 0x0b, 0x6b, 0x65, 0x79, 0x73, 0x45, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x18, 0x04,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x72, 0x61, 0x73,
+	0x68, 0x65, 0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x2e, 0x4b, 0x65, 0x79, 0x73, 0x45, 0x78,
+	0x70, 0x69, 0x72, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0b, 0x6b, 0x65, 0x79, 0x73,
+	0x45, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x1a, 0x5a, 0x0a, 0x10, 0x4b, 0x65, 0x79, 0x73, 0x45,
+	0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x30, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
+	0x02, 0x38, 0x01, 0x22, 0xe4, 0x02, 0x0a, 0x12, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x48, 0x69,
+	0x73, 0x74, 0x6f, 0x72, 0x79, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65,
+	0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x73, 0x65,
+	0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x2e, 0x0a, 0x06, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x16, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x43, 0x6c,
+	0x69, 0x65, 0x6e, 0x74, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x06,
+	0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x49, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x61, 0x6c, 0x6c, 0x65, 0x72, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x61, 0x6c, 0x6c, 0x65, 0x72, 0x12, 0x2a, 0x0a, 0x09,
+	0x6f, 0x6c, 0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0c, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x52, 0x09, 0x6f,
+	0x6c, 0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x12, 0x2a, 0x0a, 0x09, 0x6e, 0x65, 0x77, 0x43,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x61, 0x73,
+	0x69, 0x74, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x52, 0x09, 0x6e, 0x65, 0x77, 0x43, 0x6c,
+	0x69, 0x65, 0x6e, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x6f, 0x6c, 0x64, 0x4b, 0x65, 0x79,
+	0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x6f, 0x6c, 0x64, 0x4b, 0x65, 0x79, 0x73,
+	0x12, 0x18, 0x0a, 0x07, 0x6e, 0x65, 0x77, 0x4b, 0x65, 0x79, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x07, 0x6e, 0x65, 0x77, 0x4b, 0x65, 0x79, 0x73, 0x22, 0xe4, 0x01, 0x0a, 0x14, 0x43,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x49, 0x6e, 0x63, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74, 0x65,
+	0x6e, 0x63, 0x79, 0x12, 0x32, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x1e, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73,
+	0x49, 0x6e, 0x63, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x54, 0x79, 0x70,
+	0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e,
+	0x74, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e,
+	0x74, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x16, 0x0a, 0x06, 0x6d,
+	0x65, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x65, 0x6d,
+	0x62, 0x65, 0x72, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69,
+	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x70, 0x61, 0x69, 0x72, 0x65,
+	0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x72, 0x65, 0x70, 0x61, 0x69, 0x72, 0x65,
+	0x64, 0x22, 0xaa, 0x01, 0x0a, 0x18, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x43, 0x6f, 0x6e,
+	0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x26,
+	0x0a, 0x0e, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x65, 0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x65, 0x64, 0x43,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x65,
+	0x64, 0x4b, 0x65, 0x79, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x63, 0x68, 0x65,
+	0x63, 0x6b, 0x65, 0x64, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x44, 0x0a, 0x0f, 0x69, 0x6e, 0x63, 0x6f,
+	0x6e, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x69, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x1a, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73,
+	0x49, 0x6e, 0x63, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x52, 0x0f, 0x69,
+	0x6e, 0x63, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x69, 0x65, 0x73, 0x22, 0x5e,
+	0x0a, 0x0c, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x18,
+	0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x34, 0x0a, 0x07, 0x63, 0x72, 0x65, 0x61,
+	0x74, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x07, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x22, 0xef,
+	0x01, 0x0a, 0x0e, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e,
+	0x74, 0x12, 0x24, 0x0a, 0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0c, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x52,
+	0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x12, 0x47, 0x0a, 0x0b, 0x6b,
+	0x65, 0x79, 0x73, 0x45, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x25, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64,
+	0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x2e, 0x4b, 0x65, 0x79, 0x73, 0x45, 0x78, 0x70, 0x69, 0x72,
+	0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0b, 0x6b, 0x65, 0x79, 0x73, 0x45, 0x78, 0x70,
+	0x69, 0x72, 0x65, 0x73, 0x1a, 0x5a, 0x0a, 0x10, 0x4b, 0x65, 0x79, 0x73, 0x45, 0x78, 0x70, 0x69,
+	0x72, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x30, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01,
+	0x22, 0x76, 0x0a, 0x0c, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64,
+	0x12, 0x2c, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x12, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x48, 0x65,
+	0x61, 0x64, 0x65, 0x72, 0x48, 0x00, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x2e,
+	0x0a, 0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14,
+	0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x43, 0x6c,
+	0x69, 0x65, 0x6e, 0x74, 0x48, 0x00, 0x52, 0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x42, 0x08,
+	0x0a, 0x06, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x22, 0x8e, 0x01, 0x0a, 0x0e, 0x49, 0x6d, 0x70,
+	0x6f, 0x72, 0x74, 0x43, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x12, 0x2c, 0x0a, 0x04, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x61, 0x73, 0x69, 0x74,
+	0x2e, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x43, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x54,
+	0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72,
+	0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65,
+	0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x8a, 0x02, 0x0a, 0x0c, 0x49, 0x6d,
+	0x70, 0x6f, 0x72, 0x74, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f,
+	0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x16,
+	0x0a, 0x06, 0x64, 0x72, 0x79, 0x52, 0x75, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06,
+	0x64, 0x72, 0x79, 0x52, 0x75, 0x6e, 0x12, 0x26, 0x0a, 0x0e, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65,
+	0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e,
+	0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x26,
+	0x0a, 0x0e, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x43,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x26, 0x0a, 0x0e, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65,
+	0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e,
+	0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x22,
+	0x0a, 0x0c, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x4b, 0x65, 0x79, 0x73, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x4b, 0x65,
+	0x79, 0x73, 0x12, 0x32, 0x0a, 0x09, 0x63, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x73, 0x18,
+	0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x49, 0x6d, 0x70,
+	0x6f, 0x72, 0x74, 0x43, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x52, 0x09, 0x63, 0x6f, 0x6e,
+	0x66, 0x6c, 0x69, 0x63, 0x74, 0x73, 0x22, 0xe3, 0x01, 0x0a, 0x11, 0x43, 0x6c, 0x69, 0x65, 0x6e,
+	0x74, 0x73, 0x43, 0x61, 0x63, 0x68, 0x65, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x12, 0x0a, 0x04,
+	0x68, 0x69, 0x74, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x68, 0x69, 0x74, 0x73,
+	0x12, 0x16, 0x0a, 0x06, 0x6d, 0x69, 0x73, 0x73, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x06, 0x6d, 0x69, 0x73, 0x73, 0x65, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x65, 0x76, 0x69, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x65, 0x76, 0x69,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x24, 0x0a, 0x0d, 0x69, 0x6e, 0x76, 0x61, 0x6c, 0x69,
+	0x64, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x69,
+	0x6e, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x18, 0x0a, 0x07,
+	0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x65,
+	0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x6d, 0x61, 0x78, 0x45, 0x6e, 0x74,
+	0x72, 0x69, 0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x6d, 0x61, 0x78, 0x45,
+	0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12, 0x24, 0x0a, 0x0d, 0x6d, 0x61, 0x78, 0x41, 0x67, 0x65,
+	0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0d, 0x6d,
+	0x61, 0x78, 0x41, 0x67, 0x65, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x22, 0x76, 0x0a, 0x08,
+	0x54, 0x65, 0x73, 0x74, 0x43, 0x61, 0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x20, 0x0a, 0x0b,
+	0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x24,
+	0x0a, 0x05, 0x73, 0x74, 0x65, 0x70, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0e, 0x2e,
+	0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65, 0x70, 0x52, 0x05, 0x73,
+	0x74, 0x65, 0x70, 0x73, 0x22, 0x77, 0x0a, 0x09, 0x54, 0x65, 0x73, 0x74, 0x53, 0x75, 0x69, 0x74,
+	0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69,
+	0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70,
+	0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63,
+	0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x24, 0x0a, 0x05, 0x74, 0x65, 0x73, 0x74, 0x73,
+	0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65,
+	0x73, 0x74, 0x43, 0x61, 0x73, 0x65, 0x52, 0x05, 0x74, 0x65, 0x73, 0x74, 0x73, 0x22, 0xb6, 0x01,
+	0x0a, 0x08, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65, 0x70, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x20,
+	0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x28, 0x0a, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x10, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x41, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3a, 0x0a, 0x0c, 0x76, 0x65,
+	0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x16, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x56, 0x65, 0x72, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0c, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0xa5, 0x01, 0x0a, 0x0a, 0x54, 0x65, 0x73, 0x74, 0x41,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x3d, 0x0a, 0x09, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x02,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74,
+	0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x41, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x09, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73,
+	0x1a, 0x3c, 0x0a, 0x0e, 0x41, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xa3,
+	0x01, 0x0a, 0x09, 0x54, 0x65, 0x73, 0x74, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x12, 0x1a, 0x0a, 0x08,
+	0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3c, 0x0a, 0x09, 0x61, 0x72, 0x67, 0x75,
+	0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x61, 0x73,
+	0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x2e, 0x41, 0x72, 0x67,
+	0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x09, 0x61, 0x72, 0x67,
+	0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x1a, 0x3c, 0x0a, 0x0e, 0x41, 0x72, 0x67, 0x75, 0x6d, 0x65,
+	0x6e, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x3a, 0x02, 0x38, 0x01, 0x22, 0x3b, 0x0a, 0x10, 0x54, 0x65, 0x73, 0x74, 0x56, 0x65, 0x72, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x27, 0x0a, 0x06, 0x63, 0x68, 0x65, 0x63,
+	0x6b, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e,
+	0x54, 0x65, 0x73, 0x74, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x52, 0x06, 0x63, 0x68, 0x65, 0x63, 0x6b,
+	0x73, 0x22, 0xc6, 0x02, 0x0a, 0x07, 0x54, 0x65, 0x73, 0x74, 0x52, 0x75, 0x6e, 0x12, 0x0e, 0x0a,
+	0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x20, 0x0a,
+	0x0b, 0x74, 0x65, 0x73, 0x74, 0x53, 0x75, 0x69, 0x74, 0x65, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0b, 0x74, 0x65, 0x73, 0x74, 0x53, 0x75, 0x69, 0x74, 0x65, 0x49, 0x64, 0x12,
+	0x2b, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32,
+	0x13, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x52, 0x75, 0x6e, 0x53, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x25, 0x0a, 0x05,
+	0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x61, 0x73,
+	0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x12, 0x2c, 0x0a, 0x11, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x44, 0x65, 0x73,
+	0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x11,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x3c, 0x0a, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x52, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x12,
+	0x1a, 0x0a, 0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x2d, 0x0a, 0x09, 0x74,
+	0x65, 0x73, 0x74, 0x53, 0x75, 0x69, 0x74, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f,
+	0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x53, 0x75, 0x69, 0x74, 0x65, 0x52,
+	0x09, 0x74, 0x65, 0x73, 0x74, 0x53, 0x75, 0x69, 0x74, 0x65, 0x22, 0xdf, 0x01, 0x0a, 0x11, 0x50,
+	0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x54, 0x65, 0x73, 0x74, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x1c, 0x0a, 0x09, 0x74, 0x65, 0x73, 0x74, 0x52, 0x75, 0x6e, 0x49, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x74, 0x65, 0x73, 0x74, 0x52, 0x75, 0x6e, 0x49, 0x64, 0x12, 0x1e,
+	0x0a, 0x0a, 0x74, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65, 0x70, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0a, 0x74, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65, 0x70, 0x49, 0x64, 0x12, 0x28,
+	0x0a, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10,
+	0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x52, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3e, 0x0a, 0x0c, 0x63, 0x6c, 0x61, 0x69,
+	0x6d, 0x45, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0c, 0x63, 0x6c, 0x61, 0x69,
+	0x6d, 0x45, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x63, 0x6c, 0x61, 0x69,
+	0x6d, 0x41, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c,
+	0x63, 0x6c, 0x61, 0x69, 0x6d, 0x41, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x22, 0x81, 0x02, 0x0a,
+	0x10, 0x54, 0x65, 0x73, 0x74, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x2c, 0x0a, 0x11, 0x73,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x11, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x44, 0x65,
+	0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x6f, 0x67,
+	0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x6c, 0x6f, 0x67, 0x73, 0x12, 0x34, 0x0a,
+	0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x61, 0x73,
+	0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x04, 0x64,
+	0x61, 0x74, 0x61, 0x12, 0x22, 0x0a, 0x0c, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x41, 0x74, 0x74, 0x65,
+	0x6d, 0x70, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c, 0x63, 0x6c, 0x61, 0x69, 0x6d,
+	0x41, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x1a, 0x37, 0x0a, 0x09, 0x44, 0x61, 0x74, 0x61, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01,
+	0x22, 0x71, 0x0a, 0x13, 0x53, 0x74, 0x61, 0x72, 0x74, 0x54, 0x65, 0x73, 0x74, 0x52, 0x75, 0x6e,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x20, 0x0a, 0x0b, 0x74, 0x65, 0x73, 0x74, 0x53,
+	0x75, 0x69, 0x74, 0x65, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x74, 0x65,
+	0x73, 0x74, 0x53, 0x75, 0x69, 0x74, 0x65, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4b,
+	0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74,
+	0x4b, 0x65, 0x79, 0x22, 0xee, 0x02, 0x0a, 0x0b, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65, 0x70,
+	0x52, 0x75, 0x6e, 0x12, 0x1e, 0x0a, 0x0a, 0x74, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65, 0x70, 0x49,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x74, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65,
+	0x70, 0x49, 0x64, 0x12, 0x2f, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x53,
+	0x74, 0x65, 0x70, 0x52, 0x75, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x6f, 0x67, 0x73, 0x18, 0x03, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x04, 0x6c, 0x6f, 0x67, 0x73, 0x12, 0x2c, 0x0a, 0x11, 0x73, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20,
 	0x01, 0x28, 0x09, 0x52, 0x11, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x44, 0x65, 0x73, 0x63, 0x72,
-	0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3c, 0x0a, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x55, 0x70,
-	0x64, 0x61, 0x74, 0x65, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f,
-	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69,
-	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x55, 0x70, 0x64,
-	0x61, 0x74, 0x65, 0x64, 0x22, 0x8a, 0x02, 0x0a, 0x0b, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65,
-	0x70, 0x52, 0x75, 0x6e, 0x12, 0x1e, 0x0a, 0x0a, 0x74, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65, 0x70,
-	0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x74, 0x65, 0x73, 0x74, 0x53, 0x74,
-	0x65, 0x70, 0x49, 0x64, 0x12, 0x2f, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74,
-	0x53, 0x74, 0x65, 0x70, 0x52, 0x75, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73,
-	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x6f, 0x67, 0x73, 0x18, 0x03, 0x20,
-	0x03, 0x28, 0x09, 0x52, 0x04, 0x6c, 0x6f, 0x67, 0x73, 0x12, 0x2c, 0x0a, 0x11, 0x73, 0x74, 0x61,
-	0x74, 0x75, 0x73, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x11, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x44, 0x65, 0x73, 0x63,
-	0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x2f, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18,
-	0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73,
-	0x74, 0x53, 0x74, 0x65, 0x70, 0x52, 0x75, 0x6e, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74,
-	0x72, 0x79, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x1a, 0x37, 0x0a, 0x09, 0x44, 0x61, 0x74, 0x61,
-	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
-	0x01, 0x22, 0xe6, 0x02, 0x0a, 0x09, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12,
-	0x2a, 0x0a, 0x10, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x65, 0x70, 0x49, 0x6e,
-	0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x10, 0x63, 0x75, 0x72, 0x72, 0x65,
-	0x6e, 0x74, 0x53, 0x74, 0x65, 0x70, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x51, 0x0a, 0x10, 0x63,
-	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69, 0x65, 0x73, 0x18,
-	0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x25, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73,
-	0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x50, 0x72, 0x6f,
-	0x70, 0x65, 0x72, 0x74, 0x69, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x10, 0x63, 0x6c,
-	0x69, 0x65, 0x6e, 0x74, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69, 0x65, 0x73, 0x12, 0x2d,
-	0x0a, 0x08, 0x73, 0x74, 0x65, 0x70, 0x52, 0x75, 0x6e, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b,
-	0x32, 0x11, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65, 0x70,
-	0x52, 0x75, 0x6e, 0x52, 0x08, 0x73, 0x74, 0x65, 0x70, 0x52, 0x75, 0x6e, 0x73, 0x12, 0x2d, 0x0a,
-	0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x61, 0x73,
-	0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x2e, 0x44, 0x61, 0x74,
-	0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x1a, 0x43, 0x0a, 0x15,
-	0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69, 0x65, 0x73,
-	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
-	0x01, 0x1a, 0x37, 0x0a, 0x09, 0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10,
-	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79,
-	0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x20, 0x0a, 0x0a, 0x43, 0x6c,
-	0x69, 0x65, 0x6e, 0x74, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x65, 0x79, 0x73,
-	0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x2a, 0x33, 0x0a, 0x0d,
-	0x54, 0x65, 0x73, 0x74, 0x52, 0x75, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x0b, 0x0a,
-	0x07, 0x53, 0x54, 0x41, 0x52, 0x54, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x55,
-	0x43, 0x43, 0x45, 0x53, 0x53, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x46, 0x41, 0x49, 0x4c, 0x10,
-	0x02, 0x2a, 0x9b, 0x01, 0x0a, 0x11, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65, 0x70, 0x52, 0x75,
-	0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x52, 0x45, 0x41, 0x54,
-	0x45, 0x44, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x41, 0x43, 0x54, 0x49, 0x56, 0x45, 0x10, 0x01,
-	0x12, 0x12, 0x0a, 0x0e, 0x41, 0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x53, 0x54, 0x41, 0x52, 0x54,
-	0x45, 0x44, 0x10, 0x03, 0x12, 0x13, 0x0a, 0x0f, 0x41, 0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x46,
-	0x49, 0x4e, 0x49, 0x53, 0x48, 0x45, 0x44, 0x10, 0x04, 0x12, 0x11, 0x0a, 0x0d, 0x41, 0x43, 0x54,
-	0x49, 0x4f, 0x4e, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x05, 0x12, 0x18, 0x0a, 0x14,
-	0x56, 0x45, 0x52, 0x49, 0x46, 0x49, 0x43, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x53, 0x55, 0x43,
-	0x43, 0x45, 0x53, 0x53, 0x10, 0x06, 0x12, 0x17, 0x0a, 0x13, 0x56, 0x45, 0x52, 0x49, 0x46, 0x49,
-	0x43, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x07, 0x42,
-	0x08, 0x5a, 0x06, 0x2f, 0x3b, 0x61, 0x73, 0x69, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x33,
+	0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x2f, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x05,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74,
+	0x53, 0x74, 0x65, 0x70, 0x52, 0x75, 0x6e, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x3e, 0x0a, 0x0c, 0x63, 0x6c, 0x61, 0x69, 0x6d,
+	0x45, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0c, 0x63, 0x6c, 0x61, 0x69, 0x6d,
+	0x45, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x63, 0x6c, 0x61, 0x69, 0x6d,
+	0x41, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c, 0x63,
+	0x6c, 0x61, 0x69, 0x6d, 0x41, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x1a, 0x37, 0x0a, 0x09, 0x44,
+	0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x3a, 0x02, 0x38, 0x01, 0x22, 0xe6, 0x02, 0x0a, 0x09, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x61,
+	0x74, 0x65, 0x12, 0x2a, 0x0a, 0x10, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x65,
+	0x70, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x10, 0x63, 0x75,
+	0x72, 0x72, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x65, 0x70, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x51,
+	0x0a, 0x10, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69,
+	0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x25, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e,
+	0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74,
+	0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
+	0x10, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x69, 0x65,
+	0x73, 0x12, 0x2d, 0x0a, 0x08, 0x73, 0x74, 0x65, 0x70, 0x52, 0x75, 0x6e, 0x73, 0x18, 0x03, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x53,
+	0x74, 0x65, 0x70, 0x52, 0x75, 0x6e, 0x52, 0x08, 0x73, 0x74, 0x65, 0x70, 0x52, 0x75, 0x6e, 0x73,
+	0x12, 0x2d, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19,
+	0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x2e,
+	0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x1a,
+	0x43, 0x0a, 0x15, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74,
+	0x69, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x3a, 0x02, 0x38, 0x01, 0x1a, 0x37, 0x0a, 0x09, 0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xb1, 0x01,
+	0x0a, 0x0a, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x12, 0x0a, 0x04,
+	0x6b, 0x65, 0x79, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x6b, 0x65, 0x79, 0x73,
+	0x12, 0x37, 0x0a, 0x07, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x1d, 0x2e, 0x61, 0x73, 0x69, 0x74, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4b,
+	0x65, 0x79, 0x73, 0x2e, 0x45, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x07, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x1a, 0x56, 0x0a, 0x0c, 0x45, 0x78, 0x70,
+	0x69, 0x72, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x30, 0x0a, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
+	0x01, 0x22, 0x37, 0x0a, 0x11, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x48, 0x69, 0x73, 0x74, 0x6f,
+	0x72, 0x79, 0x48, 0x65, 0x61, 0x64, 0x12, 0x22, 0x0a, 0x0c, 0x6c, 0x61, 0x73, 0x74, 0x53, 0x65,
+	0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x6c, 0x61,
+	0x73, 0x74, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x2a, 0xf3, 0x01, 0x0a, 0x10, 0x43,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12,
+	0x1d, 0x0a, 0x19, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54, 0x5f, 0x43, 0x48, 0x41, 0x4e, 0x47, 0x45,
+	0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x12,
+	0x0a, 0x0e, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54, 0x5f, 0x43, 0x52, 0x45, 0x41, 0x54, 0x45, 0x44,
+	0x10, 0x01, 0x12, 0x12, 0x0a, 0x0e, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54, 0x5f, 0x55, 0x50, 0x44,
+	0x41, 0x54, 0x45, 0x44, 0x10, 0x02, 0x12, 0x12, 0x0a, 0x0e, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54,
+	0x5f, 0x44, 0x45, 0x4c, 0x45, 0x54, 0x45, 0x44, 0x10, 0x03, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x4c,
+	0x49, 0x45, 0x4e, 0x54, 0x5f, 0x52, 0x45, 0x53, 0x54, 0x4f, 0x52, 0x45, 0x44, 0x10, 0x04, 0x12,
+	0x11, 0x0a, 0x0d, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54, 0x5f, 0x50, 0x55, 0x52, 0x47, 0x45, 0x44,
+	0x10, 0x05, 0x12, 0x14, 0x0a, 0x10, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54, 0x5f, 0x4b, 0x45, 0x59,
+	0x5f, 0x41, 0x44, 0x44, 0x45, 0x44, 0x10, 0x06, 0x12, 0x16, 0x0a, 0x12, 0x43, 0x4c, 0x49, 0x45,
+	0x4e, 0x54, 0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x52, 0x45, 0x4d, 0x4f, 0x56, 0x45, 0x44, 0x10, 0x07,
+	0x12, 0x16, 0x0a, 0x12, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54, 0x5f, 0x52, 0x4f, 0x4c, 0x4c, 0x45,
+	0x44, 0x5f, 0x42, 0x41, 0x43, 0x4b, 0x10, 0x08, 0x12, 0x16, 0x0a, 0x12, 0x43, 0x4c, 0x49, 0x45,
+	0x4e, 0x54, 0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x45, 0x58, 0x50, 0x49, 0x52, 0x45, 0x44, 0x10, 0x09,
+	0x2a, 0xa3, 0x02, 0x0a, 0x18, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x49, 0x6e, 0x63, 0x6f,
+	0x6e, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x54, 0x79, 0x70, 0x65, 0x12, 0x25, 0x0a,
+	0x21, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54, 0x53, 0x5f, 0x49, 0x4e, 0x43, 0x4f, 0x4e, 0x53, 0x49,
+	0x53, 0x54, 0x45, 0x4e, 0x43, 0x59, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49,
+	0x45, 0x44, 0x10, 0x00, 0x12, 0x18, 0x0a, 0x14, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e, 0x47, 0x5f,
+	0x49, 0x4e, 0x44, 0x45, 0x58, 0x5f, 0x4d, 0x45, 0x4d, 0x42, 0x45, 0x52, 0x10, 0x01, 0x12, 0x19,
+	0x0a, 0x15, 0x4f, 0x52, 0x50, 0x48, 0x41, 0x4e, 0x45, 0x44, 0x5f, 0x49, 0x4e, 0x44, 0x45, 0x58,
+	0x5f, 0x4d, 0x45, 0x4d, 0x42, 0x45, 0x52, 0x10, 0x02, 0x12, 0x18, 0x0a, 0x14, 0x4f, 0x52, 0x50,
+	0x48, 0x41, 0x4e, 0x45, 0x44, 0x5f, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54, 0x5f, 0x4b, 0x45, 0x59,
+	0x53, 0x10, 0x03, 0x12, 0x1c, 0x0a, 0x18, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e, 0x47, 0x5f, 0x43,
+	0x4c, 0x49, 0x45, 0x4e, 0x54, 0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x41, 0x4c, 0x49, 0x41, 0x53, 0x10,
+	0x04, 0x12, 0x1a, 0x0a, 0x16, 0x53, 0x54, 0x41, 0x4c, 0x45, 0x5f, 0x43, 0x4c, 0x49, 0x45, 0x4e,
+	0x54, 0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x41, 0x4c, 0x49, 0x41, 0x53, 0x10, 0x05, 0x12, 0x1d, 0x0a,
+	0x19, 0x4f, 0x52, 0x50, 0x48, 0x41, 0x4e, 0x45, 0x44, 0x5f, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54,
+	0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x41, 0x4c, 0x49, 0x41, 0x53, 0x10, 0x06, 0x12, 0x18, 0x0a, 0x14,
+	0x44, 0x55, 0x50, 0x4c, 0x49, 0x43, 0x41, 0x54, 0x45, 0x5f, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54,
+	0x5f, 0x4b, 0x45, 0x59, 0x10, 0x07, 0x12, 0x1e, 0x0a, 0x1a, 0x4f, 0x52, 0x50, 0x48, 0x41, 0x4e,
+	0x45, 0x44, 0x5f, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54, 0x5f, 0x50, 0x52, 0x4f, 0x50, 0x45, 0x52,
+	0x54, 0x49, 0x45, 0x53, 0x10, 0x08, 0x2a, 0x7e, 0x0a, 0x12, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74,
+	0x43, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1f, 0x0a, 0x1b,
+	0x49, 0x4d, 0x50, 0x4f, 0x52, 0x54, 0x5f, 0x43, 0x4f, 0x4e, 0x46, 0x4c, 0x49, 0x43, 0x54, 0x5f,
+	0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x1a, 0x0a,
+	0x16, 0x44, 0x55, 0x50, 0x4c, 0x49, 0x43, 0x41, 0x54, 0x45, 0x5f, 0x49, 0x4d, 0x50, 0x4f, 0x52,
+	0x54, 0x45, 0x44, 0x5f, 0x4b, 0x45, 0x59, 0x10, 0x01, 0x12, 0x14, 0x0a, 0x10, 0x43, 0x4c, 0x49,
+	0x45, 0x4e, 0x54, 0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x54, 0x41, 0x4b, 0x45, 0x4e, 0x10, 0x02, 0x12,
+	0x15, 0x0a, 0x11, 0x54, 0x52, 0x41, 0x53, 0x48, 0x45, 0x44, 0x5f, 0x43, 0x4c, 0x49, 0x45, 0x4e,
+	0x54, 0x5f, 0x49, 0x44, 0x10, 0x03, 0x2a, 0x33, 0x0a, 0x0d, 0x54, 0x65, 0x73, 0x74, 0x52, 0x75,
+	0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x54, 0x41, 0x52, 0x54,
+	0x45, 0x44, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x55, 0x43, 0x43, 0x45, 0x53, 0x53, 0x10,
+	0x01, 0x12, 0x08, 0x0a, 0x04, 0x46, 0x41, 0x49, 0x4c, 0x10, 0x02, 0x2a, 0x9b, 0x01, 0x0a, 0x11,
+	0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x65, 0x70, 0x52, 0x75, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x52, 0x45, 0x41, 0x54, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0a,
+	0x0a, 0x06, 0x41, 0x43, 0x54, 0x49, 0x56, 0x45, 0x10, 0x01, 0x12, 0x12, 0x0a, 0x0e, 0x41, 0x43,
+	0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x53, 0x54, 0x41, 0x52, 0x54, 0x45, 0x44, 0x10, 0x03, 0x12, 0x13,
+	0x0a, 0x0f, 0x41, 0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x46, 0x49, 0x4e, 0x49, 0x53, 0x48, 0x45,
+	0x44, 0x10, 0x04, 0x12, 0x11, 0x0a, 0x0d, 0x41, 0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x46, 0x41,
+	0x49, 0x4c, 0x45, 0x44, 0x10, 0x05, 0x12, 0x18, 0x0a, 0x14, 0x56, 0x45, 0x52, 0x49, 0x46, 0x49,
+	0x43, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x53, 0x55, 0x43, 0x43, 0x45, 0x53, 0x53, 0x10, 0x06,
+	0x12, 0x17, 0x0a, 0x13, 0x56, 0x45, 0x52, 0x49, 0x46, 0x49, 0x43, 0x41, 0x54, 0x49, 0x4f, 0x4e,
+	0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x07, 0x42, 0x08, 0x5a, 0x06, 0x2f, 0x3b, 0x61,
+	0x73, 0x69, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -1076,55 +2669,101 @@ func file_proto_asit_proto_rawDescGZIP() []byte {
 	return file_proto_asit_proto_rawDescData
 }
 
-var file_proto_asit_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_proto_asit_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_proto_asit_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
+var file_proto_asit_proto_msgTypes = make([]protoimpl.MessageInfo, 36)
 var file_proto_asit_proto_goTypes = []interface{}{
-	(TestRunStatus)(0),            // 0: asit.TestRunStatus
-	(TestStepRunStatus)(0),        // 1: asit.TestStepRunStatus
-	(*ClientList)(nil),            // 2: asit.ClientList
-	(*Client)(nil),                // 3: asit.Client
-	(*TestCase)(nil),              // 4: asit.TestCase
-	(*TestSuite)(nil),             // 5: asit.TestSuite
-	(*TestStep)(nil),              // 6: asit.TestStep
-	(*TestAction)(nil),            // 7: asit.TestAction
-	(*TestCheck)(nil),             // 8: asit.TestCheck
-	(*TestVerification)(nil),      // 9: asit.TestVerification
-	(*TestRun)(nil),               // 10: asit.TestRun
-	(*TestStepRun)(nil),           // 11: asit.TestStepRun
-	(*TestState)(nil),             // 12: asit.TestState
-	(*ClientKeys)(nil),            // 13: asit.ClientKeys
-	nil,                           // 14: asit.Client.ClientPropertiesEntry
-	nil,                           // 15: asit.TestAction.ArgumentsEntry
-	nil,                           // 16: asit.TestCheck.ArgumentsEntry
-	nil,                           // 17: asit.TestStepRun.DataEntry
-	nil,                           // 18: asit.TestState.ClientPropertiesEntry
-	nil,                           // 19: asit.TestState.DataEntry
-	(*timestamppb.Timestamp)(nil), // 20: google.protobuf.Timestamp
+	(ClientChangeType)(0),            // 0: asit.ClientChangeType
+	(ClientsInconsistencyType)(0),    // 1: asit.ClientsInconsistencyType
+	(ImportConflictType)(0),          // 2: asit.ImportConflictType
+	(TestRunStatus)(0),               // 3: asit.TestRunStatus
+	(TestStepRunStatus)(0),           // 4: asit.TestStepRunStatus
+	(*ClientList)(nil),               // 5: asit.ClientList
+	(*Client)(nil),                   // 6: asit.Client
+	(*TrashedClient)(nil),            // 7: asit.TrashedClient
+	(*ClientHistoryEntry)(nil),       // 8: asit.ClientHistoryEntry
+	(*ClientsInconsistency)(nil),     // 9: asit.ClientsInconsistency
+	(*ClientsConsistencyReport)(nil), // 10: asit.ClientsConsistencyReport
+	(*ExportHeader)(nil),             // 11: asit.ExportHeader
+	(*ExportedClient)(nil),           // 12: asit.ExportedClient
+	(*ExportRecord)(nil),             // 13: asit.ExportRecord
+	(*ImportConflict)(nil),           // 14: asit.ImportConflict
+	(*ImportReport)(nil),             // 15: asit.ImportReport
+	(*ClientsCacheStats)(nil),        // 16: asit.ClientsCacheStats
+	(*TestCase)(nil),                 // 17: asit.TestCase
+	(*TestSuite)(nil),                // 18: asit.TestSuite
+	(*TestStep)(nil),                 // 19: asit.TestStep
+	(*TestAction)(nil),               // 20: asit.TestAction
+	(*TestCheck)(nil),                // 21: asit.TestCheck
+	(*TestVerification)(nil),         // 22: asit.TestVerification
+	(*TestRun)(nil),                  // 23: asit.TestRun
+	(*PendingTestAction)(nil),        // 24: asit.PendingTestAction
+	(*TestActionResult)(nil),         // 25: asit.TestActionResult
+	(*StartTestRunRequest)(nil),      // 26: asit.StartTestRunRequest
+	(*TestStepRun)(nil),              // 27: asit.TestStepRun
+	(*TestState)(nil),                // 28: asit.TestState
+	(*ClientKeys)(nil),               // 29: asit.ClientKeys
+	(*ClientHistoryHead)(nil),        // 30: asit.ClientHistoryHead
+	nil,                              // 31: asit.Client.ClientPropertiesEntry
+	nil,                              // 32: asit.TrashedClient.KeysExpiresEntry
+	nil,                              // 33: asit.ExportedClient.KeysExpiresEntry
+	nil,                              // 34: asit.TestAction.ArgumentsEntry
+	nil,                              // 35: asit.TestCheck.ArgumentsEntry
+	nil,                              // 36: asit.TestActionResult.DataEntry
+	nil,                              // 37: asit.TestStepRun.DataEntry
+	nil,                              // 38: asit.TestState.ClientPropertiesEntry
+	nil,                              // 39: asit.TestState.DataEntry
+	nil,                              // 40: asit.ClientKeys.ExpiresEntry
+	(*timestamppb.Timestamp)(nil),    // 41: google.protobuf.Timestamp
 }
 var file_proto_asit_proto_depIdxs = []int32{
-	3,  // 0: asit.ClientList.clients:type_name -> asit.Client
-	20, // 1: asit.Client.lastUpdated:type_name -> google.protobuf.Timestamp
-	14, // 2: asit.Client.clientProperties:type_name -> asit.Client.ClientPropertiesEntry
-	6,  // 3: asit.TestCase.steps:type_name -> asit.TestStep
-	4,  // 4: asit.TestSuite.tests:type_name -> asit.TestCase
-	7,  // 5: asit.TestStep.action:type_name -> asit.TestAction
-	9,  // 6: asit.TestStep.verification:type_name -> asit.TestVerification
-	15, // 7: asit.TestAction.arguments:type_name -> asit.TestAction.ArgumentsEntry
-	16, // 8: asit.TestCheck.arguments:type_name -> asit.TestCheck.ArgumentsEntry
-	8,  // 9: asit.TestVerification.checks:type_name -> asit.TestCheck
-	0,  // 10: asit.TestRun.status:type_name -> asit.TestRunStatus
-	12, // 11: asit.TestRun.state:type_name -> asit.TestState
-	20, // 12: asit.TestRun.lastUpdated:type_name -> google.protobuf.Timestamp
-	1,  // 13: asit.TestStepRun.status:type_name -> asit.TestStepRunStatus
-	17, // 14: asit.TestStepRun.data:type_name -> asit.TestStepRun.DataEntry
-	18, // 15: asit.TestState.clientProperties:type_name -> asit.TestState.ClientPropertiesEntry
-	11, // 16: asit.TestState.stepRuns:type_name -> asit.TestStepRun
-	19, // 17: asit.TestState.data:type_name -> asit.TestState.DataEntry
-	18, // [18:18] is the sub-list for method output_type
-	18, // [18:18] is the sub-list for method input_type
-	18, // [18:18] is the sub-list for extension type_name
-	18, // [18:18] is the sub-list for extension extendee
-	0,  // [0:18] is the sub-list for field type_name
+	6,  // 0: asit.ClientList.clients:type_name -> asit.Client
+	41, // 1: asit.Client.lastUpdated:type_name -> google.protobuf.Timestamp
+	31, // 2: asit.Client.clientProperties:type_name -> asit.Client.ClientPropertiesEntry
+	6,  // 3: asit.TrashedClient.client:type_name -> asit.Client
+	41, // 4: asit.TrashedClient.deleted:type_name -> google.protobuf.Timestamp
+	32, // 5: asit.TrashedClient.keysExpires:type_name -> asit.TrashedClient.KeysExpiresEntry
+	0,  // 6: asit.ClientHistoryEntry.change:type_name -> asit.ClientChangeType
+	41, // 7: asit.ClientHistoryEntry.time:type_name -> google.protobuf.Timestamp
+	6,  // 8: asit.ClientHistoryEntry.oldClient:type_name -> asit.Client
+	6,  // 9: asit.ClientHistoryEntry.newClient:type_name -> asit.Client
+	1,  // 10: asit.ClientsInconsistency.type:type_name -> asit.ClientsInconsistencyType
+	9,  // 11: asit.ClientsConsistencyReport.inconsistencies:type_name -> asit.ClientsInconsistency
+	41, // 12: asit.ExportHeader.created:type_name -> google.protobuf.Timestamp
+	6,  // 13: asit.ExportedClient.client:type_name -> asit.Client
+	33, // 14: asit.ExportedClient.keysExpires:type_name -> asit.ExportedClient.KeysExpiresEntry
+	11, // 15: asit.ExportRecord.header:type_name -> asit.ExportHeader
+	12, // 16: asit.ExportRecord.client:type_name -> asit.ExportedClient
+	2,  // 17: asit.ImportConflict.type:type_name -> asit.ImportConflictType
+	14, // 18: asit.ImportReport.conflicts:type_name -> asit.ImportConflict
+	19, // 19: asit.TestCase.steps:type_name -> asit.TestStep
+	17, // 20: asit.TestSuite.tests:type_name -> asit.TestCase
+	20, // 21: asit.TestStep.action:type_name -> asit.TestAction
+	22, // 22: asit.TestStep.verification:type_name -> asit.TestVerification
+	34, // 23: asit.TestAction.arguments:type_name -> asit.TestAction.ArgumentsEntry
+	35, // 24: asit.TestCheck.arguments:type_name -> asit.TestCheck.ArgumentsEntry
+	21, // 25: asit.TestVerification.checks:type_name -> asit.TestCheck
+	3,  // 26: asit.TestRun.status:type_name -> asit.TestRunStatus
+	28, // 27: asit.TestRun.state:type_name -> asit.TestState
+	41, // 28: asit.TestRun.lastUpdated:type_name -> google.protobuf.Timestamp
+	18, // 29: asit.TestRun.testSuite:type_name -> asit.TestSuite
+	20, // 30: asit.PendingTestAction.action:type_name -> asit.TestAction
+	41, // 31: asit.PendingTestAction.claimExpires:type_name -> google.protobuf.Timestamp
+	36, // 32: asit.TestActionResult.data:type_name -> asit.TestActionResult.DataEntry
+	4,  // 33: asit.TestStepRun.status:type_name -> asit.TestStepRunStatus
+	37, // 34: asit.TestStepRun.data:type_name -> asit.TestStepRun.DataEntry
+	41, // 35: asit.TestStepRun.claimExpires:type_name -> google.protobuf.Timestamp
+	38, // 36: asit.TestState.clientProperties:type_name -> asit.TestState.ClientPropertiesEntry
+	27, // 37: asit.TestState.stepRuns:type_name -> asit.TestStepRun
+	39, // 38: asit.TestState.data:type_name -> asit.TestState.DataEntry
+	40, // 39: asit.ClientKeys.expires:type_name -> asit.ClientKeys.ExpiresEntry
+	41, // 40: asit.TrashedClient.KeysExpiresEntry.value:type_name -> google.protobuf.Timestamp
+	41, // 41: asit.ExportedClient.KeysExpiresEntry.value:type_name -> google.protobuf.Timestamp
+	41, // 42: asit.ClientKeys.ExpiresEntry.value:type_name -> google.protobuf.Timestamp
+	43, // [43:43] is the sub-list for method output_type
+	43, // [43:43] is the sub-list for method input_type
+	43, // [43:43] is the sub-list for extension type_name
+	43, // [43:43] is the sub-list for extension extendee
+	0,  // [0:43] is the sub-list for field type_name
 }
 
 func init() { file_proto_asit_proto_init() }
@@ -1158,7 +2797,7 @@ func file_proto_asit_proto_init() {
 			}
 		}
 		file_proto_asit_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TestCase); i {
+			switch v := v.(*TrashedClient); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1170,7 +2809,7 @@ func file_proto_asit_proto_init() {
 			}
 		}
 		file_proto_asit_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TestSuite); i {
+			switch v := v.(*ClientHistoryEntry); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1182,7 +2821,7 @@ func file_proto_asit_proto_init() {
 			}
 		}
 		file_proto_asit_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TestStep); i {
+			switch v := v.(*ClientsInconsistency); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1194,7 +2833,7 @@ func file_proto_asit_proto_init() {
 			}
 		}
 		file_proto_asit_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TestAction); i {
+			switch v := v.(*ClientsConsistencyReport); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1206,7 +2845,7 @@ func file_proto_asit_proto_init() {
 			}
 		}
 		file_proto_asit_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TestCheck); i {
+			switch v := v.(*ExportHeader); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1218,7 +2857,7 @@ func file_proto_asit_proto_init() {
 			}
 		}
 		file_proto_asit_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TestVerification); i {
+			switch v := v.(*ExportedClient); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1230,7 +2869,7 @@ func file_proto_asit_proto_init() {
 			}
 		}
 		file_proto_asit_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TestRun); i {
+			switch v := v.(*ExportRecord); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1242,7 +2881,7 @@ func file_proto_asit_proto_init() {
 			}
 		}
 		file_proto_asit_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TestStepRun); i {
+			switch v := v.(*ImportConflict); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1254,7 +2893,7 @@ func file_proto_asit_proto_init() {
 			}
 		}
 		file_proto_asit_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TestState); i {
+			switch v := v.(*ImportReport); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1266,6 +2905,162 @@ func file_proto_asit_proto_init() {
 			}
 		}
 		file_proto_asit_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientsCacheStats); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TestCase); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TestSuite); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TestStep); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TestAction); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TestCheck); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TestVerification); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TestRun); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PendingTestAction); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TestActionResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StartTestRunRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TestStepRun); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TestState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_asit_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ClientKeys); i {
 			case 0:
 				return &v.state
@@ -1277,14 +3072,30 @@ func file_proto_asit_proto_init() {
 				return nil
 			}
 		}
+		file_proto_asit_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientHistoryHead); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_proto_asit_proto_msgTypes[8].OneofWrappers = []interface{}{
+		(*ExportRecord_Header)(nil),
+		(*ExportRecord_Client)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_proto_asit_proto_rawDesc,
-			NumEnums:      2,
-			NumMessages:   18,
+			NumEnums:      5,
+			NumMessages:   36,
 			NumExtensions: 0,
 			NumServices:   0,
 		},